@@ -0,0 +1,193 @@
+// Package appsyncgraphql provides AWS IAM authorization for GraphQL clients
+// of package graphql talking to AWS AppSync APIs. It signs requests with
+// Signature Version 4, and authorizes subscriptions over AppSync's
+// real-time WebSocket endpoint.
+//
+// Create a client with NewClient, given the credentials of the AWS SDK:
+//
+//	cfg, err := config.LoadDefaultConfig(ctx)
+//	if err != nil {
+//		// Handle error.
+//	}
+//	client := appsyncgraphql.NewClient("https://example1234567890000.appsync-api.us-east-1.amazonaws.com/graphql", cfg.Region, cfg.Credentials)
+package appsyncgraphql
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	graphql "github.com/nobody05/graphql_go_client"
+)
+
+// service is the name of AppSync in the scope of signatures.
+const service = "appsync"
+
+// Signer signs the requests to an AppSync API with AWS credentials.
+// It's a graphql.SubscriptionAuthorizer, authorizing the subscriptions
+// of the API too.
+type Signer struct {
+	endpoint    *url.URL // GraphQL endpoint of the API.
+	region      string
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+}
+
+var _ graphql.SubscriptionAuthorizer = (*Signer)(nil)
+
+// NewSigner returns a Signer for the AppSync API with the given GraphQL
+// endpoint, in region, signing with the credentials of provider.
+func NewSigner(endpoint, region string, provider aws.CredentialsProvider) (*Signer, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{endpoint: u, region: region, credentials: provider, signer: v4.NewSigner()}, nil
+}
+
+// NewClient returns a client of the AppSync API with the given GraphQL
+// endpoint, in region, signing its requests with the credentials of
+// provider, and subscribing via the real-time endpoint of the API,
+// configured by opts, which mustn't include graphql.WithHTTPClient.
+func NewClient(endpoint, region string, provider aws.CredentialsProvider, opts ...graphql.ClientOption) (*graphql.Client, error) {
+	s, err := NewSigner(endpoint, region, provider)
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]graphql.ClientOption{
+		graphql.WithHTTPClient(&http.Client{Transport: s.Transport(nil)}),
+		graphql.WithSubscriptionURL(RealtimeURL(s.endpoint).String()),
+		graphql.WithSubscriptionProtocol(graphql.SubscriptionsTransportWS),
+		graphql.WithSubscriptionAuthorizer(s),
+	}, opts...)
+	return graphql.NewClient(endpoint, opts...), nil
+}
+
+// RealtimeURL returns the URL of the real-time endpoint of the AppSync API
+// with the given GraphQL endpoint, which subscriptions are made through.
+func RealtimeURL(endpoint *url.URL) *url.URL {
+	u := *endpoint
+	u.Scheme = "wss"
+	if strings.Contains(u.Host, ".appsync-api.") {
+		u.Host = strings.Replace(u.Host, ".appsync-api.", ".appsync-realtime-api.", 1)
+	} else {
+		// Custom domains serve it under the path of the endpoint.
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/realtime"
+	}
+	return &u
+}
+
+// Transport returns an http.RoundTripper signing requests before sending
+// them via base, or http.DefaultTransport if base is nil.
+func (s *Signer) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripper{s: s, base: base}
+}
+
+type roundTripper struct {
+	s    *Signer
+	base http.RoundTripper
+}
+
+func (t roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Per its contract, a RoundTripper mustn't modify the request.
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	if err := t.s.sign(req.Context(), req, body); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// sign signs req, with the given body.
+func (s *Signer) sign(ctx context.Context, req *http.Request, body []byte) error {
+	creds, err := s.credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("appsyncgraphql: retrieving credentials: %w", err)
+	}
+	sum := sha256.Sum256(body)
+	return s.signer.SignHTTP(ctx, creds, req, hex.EncodeToString(sum[:]), service, s.region, time.Now())
+}
+
+// authorization returns the headers authorizing a request to the given path
+// of the API, with the given body, as expected in subscription messages.
+func (s *Signer) authorization(ctx context.Context, path string, body []byte) (map[string]string, error) {
+	u := *s.endpoint
+	u.Path = path
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json, text/javascript")
+	req.Header.Set("Content-Encoding", "amz-1.0")
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	if err := s.sign(ctx, req, body); err != nil {
+		return nil, err
+	}
+	header := map[string]string{"host": u.Host}
+	for _, k := range []string{"Accept", "Content-Encoding", "Content-Type", "X-Amz-Date", "X-Amz-Security-Token", "Authorization"} {
+		if v := req.Header.Get(k); v != "" {
+			header[strings.ToLower(k)] = v
+		}
+	}
+	return header, nil
+}
+
+// AuthorizeConnection implements graphql.SubscriptionAuthorizer,
+// adding the authorization of the connection to u.
+func (s *Signer) AuthorizeConnection(ctx context.Context, u *url.URL) (*url.URL, error) {
+	header, err := s.authorization(ctx, strings.TrimSuffix(s.endpoint.Path, "/")+"/connect", []byte("{}"))
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	authorized := *u
+	q := authorized.Query()
+	q.Set("header", base64.StdEncoding.EncodeToString(b))
+	q.Set("payload", base64.StdEncoding.EncodeToString([]byte("{}")))
+	authorized.RawQuery = q.Encode()
+	return &authorized, nil
+}
+
+// AuthorizeSubscription implements graphql.SubscriptionAuthorizer,
+// sending payload as a string, along with its authorization.
+func (s *Signer) AuthorizeSubscription(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+	header, err := s.authorization(ctx, s.endpoint.Path, payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]interface{}{
+		"data": string(payload),
+		"extensions": map[string]interface{}{
+			"authorization": header,
+		},
+	})
+}
@@ -0,0 +1,137 @@
+package appsyncgraphql_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	graphql "github.com/nobody05/graphql_go_client"
+	"github.com/nobody05/graphql_go_client/appsyncgraphql"
+	"golang.org/x/net/websocket"
+)
+
+var credentials = aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+	return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET", SessionToken: "SESSION"}, nil
+})
+
+// checkAuthorization checks that authorization holds a signature
+// of AppSync in us-east-1 by credentials.
+func checkAuthorization(t *testing.T, authorization string) {
+	t.Helper()
+	if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(authorization, "/us-east-1/appsync/aws4_request") {
+		t.Errorf("got authorization: %q, want an AppSync signature", authorization)
+	}
+}
+
+func TestSigner_Transport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		checkAuthorization(t, req.Header.Get("Authorization"))
+		if got, want := req.Header.Get("X-Amz-Security-Token"), "SESSION"; got != want {
+			t.Errorf("got security token: %q, want: %q", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data": {"viewer": {"login": "gopher"}}}`))
+	}))
+	defer srv.Close()
+	signer, err := appsyncgraphql.NewSigner(srv.URL+"/graphql", "us-east-1", credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := graphql.NewClient(srv.URL+"/graphql", graphql.WithHTTPClient(&http.Client{Transport: signer.Transport(nil)}))
+
+	var q struct {
+		Viewer struct {
+			Login string `graphql:"login"`
+		} `graphql:"viewer"`
+	}
+	if err := client.NamedQuery(context.Background(), "GetViewer", &q, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.Viewer.Login, "gopher"; got != want {
+		t.Errorf("got login: %q, want: %q", got, want)
+	}
+}
+
+func TestNewClient_subscribe(t *testing.T) {
+	srv := httptest.NewUnstartedServer(websocket.Server{
+		Handshake: func(config *websocket.Config, req *http.Request) error {
+			if got, want := req.URL.Path, "/graphql/realtime"; got != want {
+				t.Errorf("got path: %q, want: %q", got, want)
+			}
+			b, err := base64.StdEncoding.DecodeString(req.URL.Query().Get("header"))
+			if err != nil {
+				return err
+			}
+			var header map[string]string
+			if err := json.Unmarshal(b, &header); err != nil {
+				return err
+			}
+			checkAuthorization(t, header["authorization"])
+			config.Protocol = []string{"graphql-ws"}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			var msg struct {
+				ID      string `json:"id"`
+				Type    string `json:"type"`
+				Payload struct {
+					Data       string `json:"data"`
+					Extensions struct {
+						Authorization map[string]string `json:"authorization"`
+					} `json:"extensions"`
+				} `json:"payload"`
+			}
+			for _, reply := range []string{"connection_ack", "start_ack"} {
+				if err := websocket.JSON.Receive(ws, &msg); err != nil {
+					t.Error(err)
+					return
+				}
+				websocket.JSON.Send(ws, map[string]string{"id": msg.ID, "type": reply})
+			}
+			if got, want := msg.Payload.Data, `{"query":"subscription{starAdded{id}}"}`; got != want {
+				t.Errorf("got data: %q, want: %q", got, want)
+			}
+			checkAuthorization(t, msg.Payload.Extensions.Authorization["authorization"])
+			websocket.JSON.Send(ws, map[string]interface{}{"id": msg.ID, "type": "data", "payload": map[string]interface{}{"data": map[string]interface{}{"starAdded": map[string]string{"id": "S1"}}}})
+			websocket.JSON.Send(ws, map[string]string{"id": msg.ID, "type": "complete"})
+		},
+	})
+	srv.StartTLS()
+	defer srv.Close()
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(srv.Certificate())
+	client, err := appsyncgraphql.NewClient(srv.URL+"/graphql", "us-east-1", credentials, graphql.WithTLSConfig(&tls.Config{RootCAs: rootCAs}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var s struct {
+		StarAdded struct {
+			ID string `graphql:"id"`
+		} `graphql:"starAdded"`
+	}
+	ch, err := client.Subscribe(context.Background(), &s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for msg := range ch {
+		if msg.Err != nil {
+			t.Fatal(msg.Err)
+		}
+		if err := msg.Decode(&s); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, s.StarAdded.ID)
+	}
+	if got, want := strings.Join(ids, ","), "S1"; got != want {
+		t.Errorf("got events: %q, want: %q", got, want)
+	}
+}
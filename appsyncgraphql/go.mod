@@ -0,0 +1,13 @@
+module github.com/nobody05/graphql_go_client/appsyncgraphql
+
+go 1.21
+
+require (
+	github.com/aws/aws-sdk-go-v2 v1.24.1
+	github.com/nobody05/graphql_go_client v0.0.0-00010101000000-000000000000
+	golang.org/x/net v0.0.0-20220225172249-27dd8689420f
+)
+
+require github.com/aws/smithy-go v1.19.0 // indirect
+
+replace github.com/nobody05/graphql_go_client => ../
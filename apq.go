@@ -0,0 +1,81 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+)
+
+// AutomaticPersistedQueries returns middleware implementing Apollo's
+// Automatic Persisted Queries. Instead of the document, each request carries
+// its SHA-256 hash in the "persistedQuery" extension. If the server doesn't
+// know the hash yet, the request is sent again with the full document,
+// which the server then stores for subsequent requests.
+//
+// Since documents derived from large query structs are sent only once,
+// this saves a lot of bandwidth. If the server reports that it doesn't
+// support persisted queries, the middleware stops using them.
+//
+// Specification: https://github.com/apollographql/apollo-link-persisted-queries#apollo-engine.
+func AutomaticPersistedQueries() Middleware {
+	var unsupported int32
+	return func(next Doer) Doer {
+		return DoerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+			if req.Query == "" || atomic.LoadInt32(&unsupported) != 0 {
+				return next.Do(ctx, req)
+			}
+			persisted := *req
+			persisted.Query = ""
+			persisted.Extensions = withExtension(req.Extensions, "persistedQuery", map[string]interface{}{
+				"version":    1,
+				"sha256Hash": DocumentHash(req.Query),
+			})
+			resp, err := next.Do(ctx, &persisted)
+			switch persistedQueryError(resp, err) {
+			case "PERSISTED_QUERY_NOT_FOUND":
+				// Register the document along with its hash.
+				persisted.Query = req.Query
+				return next.Do(ctx, &persisted)
+			case "PERSISTED_QUERY_NOT_SUPPORTED":
+				atomic.StoreInt32(&unsupported, 1)
+				return next.Do(ctx, req)
+			}
+			return resp, err
+		})
+	}
+}
+
+// withExtension returns a copy of extensions with key set to value.
+func withExtension(extensions map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	m := make(map[string]interface{}, len(extensions)+1)
+	for k, v := range extensions {
+		m[k] = v
+	}
+	m[key] = value
+	return m
+}
+
+// persistedQueryError returns the code of the persisted query error
+// reported by the server, if any. Besides a regular response, the error
+// may come in the body of an *HTTPError, as some servers use status 400.
+func persistedQueryError(resp *Response, err error) string {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		resp = new(Response)
+		if json.Unmarshal(httpErr.Body, resp) != nil {
+			return ""
+		}
+	} else if err != nil {
+		return ""
+	}
+	for _, e := range resp.Errors {
+		switch code, _ := e.Extensions["code"].(string); {
+		case code == "PERSISTED_QUERY_NOT_FOUND" || e.Message == "PersistedQueryNotFound":
+			return "PERSISTED_QUERY_NOT_FOUND"
+		case code == "PERSISTED_QUERY_NOT_SUPPORTED" || e.Message == "PersistedQueryNotSupported":
+			return "PERSISTED_QUERY_NOT_SUPPORTED"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,113 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TokenProvider provides the bearer tokens that requests are authenticated
+// with by BearerAuth. Implementations must be safe for concurrent use.
+// NewTokenProvider returns one caching tokens until they expire; package
+// oauth2graphql provides ones getting them from OAuth 2.0 token sources.
+type TokenProvider interface {
+	// Token returns the token to authenticate requests with,
+	// getting a new one if the current one has expired.
+	Token(ctx context.Context) (string, error)
+
+	// Refresh returns a new token to replace token, which the server
+	// rejected. If the current token is no longer token, as when another
+	// request refreshed it already, the current one is returned instead.
+	Refresh(ctx context.Context, token string) (string, error)
+}
+
+// BearerAuth returns middleware authenticating requests with bearer tokens
+// from p, sent in the Authorization header. If the server responds with
+// 401 Unauthorized, the token is refreshed and the request sent again,
+// once. Subscriptions via WebSocket aren't affected, as their
+// authentication is typically set via WithConnectionParams.
+func BearerAuth(p TokenProvider) Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+			token, err := p.Token(ctx)
+			if err != nil {
+				return nil, err
+			}
+			resp, err := next.Do(ctx, withBearerToken(req, token))
+			var httpErr *HTTPError
+			if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusUnauthorized {
+				return resp, err
+			}
+			token, err = p.Refresh(ctx, token)
+			if err != nil {
+				return nil, err
+			}
+			return next.Do(ctx, withBearerToken(req, token))
+		})
+	}
+}
+
+// withBearerToken returns a copy of req authenticated with token.
+func withBearerToken(req *Request, token string) *Request {
+	authed := *req
+	authed.Header = req.Header.Clone()
+	if authed.Header == nil {
+		authed.Header = make(http.Header)
+	}
+	authed.Header.Set("Authorization", "Bearer "+token)
+	return &authed
+}
+
+// TokenFunc gets a new token, along with the time it expires,
+// or the zero time if it doesn't.
+type TokenFunc func(ctx context.Context) (token string, expiry time.Time, err error)
+
+// tokenProvider is the TokenProvider returned by NewTokenProvider.
+type tokenProvider struct {
+	fetch TokenFunc
+
+	mu     sync.Mutex // Held while getting a new token, so that it's done once.
+	token  string
+	expiry time.Time
+}
+
+// NewTokenProvider returns a TokenProvider getting tokens from fetch,
+// and caching them until shortly before they expire. fetch isn't called
+// concurrently, so that concurrent requests share a new token.
+func NewTokenProvider(fetch TokenFunc) TokenProvider {
+	return &tokenProvider{fetch: fetch}
+}
+
+// tokenExpiryDelta is how long before their expiry tokens are replaced,
+// so that they don't expire on the way to the server.
+const tokenExpiryDelta = 10 * time.Second
+
+func (p *tokenProvider) Token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.token != "" && (p.expiry.IsZero() || time.Until(p.expiry) > tokenExpiryDelta) {
+		return p.token, nil
+	}
+	return p.refresh(ctx)
+}
+
+func (p *tokenProvider) Refresh(ctx context.Context, token string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.token != "" && p.token != token {
+		return p.token, nil
+	}
+	return p.refresh(ctx)
+}
+
+// refresh gets a new token. p.mu must be held.
+func (p *tokenProvider) refresh(ctx context.Context) (string, error) {
+	token, expiry, err := p.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	p.token, p.expiry = token, expiry
+	return token, nil
+}
@@ -0,0 +1,256 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BalanceStrategy is how a LoadBalancer picks the endpoint of each request.
+type BalanceStrategy int
+
+const (
+	// RoundRobin sends requests to the healthy endpoints in turn.
+	// It's the default.
+	RoundRobin BalanceStrategy = iota
+
+	// Failover sends requests to the first healthy endpoint, in the order
+	// they're listed, so that the others are only used while those
+	// preceding them are down.
+	Failover
+
+	// Weighted sends requests to healthy endpoints picked at random,
+	// in proportion to their weights.
+	Weighted
+)
+
+// Endpoint is a GraphQL server among those requests are spread over.
+type Endpoint struct {
+	// URL is the URL of the server, such as
+	// "https://replica1.example.com/graphql".
+	URL string
+
+	// Weight is the relative share of requests sent to the server
+	// with the Weighted strategy. Defaults to 1.
+	Weight int
+}
+
+// LoadBalancer configures the spreading of requests over several replicas
+// of a GraphQL server, as set by WithLoadBalancing. The zero value of each
+// field other than Endpoints selects a reasonable default.
+//
+// Endpoints are health checked passively: an endpoint is ejected when
+// a request to it fails with a network error or a 5xx status code, and
+// the following requests go to the others. Once EjectionTime has passed,
+// the endpoint is health checked in the background, and takes requests
+// again once a check passes. If all endpoints are ejected, requests are
+// sent to them regardless, rather than failed.
+//
+// Requests that fail aren't sent to another endpoint unless retried,
+// as set by WithRetry.
+type LoadBalancer struct {
+	// Endpoints are the servers requests are spread over.
+	Endpoints []Endpoint
+
+	// Strategy is how the endpoint of each request is picked.
+	Strategy BalanceStrategy
+
+	// EjectionTime is how long an endpoint is left out after a request
+	// to it failed, or after a failed health check, before being health
+	// checked. Defaults to 10 seconds.
+	EjectionTime time.Duration
+
+	// HealthCheck, if non-nil, checks whether the server at url is up.
+	// By default, the server is sent the query "{__typename}" with the
+	// client's default headers, and is up if it responds with 200 OK.
+	HealthCheck func(ctx context.Context, url string) error
+
+	// HealthCheckTimeout is the time limit for each health check.
+	// Defaults to 5 seconds.
+	HealthCheckTimeout time.Duration
+}
+
+// balancer picks the endpoints of requests, as configured by a LoadBalancer.
+type balancer struct {
+	c         *Client
+	lb        LoadBalancer
+	endpoints []*endpoint
+	err       error  // Error parsing the URLs of the endpoints, if any.
+	next      uint32 // Counter of requests, for RoundRobin.
+}
+
+// endpoint is the state of an Endpoint.
+type endpoint struct {
+	url    *url.URL
+	weight int
+
+	mu      sync.Mutex
+	down    bool      // Whether the endpoint is ejected.
+	checkAt time.Time // When to health check the endpoint, if down.
+	probing bool      // Whether a health check is in progress.
+}
+
+// newBalancer returns a balancer of the requests of c, as configured by lb.
+func newBalancer(c *Client, lb LoadBalancer) *balancer {
+	b := &balancer{c: c, lb: lb}
+	for _, e := range lb.Endpoints {
+		u, err := url.Parse(e.URL)
+		if err != nil {
+			b.err = fmt.Errorf("graphql: invalid endpoint URL: %w", err)
+			continue
+		}
+		weight := e.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		b.endpoints = append(b.endpoints, &endpoint{url: u, weight: weight})
+	}
+	if b.err == nil && len(b.endpoints) == 0 {
+		b.err = fmt.Errorf("graphql: no endpoints to balance requests over")
+	}
+	return b
+}
+
+// ejectionTime returns how long endpoints are left out after failing.
+func (b *balancer) ejectionTime() time.Duration {
+	if b.lb.EjectionTime <= 0 {
+		return 10 * time.Second
+	}
+	return b.lb.EjectionTime
+}
+
+// pick returns the endpoint to send a request to.
+func (b *balancer) pick() (*endpoint, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	now := time.Now()
+	var healthy []*endpoint
+	for _, e := range b.endpoints {
+		if b.available(e, now) {
+			healthy = append(healthy, e)
+		}
+	}
+	if len(healthy) == 0 {
+		healthy = b.endpoints
+	}
+	switch b.lb.Strategy {
+	case Failover:
+		return healthy[0], nil
+	case Weighted:
+		total := 0
+		for _, e := range healthy {
+			total += e.weight
+		}
+		n := rand.Intn(total)
+		for _, e := range healthy {
+			if n < e.weight {
+				return e, nil
+			}
+			n -= e.weight
+		}
+	}
+	i := atomic.AddUint32(&b.next, 1) - 1
+	return healthy[int(i%uint32(len(healthy)))], nil
+}
+
+// available reports whether e takes requests, starting a health check
+// of it if it's due.
+func (b *balancer) available(e *endpoint, now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.down {
+		return true
+	}
+	if !e.probing && !now.Before(e.checkAt) {
+		e.probing = true
+		go b.probe(e)
+	}
+	return false
+}
+
+// probe health checks e, which is down.
+func (b *balancer) probe(e *endpoint) {
+	timeout := b.lb.HealthCheckTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	check := b.lb.HealthCheck
+	if check == nil {
+		check = b.healthCheck
+	}
+	err := check(ctx, e.url.String())
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.probing = false
+	if err != nil {
+		e.checkAt = time.Now().Add(b.ejectionTime())
+		return
+	}
+	e.down = false
+}
+
+// healthCheck is the default health check, sending "{__typename}".
+func (b *balancer) healthCheck(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader([]byte(`{"query":"{__typename}"}`)))
+	if err != nil {
+		return err
+	}
+	for k, v := range b.c.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := b.c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("graphql: health check of %s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// report records the outcome of a request to e, ejecting it if the request
+// failed. Requests canceled by ctx don't count.
+func (b *balancer) report(ctx context.Context, e *endpoint, resp *http.Response, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch {
+	case !failed:
+		e.down = false
+	case !e.down:
+		e.down = true
+		e.checkAt = time.Now().Add(b.ejectionTime())
+	}
+}
+
+// route returns u, the URL of a request built for the client's URL,
+// sent to e instead.
+func (e *endpoint) route(u *url.URL) *url.URL {
+	routed := *e.url
+	if u.RawQuery != "" {
+		q := routed.Query()
+		for k, v := range u.Query() {
+			q[k] = v
+		}
+		routed.RawQuery = q.Encode()
+	}
+	return &routed
+}
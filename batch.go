@@ -0,0 +1,194 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Batcher configures the batching of requests made concurrently
+// into a single HTTP request, whose body is a JSON array of the
+// individual requests, answered by a JSON array of their responses
+// in the same order. This is the batching format of Apollo Server
+// and other servers.
+//
+// Only requests with the same HTTP headers are batched together.
+// Requests with file uploads are always sent on their own.
+type Batcher struct {
+	// Window is how long to wait for more requests after the first
+	// request of a batch, before sending it.
+	// Defaults to 10 milliseconds.
+	Window time.Duration
+
+	// MaxSize, if positive, is the maximum number of requests in a batch.
+	// A batch that reaches it is sent right away.
+	MaxSize int
+}
+
+// batchTransport is a Transport that batches requests before
+// sending them to the client's GraphQL server over HTTP.
+type batchTransport struct {
+	c *Client
+	b Batcher
+
+	mu      sync.Mutex
+	pending map[string]*batch // Batches being collected, by HTTP header.
+}
+
+// batch is a set of requests to send together.
+type batch struct {
+	header http.Header
+	calls  []*batchCall
+	timer  *time.Timer
+}
+
+// batchCall is a request waiting for its response as part of a batch.
+type batchCall struct {
+	ctx  context.Context
+	req  *Request
+	done chan struct{} // Closed once resp or err is set.
+	resp *Response
+	err  error
+}
+
+// Execute adds req to a batch, and waits for its response.
+func (t *batchTransport) Execute(ctx context.Context, req *Request) (*Response, error) {
+	if _, uploads := extractUploads(req.Variables); len(uploads) > 0 {
+		return httpTransport{c: t.c}.Execute(ctx, req)
+	}
+	call := &batchCall{ctx: ctx, req: req, done: make(chan struct{})}
+	key := headerKey(req.Header)
+
+	t.mu.Lock()
+	b, ok := t.pending[key]
+	if !ok {
+		b = &batch{header: req.Header}
+		t.pending[key] = b
+		b.timer = time.AfterFunc(t.b.Window, func() { t.flush(key, b) })
+	}
+	b.calls = append(b.calls, call)
+	full := t.b.MaxSize > 0 && len(b.calls) >= t.b.MaxSize
+	t.mu.Unlock()
+	if full {
+		t.flush(key, b)
+	}
+
+	select {
+	case <-call.done:
+		return call.resp, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// flush sends b, unless it has been sent already.
+func (t *batchTransport) flush(key string, b *batch) {
+	t.mu.Lock()
+	if t.pending[key] != b {
+		t.mu.Unlock()
+		return
+	}
+	delete(t.pending, key)
+	b.timer.Stop()
+	t.mu.Unlock()
+	t.send(b)
+}
+
+// send sends the requests of b as a single HTTP request,
+// and delivers the responses to their callers.
+func (t *batchTransport) send(b *batch) {
+	// The batch is abandoned once all callers have given up on it.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for _, call := range b.calls {
+			select {
+			case <-call.ctx.Done():
+			case <-ctx.Done():
+				return
+			}
+		}
+		cancel()
+	}()
+
+	resps, err := t.post(ctx, b)
+	for i, call := range b.calls {
+		if err != nil {
+			call.err = err
+		} else {
+			call.resp = resps[i]
+		}
+		close(call.done)
+	}
+}
+
+// post sends the requests of b and decodes the server's responses.
+func (t *batchTransport) post(ctx context.Context, b *batch) ([]*Response, error) {
+	reqs := make([]*Request, len(b.calls))
+	idempotent := true
+	for i, call := range b.calls {
+		reqs[i] = call.req
+		idempotent = idempotent && isIdempotent(call.req)
+	}
+	body, err := json.Marshal(reqs)
+	if err != nil {
+		return nil, err
+	}
+	header := b.header
+	if h := t.c.requestIDHeader; h != "" && header.Get(h) == "" {
+		var ids []string
+		for _, call := range b.calls {
+			if info, ok := CallInfoFromContext(call.ctx); ok && info.RequestID != "" {
+				ids = append(ids, info.RequestID)
+			}
+		}
+		header = header.Clone()
+		header.Set(h, strings.Join(ids, ","))
+	}
+	resp, err := t.c.post(ctx, body, header, idempotent)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(resp)
+	}
+	var out []*Response
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("graphql: decoding batch response: %v", err)
+	}
+	if len(out) != len(reqs) {
+		return nil, fmt.Errorf("graphql: got %d responses to a batch of %d requests", len(out), len(reqs))
+	}
+	for i := range out {
+		if out[i] == nil {
+			out[i] = new(Response)
+		}
+		out[i].StatusCode, out[i].Header = resp.StatusCode, resp.Header
+	}
+	return out, nil
+}
+
+// headerKey returns a string that's equal for equal headers.
+func headerKey(header http.Header) string {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		for _, v := range header[k] {
+			sb.WriteString(k)
+			sb.WriteByte(':')
+			sb.WriteString(v)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
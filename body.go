@@ -0,0 +1,218 @@
+package graphql
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+// WithStreamedRequests makes the client stream the JSON bodies of POST
+// requests to the server as they're encoded, rather than encode them whole
+// in memory first, for bulk mutations whose variables take megabytes.
+// Variables are encoded one at a time, as are the items of lists and the
+// fields of maps they hold, so that memory use is bounded by the largest
+// of those rather than by the request.
+//
+// Bodies are encoded twice: once, discarding the encoding, to learn their
+// length, which is sent as the Content-Length, and whether they're large
+// enough to be compressed, per WithRequestCompression, and once more as
+// they're sent, which is repeated for each retry. Compressed bodies are
+// sent without a Content-Length. It doesn't apply to clients with a codec,
+// set by WithCodec, or to requests uploading files.
+func WithStreamedRequests(stream bool) ClientOption {
+	return func(c *Client) {
+		c.streamRequests = stream
+	}
+}
+
+// postStreamed sends req as a JSON POST request to the GraphQL server,
+// as by post, except that its body is streamed as it's encoded.
+func (c *Client) postStreamed(ctx context.Context, req *Request, header http.Header, idempotent bool) (*http.Response, error) {
+	var n countingWriter
+	if err := writeRequest(&n, req); err != nil {
+		return nil, err
+	}
+	compressed := c.gzipMinSize > 0 && n >= countingWriter(c.gzipMinSize)
+	write := func(w io.Writer) error {
+		if !compressed {
+			return writeRequest(w, req)
+		}
+		zw := gzip.NewWriter(w)
+		if err := writeRequest(zw, req); err != nil {
+			return err
+		}
+		return zw.Close()
+	}
+	return c.send(ctx, header, idempotent, func() (*http.Request, error) {
+		getBody := func() (io.ReadCloser, error) {
+			return newStreamedBody(write), nil
+		}
+		body, _ := getBody()
+		r, err := http.NewRequest(http.MethodPost, c.url, body)
+		if err != nil {
+			return nil, err
+		}
+		r.GetBody = getBody
+		r.Header.Set("Content-Type", "application/json")
+		if compressed {
+			r.Header.Set("Content-Encoding", "gzip")
+			r.ContentLength = -1
+		} else {
+			r.ContentLength = int64(n)
+		}
+		return r, nil
+	})
+}
+
+// streamedBody is a request body written by a function as it's read,
+// through a pipe.
+type streamedBody struct {
+	write func(w io.Writer) error
+	once  sync.Once
+	pr    *io.PipeReader
+	pw    *io.PipeWriter
+}
+
+// newStreamedBody returns a body whose content is written by write.
+func newStreamedBody(write func(w io.Writer) error) *streamedBody {
+	pr, pw := io.Pipe()
+	return &streamedBody{write: write, pr: pr, pw: pw}
+}
+
+func (b *streamedBody) Read(p []byte) (int, error) {
+	// Start writing once the body is read, so that bodies of requests
+	// that aren't sent, as when a circuit is open, don't leave goroutines
+	// waiting for a reader.
+	b.once.Do(func() {
+		go func() {
+			b.pw.CloseWithError(b.write(b.pw))
+		}()
+	})
+	return b.pr.Read(p)
+}
+
+// Close closes the body, which stops the function writing it, if any.
+func (b *streamedBody) Close() error {
+	return b.pr.Close()
+}
+
+// countingWriter counts the bytes written to it, discarding them.
+type countingWriter int64
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
+
+// writeRequest writes the JSON encoding of req to w, followed by a newline,
+// as encodeRequest encodes it, encoding variables piece by piece.
+func writeRequest(w io.Writer, req *Request) error {
+	bw := bufio.NewWriterSize(w, 32<<10)
+	buf := getBuffer()
+	defer putBuffer(buf)
+	e := requestWriter{w: bw, buf: buf}
+	e.w.WriteByte('{')
+	if req.Query != "" {
+		e.key("query")
+		e.encode(req.Query)
+	}
+	if len(req.Variables) > 0 {
+		e.key("variables")
+		e.object(req.Variables)
+	}
+	if req.OperationName != "" {
+		e.key("operationName")
+		e.encode(req.OperationName)
+	}
+	if req.DocumentID != "" {
+		e.key("id")
+		e.encode(req.DocumentID)
+	}
+	if len(req.Extensions) > 0 {
+		e.key("extensions")
+		e.encode(req.Extensions)
+	}
+	e.w.WriteString("}\n")
+	if e.err != nil {
+		return e.err
+	}
+	return bw.Flush()
+}
+
+// requestWriter writes JSON values to w, keeping the first error.
+type requestWriter struct {
+	w      *bufio.Writer
+	buf    *buffer
+	fields int // Fields written to the current object.
+	err    error
+}
+
+// key writes the key of the next field of the current object.
+func (e *requestWriter) key(k string) {
+	if e.fields > 0 {
+		e.w.WriteByte(',')
+	}
+	e.fields++
+	e.encode(k)
+	e.w.WriteByte(':')
+}
+
+// encode writes the JSON encoding of v, encoded whole.
+func (e *requestWriter) encode(v interface{}) {
+	if e.err != nil {
+		return
+	}
+	e.buf.Reset()
+	if e.err = e.buf.enc.Encode(v); e.err != nil {
+		return
+	}
+	// Drop the newline Encode appends.
+	_, e.err = e.w.Write(e.buf.Bytes()[:e.buf.Len()-1])
+}
+
+// value writes the JSON encoding of v, writing the items of lists and the
+// fields of maps one at a time.
+func (e *requestWriter) value(v interface{}) {
+	if m, ok := v.(map[string]interface{}); ok && m != nil {
+		e.object(m)
+		return
+	}
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Kind() == reflect.Slice && !rv.IsNil() && rv.Type().Elem().Kind() != reflect.Uint8 && !marshalsItself(rv.Type()),
+		rv.Kind() == reflect.Array && !marshalsItself(rv.Type()):
+		e.w.WriteByte('[')
+		for i := 0; i < rv.Len() && e.err == nil; i++ {
+			if i > 0 {
+				e.w.WriteByte(',')
+			}
+			e.value(rv.Index(i).Interface())
+		}
+		e.w.WriteByte(']')
+	default:
+		e.encode(v)
+	}
+}
+
+// object writes m as a JSON object, with sorted keys, as json.Marshal does.
+func (e *requestWriter) object(m map[string]interface{}) {
+	fields := e.fields
+	e.fields = 0
+	e.w.WriteByte('{')
+	for _, k := range sortedKeys(m) {
+		e.key(k)
+		e.value(m[k])
+	}
+	e.w.WriteByte('}')
+	e.fields = fields
+}
+
+// marshalsItself reports whether values of type t encode themselves
+// as JSON, or as text, which encoding/json encodes as a string.
+func marshalsItself(t reflect.Type) bool {
+	return t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType)
+}
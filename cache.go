@@ -0,0 +1,555 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/nobody05/graphql_go_client/internal/parser"
+)
+
+// CachePolicy determines how a query uses the client's cache,
+// as set by WithCache.
+type CachePolicy uint8
+
+const (
+	// CacheFirst serves the query from the cache if all of its fields are
+	// cached, and otherwise sends it to the server, caching the result.
+	// It's the default.
+	CacheFirst CachePolicy = iota
+
+	// NetworkOnly always sends the query to the server,
+	// caching the result.
+	NetworkOnly
+
+	// CacheAndNetwork serves the query from the cache like CacheFirst,
+	// but also sends it to the server in the background when it's served
+	// from the cache, refreshing the cache for subsequent queries.
+	CacheAndNetwork
+)
+
+// rootQueryKey is the key of the record holding the root fields of queries.
+const rootQueryKey = "ROOT_QUERY"
+
+// Cache is an in-memory normalized cache of query results, used by
+// a client via WithCache. It's safe for concurrent use.
+//
+// Results are stored by field rather than by document, so that queries
+// selecting fields already fetched by other queries can be served from the
+// cache. Objects with both __typename and id selected are normalized into
+// a single record per entity, keyed by the two, so that their fields are
+// shared by all queries and updated by the results of mutations that
+// select them. Other objects are stored as part of the field holding them.
+// Fields are keyed by name and arguments, not by alias.
+//
+// Results are cached regardless of the headers sent with queries,
+// so a cache must not be shared by requests made for different users.
+type Cache struct {
+	ttl time.Duration
+
+	mu      sync.RWMutex
+	records map[string]cacheRecord // By key: rootQueryKey, or "User:1" for entities.
+
+	docs *lru[string, *parser.Document] // Parsed documents, by document.
+}
+
+// maxCachedDocuments is the number of parsed documents a Cache keeps.
+// Documents inlining argument values, as with WithFieldArguments,
+// may each be seen once, so they're not all kept.
+const maxCachedDocuments = 256
+
+// NewCache returns an empty Cache whose fields expire ttl after they
+// were last fetched, or never if ttl is 0.
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{
+		ttl:     ttl,
+		records: make(map[string]cacheRecord),
+		docs:    newLRU[string, *parser.Document](maxCachedDocuments),
+	}
+}
+
+// Evict removes the entity with the given __typename and id from the cache.
+// Queries selecting it are then sent to the server, which fetches it anew.
+func (c *Cache) Evict(typename, id string) {
+	c.mu.Lock()
+	delete(c.records, typename+":"+id)
+	c.mu.Unlock()
+}
+
+// Clear removes everything from the cache.
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	c.records = make(map[string]cacheRecord)
+	c.mu.Unlock()
+	c.docs.clear()
+}
+
+// cacheRecord holds the fields of an entity, or of the root query type,
+// by field key.
+type cacheRecord map[string]cacheField
+
+type cacheField struct {
+	value   interface{} // See cacheObject.
+	expires time.Time   // When the value expires, or zero if never.
+}
+
+// cacheObject holds the fields of an object that isn't an entity, by field
+// key. Values of fields, whether in a cacheObject or a cacheRecord, are
+// decoded JSON values for leaf fields; a cacheRef, cacheObject or nil for
+// fields with a selection set; or lists of those.
+type cacheObject map[string]interface{}
+
+// cacheRef refers to the record of an entity by key.
+type cacheRef string
+
+// cached executes req via fetch, using the client's cache according to the
+// request's cache policy: serving queries from the cache if possible, and
+// caching the results of queries and the entities in those of mutations.
+func (c *Client) cached(ctx context.Context, op operationType, req *Request, o *requestOptions, fetch func(ctx context.Context) (*Response, error)) (*Response, error) {
+	cache := c.cache
+	doc := cache.parse(req.Query)
+	if doc == nil {
+		return fetch(ctx)
+	}
+	operation := findOperation(doc, req.OperationName)
+	if operation == nil {
+		return fetch(ctx)
+	}
+	w := cacheWalker{doc: doc, vars: variableValues(operation, req.Variables)}
+	if op == queryOperation && o.cachePolicy != NetworkOnly {
+		if data, ok := cache.read(&w, operation.SelectionSet); ok {
+			if o.cachePolicy == CacheAndNetwork {
+				go func() {
+					ctx, cancel := c.withTimeout(context.WithoutCancel(ctx), o)
+					defer cancel()
+					if out, err := fetch(ctx); err == nil {
+						cache.write(&w, op, operation.SelectionSet, out)
+					}
+				}()
+			}
+			return &Response{Data: data}, nil
+		}
+	}
+	out, err := fetch(ctx)
+	if err == nil {
+		cache.write(&w, op, operation.SelectionSet, out)
+	}
+	return out, err
+}
+
+// parse returns the parsed document, or nil if it can't be parsed.
+func (c *Cache) parse(document string) *parser.Document {
+	return c.docs.get(document, func(document string) *parser.Document {
+		doc, _ := parser.Parse(document)
+		return doc
+	})
+}
+
+// findOperation returns the operation of doc with the given name,
+// or its only operation if name is empty, or nil if there's none.
+func findOperation(doc *parser.Document, name string) *parser.Operation {
+	for _, op := range doc.Operations {
+		if op.Name == name || name == "" && len(doc.Operations) == 1 {
+			return op
+		}
+	}
+	return nil
+}
+
+// variableValues returns the values of the variables of op, with those
+// missing from variables set to their defaults, in canonical form.
+func variableValues(op *parser.Operation, variables map[string]interface{}) map[string]interface{} {
+	values := make(map[string]interface{}, len(op.Variables))
+	for _, d := range op.Variables {
+		if v, ok := variables[d.Name]; ok {
+			values[d.Name] = canonical(v)
+		} else if d.DefaultValue != nil {
+			values[d.Name] = literal(d.DefaultValue, nil)
+		}
+	}
+	return values
+}
+
+// canonical returns v as decoded from its JSON encoding,
+// so that its encoding doesn't depend on its Go type.
+func canonical(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var c interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if d.Decode(&c) != nil {
+		return nil
+	}
+	return c
+}
+
+// literal returns the value of v as decoded from JSON,
+// taking the values of variables from vars.
+func literal(v *parser.Value, vars map[string]interface{}) interface{} {
+	switch v.Kind {
+	case parser.VariableValue:
+		return vars[v.Raw]
+	case parser.IntValue, parser.FloatValue:
+		return json.Number(v.Raw)
+	case parser.BooleanValue:
+		return v.Raw == "true"
+	case parser.NullValue:
+		return nil
+	case parser.ListValue:
+		l := make([]interface{}, len(v.List))
+		for i, item := range v.List {
+			l[i] = literal(item, vars)
+		}
+		return l
+	case parser.ObjectValue:
+		m := make(map[string]interface{}, len(v.Fields))
+		for _, f := range v.Fields {
+			m[f.Name] = literal(f.Value, vars)
+		}
+		return m
+	}
+	return v.Raw
+}
+
+// cacheWalker walks the selection sets of an operation,
+// for reading or writing its results.
+type cacheWalker struct {
+	doc  *parser.Document
+	vars map[string]interface{} // Values of the variables of the operation.
+}
+
+// cacheFieldGroup is the fields of a selection set sharing a response key,
+// whose selection sets are merged.
+type cacheFieldGroup struct {
+	key    string // Response key.
+	fields []*parser.Field
+}
+
+// fieldKey returns the key under which the value of f is cached: its name,
+// followed by the values of its arguments, if any, such as
+// `user({"id":"1"})`.
+func (w *cacheWalker) fieldKey(f *parser.Field) string {
+	if len(f.Arguments) == 0 {
+		return f.Name
+	}
+	args := make(map[string]interface{}, len(f.Arguments))
+	for _, a := range f.Arguments {
+		args[a.Name] = literal(a.Value, w.vars)
+	}
+	b, _ := json.Marshal(args) // Keys are sorted, so the encoding is canonical.
+	return f.Name + "(" + string(b) + ")"
+}
+
+// included reports whether a selection with directives ds is included,
+// as determined by @skip and @include.
+func (w *cacheWalker) included(ds []*parser.Directive) bool {
+	for _, d := range ds {
+		if d.Name != "skip" && d.Name != "include" {
+			continue
+		}
+		if a := d.Argument("if"); a != nil {
+			if cond, _ := literal(a.Value, w.vars).(bool); cond == (d.Name == "skip") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// collect returns the fields of the selection set set, grouped by response
+// key, in order. Fragments are included if applies returns true given their
+// type condition and selection set.
+func (w *cacheWalker) collect(set []parser.Selection, applies func(on string, set []parser.Selection) bool) []cacheFieldGroup {
+	var groups []cacheFieldGroup
+	var add func(set []parser.Selection, visited map[string]bool)
+	add = func(set []parser.Selection, visited map[string]bool) {
+		for _, sel := range set {
+			switch sel := sel.(type) {
+			case *parser.Field:
+				if !w.included(sel.Directives) {
+					continue
+				}
+				key := sel.ResponseKey()
+				i := 0
+				for i < len(groups) && groups[i].key != key {
+					i++
+				}
+				if i == len(groups) {
+					groups = append(groups, cacheFieldGroup{key: key})
+				}
+				groups[i].fields = append(groups[i].fields, sel)
+			case *parser.FragmentSpread:
+				f := w.doc.Fragment(sel.Name)
+				if f == nil || visited[f.Name] || !w.included(sel.Directives) || !applies(f.TypeCondition, f.SelectionSet) {
+					continue
+				}
+				visited[f.Name] = true
+				add(f.SelectionSet, visited)
+			case *parser.InlineFragment:
+				if w.included(sel.Directives) && applies(sel.TypeCondition, sel.SelectionSet) {
+					add(sel.SelectionSet, visited)
+				}
+			}
+		}
+	}
+	add(set, make(map[string]bool))
+	return groups
+}
+
+// subselections returns the merged selection sets of the fields of g.
+func (g cacheFieldGroup) subselections() []parser.Selection {
+	if len(g.fields) == 1 {
+		return g.fields[0].SelectionSet
+	}
+	var set []parser.Selection
+	for _, f := range g.fields {
+		set = append(set, f.SelectionSet...)
+	}
+	return set
+}
+
+// read returns the data of the operation with selection set set, encoded
+// as JSON, if all of its fields are cached and unexpired.
+func (c *Cache) read(w *cacheWalker, set []parser.Selection) (json.RawMessage, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	root, ok := c.records[rootQueryKey]
+	if !ok {
+		return nil, false
+	}
+	var buf bytes.Buffer
+	if !c.readObject(&buf, w, c.recordLookup(root), set) {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+// recordLookup returns a function looking up the unexpired fields of r.
+func (c *Cache) recordLookup(r cacheRecord) func(key string) (interface{}, bool) {
+	now := time.Now()
+	return func(key string) (interface{}, bool) {
+		f, ok := r[key]
+		if !ok || !f.expires.IsZero() && now.After(f.expires) {
+			return nil, false
+		}
+		return f.value, true
+	}
+}
+
+// readObject writes the object with the fields looked up by lookup,
+// selected by set, to buf. It returns false if a field is missing.
+func (c *Cache) readObject(buf *bytes.Buffer, w *cacheWalker, lookup func(key string) (interface{}, bool), set []parser.Selection) bool {
+	typename, _ := lookup("__typename")
+	groups := w.collect(set, func(on string, set []parser.Selection) bool {
+		if on == "" || on == typename {
+			return true
+		}
+		// Without the schema, it can't be told whether the object's type
+		// implements an interface or belongs to a union, so the fragment
+		// is taken to apply if the fields it selects directly are cached.
+		for _, sel := range set {
+			if f, ok := sel.(*parser.Field); ok && w.included(f.Directives) {
+				if _, ok := lookup(w.fieldKey(f)); !ok {
+					return false
+				}
+			}
+		}
+		return true
+	})
+	buf.WriteByte('{')
+	for i, g := range groups {
+		value, ok := lookup(w.fieldKey(g.fields[0]))
+		if !ok {
+			return false
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(g.key)
+		buf.Write(key)
+		buf.WriteByte(':')
+		if !c.readValue(buf, w, value, g.subselections()) {
+			return false
+		}
+	}
+	buf.WriteByte('}')
+	return true
+}
+
+// readValue writes the cached value v of a field with selection set set
+// to buf. It returns false if a field is missing.
+func (c *Cache) readValue(buf *bytes.Buffer, w *cacheWalker, v interface{}, set []parser.Selection) bool {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteString("null")
+		return true
+	case cacheRef:
+		r, ok := c.records[string(v)]
+		return ok && c.readObject(buf, w, c.recordLookup(r), set)
+	case cacheObject:
+		return c.readObject(buf, w, func(key string) (interface{}, bool) {
+			value, ok := v[key]
+			return value, ok
+		}, set)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if !c.readValue(buf, w, item, set) {
+				return false
+			}
+		}
+		buf.WriteByte(']')
+		return true
+	}
+	if len(set) > 0 {
+		// The field was cached as a leaf field.
+		return false
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	buf.Write(b)
+	return true
+}
+
+// write caches the result out of an operation of type op with selection
+// set set. The root fields of mutations aren't cached, but the entities
+// among their values are. Results with errors aren't cached, since their
+// data may be partial.
+func (c *Cache) write(w *cacheWalker, op operationType, set []parser.Selection, out *Response) {
+	if len(out.Errors) > 0 || !out.hasData() {
+		return
+	}
+	var data map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(out.Data))
+	d.UseNumber()
+	if d.Decode(&data) != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := time.Time{}
+	if c.ttl > 0 {
+		expires = time.Now().Add(c.ttl)
+	}
+	if op != queryOperation {
+		c.writeObject(w, cacheObject{}.store(), data, set, expires)
+		return
+	}
+	root, ok := c.records[rootQueryKey]
+	if !ok {
+		root = make(cacheRecord)
+		c.records[rootQueryKey] = root
+	}
+	c.writeObject(w, root.store(expires), data, set, expires)
+}
+
+// cacheStore gets and sets the fields of a cacheRecord or cacheObject.
+type cacheStore struct {
+	get func(key string) (interface{}, bool)
+	set func(key string, value interface{})
+}
+
+func (r cacheRecord) store(expires time.Time) cacheStore {
+	return cacheStore{
+		get: func(key string) (interface{}, bool) {
+			f, ok := r[key]
+			return f.value, ok
+		},
+		set: func(key string, value interface{}) {
+			r[key] = cacheField{value: value, expires: expires}
+		},
+	}
+}
+
+func (o cacheObject) store() cacheStore {
+	return cacheStore{
+		get: func(key string) (interface{}, bool) {
+			value, ok := o[key]
+			return value, ok
+		},
+		set: func(key string, value interface{}) {
+			o[key] = value
+		},
+	}
+}
+
+// writeObject caches the fields of obj selected by set into s.
+func (c *Cache) writeObject(w *cacheWalker, s cacheStore, obj map[string]interface{}, set []parser.Selection, expires time.Time) {
+	groups := w.collect(set, func(string, []parser.Selection) bool {
+		// Fields of fragments that don't apply are missing from obj.
+		return true
+	})
+	for _, g := range groups {
+		value, ok := obj[g.key]
+		if !ok {
+			continue
+		}
+		key := w.fieldKey(g.fields[0])
+		prev, _ := s.get(key)
+		s.set(key, c.normalize(w, prev, value, g.subselections(), expires))
+	}
+}
+
+// normalize returns the value to cache for the value v of a field with
+// selection set set, whose previously cached value is prev, caching
+// the entities within it in their records.
+func (c *Cache) normalize(w *cacheWalker, prev, v interface{}, set []parser.Selection, expires time.Time) interface{} {
+	if len(set) == 0 {
+		return v
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if key := entityKey(v); key != "" {
+			r, ok := c.records[key]
+			if !ok {
+				r = make(cacheRecord)
+				c.records[key] = r
+			}
+			c.writeObject(w, r.store(expires), v, set, expires)
+			return cacheRef(key)
+		}
+		// Merge with the fields previously cached, which other
+		// queries may select.
+		obj := make(cacheObject)
+		if prev, ok := prev.(cacheObject); ok {
+			for k, v := range prev {
+				obj[k] = v
+			}
+		}
+		c.writeObject(w, obj.store(), v, set, expires)
+		return obj
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, item := range v {
+			l[i] = c.normalize(w, nil, item, set, expires)
+		}
+		return l
+	}
+	return v
+}
+
+// entityKey returns the key of the record of the entity obj, such as
+// "User:1", or "" if it lacks __typename or id.
+func entityKey(obj map[string]interface{}) string {
+	typename, ok := obj["__typename"].(string)
+	if !ok {
+		return ""
+	}
+	switch id := obj["id"].(type) {
+	case string:
+		return typename + ":" + id
+	case json.Number:
+		return typename + ":" + string(id)
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package graphql
+
+import (
+	"context"
+	"time"
+)
+
+// CallInfo describes the operation that a client is executing, as carried
+// by the contexts passed to middleware, transports, and log handlers, and
+// by those of the HTTP requests sent to the server, so that low-level events
+// such as those of an http.RoundTripper can be correlated with it.
+type CallInfo struct {
+	OperationName string    // Name of the operation, or empty if anonymous.
+	OperationType string    // Type of the operation, as by Request.OperationType.
+	Start         time.Time // Time the client started executing the operation.
+	RequestID     string    // ID of the operation, if set by WithRequestID.
+
+	// Attempt is the number of the HTTP request among those sent for the
+	// operation according to the client's retry policy, starting at 1.
+	// Hedged requests share the number of the attempt they duplicate.
+	// It's 0 outside of the sending of HTTP requests, as in middleware.
+	Attempt int
+}
+
+// callInfoKey is the context key for the CallInfo of an operation.
+type callInfoKey struct{}
+
+// CallInfoFromContext returns the CallInfo carried by ctx, reporting
+// whether there was one.
+func CallInfoFromContext(ctx context.Context) (CallInfo, bool) {
+	info, ok := ctx.Value(callInfoKey{}).(CallInfo)
+	return info, ok
+}
+
+// withCallInfo returns ctx carrying info.
+func withCallInfo(ctx context.Context, info CallInfo) context.Context {
+	return context.WithValue(ctx, callInfoKey{}, info)
+}
+
+// withAttempt returns ctx carrying its CallInfo, if any,
+// with the given attempt number.
+func withAttempt(ctx context.Context, attempt int) context.Context {
+	info, ok := CallInfoFromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	info.Attempt = attempt
+	return withCallInfo(ctx, info)
+}
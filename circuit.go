@@ -0,0 +1,213 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is the error of requests not sent because the circuit
+// breaker of their endpoint is open, as set by WithCircuitBreaker.
+var ErrCircuitOpen = errors.New("graphql: circuit breaker open")
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets requests through. It's the initial state.
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen fails requests right away with ErrCircuitOpen,
+	// after too many requests failed.
+	CircuitOpen
+
+	// CircuitHalfOpen lets a few requests through, to probe whether
+	// the endpoint recovered, once the circuit has been open for a while.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// CircuitBreaker configures the circuit breakers of a client, as set by
+// WithCircuitBreaker: one per endpoint, so that requests to a failing
+// GraphQL server fail fast rather than pile up, giving it time to recover.
+// Requests fail if they fail with a network error or a 5xx status code.
+// The zero value of each field selects a reasonable default.
+//
+// A circuit opens once ConsecutiveFailures requests in a row failed, or
+// once the share of requests failing within Window reaches FailureRate.
+// After OpenTimeout, it's half-open: HalfOpenRequests requests are let
+// through, closing the circuit if they all succeed, and opening it again
+// otherwise.
+type CircuitBreaker struct {
+	// ConsecutiveFailures is the number of consecutive failed requests
+	// opening the circuit. Defaults to 5.
+	ConsecutiveFailures int
+
+	// FailureRate, if positive, is the fraction, between 0 and 1, of
+	// requests failing within Window that opens the circuit, once at least
+	// MinRequests were sent within it.
+	FailureRate float64
+
+	// MinRequests is the minimum number of requests within Window for
+	// FailureRate to apply. Defaults to 20.
+	MinRequests int
+
+	// Window is the period over which FailureRate is computed.
+	// Defaults to 10 seconds.
+	Window time.Duration
+
+	// OpenTimeout is how long the circuit stays open before being
+	// half-open. Defaults to 30 seconds.
+	OpenTimeout time.Duration
+
+	// HalfOpenRequests is the number of requests let through while
+	// half-open. Defaults to 1.
+	HalfOpenRequests int
+
+	// OnStateChange, if non-nil, is called when the circuit of the
+	// endpoint with the given URL goes from one state to another,
+	// such as to log it or alert.
+	OnStateChange func(endpoint string, from, to CircuitState)
+}
+
+// circuits are the circuit breakers of a client, by endpoint.
+type circuits struct {
+	config CircuitBreaker
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// circuit is the circuit breaker of an endpoint.
+type circuit struct {
+	endpoint string
+
+	mu          sync.Mutex
+	state       CircuitState
+	consecutive int       // Consecutive failures, while closed.
+	windowStart time.Time // Start of the current window, while closed.
+	requests    int       // Requests in the current window.
+	failures    int       // Failed requests in the current window.
+	openedAt    time.Time // When the circuit opened, while open.
+	probes      int       // Requests let through, while half-open.
+	successes   int       // Successful requests, while half-open.
+}
+
+// circuit returns the circuit breaker of the endpoint of requests to u.
+func (cs *circuits) circuit(u *url.URL) *circuit {
+	endpoint := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String()
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	c, ok := cs.circuits[endpoint]
+	if !ok {
+		if cs.circuits == nil {
+			cs.circuits = make(map[string]*circuit)
+		}
+		c = &circuit{endpoint: endpoint}
+		cs.circuits[endpoint] = c
+	}
+	return c
+}
+
+// allow returns ErrCircuitOpen, wrapped, if c doesn't let a request through,
+// and records the request otherwise.
+func (cs *circuits) allow(c *circuit) error {
+	c.mu.Lock()
+	from := c.state
+	if c.state == CircuitOpen && time.Since(c.openedAt) >= defaultDuration(cs.config.OpenTimeout, 30*time.Second) {
+		c.state, c.probes, c.successes = CircuitHalfOpen, 0, 0
+	}
+	var err error
+	switch c.state {
+	case CircuitOpen:
+		err = fmt.Errorf("%w for %s", ErrCircuitOpen, c.endpoint)
+	case CircuitHalfOpen:
+		if c.probes < defaultInt(cs.config.HalfOpenRequests, 1) {
+			c.probes++
+		} else {
+			err = fmt.Errorf("%w for %s", ErrCircuitOpen, c.endpoint)
+		}
+	}
+	to := c.state
+	c.mu.Unlock()
+	cs.changed(c, from, to)
+	return err
+}
+
+// record records the outcome of a request let through by c.
+// Requests canceled by ctx don't count.
+func (cs *circuits) record(ctx context.Context, c *circuit, resp *http.Response, err error) {
+	c.mu.Lock()
+	from := c.state
+	switch {
+	case ctx.Err() != nil:
+		if c.state == CircuitHalfOpen {
+			c.probes--
+		}
+	case c.state == CircuitHalfOpen:
+		if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+			c.state, c.openedAt = CircuitOpen, time.Now()
+		} else if c.successes++; c.successes >= defaultInt(cs.config.HalfOpenRequests, 1) {
+			c.state, c.consecutive = CircuitClosed, 0
+			c.windowStart, c.requests, c.failures = time.Now(), 0, 0
+		}
+	case c.state == CircuitClosed:
+		now := time.Now()
+		if now.Sub(c.windowStart) >= defaultDuration(cs.config.Window, 10*time.Second) {
+			c.windowStart, c.requests, c.failures = now, 0, 0
+		}
+		c.requests++
+		if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+			c.failures++
+			c.consecutive++
+		} else {
+			c.consecutive = 0
+		}
+		rate := cs.config.FailureRate
+		if c.consecutive >= defaultInt(cs.config.ConsecutiveFailures, 5) ||
+			rate > 0 && c.requests >= defaultInt(cs.config.MinRequests, 20) && float64(c.failures) >= rate*float64(c.requests) {
+			c.state, c.openedAt = CircuitOpen, now
+		}
+	}
+	to := c.state
+	c.mu.Unlock()
+	cs.changed(c, from, to)
+}
+
+// changed reports the change of state of c, if any, to OnStateChange.
+func (cs *circuits) changed(c *circuit, from, to CircuitState) {
+	if from != to && cs.config.OnStateChange != nil {
+		cs.config.OnStateChange(c.endpoint, from, to)
+	}
+}
+
+// defaultInt returns n, or def if n isn't positive.
+func defaultInt(n, def int) int {
+	if n <= 0 {
+		return def
+	}
+	return n
+}
+
+// defaultDuration returns d, or def if d isn't positive.
+func defaultDuration(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
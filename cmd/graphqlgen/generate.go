@@ -0,0 +1,569 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	graphql "github.com/nobody05/graphql_go_client"
+	"github.com/nobody05/graphql_go_client/internal/parser"
+)
+
+// goType is a Go type that a custom scalar is mapped to, such as time.Time.
+type goType struct {
+	importPath string // Import path of the package declaring the type, or empty for none.
+	expr       string // Type expression, such as "time.Time".
+}
+
+// parseGoType parses a Go type given as an import path followed by a dot
+// and the type name, such as "time.Time" or "github.com/google/uuid.UUID".
+func parseGoType(s string) (goType, error) {
+	i := strings.LastIndex(s, ".")
+	if i < 0 {
+		return goType{expr: s}, nil
+	}
+	importPath, name := s[:i], s[i+1:]
+	if importPath == "" || name == "" {
+		return goType{}, fmt.Errorf("invalid Go type %q, want an import path and a type name, such as time.Time", s)
+	}
+	return goType{importPath: importPath, expr: importPath[strings.LastIndex(importPath, "/")+1:] + "." + name}, nil
+}
+
+// generator generates Go code for the operations of a document.
+type generator struct {
+	schema  *graphql.Schema
+	doc     *parser.Document
+	pkg     string            // Name of the generated package.
+	scalars map[string]goType // Go types of custom scalars, by name.
+
+	buf     bytes.Buffer
+	imports map[string]bool     // Import paths used by the generated code.
+	named   map[string]bool     // Names of schema types declared as Go types so far.
+	pending []*graphql.Type     // Schema types to declare as Go types.
+	spreads map[string]struct{} // Fragments being expanded, to detect cycles.
+}
+
+// generate returns the Go source code of package pkg, which declares
+// for each operation in doc the struct its data is decoded into,
+// a struct of its variables, and a function executing it.
+func generate(schema *graphql.Schema, doc *parser.Document, pkg string, scalars map[string]goType) ([]byte, error) {
+	g := &generator{
+		schema:  schema,
+		doc:     doc,
+		pkg:     pkg,
+		scalars: scalars,
+		imports: map[string]bool{"context": true, "github.com/nobody05/graphql_go_client": true},
+		named:   make(map[string]bool),
+		spreads: make(map[string]struct{}),
+	}
+	if len(doc.Operations) == 0 {
+		return nil, fmt.Errorf("no operations")
+	}
+	seen := make(map[string]bool)
+	for _, op := range doc.Operations {
+		if op.Name == "" {
+			return nil, fmt.Errorf("operations must be named")
+		}
+		if seen[op.Name] {
+			return nil, fmt.Errorf("operation %s is defined more than once", op.Name)
+		}
+		seen[op.Name] = true
+		if err := g.operation(op); err != nil {
+			return nil, fmt.Errorf("operation %s: %v", op.Name, err)
+		}
+	}
+	for len(g.pending) > 0 {
+		t := g.pending[0]
+		g.pending = g.pending[1:]
+		if err := g.namedType(t); err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by graphqlgen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	paths := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if isStd(paths[i]) != isStd(paths[j]) {
+			return isStd(paths[i])
+		}
+		return paths[i] < paths[j]
+	})
+	std := true
+	for _, path := range paths {
+		if std && !isStd(path) {
+			// Separate third-party imports from standard library ones.
+			std = false
+			out.WriteString("\n")
+		}
+		if path == "github.com/nobody05/graphql_go_client" {
+			fmt.Fprintf(&out, "graphql %q\n", path)
+		} else {
+			fmt.Fprintf(&out, "%q\n", path)
+		}
+	}
+	out.WriteString(")\n")
+	out.Write(g.buf.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v\n%s", err, out.Bytes())
+	}
+	return src, nil
+}
+
+// isStd reports whether the package with the given import path
+// is in the standard library, whose paths have no dot in their first element.
+func isStd(path string) bool {
+	return !strings.Contains(strings.Split(path, "/")[0], ".")
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// operation generates the declarations for op.
+func (g *generator) operation(op *parser.Operation) error {
+	var root *graphql.TypeRef
+	var suffix, method string
+	switch op.Type {
+	case "query":
+		root, suffix, method = g.schema.QueryType, "Query", "NamedQuery"
+	case "mutation":
+		root, suffix, method = g.schema.MutationType, "Mutation", "NamedMutate"
+	case "subscription":
+		root, suffix, method = g.schema.SubscriptionType, "Subscription", "Subscribe"
+	}
+	if root == nil {
+		return fmt.Errorf("schema has no %s type", op.Type)
+	}
+	t := g.schema.Type(root.Name)
+	if t == nil {
+		return fmt.Errorf("schema has no type %s", root.Name)
+	}
+	dataType := op.Name + suffix
+	g.printf("\n// %s is the data of the %s %s.\ntype %s ", dataType, op.Name, op.Type, dataType)
+	if err := g.selectionSet(t, op.SelectionSet); err != nil {
+		return err
+	}
+	g.printf("\n")
+
+	varsType := op.Name + "Variables"
+	if len(op.Variables) > 0 {
+		g.printf("\n// %s are the variables of the %s %s.\ntype %s struct {\n", varsType, op.Name, op.Type, varsType)
+		for _, v := range op.Variables {
+			typ, err := g.inputType(v.Type)
+			if err != nil {
+				return fmt.Errorf("variable $%s: %v", v.Name, err)
+			}
+			g.printf("%s %s\n", exported(v.Name), typ)
+		}
+		g.printf("}\n")
+	}
+
+	params, vars := "", "nil"
+	if len(op.Variables) > 0 {
+		params = ", variables " + varsType
+		var sb strings.Builder
+		sb.WriteString("map[string]interface{}{\n")
+		for _, v := range op.Variables {
+			fmt.Fprintf(&sb, "%q: variables.%s,\n", v.Name, exported(v.Name))
+		}
+		sb.WriteString("}")
+		vars = sb.String()
+	}
+	if op.Type == "subscription" {
+		g.printf("\n// %s subscribes to the %s subscription. The data of its events\n// can be decoded into a %s.\n", op.Name, op.Name, dataType)
+		g.printf("func %s(ctx context.Context, client *graphql.Client%s, opts ...graphql.RequestOption) (<-chan graphql.SubscriptionMessage, error) {\n", op.Name, params)
+		g.printf("return client.%s(ctx, &%s{}, %s, append([]graphql.RequestOption{graphql.WithOperationName(%q)}, opts...)...)\n}\n", method, dataType, vars, op.Name)
+		return nil
+	}
+	g.printf("\n// %s executes the %s %s.\n", op.Name, op.Name, op.Type)
+	g.printf("func %s(ctx context.Context, client *graphql.Client%s, opts ...graphql.RequestOption) (*%s, error) {\n", op.Name, params, dataType)
+	g.printf("var data %s\nerr := client.%s(ctx, %q, &data, %s, opts...)\nreturn &data, err\n}\n", dataType, method, op.Name, vars)
+	return nil
+}
+
+// selection is a field or inline fragment of a struct,
+// merged from all selections with the same response key.
+type selection struct {
+	key   string             // Response key, or type condition and directives of inline fragments.
+	field *parser.Field      // Field, or nil for inline fragments.
+	on    *graphql.Type      // Type of inline fragments.
+	tag   string             // Value of the graphql struct tag.
+	set   []parser.Selection // Merged selection sets.
+}
+
+// selectionSet generates a struct type for set, selected on type t.
+func (g *generator) selectionSet(t *graphql.Type, set []parser.Selection) error {
+	sels, err := g.collect(t, set, nil)
+	if err != nil {
+		return err
+	}
+	g.printf("struct {\n")
+	for _, s := range sels {
+		if s.field == nil {
+			g.printf("%s ", exported(s.on.Name))
+			if err := g.selectionSet(s.on, s.set); err != nil {
+				return err
+			}
+			g.printf(" `graphql:%s`\n", strconv.Quote(s.tag))
+			continue
+		}
+		if s.field.Name == "__typename" {
+			g.printf("%s string `graphql:%s`\n", exported(s.key), strconv.Quote(s.tag))
+			continue
+		}
+		f := t.Field(s.field.Name)
+		if f == nil {
+			return fmt.Errorf("type %s has no field %s", t.Name, s.field.Name)
+		}
+		g.printf("%s ", exported(s.key))
+		if err := g.outputType(f.Type, s.set, true); err != nil {
+			return fmt.Errorf("%s.%s: %v", t.Name, f.Name, err)
+		}
+		g.printf(" `graphql:%s`\n", strconv.Quote(s.tag))
+	}
+	g.printf("}")
+	return nil
+}
+
+// collect collects the selections of set on type t into sels, merging
+// those with the same response key and expanding fragment spreads and
+// inline fragments on t itself.
+func (g *generator) collect(t *graphql.Type, set []parser.Selection, sels []*selection) ([]*selection, error) {
+	find := func(key string) *selection {
+		for _, s := range sels {
+			if s.key == key {
+				return s
+			}
+		}
+		return nil
+	}
+	for _, sel := range set {
+		var err error
+		switch sel := sel.(type) {
+		case *parser.Field:
+			key := sel.ResponseKey()
+			if s := find(key); s != nil {
+				if s.field.Name != sel.Name {
+					return nil, fmt.Errorf("fields %s and %s both have response key %s", s.field.Name, sel.Name, key)
+				}
+				s.set = append(s.set, sel.SelectionSet...)
+				continue
+			}
+			sels = append(sels, &selection{key: key, field: sel, tag: fieldTag(sel), set: sel.SelectionSet})
+		case *parser.FragmentSpread:
+			f := g.doc.Fragment(sel.Name)
+			if f == nil {
+				return nil, fmt.Errorf("undefined fragment %s", sel.Name)
+			}
+			if _, ok := g.spreads[f.Name]; ok {
+				return nil, fmt.Errorf("fragment %s spreads itself", f.Name)
+			}
+			g.spreads[f.Name] = struct{}{}
+			sels, err = g.fragment(t, f.TypeCondition, sel.Directives, f.SelectionSet, sels, find)
+			delete(g.spreads, f.Name)
+		case *parser.InlineFragment:
+			sels, err = g.fragment(t, sel.TypeCondition, sel.Directives, sel.SelectionSet, sels, find)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sels, nil
+}
+
+// fragment collects the selections of a fragment with the given type
+// condition and directives into sels. Fragments on t itself without
+// directives are expanded in place.
+func (g *generator) fragment(t *graphql.Type, on string, directives []*parser.Directive, set []parser.Selection, sels []*selection, find func(string) *selection) ([]*selection, error) {
+	if (on == "" || on == t.Name) && len(directives) == 0 {
+		return g.collect(t, set, sels)
+	}
+	if on == "" {
+		on = t.Name
+	}
+	typ := g.schema.Type(on)
+	if typ == nil {
+		return nil, fmt.Errorf("undefined type %s", on)
+	}
+	tag := "... on " + on + directivesString(directives)
+	if s := find(tag); s != nil {
+		s.set = append(s.set, set...)
+		return sels, nil
+	}
+	return append(sels, &selection{key: tag, on: typ, tag: tag, set: set}), nil
+}
+
+// fieldTag returns the value of the graphql struct tag for f,
+// such as "repo: repository(owner: $owner) @include(if: $withRepo)".
+func fieldTag(f *parser.Field) string {
+	var sb strings.Builder
+	if f.Alias != "" {
+		sb.WriteString(f.Alias + ": ")
+	}
+	sb.WriteString(f.Name)
+	sb.WriteString(argumentsString(f.Arguments))
+	sb.WriteString(directivesString(f.Directives))
+	return sb.String()
+}
+
+func argumentsString(args []*parser.Argument) string {
+	if len(args) == 0 {
+		return ""
+	}
+	s := make([]string, len(args))
+	for i, a := range args {
+		s[i] = a.Name + ": " + a.Value.String()
+	}
+	return "(" + strings.Join(s, ", ") + ")"
+}
+
+func directivesString(ds []*parser.Directive) string {
+	var sb strings.Builder
+	for _, d := range ds {
+		sb.WriteString(" @" + d.Name + argumentsString(d.Arguments))
+	}
+	return sb.String()
+}
+
+// outputType generates the Go type of a field of type r, with selection
+// set set. If nullable is true, r is nullable unless it's non-null.
+func (g *generator) outputType(r graphql.TypeRef, set []parser.Selection, nullable bool) error {
+	switch r.Kind {
+	case graphql.TypeKindNonNull:
+		return g.outputType(*r.OfType, set, false)
+	case graphql.TypeKindList:
+		g.printf("[]")
+		return g.outputType(*r.OfType, set, true)
+	}
+	t := g.schema.Type(r.Name)
+	if t == nil {
+		return fmt.Errorf("undefined type %s", r.Name)
+	}
+	if nullable {
+		g.printf("*")
+	}
+	switch t.Kind {
+	case graphql.TypeKindObject, graphql.TypeKindInterface, graphql.TypeKindUnion:
+		if len(set) == 0 {
+			return fmt.Errorf("%s %s needs a selection set", strings.ToLower(string(t.Kind)), t.Name)
+		}
+		return g.selectionSet(t, set)
+	}
+	if len(set) > 0 {
+		return fmt.Errorf("%s %s can't have a selection set", strings.ToLower(string(t.Kind)), t.Name)
+	}
+	g.printf("%s", g.leafType(t, false))
+	return nil
+}
+
+// leafType returns the Go type of scalar or enum type t. Built-in scalars
+// are the predeclared Go types in responses, and the scalar types of
+// package graphql in variables, so that they're declared correctly.
+func (g *generator) leafType(t *graphql.Type, input bool) string {
+	switch t.Name {
+	case "String":
+		if input {
+			return "graphql.String"
+		}
+		return "string"
+	case "Int":
+		if input {
+			return "graphql.Int"
+		}
+		return "int"
+	case "Float":
+		if input {
+			return "graphql.Float"
+		}
+		return "float64"
+	case "Boolean":
+		if input {
+			return "graphql.Boolean"
+		}
+		return "bool"
+	case "ID":
+		return "graphql.ID"
+	}
+	if s, ok := g.scalars[t.Name]; ok {
+		if s.importPath != "" {
+			g.imports[s.importPath] = true
+		}
+		return s.expr
+	}
+	g.declare(t)
+	return exported(t.Name)
+}
+
+// inputType returns the Go type of a variable or input object field
+// of type r. Nullable types are Optional, so that they can be omitted.
+func (g *generator) inputType(r *parser.Type) (string, error) {
+	typ, err := g.inputElemType(r)
+	if err != nil {
+		return "", err
+	}
+	if !r.NonNull {
+		typ = "graphql.Optional[" + typ + "]"
+	}
+	return typ, nil
+}
+
+// inputElemType returns the Go type of values of type r, ignoring
+// whether r is non-null. Nullable list elements are pointers.
+func (g *generator) inputElemType(r *parser.Type) (string, error) {
+	if r.Elem != nil {
+		elem, err := g.inputElemType(r.Elem)
+		if err != nil {
+			return "", err
+		}
+		if !r.Elem.NonNull {
+			elem = "*" + elem
+		}
+		return "[]" + elem, nil
+	}
+	t := g.schema.Type(r.Name)
+	if t == nil {
+		return "", fmt.Errorf("undefined type %s", r.Name)
+	}
+	switch t.Kind {
+	case graphql.TypeKindScalar, graphql.TypeKindEnum:
+		return g.leafType(t, true), nil
+	case graphql.TypeKindInputObject:
+		g.declare(t)
+		return exported(t.Name), nil
+	}
+	return "", fmt.Errorf("%s %s isn't an input type", strings.ToLower(string(t.Kind)), t.Name)
+}
+
+// declare queues schema type t to be declared as a Go type, once.
+func (g *generator) declare(t *graphql.Type) {
+	if !g.named[t.Name] {
+		g.named[t.Name] = true
+		g.pending = append(g.pending, t)
+	}
+}
+
+// namedType declares the Go type for custom scalar, enum or input object type t.
+func (g *generator) namedType(t *graphql.Type) error {
+	name := exported(t.Name)
+	g.printf("\n")
+	if t.Description != "" {
+		g.printf("%s\n", comment(t.Description))
+	}
+	switch t.Kind {
+	case graphql.TypeKindScalar:
+		if t.Description == "" {
+			g.printf("// %s is the custom scalar %s. Map it to another Go type with -scalar.\n", name, t.Name)
+		}
+		g.printf("type %s string\n", name)
+	case graphql.TypeKindEnum:
+		if t.Description == "" {
+			g.printf("// %s is the enum %s.\n", name, t.Name)
+		}
+		g.printf("type %s string\n\n// Values of %s.\nconst (\n", name, name)
+		for _, v := range t.EnumValues {
+			if v.IsDeprecated {
+				g.printf("// Deprecated: %s\n", v.DeprecationReason)
+			}
+			g.printf("%s%s %s = %q\n", name, exported(strings.ToLower(v.Name)), name, v.Name)
+		}
+		g.printf(")\n")
+		var values []string
+		for _, v := range t.EnumValues {
+			values = append(values, strconv.Quote(v.Name))
+		}
+		g.printf("\n// GraphQLEnumValues returns the values of the enum %s.\n", t.Name)
+		g.printf("func (%s) GraphQLEnumValues() []string { return []string{%s} }\n", name, strings.Join(values, ", "))
+	case graphql.TypeKindInputObject:
+		if t.Description == "" {
+			g.printf("// %s is the input object %s.\n", name, t.Name)
+		}
+		g.printf("type %s struct {\n", name)
+		for _, f := range t.InputFields {
+			r, err := typeRefToType(f.Type)
+			if err != nil {
+				return err
+			}
+			typ, err := g.inputType(r)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %v", t.Name, f.Name, err)
+			}
+			g.printf("%s %s `json:%q`\n", exported(f.Name), typ, f.Name)
+		}
+		g.printf("}\n")
+	}
+	if name != t.Name {
+		g.printf("\n// GraphQLType returns the name of the GraphQL type of %s.\n", name)
+		g.printf("func (%s) GraphQLType() string { return %q }\n", name, t.Name)
+	}
+	return nil
+}
+
+// typeRefToType converts a type reference of the schema to one of a document.
+func typeRefToType(r graphql.TypeRef) (*parser.Type, error) {
+	switch r.Kind {
+	case graphql.TypeKindNonNull:
+		if r.OfType == nil {
+			return nil, fmt.Errorf("non-null type without element type")
+		}
+		t, err := typeRefToType(*r.OfType)
+		if err != nil {
+			return nil, err
+		}
+		t.NonNull = true
+		return t, nil
+	case graphql.TypeKindList:
+		if r.OfType == nil {
+			return nil, fmt.Errorf("list type without element type")
+		}
+		elem, err := typeRefToType(*r.OfType)
+		if err != nil {
+			return nil, err
+		}
+		return &parser.Type{Elem: elem}, nil
+	}
+	return &parser.Type{Name: r.Name}, nil
+}
+
+// initialisms are the common initialisms that Go identifiers spell in
+// upper case, as in "UserID".
+var initialisms = []string{"Id", "Url", "Uri", "Api", "Json", "Html", "Http"}
+
+// exported returns an exported Go identifier for GraphQL name s,
+// such as "ViewerLogin" for "viewer_login", or "UserID" for "userId".
+func exported(s string) string {
+	var words []string
+	for _, word := range strings.Split(s, "_") {
+		if word == "" {
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(word)
+		words = append(words, string(unicode.ToUpper(r))+word[size:])
+	}
+	if len(words) == 0 {
+		return "X" + s
+	}
+	for i, word := range words {
+		for _, initialism := range initialisms {
+			if strings.HasSuffix(word, initialism) {
+				words[i] = strings.TrimSuffix(word, initialism) + strings.ToUpper(initialism)
+				break
+			}
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// comment returns s as a Go comment.
+func comment(s string) string {
+	return "// " + strings.ReplaceAll(s, "\n", "\n// ")
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"testing"
+
+	graphql "github.com/nobody05/graphql_go_client"
+	"github.com/nobody05/graphql_go_client/internal/parser"
+)
+
+func TestGenerate(t *testing.T) {
+	schema, err := graphql.ParseSchema(`
+		type Query {
+			repository(owner: String!, name: String!): Repository
+			search(filter: IssueFilter): [SearchResult!]!
+		}
+		type Repository {
+			id: ID!
+			name: String!
+			updated_at: DateTime
+			issues(first: Int): [Issue]
+		}
+		type Issue { title: String! state: IssueState! }
+		union SearchResult = Issue | Repository
+		enum IssueState { OPEN CLOSED @deprecated(reason: "Use OPEN.") }
+		input IssueFilter { states: [IssueState!], label_name: String! }
+		scalar DateTime
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := parser.Parse(`
+		query GetRepo($owner: String!, $first: Int) {
+			repo: repository(owner: $owner, name: "graphql") {
+				...RepoFields
+				issues(first: $first) { title }
+				issues(first: $first) { state }
+			}
+		}
+		fragment RepoFields on Repository { id name updated_at }
+		query Search($filter: IssueFilter) {
+			search(filter: $filter) {
+				__typename
+				... on Issue { title }
+			}
+		}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := generate(schema, doc, "github", map[string]goType{"DateTime": {importPath: "time", expr: "time.Time"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "// Code generated by graphqlgen. DO NOT EDIT.\n\n" + `package github
+
+import (
+	"context"
+	"time"
+
+	graphql "github.com/nobody05/graphql_go_client"
+)
+
+// GetRepoQuery is the data of the GetRepo query.
+type GetRepoQuery struct {
+	Repo *struct {
+		ID        graphql.ID ` + "`" + `graphql:"id"` + "`" + `
+		Name      string     ` + "`" + `graphql:"name"` + "`" + `
+		UpdatedAt *time.Time ` + "`" + `graphql:"updated_at"` + "`" + `
+		Issues    []*struct {
+			Title string     ` + "`" + `graphql:"title"` + "`" + `
+			State IssueState ` + "`" + `graphql:"state"` + "`" + `
+		} ` + "`" + `graphql:"issues(first: $first)"` + "`" + `
+	} ` + "`" + `graphql:"repo: repository(owner: $owner, name: \"graphql\")"` + "`" + `
+}
+
+// GetRepoVariables are the variables of the GetRepo query.
+type GetRepoVariables struct {
+	Owner graphql.String
+	First graphql.Optional[graphql.Int]
+}
+
+// GetRepo executes the GetRepo query.
+func GetRepo(ctx context.Context, client *graphql.Client, variables GetRepoVariables, opts ...graphql.RequestOption) (*GetRepoQuery, error) {
+	var data GetRepoQuery
+	err := client.NamedQuery(ctx, "GetRepo", &data, map[string]interface{}{
+		"owner": variables.Owner,
+		"first": variables.First,
+	}, opts...)
+	return &data, err
+}
+
+// SearchQuery is the data of the Search query.
+type SearchQuery struct {
+	Search []struct {
+		Typename string ` + "`" + `graphql:"__typename"` + "`" + `
+		Issue    struct {
+			Title string ` + "`" + `graphql:"title"` + "`" + `
+		} ` + "`" + `graphql:"... on Issue"` + "`" + `
+	} ` + "`" + `graphql:"search(filter: $filter)"` + "`" + `
+}
+
+// SearchVariables are the variables of the Search query.
+type SearchVariables struct {
+	Filter graphql.Optional[IssueFilter]
+}
+
+// Search executes the Search query.
+func Search(ctx context.Context, client *graphql.Client, variables SearchVariables, opts ...graphql.RequestOption) (*SearchQuery, error) {
+	var data SearchQuery
+	err := client.NamedQuery(ctx, "Search", &data, map[string]interface{}{
+		"filter": variables.Filter,
+	}, opts...)
+	return &data, err
+}
+
+// IssueState is the enum IssueState.
+type IssueState string
+
+// Values of IssueState.
+const (
+	IssueStateOpen IssueState = "OPEN"
+	// Deprecated: Use OPEN.
+	IssueStateClosed IssueState = "CLOSED"
+)
+
+// GraphQLEnumValues returns the values of the enum IssueState.
+func (IssueState) GraphQLEnumValues() []string { return []string{"OPEN", "CLOSED"} }
+
+// IssueFilter is the input object IssueFilter.
+type IssueFilter struct {
+	States    graphql.Optional[[]IssueState] ` + "`" + `json:"states"` + "`" + `
+	LabelName graphql.String                 ` + "`" + `json:"label_name"` + "`" + `
+}
+`
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGenerate_error(t *testing.T) {
+	schema, err := graphql.ParseSchema(`type Query { viewer: User } type User { login: String! }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`{ viewer { login } }`, "operations must be named"},
+		{`query Q { viewer { name } }`, "operation Q: Query.viewer: type User has no field name"},
+		{`query Q { viewer }`, "operation Q: Query.viewer: object User needs a selection set"},
+		{`query Q { viewer { ...F } } fragment F on User { ...F }`, "operation Q: Query.viewer: fragment F spreads itself"},
+		{`mutation M { viewer { login } }`, "operation M: schema has no mutation type"},
+	}
+	for _, tc := range tests {
+		doc, err := parser.Parse(tc.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = generate(schema, doc, "p", nil)
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("%s: got error: %v, want: %s", tc.in, err, tc.want)
+		}
+	}
+}
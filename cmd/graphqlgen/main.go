@@ -0,0 +1,161 @@
+// graphqlgen generates Go code for GraphQL operations, to be used with
+// package graphql.
+//
+// It reads a schema, either in the GraphQL schema definition language
+// or as the JSON result of an introspection query, and files with named
+// operations and fragments. For each operation, it generates the struct
+// that its data is decoded into, following the struct conventions of
+// package graphql, a struct of its variables, and a function executing it.
+// Enums, input objects and custom scalars used by the operations are
+// declared as Go types.
+//
+// Usage:
+//
+//	graphqlgen -schema schema.graphql -package github -o operations.go queries.graphql...
+//
+// It's meant to be run by go generate:
+//
+//	//go:generate go run github.com/nobody05/graphql_go_client/cmd/graphqlgen -schema schema.graphql -o operations.go queries.graphql
+//
+// Custom scalars are declared as string types by default. The -scalar flag
+// maps them to other Go types, such as -scalar DateTime=time.Time. Types
+// whose name differs from the scalar's must be registered with
+// graphql.RegisterScalar for variables of their type to be declared correctly.
+//
+// Default values of variables aren't declared in the documents derived
+// from the structs, so omitted variables are null.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	graphql "github.com/nobody05/graphql_go_client"
+	"github.com/nobody05/graphql_go_client/internal/parser"
+)
+
+var (
+	schemaFlag  = flag.String("schema", "", "Schema file, in SDL (.graphql, .graphqls) or as an introspection result (.json).")
+	packageFlag = flag.String("package", "", "Name of the generated package. Defaults to the name of the output file's directory.")
+	outputFlag  = flag.String("o", "", "Output file. Defaults to standard output.")
+	scalarFlags = make(scalarMap)
+)
+
+func init() {
+	flag.Var(scalarFlags, "scalar", "Maps a custom scalar to a Go type, such as DateTime=time.Time. May be repeated.")
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: graphqlgen -schema schema.graphql [flags] operations.graphql...")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if *schemaFlag == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "graphqlgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	schema, err := readSchema(*schemaFlag)
+	if err != nil {
+		return err
+	}
+	var src strings.Builder
+	for _, name := range flag.Args() {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if _, err := parser.Parse(string(b)); err != nil {
+			return fmt.Errorf("%s:%v", name, err)
+		}
+		src.Write(b)
+		src.WriteString("\n")
+	}
+	doc, err := parser.Parse(src.String())
+	if err != nil {
+		return err
+	}
+	pkg := *packageFlag
+	if pkg == "" {
+		dir, err := filepath.Abs(filepath.Dir(*outputFlag))
+		if err != nil {
+			return err
+		}
+		pkg = strings.NewReplacer("-", "_", ".", "_").Replace(filepath.Base(dir))
+	}
+	out, err := generate(schema, doc, pkg, scalarFlags)
+	if err != nil {
+		return err
+	}
+	if *outputFlag == "" {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	return ioutil.WriteFile(*outputFlag, out, 0644)
+}
+
+// readSchema reads the schema in the named file, which is either
+// an introspection result if its name ends in .json, or in SDL.
+func readSchema(name string) (*graphql.Schema, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Ext(name) != ".json" {
+		return graphql.ParseSchema(string(b))
+	}
+	var result struct {
+		Data struct {
+			Schema *graphql.Schema `json:"__schema"`
+		} `json:"data"`
+		Schema *graphql.Schema `json:"__schema"`
+	}
+	if err := json.Unmarshal(b, &result); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	switch {
+	case result.Schema != nil:
+		return result.Schema, nil
+	case result.Data.Schema != nil:
+		return result.Data.Schema, nil
+	}
+	return nil, fmt.Errorf("%s: no __schema in introspection result", name)
+}
+
+// scalarMap is the flag.Value of -scalar flags.
+type scalarMap map[string]goType
+
+func (m scalarMap) String() string {
+	var s []string
+	for name, t := range m {
+		s = append(s, name+"="+t.expr)
+	}
+	return strings.Join(s, ",")
+}
+
+func (m scalarMap) Set(value string) error {
+	i := strings.Index(value, "=")
+	if i < 0 {
+		return fmt.Errorf("want Scalar=GoType, such as DateTime=time.Time")
+	}
+	t, err := parseGoType(value[i+1:])
+	if err != nil {
+		return err
+	}
+	m[value[:i]] = t
+	return nil
+}
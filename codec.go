@@ -0,0 +1,36 @@
+package graphql
+
+import "encoding/json"
+
+// Codec encodes and decodes JSON, like encoding/json. Clients encode the
+// bodies of their HTTP requests and decode those of responses with the codec
+// set by WithCodec, so that faster implementations, such as jsoniter, go-json
+// or sonic, can be plugged into hot paths. Response data is still decoded
+// into query structs by the client itself, which handles GraphQL fragments.
+//
+// Implementations must be compatible with encoding/json: they must honor
+// json struct tags, json.Marshaler and json.Unmarshaler implementations,
+// and json.Number, and copy json.RawMessage values rather than keep
+// references to the data being decoded. graphqltest.TestCodec checks
+// implementations against these requirements.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// StdCodec is the Codec using encoding/json, which clients use by default.
+var StdCodec Codec = stdCodec{}
+
+type stdCodec struct{}
+
+func (stdCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (stdCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// WithCodec makes the client encode requests and decode responses with codec,
+// rather than StdCodec.
+func WithCodec(codec Codec) ClientOption {
+	return func(c *Client) {
+		c.codec = codec
+	}
+}
@@ -0,0 +1,62 @@
+package graphql
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+)
+
+// gzipBody returns body compressed with gzip.
+func gzipBody(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// acceptGzip asks the server for a gzip-compressed response,
+// unless req already specifies acceptable encodings.
+func acceptGzip(req *http.Request) {
+	if req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+}
+
+// decompress replaces the body of resp with its decompressed content,
+// if the server compressed it with gzip.
+//
+// The http.Transport does so itself when it asks for gzip on its own,
+// but not when the request carries an explicit Accept-Encoding header,
+// nor do custom transports.
+func decompress(resp *http.Response) error {
+	if resp.Uncompressed || resp.Header.Get("Content-Encoding") != "gzip" {
+		return nil
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return err
+	}
+	resp.Body = gzipReadCloser{Reader: zr, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// gzipReadCloser reads a gzip-compressed response body.
+type gzipReadCloser struct {
+	*gzip.Reader
+	body io.Closer // Underlying, compressed body.
+}
+
+func (r gzipReadCloser) Close() error {
+	return r.body.Close()
+}
@@ -0,0 +1,108 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nobody05/graphql_go_client"
+)
+
+// TestClient_concurrentUse exercises a client from many goroutines at once,
+// with its caches, middleware and options in play, for go test -race.
+func TestClient_concurrentUse(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		var body struct {
+			Query     string
+			Variables map[string]string
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasPrefix(body.Query, "mutation") {
+			mustWrite(w, `{"data": {"addStar": {"count": 1}}}`)
+			return
+		}
+		mustWrite(w, fmt.Sprintf(`{"data": {"user": {"__typename": "User", "id": %q, "login": "user-%s"}}}`, body.Variables["id"], body.Variables["id"]))
+	})
+	cache := graphql.NewCache(time.Minute)
+	params := map[string]interface{}{"token": "secret"}
+	client := graphql.NewClient("/graphql",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithCache(cache),
+		graphql.WithSingleflight(),
+		graphql.WithConnectionParams(params),
+		graphql.WithRetry(graphql.RetryPolicy{MaxAttempts: 2}),
+	)
+	params["token"] = "changed" // Options copy their arguments.
+
+	var used int32
+	counting := func(next graphql.Doer) graphql.Doer {
+		return graphql.DoerFunc(func(ctx context.Context, req *graphql.Request) (*graphql.Response, error) {
+			atomic.AddInt32(&used, 1)
+			return next.Do(ctx, req)
+		})
+	}
+
+	type userQuery struct {
+		User struct {
+			Typename string `graphql:"__typename"`
+			ID       string `graphql:"id"`
+			Login    string `graphql:"login"`
+		} `graphql:"user(id:$id)"`
+	}
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		i, id := i, fmt.Sprint(i%5)
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			var q userQuery
+			if err := client.NamedQuery(context.Background(), "GetUser", &q, map[string]interface{}{"id": id}); err != nil {
+				t.Error(err)
+			} else if got, want := q.User.Login, "user-"+id; got != want {
+				t.Errorf("got login: %q, want: %q", got, want)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			q, err := graphql.Query[userQuery](context.Background(), client, map[string]interface{}{"id": id}, graphql.WithCachePolicy(graphql.NetworkOnly))
+			if err != nil {
+				t.Error(err)
+			} else if got, want := q.User.Login, "user-"+id; got != want {
+				t.Errorf("got login: %q, want: %q", got, want)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			var m struct {
+				AddStar struct {
+					Count int `graphql:"count"`
+				} `graphql:"addStar"`
+			}
+			if err := client.Mutate(context.Background(), &m, nil); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if i%10 == 0 {
+				client.Use(counting)
+			}
+			cache.Evict("User", id)
+		}()
+	}
+	wg.Wait()
+	if atomic.LoadInt32(&used) == 0 {
+		t.Error("got no requests through middleware added concurrently")
+	}
+}
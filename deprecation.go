@@ -0,0 +1,61 @@
+package graphql
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// Deprecation is the selection of a field marked @deprecated
+// in the schema, found by Schema.Deprecations.
+type Deprecation struct {
+	// Path holds the response keys of the fields leading to the field,
+	// ending with its own, such as ["repository", "oldName"].
+	Path   []string
+	Type   string // Name of the type the field belongs to, such as "Repository".
+	Field  string // Name of the field, such as "oldName".
+	Reason string // Deprecation reason.
+}
+
+// String returns d in the form "repository.oldName: field
+// Repository.oldName is deprecated: Use name.".
+func (d Deprecation) String() string {
+	return strings.Join(d.Path, ".") + ": field " + d.Type + "." + d.Field + " is deprecated: " + d.Reason
+}
+
+// Deprecations returns the fields marked @deprecated in s that document
+// selects, so that their use can be migrated away from before the server
+// removes them. An error is returned only if document can't be parsed;
+// other problems, as reported by Validate, are ignored.
+func (s *Schema) Deprecations(document string) ([]Deprecation, error) {
+	deprecations, err := s.check(document)
+	if _, ok := err.(ValidationErrors); ok {
+		err = nil
+	}
+	return deprecations, err
+}
+
+// validate validates the document query against the schema set by
+// WithSchema, if any. The first time a document is validated, the
+// deprecated fields it selects are logged to the client's logger,
+// if any, at warn level.
+func (c *Client) validate(ctx context.Context, query string) error {
+	if c.validation == nil || query == "" {
+		return nil
+	}
+	deprecations, err := c.validation.validate(query)
+	if err != nil {
+		return err
+	}
+	if c.logger == nil {
+		return nil
+	}
+	for _, d := range deprecations {
+		c.logger.WarnContext(ctx, "graphql: deprecated field selected",
+			slog.String("path", strings.Join(d.Path, ".")),
+			slog.String("field", d.Type+"."+d.Field),
+			slog.String("reason", d.Reason),
+		)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package graphql
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ConnectionPool configures the pool of HTTP connections of a client,
+// as set by WithConnectionPool. The zero value of each field keeps the
+// setting of the HTTP client's transport.
+//
+// The defaults of http.DefaultTransport keep only 2 idle connections per
+// host, so high-throughput workloads against a single GraphQL server
+// otherwise keep opening and closing connections.
+type ConnectionPool struct {
+	// MaxIdleConns is the maximum number of idle connections
+	// across all hosts.
+	MaxIdleConns int
+
+	// MaxIdleConnsPerHost is the maximum number of idle connections
+	// kept per host.
+	MaxIdleConnsPerHost int
+
+	// MaxConnsPerHost limits the number of connections per host,
+	// including those in use. Requests wait for a connection
+	// once the limit is reached.
+	MaxConnsPerHost int
+
+	// IdleConnTimeout is how long idle connections are kept
+	// before being closed.
+	IdleConnTimeout time.Duration
+
+	// KeepAlive is the interval between TCP keep-alive probes
+	// of connections. A negative value disables them.
+	KeepAlive time.Duration
+
+	// DisableHTTP2 makes the client use HTTP/1.1 even with servers
+	// supporting HTTP/2, spreading requests over many connections
+	// rather than multiplexing them over one.
+	DisableHTTP2 bool
+}
+
+// unixSocketHost is the host of the HTTP URLs of servers listening on
+// a Unix domain socket.
+const unixSocketHost = "localhost"
+
+// parseUnixURL splits a URL of a server listening on a Unix domain socket,
+// such as "unix:///var/run/api.sock:/graphql", into the path of the socket
+// and the HTTP URL of the endpoint, with host unixSocketHost. It reports
+// whether rawURL is such a URL. The path of the endpoint defaults to "/".
+func parseUnixURL(rawURL string) (socket, httpURL string, ok bool) {
+	rest, ok := strings.CutPrefix(rawURL, "unix://")
+	if !ok {
+		return "", "", false
+	}
+	socket, path, _ := strings.Cut(rest, ":")
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return socket, "http://" + unixSocketHost + path, true
+}
+
+// tlsClientConfig returns a copy of base, or of an empty config if it's nil,
+// replaced by the config given via WithTLSConfig, if any, and presenting
+// the certificate given via WithClientCertificate, if any.
+func (c *Client) tlsClientConfig(base *tls.Config) *tls.Config {
+	if c.tlsConfig != nil {
+		base = c.tlsConfig
+	}
+	var config *tls.Config
+	if base != nil {
+		config = base.Clone()
+	} else {
+		config = &tls.Config{}
+	}
+	if c.clientCert != nil {
+		config.GetClientCertificate = c.clientCert
+	}
+	return config
+}
+
+// loadClientCertificate returns a function loading the certificate and
+// private key in the PEM files certFile and keyFile on each TLS handshake.
+func loadClientCertificate(certFile, keyFile string) func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("graphql: loading client certificate: %w", err)
+		}
+		return &cert, nil
+	}
+}
+
+// configureTransport returns a copy of httpClient whose transport is
+// configured by the client's options, such as WithConnectTimeout.
+// If the transport isn't an *http.Transport, httpClient is returned as is.
+func (c *Client) configureTransport(httpClient *http.Client) *http.Client {
+	rt := httpClient.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	t, ok := rt.(*http.Transport)
+	if !ok {
+		return httpClient
+	}
+	t = t.Clone()
+	if c.proxy != nil {
+		t.Proxy = c.proxy
+	}
+	if c.tlsConfig != nil || c.clientCert != nil {
+		t.TLSClientConfig = c.tlsClientConfig(t.TLSClientConfig)
+	}
+	if c.connectTimeout > 0 || c.pool != nil && c.pool.KeepAlive != 0 || c.socket != "" {
+		dialer := &net.Dialer{Timeout: c.connectTimeout, KeepAlive: 30 * time.Second}
+		if c.pool != nil && c.pool.KeepAlive != 0 {
+			dialer.KeepAlive = c.pool.KeepAlive
+		}
+		t.DialContext = dialer.DialContext
+		if c.socket != "" {
+			t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", c.socket)
+			}
+			t.Proxy = nil
+		}
+	}
+	if c.connectTimeout > 0 {
+		t.TLSHandshakeTimeout = c.connectTimeout
+	}
+	if p := c.pool; p != nil {
+		if p.MaxIdleConns > 0 {
+			t.MaxIdleConns = p.MaxIdleConns
+		}
+		if p.MaxIdleConnsPerHost > 0 {
+			t.MaxIdleConnsPerHost = p.MaxIdleConnsPerHost
+		}
+		if p.MaxConnsPerHost > 0 {
+			t.MaxConnsPerHost = p.MaxConnsPerHost
+		}
+		if p.IdleConnTimeout > 0 {
+			t.IdleConnTimeout = p.IdleConnTimeout
+		}
+		if p.DisableHTTP2 {
+			// A non-nil, empty TLSNextProto disables HTTP/2,
+			// which mustn't be negotiated via ALPN either.
+			t.ForceAttemptHTTP2 = false
+			t.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+			if t.TLSClientConfig != nil {
+				t.TLSClientConfig = t.TLSClientConfig.Clone()
+				var protos []string
+				for _, p := range t.TLSClientConfig.NextProtos {
+					if p != "h2" {
+						protos = append(protos, p)
+					}
+				}
+				t.TLSClientConfig.NextProtos = protos
+			}
+		}
+	}
+	hc := *httpClient
+	hc.Transport = t
+	return &hc
+}
@@ -0,0 +1,321 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nobody05/graphql_go_client/internal/parser"
+)
+
+// Variable refers to a variable of the operation in the arguments of
+// directives applied via WithOperationDirective and WithFieldDirective,
+// such as Variable("ttl") for $ttl.
+type Variable string
+
+// appliedDirective is a directive applied to an operation or field
+// via WithOperationDirective or WithFieldDirective.
+type appliedDirective struct {
+	path string // Path of the field, or empty for the operation.
+	name string
+	args map[string]interface{}
+}
+
+// WithOperationDirective applies the directive @name, with the given
+// arguments, to the operation, such as @cached(ttl: 60) for servers
+// caching results:
+//
+//	graphql.WithOperationDirective("cached", map[string]interface{}{"ttl": 60})
+//
+// Arguments are encoded as GraphQL literals: Go strings as strings, except
+// for values of named string types other than String, which are enum values,
+// numbers and booleans as such, slices as lists, maps as input objects,
+// nil as null, and Variable as a reference to a variable.
+func WithOperationDirective(name string, args map[string]interface{}) RequestOption {
+	return func(o *requestOptions) {
+		o.directives = append(o.directives, appliedDirective{name: name, args: args})
+	}
+}
+
+// WithFieldDirective applies the directive @name, with the given arguments,
+// encoded as by WithOperationDirective, to the field at path in the
+// operation, after any directives of its struct field tag.
+//
+// path consists of response keys separated by dots, such as
+// "repository.issues", like with WithStreamedList. Fields selected within
+// named fragments can't be targeted.
+func WithFieldDirective(path, name string, args map[string]interface{}) RequestOption {
+	return func(o *requestOptions) {
+		o.directives = append(o.directives, appliedDirective{path: path, name: name, args: args})
+	}
+}
+
+// fieldArguments are arguments given to a field via WithFieldArguments.
+type fieldArguments struct {
+	path string
+	args map[string]interface{}
+}
+
+// WithFieldArguments gives the field at path in the operation the given
+// arguments, after any arguments of its struct field tag, encoded as GraphQL
+// literals as by WithOperationDirective, for arguments known only at run
+// time, without declaring variables for them:
+//
+//	graphql.WithFieldArguments("repository.issues", map[string]interface{}{
+//		"orderBy": map[string]interface{}{"field": IssueOrderFieldCreatedAt, "direction": OrderDirectionDesc},
+//	})
+//
+// Input objects may also be given as the structs used as variables, and
+// custom scalars as their Go types, which are encoded as for variables.
+// path is as for WithFieldDirective.
+func WithFieldArguments(path string, args map[string]interface{}) RequestOption {
+	return func(o *requestOptions) {
+		o.arguments = append(o.arguments, fieldArguments{path: path, args: args})
+	}
+}
+
+// document returns doc, the document of an operation, with the arguments,
+// directives and fragments of o.
+func (o *requestOptions) document(doc string) (string, error) {
+	doc, err := applyDirectives(doc, o.arguments, o.directives)
+	if err != nil {
+		return "", err
+	}
+	return appendFragments(doc, o.fragments), nil
+}
+
+// applyDirectives returns query, the document of an operation,
+// with arguments given to its fields and directives applied to it.
+func applyDirectives(query string, arguments []fieldArguments, directives []appliedDirective) (string, error) {
+	if len(arguments) == 0 && len(directives) == 0 {
+		return query, nil
+	}
+	doc, err := parser.Parse(query)
+	if err != nil {
+		return "", err
+	}
+	op := doc.Operations[0]
+	type insertion struct {
+		pos  int
+		text string
+	}
+	var insertions []insertion
+	for _, a := range arguments {
+		f := findField(op.SelectionSet, strings.Split(a.path, "."))
+		if f == nil {
+			return "", fmt.Errorf("graphql: no field at %q to give arguments to", a.path)
+		}
+		var buf bytes.Buffer
+		if err := writeObjectLiteral(&buf, reflect.ValueOf(a.args)); err != nil {
+			return "", fmt.Errorf("graphql: arguments of %s: %w", a.path, err)
+		}
+		text := "(" + buf.String() + ")"
+		if len(f.Arguments) > 0 {
+			text = "," + buf.String()
+		}
+		insertions = append(insertions, insertion{pos: f.ArgumentsEnd, text: text})
+	}
+	for _, d := range directives {
+		text, err := d.String()
+		if err != nil {
+			return "", err
+		}
+		pos := op.DirectivesEnd
+		if d.path != "" {
+			f := findField(op.SelectionSet, strings.Split(d.path, "."))
+			if f == nil {
+				return "", fmt.Errorf("graphql: no field at %q to apply @%s to", d.path, d.name)
+			}
+			pos = f.DirectivesEnd
+		}
+		insertions = append(insertions, insertion{pos: pos, text: text})
+	}
+	sort.SliceStable(insertions, func(i, j int) bool {
+		return insertions[i].pos < insertions[j].pos
+	})
+	var b strings.Builder
+	if strings.HasPrefix(query, "{") && insertions[0].pos == 0 {
+		// The query shorthand can't have directives.
+		b.WriteString("query")
+	}
+	last := 0
+	for _, in := range insertions {
+		b.WriteString(query[last:in.pos])
+		b.WriteString(in.text)
+		last = in.pos
+	}
+	b.WriteString(query[last:])
+	return b.String(), nil
+}
+
+// findField returns the field at path in set, looking into inline
+// fragments, or nil if there's none.
+func findField(set []parser.Selection, path []string) *parser.Field {
+	for _, sel := range set {
+		switch sel := sel.(type) {
+		case *parser.Field:
+			if sel.ResponseKey() != path[0] {
+				continue
+			}
+			if len(path) == 1 {
+				return sel
+			}
+			if f := findField(sel.SelectionSet, path[1:]); f != nil {
+				return f
+			}
+		case *parser.InlineFragment:
+			if f := findField(sel.SelectionSet, path); f != nil {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+// String returns d as written in documents, such as "@cached(ttl:60)".
+func (d appliedDirective) String() (string, error) {
+	var buf bytes.Buffer
+	buf.WriteString("@" + d.name)
+	if len(d.args) > 0 {
+		buf.WriteString("(")
+		if err := writeObjectLiteral(&buf, reflect.ValueOf(d.args)); err != nil {
+			return "", fmt.Errorf("graphql: arguments of @%s: %w", d.name, err)
+		}
+		buf.WriteString(")")
+	}
+	return buf.String(), nil
+}
+
+// writeLiteral writes v as a GraphQL literal to buf.
+func writeLiteral(buf *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		buf.WriteString("null")
+		return nil
+	}
+	switch e := v.Interface().(type) {
+	case Variable:
+		buf.WriteString("$" + string(e))
+		return nil
+	case json.Number:
+		buf.WriteString(e.String())
+		return nil
+	}
+	if v.Kind() != reflect.Map {
+		// Encode input objects, custom scalars and optional values as for
+		// variables. Maps are encoded by writeObjectLiteral.
+		e, ok, err := marshalInput(v)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return writeLiteral(buf, reflect.ValueOf(e))
+		}
+	}
+	if m, ok := v.Interface().(json.Marshaler); ok && v.Kind() == reflect.Struct {
+		// A scalar encoding itself, such as time.Time.
+		return writeJSONLiteral(buf, m)
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		return writeLiteral(buf, v.Elem())
+	case reflect.Bool:
+		buf.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		buf.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		buf.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		buf.WriteString(strconv.FormatFloat(v.Float(), 'g', -1, 64))
+	case reflect.String:
+		if t := v.Type(); t.PkgPath() != "" && t != reflect.TypeOf(String("")) {
+			// Enum value, such as IssueStateOpen.
+			if err := checkEnum(v); err != nil {
+				return err
+			}
+			buf.WriteString(v.String())
+			return nil
+		}
+		// JSON strings are valid GraphQL strings.
+		b, err := json.Marshal(v.String())
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		buf.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i != 0 {
+				buf.WriteString(",")
+			}
+			if err := writeLiteral(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("]")
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type %v", v.Type().Key())
+		}
+		buf.WriteString("{")
+		if err := writeObjectLiteral(buf, v); err != nil {
+			return err
+		}
+		buf.WriteString("}")
+	default:
+		return fmt.Errorf("unsupported type %v", v.Type())
+	}
+	return nil
+}
+
+// writeJSONLiteral writes the JSON encoding of m as a GraphQL literal to buf.
+func writeJSONLiteral(buf *bytes.Buffer, m json.Marshaler) error {
+	b, err := m.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var e interface{}
+	if err := dec.Decode(&e); err != nil {
+		return err
+	}
+	return writeLiteral(buf, reflect.ValueOf(e))
+}
+
+// writeObjectLiteral writes the entries of map v, sorted by key,
+// as the fields of an input object or arguments, such as "a:1,b:2".
+// Omitted optional values are left out.
+func writeObjectLiteral(buf *bytes.Buffer, v reflect.Value) error {
+	keys := v.MapKeys()
+	for i := 0; i < len(keys); i++ {
+		if isOmitted(v.MapIndex(keys[i])) {
+			keys = append(keys[:i], keys[i+1:]...)
+			i--
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	for i, k := range keys {
+		if i != 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString(k.String() + ":")
+		if err := writeLiteral(buf, v.MapIndex(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package graphql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/nobody05/graphql_go_client/internal/parser"
+)
+
+// MinifyDocument returns doc, a GraphQL document, without the characters
+// insignificant to its meaning: whitespace, line terminators, commas and
+// comments, except for single spaces needed to keep tokens apart. Strings
+// are left as is. It returns an error if doc can't be tokenized.
+//
+// Documents written by hand, such as in .graphql files, are minified so
+// that they take less bandwidth, and so that their hashes don't change
+// with their formatting.
+func MinifyDocument(doc string) (string, error) {
+	return parser.Minify(doc)
+}
+
+// FormatDocument returns doc, a GraphQL document, printed in the
+// conventional layout: one selection per line, indented by two spaces per
+// level of nesting, with arguments and variable definitions on the line of
+// their field or operation, and a blank line between definitions. Comments
+// are dropped. It returns an error if doc can't be tokenized.
+//
+// Documents derived from structs, as by ConstructQuery, are sent on a single
+// line; formatting them makes them readable in logs, and their changes
+// reviewable in the diffs of snapshot tests.
+func FormatDocument(doc string) (string, error) {
+	return parser.Format(doc)
+}
+
+// DocumentHash returns the hex-encoded SHA-256 hash of doc, as sent by
+// AutomaticPersistedQueries, used as document IDs by Manifest, and reported
+// by package otelgraphql. It's computed over doc as is, as servers do, so
+// doc should be minified first, if at all, by MinifyDocument.
+func DocumentHash(doc string) string {
+	sum := sha256.Sum256([]byte(doc))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,41 @@
+package graphql
+
+import (
+	"context"
+	"net/http"
+	"net/http/httputil"
+)
+
+// dumpKey is the context key for the function set by WithDump.
+type dumpKey struct{}
+
+// withDump returns ctx carrying fn, to be called by sendOnce, if fn isn't nil.
+func withDump(ctx context.Context, fn func(request, response []byte)) context.Context {
+	if fn == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, dumpKey{}, fn)
+}
+
+// dumpRequest dumps req if ctx carries a function set by WithDump,
+// and returns a function to call with the response received for it,
+// or nil if none was. Otherwise, it returns nil.
+func dumpRequest(ctx context.Context, req *http.Request) func(resp *http.Response) {
+	fn, ok := ctx.Value(dumpKey{}).(func(request, response []byte))
+	if !ok {
+		return nil
+	}
+	request, err := httputil.DumpRequestOut(req, true)
+	if err != nil {
+		// Such as for URLs without a host, which custom HTTP transports
+		// may accept; dump the request as is instead.
+		request, _ = httputil.DumpRequest(req, true)
+	}
+	return func(resp *http.Response) {
+		var response []byte
+		if resp != nil {
+			response, _ = httputil.DumpResponse(resp, true)
+		}
+		fn(request, response)
+	}
+}
@@ -0,0 +1,45 @@
+package graphql
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// GraphQLEnum is implemented by Go types of GraphQL enums listing the values
+// the enum allows, such as those generated by graphqlgen:
+//
+//	type IssueState string
+//
+//	const (
+//		IssueStateOpen   IssueState = "OPEN"
+//		IssueStateClosed IssueState = "CLOSED"
+//	)
+//
+//	func (IssueState) GraphQLEnumValues() []string { return []string{"OPEN", "CLOSED"} }
+//
+// Variables, input object fields and directive arguments of such types are
+// checked to hold one of the values before operations are sent. Responses
+// aren't checked, so that values added to an enum by the server don't break
+// clients, which decode them like any other value.
+//
+// Enum types are named in variable definitions after their Go type,
+// unless they implement GraphQLTyper.
+type GraphQLEnum interface {
+	GraphQLEnumValues() []string
+}
+
+var graphQLEnumType = reflect.TypeOf((*GraphQLEnum)(nil)).Elem()
+
+// checkEnum returns an error if v is of a string type implementing
+// GraphQLEnum, and doesn't hold one of the values of the enum.
+func checkEnum(v reflect.Value) error {
+	if v.Kind() != reflect.String || !v.Type().Implements(graphQLEnumType) {
+		return nil
+	}
+	for _, value := range v.Interface().(GraphQLEnum).GraphQLEnumValues() {
+		if v.String() == value {
+			return nil
+		}
+	}
+	return fmt.Errorf("graphql: invalid value %q of enum %s", v.String(), typeName(v.Type()))
+}
@@ -0,0 +1,91 @@
+package graphql
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Errors represents the "errors" array in a response from a GraphQL server.
+// If returned via error interface, the slice is expected to contain at least 1 element.
+//
+// Specification: https://spec.graphql.org/October2021/#sec-Errors.
+type Errors []Error
+
+// Error implements error interface. It joins the messages of all errors.
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Error is a single error in a response from a GraphQL server.
+type Error struct {
+	// Message describes the error.
+	Message string `json:"message"`
+
+	// Locations are the locations in the GraphQL document
+	// associated with the error, if any.
+	Locations []Location `json:"locations,omitempty"`
+
+	// Path is the path of the response field that experienced the error,
+	// if any. Its elements are field names (strings) and list indices
+	// (float64 numbers), e.g., ["repository", "issues", 3, "title"].
+	Path []interface{} `json:"path,omitempty"`
+
+	// Extensions holds additional information about the error, such as
+	// a machine-readable error code, as provided by the server.
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// Error implements error interface.
+func (e Error) Error() string {
+	return e.Message
+}
+
+// Location is a location in a GraphQL document.
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// HTTPError is returned when the server responds with
+// an HTTP status code other than 200 OK. Use errors.As to
+// inspect it, for instance to tell authentication failures
+// from rate limiting or server errors.
+type HTTPError struct {
+	StatusCode int         // E.g., 401.
+	Status     string      // E.g., "401 Unauthorized".
+	Body       []byte      // Response body.
+	Header     http.Header // Response headers.
+}
+
+// Error implements error interface.
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("non-200 OK status code: %v body: %q", e.Status, e.Body)
+}
+
+// newHTTPError returns an *HTTPError for resp, reading its body.
+func newHTTPError(resp *http.Response) *HTTPError {
+	body, _ := ioutil.ReadAll(resp.Body)
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       body,
+		Header:     resp.Header,
+	}
+}
+
+// ResponseTooLargeError is returned when the body of a response from
+// the server exceeds the limit set by WithMaxResponseBytes.
+type ResponseTooLargeError struct {
+	Limit int64 // Maximum size of response bodies, in bytes.
+}
+
+// Error implements error interface.
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("graphql: response body exceeds limit of %d bytes", e.Limit)
+}
@@ -0,0 +1,46 @@
+package graphql
+
+import (
+	"regexp"
+)
+
+// Fragment is a named fragment definition, which can be spread in place
+// of repeated selection sets to keep large documents small.
+//
+// A struct field spreads it with a tag such as `graphql:"...UserFields"`;
+// the field's type is where the fragment's fields are unmarshaled into,
+// typically the same type as Selection.
+type Fragment struct {
+	Name      string      // Name of the fragment, such as "UserFields".
+	On        string      // Type condition, such as "User".
+	Selection interface{} // Struct, or pointer to struct, from which the selection set is derived.
+}
+
+// appendFragments appends to document the definitions of the fragments
+// it spreads, directly or through other fragments, once each.
+// Fragments that aren't spread are left out, since GraphQL
+// doesn't allow unused fragments.
+func appendFragments(document string, fragments []Fragment) string {
+	if len(fragments) == 0 {
+		return document
+	}
+	defined := make([]bool, len(fragments))
+	for text := document; text != ""; {
+		var definitions string
+		for i, f := range fragments {
+			if defined[i] || !spreads(text, f.Name) {
+				continue
+			}
+			defined[i] = true
+			definitions += "fragment " + f.Name + " on " + f.On + query(f.Selection)
+		}
+		document += definitions
+		text = definitions
+	}
+	return document
+}
+
+// spreads reports whether document spreads the fragment with the given name.
+func spreads(document, name string) bool {
+	return regexp.MustCompile(`\.\.\.\s*` + regexp.QuoteMeta(name) + `\b`).MatchString(document)
+}
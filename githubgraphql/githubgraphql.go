@@ -0,0 +1,160 @@
+// Package githubgraphql provides helpers for GraphQL clients of package
+// graphql talking to the GitHub GraphQL API: tracking its rate limit,
+// optionally waiting for it to reset rather than exhausting it, and
+// opting into API previews.
+//
+// Track the rate limit of a client with a RateLimiter:
+//
+//	limiter := &githubgraphql.RateLimiter{MinRemaining: 100}
+//	client := graphql.NewClient("https://api.github.com/graphql", graphql.WithHTTPClient(httpClient))
+//	client.Use(limiter.Middleware())
+package githubgraphql
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	graphql "github.com/nobody05/graphql_go_client"
+)
+
+// RateLimitState is the state of a rate limit of the GitHub API.
+type RateLimitState struct {
+	Limit     int       // Maximum number of points per window.
+	Remaining int       // Number of points left in the current window.
+	Used      int       // Number of points used in the current window.
+	ResetAt   time.Time // Time the current window ends, replenishing the points.
+	Resource  string    // Rate limit the state is of, such as "graphql", if known.
+}
+
+// ParseRateLimitHeaders returns the state of the rate limit reported by
+// the X-RateLimit-* headers of a response, and whether there are any.
+func ParseRateLimitHeaders(header http.Header) (RateLimitState, bool) {
+	limit, err1 := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	remaining, err2 := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	reset, err3 := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return RateLimitState{}, false
+	}
+	used, err := strconv.Atoi(header.Get("X-RateLimit-Used"))
+	if err != nil {
+		used = limit - remaining
+	}
+	return RateLimitState{
+		Limit:     limit,
+		Remaining: remaining,
+		Used:      used,
+		ResetAt:   time.Unix(reset, 0),
+		Resource:  header.Get("X-RateLimit-Resource"),
+	}, true
+}
+
+// RateLimit is the rateLimit object of the GitHub GraphQL API, to select
+// in queries, such as to learn the cost of a query before running it with
+// rateLimit(dryRun:true).
+type RateLimit struct {
+	Limit     int       `graphql:"limit"`
+	Cost      int       `graphql:"cost"`
+	Remaining int       `graphql:"remaining"`
+	Used      int       `graphql:"used"`
+	ResetAt   time.Time `graphql:"resetAt"`
+	NodeCount int       `graphql:"nodeCount"`
+}
+
+// State returns the state of the rate limit of the GraphQL API described by r.
+func (r RateLimit) State() RateLimitState {
+	return RateLimitState{
+		Limit:     r.Limit,
+		Remaining: r.Remaining,
+		Used:      r.Used,
+		ResetAt:   r.ResetAt,
+		Resource:  "graphql",
+	}
+}
+
+// RateLimiter tracks the rate limit of a client via the headers of its
+// responses, and optionally paces its requests. Its zero value tracks the
+// rate limit without pacing. It's safe for concurrent use.
+type RateLimiter struct {
+	// MinRemaining, if positive, is the number of points to keep in
+	// reserve: once fewer remain, requests wait for the rate limit to reset
+	// before being sent, or fail with ctx.Err() if their context is done
+	// first, rather than exhausting it.
+	MinRemaining int
+
+	mu    sync.Mutex
+	state RateLimitState
+	known bool // Whether state was reported by the server.
+}
+
+// State returns the last state of the rate limit reported by the server,
+// and whether there's one.
+func (l *RateLimiter) State() (RateLimitState, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.state, l.known
+}
+
+// Middleware returns middleware tracking the rate limit of the client
+// it's added to, and pacing its requests, as configured by l.
+func (l *RateLimiter) Middleware() graphql.Middleware {
+	return func(next graphql.Doer) graphql.Doer {
+		return graphql.DoerFunc(func(ctx context.Context, req *graphql.Request) (*graphql.Response, error) {
+			if err := l.wait(ctx); err != nil {
+				return nil, err
+			}
+			resp, err := next.Do(ctx, req)
+			var header http.Header
+			var httpErr *graphql.HTTPError
+			switch {
+			case err == nil:
+				header = resp.Header
+			case errors.As(err, &httpErr):
+				// Exhausted rate limits are reported with status 403.
+				header = httpErr.Header
+			}
+			if state, ok := ParseRateLimitHeaders(header); ok {
+				l.mu.Lock()
+				l.state, l.known = state, true
+				l.mu.Unlock()
+			}
+			return resp, err
+		})
+	}
+}
+
+// wait waits until the rate limit resets if it's nearly exhausted,
+// or until ctx is done.
+func (l *RateLimiter) wait(ctx context.Context) error {
+	state, ok := l.State()
+	if !ok || l.MinRemaining <= 0 || state.Remaining >= l.MinRemaining {
+		return nil
+	}
+	d := time.Until(state.ResetAt)
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WithPreview makes the request opt into the API previews with the given
+// names, such as "merge-info", by requesting their media types, such as
+// application/vnd.github.merge-info-preview+json, via the Accept header.
+func WithPreview(names ...string) graphql.RequestOption {
+	types := make([]string, len(names))
+	for i, name := range names {
+		types[i] = "application/vnd.github." + name + "-preview+json"
+	}
+	return graphql.WithHeader("Accept", strings.Join(types, ", "))
+}
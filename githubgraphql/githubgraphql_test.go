@@ -0,0 +1,71 @@
+package githubgraphql_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	graphql "github.com/nobody05/graphql_go_client"
+	"github.com/nobody05/graphql_go_client/githubgraphql"
+)
+
+func TestRateLimiter(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+	var requests int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		if got, want := req.Header.Get("Accept"), "application/vnd.github.merge-info-preview+json"; got != want {
+			t.Errorf("got Accept: %q, want: %q", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "10")
+		w.Header().Set("X-RateLimit-Used", "4990")
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprint(reset.Unix()))
+		w.Header().Set("X-RateLimit-Resource", "graphql")
+		w.Write([]byte(`{"data": {"rateLimit": {"limit": 5000, "cost": 1, "remaining": 10, "used": 4990, "resetAt": "` + reset.UTC().Format(time.RFC3339) + `", "nodeCount": 0}}}`))
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+	limiter := &githubgraphql.RateLimiter{MinRemaining: 100}
+	client.Use(limiter.Middleware())
+
+	var q struct {
+		RateLimit githubgraphql.RateLimit `graphql:"rateLimit"`
+	}
+	if err := client.NamedQuery(context.Background(), "GetRateLimit", &q, nil, githubgraphql.WithPreview("merge-info")); err != nil {
+		t.Fatal(err)
+	}
+	want := githubgraphql.RateLimitState{Limit: 5000, Remaining: 10, Used: 4990, ResetAt: reset, Resource: "graphql"}
+	if got, ok := limiter.State(); !ok || got != want {
+		t.Errorf("got state: %+v, want: %+v", got, want)
+	}
+	if got := q.RateLimit.State(); !got.ResetAt.Equal(want.ResetAt) || got.Remaining != want.Remaining {
+		t.Errorf("got state of rateLimit: %+v, want: %+v", got, want)
+	}
+
+	// With fewer points remaining than MinRemaining,
+	// the next request waits for the reset.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := client.NamedQuery(ctx, "GetRateLimit", &q, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error: %v, want: %v", err, context.DeadlineExceeded)
+	}
+	if got, want := requests, 1; got != want {
+		t.Errorf("got requests: %v, want: %v", got, want)
+	}
+}
+
+type localRoundTripper struct {
+	handler http.Handler
+}
+
+func (l localRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := httptest.NewRecorder()
+	l.handler.ServeHTTP(w, req)
+	return w.Result(), nil
+}
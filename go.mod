@@ -1,10 +1,5 @@
 module github.com/nobody05/graphql_go_client
 
-go 1.15
+go 1.21
 
-require (
-	golang.org/x/net v0.0.0-20210119194325-5f4716e94777
-	golang.org/x/sys v0.0.0-20220728004956-3c1f35247d10 // indirect
-	golang.org/x/term v0.0.0-20210927222741-03fcf44c2211 // indirect
-	golang.org/x/text v0.3.7 // indirect
-)
+require golang.org/x/net v0.0.0-20220225172249-27dd8689420f
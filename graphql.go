@@ -3,163 +3,818 @@ package graphql
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/nobody05/graphql_go_client/internal/jsonutil"
 	"golang.org/x/net/context/ctxhttp"
 )
 
 // Client is a GraphQL client.
+//
+// A Client is safe for concurrent use by multiple goroutines, and should
+// be reused rather than created per operation, so that its connections,
+// caches and subscription connections are shared.
 type Client struct {
 	url        string // GraphQL server URL.
+	socket     string // Path of the Unix domain socket the server listens on, or empty for none.
 	httpClient *http.Client
+	header     http.Header   // Default headers sent with every request.
+	timeout    time.Duration // Time limit for each operation, or 0 for none.
+
+	modifiers []func(*http.Request) error // Modify HTTP requests just before they're sent.
+
+	jar http.CookieJar // Cookie jar replacing that of httpClient, or nil.
+
+	requestIDHeader string // Header to send operation IDs in, or empty to not send them.
+
+	idempotencyHeader string // Header to send idempotency keys of mutations in, or empty to not send them.
+
+	connectTimeout time.Duration   // Time limit for establishing connections, or 0 for none.
+	pool           *ConnectionPool // Settings of the connection pool, or nil for the transport's.
+
+	proxy      func(*http.Request) (*url.URL, error)                       // Proxy for requests, or nil for the transport's.
+	tlsConfig  *tls.Config                                                 // TLS configuration, or nil for the transport's.
+	clientCert func(*tls.CertificateRequestInfo) (*tls.Certificate, error) // Client certificate for mutual TLS, or nil for none.
+
+	errorPolicy ErrorPolicy
+
+	decoding        jsonutil.Options // How response data is decoded into structs.
+	strictVariables bool             // Whether to reject variables that operations don't use.
+
+	codec          Codec // Codec of HTTP request and response bodies, or nil for the pooled encoding/json.
+	streamRequests bool  // Whether to stream request bodies as they're encoded.
+
+	subscriptionEndpoint string                 // WebSocket URL for subscriptions, or empty to derive from url.
+	subscriptionProtocol SubscriptionProtocol   // Protocol for subscriptions, or empty for the default.
+	connectionParams     map[string]interface{} // Payload of the subscription connection_init message.
+	keepAlive            time.Duration          // Interval between subscription pings, or 0 for none.
+
+	subscriptionAuthorizer SubscriptionAuthorizer // Authorizes WebSocket subscriptions, or nil.
+
+	reconnect *ReconnectPolicy // Reconnect policy of WebSocket subscriptions, or nil to not reconnect.
+
+	subscriptionBuffer int                // Size of the buffers of WebSocket subscriptions.
+	slowConsumer       SlowConsumerPolicy // Policy of WebSocket subscriptions whose buffer is full.
+
+	wsMu    sync.Mutex
+	wsConns map[string]*wsConn       // Open subscription connections, by HTTP header.
+	wsDials map[string]chan struct{} // Connections being opened, closed once done, by HTTP header.
+
+	retry  *RetryPolicy // Retry policy, or nil to not retry.
+	hedge  *HedgePolicy // Hedge policy, or nil to not hedge.
+	useGET bool         // Whether to send queries via GET.
+
+	balancer *balancer // Spreads requests over endpoints, or nil to send them to url.
+	circuits *circuits // Circuit breakers of endpoints, or nil for none.
+
+	limiter RateLimiter // Paces requests, or nil to not limit their rate.
+
+	gzipMinSize int // Minimum size of request bodies to compress, or 0 to not compress.
+
+	maxResponseBytes int64 // Maximum size of response bodies, or 0 for no limit.
+
+	metrics MetricsCollector // Receives metrics about operations, or nil.
+	logger  *slog.Logger     // Logger for debugging operations, or nil.
+	redact  Redactor         // Replaces variables in logs, or nil.
+
+	validation *validationCache // Validates documents against a schema, or nil.
+
+	cache *Cache // Cache of query results, or nil.
+
+	transport Transport       // Transport for requests, or nil for HTTP.
+	batcher   *batchTransport // Batching HTTP transport, or nil to not batch.
+
+	mwMu       sync.Mutex           // Serializes calls to Use.
+	middleware []Middleware         // Guarded by mwMu.
+	doer       atomic.Pointer[Doer] // Executes requests, through any middleware.
+
+	singleflight bool // Whether to coalesce identical concurrent queries.
 }
 
-// NewClient creates a GraphQL client targeting the specified GraphQL server URL.
-// If httpClient is nil, then http.DefaultClient is used.
-func NewClient(url string, httpClient *http.Client) *Client {
-	if httpClient == nil {
-		httpClient = http.DefaultClient
-	}
-	return &Client{
+// NewClient creates a GraphQL client targeting the specified GraphQL server URL,
+// configured by opts. If no HTTP client is provided via WithHTTPClient,
+// then http.DefaultClient is used. Nil options are ignored, so that calls
+// of the former form NewClient(url, nil) keep working.
+//
+// Servers listening on a Unix domain socket, such as sidecar gateways, are
+// targeted by URLs of the form "unix://" followed by the path of the socket,
+// a colon and the path of the endpoint, such as
+// "unix:///var/run/api.sock:/graphql". Requests are then sent over HTTP
+// with host localhost. This requires the HTTP client's transport to be an
+// *http.Transport (or nil, for http.DefaultTransport).
+func NewClient(url string, opts ...ClientOption) *Client {
+	c := &Client{
 		url:        url,
-		httpClient: httpClient,
+		httpClient: http.DefaultClient,
+	}
+	if socket, httpURL, ok := parseUnixURL(url); ok {
+		c.socket, c.url = socket, httpURL
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(c)
+		}
 	}
+	if c.connectTimeout > 0 || c.pool != nil || c.proxy != nil || c.tlsConfig != nil || c.clientCert != nil || c.socket != "" {
+		c.httpClient = c.configureTransport(c.httpClient)
+	}
+	if c.jar != nil {
+		c.httpClient = useCookieJar(c.httpClient, c.jar)
+	}
+	c.buildDoer()
+	return c
+}
+
+// NewClientWithHTTPClient creates a GraphQL client targeting the specified
+// GraphQL server URL. If httpClient is nil, then http.DefaultClient is used.
+//
+// Deprecated: Use NewClient with WithHTTPClient instead.
+func NewClientWithHTTPClient(url string, httpClient *http.Client) *Client {
+	return NewClient(url, WithHTTPClient(httpClient))
 }
 
-// Query executes a single GraphQL query request,
-// with a query derived from q, populating the response into it.
+// Query executes a single GraphQL query request selecting the root field fn,
+// with a selection set derived from q, populating the field's data into q
+// and returning the data of the response, decoded as by json.Unmarshal,
+// for dynamic access. fn may include an alias and arguments, such as
+// "user(id:$id)" or "me:viewer". If fn is empty, q describes the whole query,
+// and is populated with the whole data.
 // q should be a pointer to struct that corresponds to the GraphQL schema.
-func (c *Client) Query(ctx context.Context, fn string, q interface{}, variables map[string]interface{}) (map[string]interface{}, error) {
-	return c.doForWbyDc(ctx, queryOperation, fn, q, variables)
+//
+// The data is also available as a json.RawMessage via WithResponse.
+func (c *Client) Query(ctx context.Context, fn string, q interface{}, variables map[string]interface{}, opts ...RequestOption) (map[string]interface{}, error) {
+	return c.queryField(ctx, fn, q, variables, newRequestOptions(opts))
 }
 
 // Mutate executes a single GraphQL mutation request,
 // with a mutation derived from m, populating the response into it.
 // m should be a pointer to struct that corresponds to the GraphQL schema.
-func (c *Client) Mutate(ctx context.Context, m interface{}, variables map[string]interface{}) error {
-	return c.do(ctx, mutationOperation, m, variables)
+func (c *Client) Mutate(ctx context.Context, m interface{}, variables map[string]interface{}, opts ...RequestOption) error {
+	return c.do(ctx, mutationOperation, m, variables, newRequestOptions(opts))
 }
 
-func (c *Client) doForWbyDc(ctx context.Context, op operationType, fn string, v interface{}, variables map[string]interface{}) (map[string]interface{}, error) {
-	var query string
-	switch op {
-	case queryOperation:
-		query = constructQueryNoQueryKeyword(fn, v, variables)
-	case mutationOperation:
-		query = constructMutation(v, variables)
-	}
+// NamedQuery executes a single GraphQL query request with the given
+// operation name, with a query derived from q, populating the response into it.
+// q should be a pointer to struct that corresponds to the GraphQL schema.
+//
+// The operation name is included in the document (e.g., "query GetUser{...}")
+// and sent as operationName, which helps server-side logging and tracing.
+func (c *Client) NamedQuery(ctx context.Context, name string, q interface{}, variables map[string]interface{}, opts ...RequestOption) error {
+	return c.do(ctx, queryOperation, q, variables, newRequestOptions(append([]RequestOption{WithOperationName(name)}, opts...)))
+}
+
+// NamedMutate is like Mutate, but with the given operation name.
+func (c *Client) NamedMutate(ctx context.Context, name string, m interface{}, variables map[string]interface{}, opts ...RequestOption) error {
+	return c.do(ctx, mutationOperation, m, variables, newRequestOptions(append([]RequestOption{WithOperationName(name)}, opts...)))
+}
 
-	var buf bytes.Buffer
-	buf.WriteString(query)
-	resp, err := ctxhttp.Post(ctx, c.httpClient, c.url, "application/json", &buf)
+// DebugQuery returns the GraphQL document that Query would send for fn, q,
+// and variables, without sending it.
+func (c *Client) DebugQuery(fn string, q interface{}, variables map[string]interface{}) (string, error) {
+	return operation(queryOperation, "", fn, q, variables)
+}
+
+// queryField executes a single GraphQL query like do, selecting the root
+// field fn with the selection set derived from v, and returns the data of
+// the response as a map, besides populating v with that of the field.
+func (c *Client) queryField(ctx context.Context, fn string, v interface{}, variables map[string]interface{}, o *requestOptions) (map[string]interface{}, error) {
+	query, err := operation(queryOperation, o.operationName, fn, v, variables)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return nil, fmt.Errorf("non-200 OK status code: %v body: %q", resp.Status, body)
-	}
-
-	result, _ := ioutil.ReadAll(resp.Body)
-
-	var resultMap map[string]interface{}
-	var resultData map[string]interface{}
-	err = json.Unmarshal(result, &resultMap)
+	query, err = o.document(query)
 	if err != nil {
 		return nil, err
 	}
-	if err, exit := resultMap["errors"]; exit {
-		errs := &errors{}
-		errStr, _ := json.Marshal(err)
-		err := json.Unmarshal(errStr, errs)
-		if err != nil {
-			return nil, err
+	var data map[string]interface{}
+	err = c.execute(ctx, queryOperation, query, variables, o, func(raw json.RawMessage) error {
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		if c.decoding.UseNumber {
+			dec.UseNumber()
 		}
-		return nil, errs
-	}
+		if err := dec.Decode(&data); err != nil {
+			return err
+		}
+		path := "data"
+		if fn != "" {
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &fields); err != nil {
+				return err
+			}
+			path += "." + responseKey(fn)
+			raw = fields[responseKey(fn)]
+			if len(raw) == 0 || string(raw) == "null" {
+				return nil
+			}
+		}
+		return c.unmarshal(raw, v, path)
+	})
+	return data, err
+}
 
-	if body, exit := resultMap["data"]; exit {
-		dataStr, _ := json.Marshal(body)
-		json.Unmarshal(dataStr, &resultData)
-		return resultData, nil
+// responseKey returns the key of the data of the root field selected by fn
+// in responses: its alias if any, or its name, such as "me" for "me:viewer"
+// and "user" for "user(id:$id)".
+func responseKey(fn string) string {
+	if i := strings.IndexAny(fn, "(@{"); i >= 0 {
+		fn = fn[:i]
+	}
+	if alias, _, ok := strings.Cut(fn, ":"); ok {
+		fn = alias
 	}
-	return nil, nil
+	return strings.TrimSpace(fn)
 }
 
 // do executes a single GraphQL operation.
-func (c *Client) do(ctx context.Context, op operationType, v interface{}, variables map[string]interface{}) error {
-	var query string
-	switch op {
-	case queryOperation:
-		query = constructQuery(v, variables)
-	case mutationOperation:
-		query = constructMutation(v, variables)
-	}
-	in := struct {
-		Query     string                 `json:"query"`
-		Variables map[string]interface{} `json:"variables,omitempty"`
-	}{
-		Query:     query,
-		Variables: variables,
-	}
-	var buf bytes.Buffer
-	err := json.NewEncoder(&buf).Encode(in)
+func (c *Client) do(ctx context.Context, op operationType, v interface{}, variables map[string]interface{}, o *requestOptions) error {
+	query, err := operation(op, o.operationName, "", v, variables)
 	if err != nil {
 		return err
 	}
-	resp, err := ctxhttp.Post(ctx, c.httpClient, c.url, "application/json", &buf)
+	query, err = o.document(query)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return fmt.Errorf("non-200 OK status code: %v body: %q", resp.Status, body)
+	return c.execute(ctx, op, query, variables, o, func(data json.RawMessage) error {
+		return c.unmarshal(data, v, "data")
+	})
+}
+
+// unmarshal decodes the response data into v, as configured by
+// WithSkipUnknownFields, WithCaseSensitiveFields and WithUseNumber.
+// path is the JSON path of data within the response, such as "data",
+// which errors report the paths of values relative to.
+func (c *Client) unmarshal(data []byte, v interface{}, path string) error {
+	opts := c.decoding
+	opts.Path = path
+	return jsonutil.UnmarshalGraphQLOptions(data, v, opts)
+}
+
+// execute sends the document query with variables through the client's
+// middleware and transport, and handles the response according to the
+// error policy, using decode to decode its data.
+func (c *Client) execute(ctx context.Context, op operationType, query string, variables map[string]interface{}, o *requestOptions, decode func(data json.RawMessage) error) (err error) {
+	info := CallInfo{
+		OperationName: o.operationName,
+		Start:         time.Now(),
+		RequestID:     c.requestID(ctx, o.header),
+	}
+	if info.RequestID != "" {
+		defer func() {
+			if err != nil {
+				err = &RequestIDError{RequestID: info.RequestID, Err: err}
+			}
+		}()
 	}
-	var out struct {
-		Data   *json.RawMessage
-		Errors errors
-		//Extensions interface{} // Unused.
+	if err := c.validate(ctx, query); err != nil {
+		return err
 	}
-	err = json.NewDecoder(resp.Body).Decode(&out)
+	variables, err = marshalVariables(variables)
 	if err != nil {
-		// TODO: Consider including response body in returned error, if deemed helpful.
 		return err
 	}
-	if out.Data != nil {
-		err := jsonutil.UnmarshalGraphQL(*out.Data, v)
+	if err := checkVariables(query, o.operationName, variables, c.strictVariables); err != nil {
+		return err
+	}
+	ctx, cancel := c.withTimeout(ctx, o)
+	defer cancel()
+	ctx = withDump(ctx, o.dump)
+
+	req := &Request{
+		Query:         query,
+		Variables:     variables,
+		OperationName: o.operationName,
+		DocumentID:    o.documentID,
+		Extensions:    o.extensions,
+		Header:        c.requestHeader(o.header),
+		query:         op == queryOperation,
+		idempotent:    o.idempotent,
+	}
+	if header, key := c.idempotencyKey(op, o); key != "" {
+		if req.Header.Get(header) == "" {
+			req.Header.Set(header, key)
+		}
+		if req.idempotent == nil {
+			req.idempotent = new(bool)
+			*req.idempotent = true
+		}
+	}
+	info.OperationType = req.OperationType()
+	ctx = withCallInfo(ctx, info)
+	if o.listFn != nil {
+		var items int
+		req.list = newListHandler(o.listPath, func(item json.RawMessage) error {
+			path := fmt.Sprintf("data.%s[%d]", o.listPath, items)
+			items++
+			return o.listFn(func(v interface{}) error {
+				return c.unmarshal(item, v, path)
+			})
+		})
+	}
+	if o.incremental != nil {
+		req.incremental = &incrementalHandler{fn: func(data json.RawMessage, path []interface{}) error {
+			if err := decode(data); err != nil {
+				return err
+			}
+			return o.incremental(path)
+		}}
+	}
+	fetch := func(ctx context.Context) (*Response, error) {
+		return c.observe(ctx, req, func(ctx context.Context) (*Response, error) {
+			return c.logged(ctx, req, (*c.doer.Load()).Do)
+		})
+	}
+	var out *Response
+	if c.cache != nil && req.list == nil && req.incremental == nil {
+		out, err = c.cached(ctx, op, req, o, fetch)
+	} else {
+		out, err = fetch(ctx)
+	}
+	if o.responseHeader != nil {
+		var httpErr *HTTPError
+		switch {
+		case errors.As(err, &httpErr):
+			*o.responseHeader = httpErr.Header
+		case err == nil && out.Header != nil:
+			*o.responseHeader = out.Header
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if l := req.list; l != nil && !l.done && out.hasData() {
+		// The transport didn't split the list off as it read the response.
+		out.Data, err = splitList(bytes.NewReader(out.Data), l.path, l.fn)
 		if err != nil {
-			// TODO: Consider including response body in returned error, if deemed helpful.
 			return err
 		}
 	}
-	if len(out.Errors) > 0 {
+	if o.response != nil {
+		*o.response = *out
+	}
+	if len(out.Errors) > 0 && c.errorPolicy == ErrorPolicyNone {
 		return out.Errors
 	}
-	return nil
+	if out.hasData() {
+		err := decode(out.Data)
+		if err != nil {
+			// TODO: Consider including response body in returned error, if deemed helpful.
+			return err
+		}
+	}
+	return c.errorPolicy.err(out.Errors)
+}
+
+// httpTransport is the default Transport, which sends requests
+// to the client's GraphQL server URL over HTTP.
+type httpTransport struct {
+	c *Client
 }
 
-// errors represents the "errors" array in a response from a GraphQL server.
-// If returned via error interface, the slice is expected to contain at least 1 element.
+// Execute sends req to the GraphQL server over HTTP and decodes its response.
+func (t httpTransport) Execute(ctx context.Context, req *Request) (*Response, error) {
+	c := t.c
+	variables, uploads := extractUploads(req.Variables)
+	var resp *http.Response
+	switch {
+	case len(uploads) > 0:
+		body, contentType, err := encodeMultipart(req, variables, uploads)
+		if err != nil {
+			return nil, err
+		}
+		header := req.Header.Clone()
+		header.Set("Content-Type", contentType)
+		resp, err = c.post(ctx, body, header, isIdempotent(req))
+		if err != nil {
+			return nil, err
+		}
+	case c.useGET && isQuery(req) && isIdempotent(req):
+		params, err := queryParams(req)
+		if err != nil {
+			return nil, err
+		}
+		resp, err = c.get(ctx, params, req.Header)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		header := req.Header
+		if req.incremental != nil {
+			header = header.Clone()
+			header.Set("Accept", incrementalAccept)
+		}
+		var err error
+		if c.streamRequests && c.codec == nil {
+			resp, err = c.postStreamed(ctx, req, header, isIdempotent(req))
+		} else {
+			var body []byte
+			body, err = c.encodeRequest(req)
+			if err != nil {
+				return nil, err
+			}
+			resp, err = c.post(ctx, body, header, isIdempotent(req))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(resp)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "multipart/mixed" && req.incremental != nil {
+		out, err := readIncremental(resp, req.incremental)
+		if err != nil {
+			return nil, err
+		}
+		out.StatusCode, out.Header = resp.StatusCode, resp.Header
+		return out, nil
+	}
+	out := Response{StatusCode: resp.StatusCode, Header: resp.Header}
+	if l := req.list; l != nil {
+		// Hand the list items over as they're read, rather than keep them.
+		b, err := splitList(resp.Body, append([]string{"data"}, l.path...), l.fn)
+		if err != nil {
+			return nil, err
+		}
+		l.done = true
+		if err := json.Unmarshal(b, &out); err != nil {
+			return nil, err
+		}
+		return &out, nil
+	}
+	if c.codec != nil {
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.codec.Unmarshal(b, &out); err != nil {
+			return nil, err
+		}
+		return &out, nil
+	}
+	err := json.NewDecoder(resp.Body).Decode(&out)
+	if err != nil {
+		// TODO: Consider including response body in returned error, if deemed helpful.
+		return nil, err
+	}
+	return &out, nil
+}
+
+// encodeRequest returns the JSON encoding of req, as the body of a POST
+// request, using the client's codec.
+func (c *Client) encodeRequest(req *Request) ([]byte, error) {
+	if c.codec != nil {
+		return c.codec.Marshal(req)
+	}
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := buf.enc.Encode(req); err != nil {
+		return nil, err
+	}
+	// The transport may read the body after the response arrives,
+	// so it's copied out of the pooled buffer.
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+// isQuery reports whether req is for a query operation, which may be sent
+// via GET. Requests identifying their document by ID or hash only are
+// assumed to be queries if their operation type was derived by the client.
+func isQuery(req *Request) bool {
+	return req.OperationType() == "query"
+}
+
+// isIdempotent reports whether req is safe to retry and hedge: queries are,
+// and mutations aren't, unless marked otherwise by WithIdempotent.
+func isIdempotent(req *Request) bool {
+	if req.idempotent != nil {
+		return *req.idempotent
+	}
+	return isQuery(req)
+}
+
+// queryParams encodes req as URL query parameters for a GET request.
+//
+// Specification: https://github.com/graphql/graphql-over-http/blob/main/spec/GraphQLOverHTTP.md#get.
+func queryParams(req *Request) (url.Values, error) {
+	params := make(url.Values)
+	if req.Query != "" {
+		params.Set("query", req.Query)
+	}
+	if req.OperationName != "" {
+		params.Set("operationName", req.OperationName)
+	}
+	if req.DocumentID != "" {
+		params.Set("id", req.DocumentID)
+	}
+	if len(req.Variables) > 0 {
+		b, err := json.Marshal(req.Variables)
+		if err != nil {
+			return nil, err
+		}
+		params.Set("variables", string(b))
+	}
+	if len(req.Extensions) > 0 {
+		b, err := json.Marshal(req.Extensions)
+		if err != nil {
+			return nil, err
+		}
+		params.Set("extensions", string(b))
+	}
+	return params, nil
+}
+
+// requestHeader returns the HTTP headers to send with a request:
+// the client's default headers, overridden by the per-request header.
+func (c *Client) requestHeader(header http.Header) http.Header {
+	h := make(http.Header, len(c.header)+len(header))
+	for k, v := range c.header {
+		h[k] = v
+	}
+	for k, v := range header {
+		h[k] = v
+	}
+	return h
+}
+
+// withTimeout derives a context bounded by the operation timeout, if any,
+// which is the request's timeout or else the client's.
+func (c *Client) withTimeout(ctx context.Context, o *requestOptions) (context.Context, context.CancelFunc) {
+	timeout := c.timeout
+	if o.timeout > 0 {
+		timeout = o.timeout
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// post sends body as a JSON POST request to the GraphQL server,
+// with the given headers, retrying according to the
+// client's retry policy. idempotent reports whether body
+// holds idempotent operations only, which are safe to retry.
+//
+// If request compression is enabled and body is large enough,
+// it's sent compressed with gzip.
+func (c *Client) post(ctx context.Context, body []byte, header http.Header, idempotent bool) (*http.Response, error) {
+	compressed := c.gzipMinSize > 0 && len(body) >= c.gzipMinSize
+	if compressed {
+		var err error
+		body, err = gzipBody(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.send(ctx, header, idempotent, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if compressed {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+		return req, nil
+	})
+}
+
+// get sends a GET request with the given URL query parameters to the
+// GraphQL server, with the given headers, retrying according to the
+// client's retry policy.
+func (c *Client) get(ctx context.Context, params url.Values, header http.Header) (*http.Response, error) {
+	u, err := url.Parse(c.url)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+	return c.send(ctx, header, true, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, u.String(), nil)
+	})
+}
+
+// send sends the HTTP requests made by newRequest, with the given headers,
+// until one succeeds or the client's retry policy gives up.
+// Each attempt is hedged, if idempotent, per the client's hedge policy.
+func (c *Client) send(ctx context.Context, header http.Header, idempotent bool, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	sendOnce := c.sendOnce
+	if idempotent && c.hedge != nil {
+		sendOnce = c.sendHedged
+	}
+	var budget *retryBudget
+	if c.retry != nil {
+		budget = c.retry.budget
+	}
+	budget.request()
+	for attempt := 1; ; attempt++ {
+		resp, err := sendOnce(withAttempt(ctx, attempt), header, newRequest)
+		if ctx.Err() != nil {
+			return resp, err
+		}
+		wait, retry := c.retry.next(ctx, attempt, idempotent, resp, err)
+		if !retry || !budget.retry() {
+			return resp, err
+		}
+		if resp != nil {
+			// Drain the body so the connection can be reused.
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+		countRetry(ctx)
+	}
+}
+
+// sendOnce makes a single attempt at sending a request to the GraphQL server.
+func (c *Client) sendOnce(ctx context.Context, header http.Header, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	var e *endpoint
+	if c.balancer != nil {
+		if e, err = c.balancer.pick(); err != nil {
+			return nil, err
+		}
+		req.URL, req.Host = e.route(req.URL), ""
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	c.setRequestID(ctx, req)
+	acceptGzip(req)
+	if len(c.modifiers) > 0 {
+		// Let modifiers see the context, as with CallInfoFromContext.
+		req = req.WithContext(ctx)
+	}
+	for _, modify := range c.modifiers {
+		if err := modify(req); err != nil {
+			return nil, err
+		}
+	}
+	var cb *circuit
+	if c.circuits != nil {
+		cb = c.circuits.circuit(req.URL)
+		if err := c.circuits.allow(cb); err != nil {
+			if e != nil {
+				c.balancer.report(ctx, e, nil, err)
+			}
+			return nil, err
+		}
+	}
+	dump := dumpRequest(ctx, req)
+	resp, err := ctxhttp.Do(ctx, c.httpClient, req)
+	if cb != nil {
+		c.circuits.record(ctx, cb, resp, err)
+	}
+	if e != nil {
+		c.balancer.report(ctx, e, resp, err)
+	}
+	if err != nil {
+		if dump != nil {
+			dump(nil)
+		}
+		return nil, err
+	}
+	if err := decompress(resp); err != nil {
+		return nil, err
+	}
+	if dump != nil {
+		dump(resp)
+	}
+	if c.maxResponseBytes > 0 {
+		limitBody(resp, c.maxResponseBytes)
+	}
+	return resp, nil
+}
+
+// Request is a GraphQL request, as seen by middleware and transports.
 //
-// Specification: https://facebook.github.io/graphql/#sec-Errors.
-type errors []struct {
-	Message   string
-	Locations []struct {
-		Line   int
-		Column int
+// Specification: https://github.com/graphql/graphql-over-http/blob/main/spec/GraphQLOverHTTP.md#request-parameters.
+type Request struct {
+	// Query is the GraphQL document. It may be empty if the server can
+	// identify the document otherwise, as with persisted queries.
+	Query string `json:"query,omitempty"`
+	// Variables are the values of the variables used by the document.
+	Variables map[string]interface{} `json:"variables,omitempty"`
+	// OperationName is the name of the operation, if any.
+	OperationName string `json:"operationName,omitempty"`
+	// DocumentID identifies a document registered with the server
+	// ahead of time, which is sent in place of Query.
+	DocumentID string `json:"id,omitempty"`
+	// Extensions holds protocol extensions, such as "persistedQuery".
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+
+	// Header holds the HTTP headers to send with the request,
+	// including the client's default headers.
+	Header http.Header `json:"-"`
+
+	query bool         // Whether the document is known to be a query.
+	list  *listHandler // Handler for the items of a streamed list, if any.
+
+	incremental *incrementalHandler // Handler for incrementally delivered results, if any.
+
+	idempotent *bool // Whether the request is safe to retry, if set by WithIdempotent.
+}
+
+// OperationType returns the type of the operation requested by r:
+// "query", "mutation" or "subscription", or "" if it can't be told,
+// as when the document is identified by ID or hash only and wasn't
+// derived by the client.
+func (r *Request) OperationType() string {
+	if r.Query == "" {
+		if r.query {
+			return "query"
+		}
+		return ""
+	}
+	doc := strings.TrimLeft(r.Query, " \t\r\n,\ufeff")
+	switch {
+	case strings.HasPrefix(doc, "{"), strings.HasPrefix(doc, "query"):
+		return "query"
+	case strings.HasPrefix(doc, "mutation"):
+		return "mutation"
+	case strings.HasPrefix(doc, "subscription"):
+		return "subscription"
 	}
+	return ""
+}
+
+// Response is a response from a GraphQL server, as decoded by the client.
+// It can be obtained for any operation via the WithResponse request option.
+//
+// Specification: https://spec.graphql.org/October2021/#sec-Response-Format.
+type Response struct {
+	// Data is the raw "data" entry of the response, if present.
+	Data json.RawMessage `json:"data,omitempty"`
+	// Errors is the "errors" entry of the response, if present.
+	Errors Errors `json:"errors,omitempty"`
+	// Extensions is the "extensions" entry of the response, if present.
+	// Servers use it for tracing, query cost, rate limits and the like.
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+
+	// StatusCode is the HTTP status code of the response,
+	// or 0 if it wasn't received over HTTP.
+	StatusCode int `json:"-"`
+	// Header holds the HTTP headers of the response,
+	// or nil if it wasn't received over HTTP.
+	Header http.Header `json:"-"`
+}
+
+// hasData reports whether r contains non-null data.
+func (r *Response) hasData() bool {
+	return len(r.Data) > 0 && string(r.Data) != "null"
 }
 
-// Error implements error interface.
-func (e errors) Error() string {
-	return e[0].Message
+// ErrorPolicy determines how GraphQL errors in a response are handled.
+// Per the GraphQL specification, a response may contain partial data
+// alongside errors.
+type ErrorPolicy uint8
+
+const (
+	// ErrorPolicyAll populates partial data and also returns
+	// GraphQL errors as the operation's error. It's the default.
+	ErrorPolicyAll ErrorPolicy = iota
+
+	// ErrorPolicyNone returns GraphQL errors as the operation's error
+	// and discards any partial data.
+	ErrorPolicyNone
+
+	// ErrorPolicyIgnore populates partial data and ignores GraphQL errors.
+	// The errors remain available via WithResponse.
+	ErrorPolicyIgnore
+)
+
+// err returns the error to report for GraphQL errors errs under policy p.
+func (p ErrorPolicy) err(errs Errors) error {
+	if len(errs) == 0 || p == ErrorPolicyIgnore {
+		return nil
+	}
+	return errs
 }
 
 type operationType uint8
@@ -167,5 +822,5 @@ type operationType uint8
 const (
 	queryOperation operationType = iota
 	mutationOperation
-	//subscriptionOperation // Unused.
+	subscriptionOperation
 )
@@ -0,0 +1,3352 @@
+package graphql_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nobody05/graphql_go_client"
+)
+
+func TestClient_Mutate_withHeader(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		if got, want := req.Header.Get("Authorization"), "Bearer token"; got != want {
+			t.Errorf("got Authorization: %q, want: %q", got, want)
+		}
+		if got, want := req.Header["X-Request-Id"], []string{"a", "b"}; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("got X-Request-Id: %q, want: %q", got, want)
+		}
+		if got, want := req.Header.Get("Content-Type"), "application/json"; got != want {
+			t.Errorf("got Content-Type: %q, want: %q", got, want)
+		}
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"mutation{add_star{starred}}"}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"addStar": {"starred": true}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	var m struct {
+		AddStar struct {
+			Starred graphql.Boolean
+		}
+	}
+	err := client.Mutate(context.Background(), &m, nil,
+		graphql.WithHeader("Authorization", "Bearer token"),
+		graphql.WithHeader("X-Request-ID", "a"),
+		graphql.WithHeader("X-Request-ID", "b"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.AddStar.Starred, graphql.Boolean(true); got != want {
+		t.Errorf("got m.AddStar.Starred: %v, want: %v", got, want)
+	}
+}
+
+func TestClient_Mutate_defaultHeaders(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		if got, want := req.Header.Get("User-Agent"), "test-agent"; got != want {
+			t.Errorf("got User-Agent: %q, want: %q", got, want)
+		}
+		if got, want := req.Header.Get("X-Tenant"), "override"; got != want {
+			t.Errorf("got X-Tenant: %q, want: %q", got, want)
+		}
+		if got, want := req.Header.Get("X-Shared"), "shared"; got != want {
+			t.Errorf("got X-Shared: %q, want: %q", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"addStar": {"starred": true}}}`)
+	})
+	client := graphql.NewClient("/graphql",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithUserAgent("test-agent"),
+		graphql.WithDefaultHeaders(http.Header{"x-tenant": {"default"}, "X-Shared": {"shared"}}),
+	)
+
+	var m struct {
+		AddStar struct {
+			Starred graphql.Boolean
+		}
+	}
+	err := client.Mutate(context.Background(), &m, nil, graphql.WithHeader("X-Tenant", "override"))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_NamedQuery(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"query GetUser($id:ID!){user(id:$id){name}}","variables":{"id":"1"},"operationName":"GetUser"}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"name": "Gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	var q struct {
+		User struct {
+			Name graphql.String
+		} `graphql:"user(id:$id)"`
+	}
+	err := client.NamedQuery(context.Background(), "GetUser", &q, map[string]interface{}{"id": graphql.ID("1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.User.Name, graphql.String("Gopher"); got != want {
+		t.Errorf("got q.User.Name: %q, want: %q", got, want)
+	}
+}
+
+func TestClient_Query_withOperationName(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"query GetUser($id:ID!){user(id:$id){name}}","variables":{"id":"1"},"operationName":"GetUser"}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"name": "Gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	var q struct {
+		Name graphql.String
+	}
+	data, err := client.Query(context.Background(), "user(id:$id)", &q, map[string]interface{}{"id": graphql.ID("1")},
+		graphql.WithOperationName("GetUser"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := data["user"], map[string]interface{}{"name": "Gopher"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got data: %v, want: %v", got, want)
+	}
+}
+
+func TestClient_Query_typedAndRaw(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"me": {"login": "gopher", "bio": null}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	var q struct {
+		Login graphql.String
+		Bio   *graphql.String
+	}
+	var resp graphql.Response
+	data, err := client.Query(context.Background(), "me:viewer", &q, nil, graphql.WithResponse(&resp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.Login, graphql.String("gopher"); got != want {
+		t.Errorf("got q.Login: %q, want: %q", got, want)
+	}
+	if got, want := data["me"], map[string]interface{}{"login": "gopher", "bio": nil}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got data: %v, want: %v", got, want)
+	}
+	if got, want := string(resp.Data), `{"me": {"login": "gopher", "bio": null}}`; got != want {
+		t.Errorf("got raw data: %s, want: %s", got, want)
+	}
+
+	// With no root field, q describes the whole query.
+	var whole struct {
+		Me struct {
+			Login graphql.String
+			Bio   *graphql.String
+		} `graphql:"me:viewer"`
+	}
+	if _, err := client.Query(context.Background(), "", &whole, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := whole.Me.Login, graphql.String("gopher"); got != want {
+		t.Errorf("got whole.Me.Login: %q, want: %q", got, want)
+	}
+}
+
+func TestClient_NamedQuery_multipleRootFields(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"query GetOverview($fork:String!$name:String!$owner:String!){viewer{login},repository(owner:$owner,name:$name){stars},fork: repository(owner:$owner,name:$fork){stars},rateLimit{remaining}}","variables":{"fork":"graphql-fork","name":"graphql","owner":"shurcooL"},"operationName":"GetOverview"}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"viewer": {"login": "gopher"}, "repository": {"stars": 10}, "fork": {"stars": 2}, "rateLimit": {"remaining": 4999}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	type repository struct {
+		Stars graphql.Int `graphql:"stars"`
+	}
+	var q struct {
+		Viewer struct {
+			Login graphql.String `graphql:"login"`
+		} `graphql:"viewer"`
+		Repository repository `graphql:"repository(owner:$owner,name:$name)"`
+		Fork       repository `graphql:"fork: repository(owner:$owner,name:$fork)"`
+		RateLimit  struct {
+			Remaining graphql.Int `graphql:"remaining"`
+		} `graphql:"rateLimit"`
+	}
+	variables := map[string]interface{}{
+		"owner": graphql.String("shurcooL"),
+		"name":  graphql.String("graphql"),
+		"fork":  graphql.String("graphql-fork"),
+	}
+	if err := client.NamedQuery(context.Background(), "GetOverview", &q, variables); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprintf("%v %v %v %v", q.Viewer.Login, q.Repository.Stars, q.Fork.Stars, q.RateLimit.Remaining), "gopher 10 2 4999"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// issueState is an enum whose Go and GraphQL type names differ.
+type issueState string
+
+const (
+	issueStateOpen   issueState = "OPEN"
+	issueStateClosed issueState = "CLOSED"
+)
+
+func (issueState) GraphQLType() string { return "IssueState" }
+
+func (issueState) GraphQLEnumValues() []string { return []string{"OPEN", "CLOSED"} }
+
+func TestClient_enum(t *testing.T) {
+	var requests int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"query($states:[IssueState!]!){issues(states:$states){state}}","variables":{"states":["OPEN","CLOSED"]}}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"issues": [{"state": "CLOSED"}, {"state": "MERGED"}]}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	type issuesQuery struct {
+		Issues []struct {
+			State issueState `graphql:"state"`
+		} `graphql:"issues(states:$states)"`
+	}
+	_, err := graphql.Query[issuesQuery](context.Background(), client, map[string]interface{}{"states": []issueState{"open"}})
+	if got, want := fmt.Sprint(err), `graphql: invalid value "open" of enum IssueState`; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+	if requests != 0 {
+		t.Errorf("got %d requests sent with an invalid enum value, want none", requests)
+	}
+
+	// Values unknown to the client are decoded as is.
+	q, err := graphql.Query[issuesQuery](context.Background(), client, map[string]interface{}{"states": []issueState{issueStateOpen, issueStateClosed}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(q.Issues), "[{CLOSED} {MERGED}]"; got != want {
+		t.Errorf("got issues: %v, want: %v", got, want)
+	}
+}
+
+func TestClient_Mutate_withFragments(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"mutation{follow{follower{...UserFields},followee{...UserFields}}}fragment UserFields on User{login}"}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"follow": {"follower": {"login": "gopher"}, "followee": {"login": "gopherine"}}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	type userFields struct {
+		Login graphql.String
+	}
+	var m struct {
+		Follow struct {
+			Follower struct {
+				userFields `graphql:"...UserFields"`
+			}
+			Followee struct {
+				userFields `graphql:"...UserFields"`
+			}
+		}
+	}
+	err := client.Mutate(context.Background(), &m, nil, graphql.WithFragments(
+		graphql.Fragment{Name: "UserFields", On: "User", Selection: userFields{}},
+		graphql.Fragment{Name: "Unused", On: "User", Selection: userFields{}},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Follow.Follower.Login, graphql.String("gopher"); got != want {
+		t.Errorf("got follower login: %q, want: %q", got, want)
+	}
+	if got, want := m.Follow.Followee.Login, graphql.String("gopherine"); got != want {
+		t.Errorf("got followee login: %q, want: %q", got, want)
+	}
+}
+
+func TestClient_Query_withResponse(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"name": "Gopher"}}, "extensions": {"cost": {"requested": 3}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	var q struct {
+		Name graphql.String
+	}
+	var resp graphql.Response
+	data, err := client.Query(context.Background(), "user", &q, nil, graphql.WithResponse(&resp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := data["user"], map[string]interface{}{"name": "Gopher"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got data: %v, want: %v", got, want)
+	}
+	if got, want := string(resp.Data), `{"user": {"name": "Gopher"}}`; got != want {
+		t.Errorf("got resp.Data: %s, want: %s", got, want)
+	}
+	if got, want := resp.Extensions, map[string]interface{}{"cost": map[string]interface{}{"requested": float64(3)}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got resp.Extensions: %v, want: %v", got, want)
+	}
+}
+
+func TestClient_Mutate_errorsWithResponse(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": null, "errors": [
+			{"message": "not allowed", "locations": [{"line": 1, "column": 10}], "path": ["addStar", 0], "extensions": {"code": "FORBIDDEN"}},
+			{"message": "also broken"}
+		]}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	var m struct {
+		AddStar struct {
+			Starred graphql.Boolean
+		}
+	}
+	var resp graphql.Response
+	err := client.Mutate(context.Background(), &m, nil, graphql.WithResponse(&resp))
+	if got, want := fmt.Sprint(err), "not allowed; also broken"; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+	var errs graphql.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("got error %T, want graphql.Errors", err)
+	}
+	want := graphql.Error{
+		Message:    "not allowed",
+		Locations:  []graphql.Location{{Line: 1, Column: 10}},
+		Path:       []interface{}{"addStar", float64(0)},
+		Extensions: map[string]interface{}{"code": "FORBIDDEN"},
+	}
+	if got := errs[0]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got errs[0]: %#v, want: %#v", got, want)
+	}
+	if got, want := len(resp.Errors), 2; got != want {
+		t.Errorf("got len(resp.Errors): %v, want: %v", got, want)
+	}
+}
+
+func TestClient_errorPolicy(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"addStar": {"starred": true}}, "errors": [{"message": "partial failure"}]}`)
+	})
+	tests := []struct {
+		opts        []graphql.ClientOption
+		wantErr     string
+		wantStarred graphql.Boolean
+	}{
+		{nil, "partial failure", true}, // The default is ErrorPolicyAll.
+		{[]graphql.ClientOption{graphql.WithErrorPolicy(graphql.ErrorPolicyNone)}, "partial failure", false},
+		{[]graphql.ClientOption{graphql.WithErrorPolicy(graphql.ErrorPolicyAll)}, "partial failure", true},
+		{[]graphql.ClientOption{graphql.WithErrorPolicy(graphql.ErrorPolicyIgnore)}, "<nil>", true},
+	}
+	for i, tc := range tests {
+		client := graphql.NewClient("/graphql", append(tc.opts,
+			graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))...)
+		var m struct {
+			AddStar struct {
+				Starred graphql.Boolean
+			}
+		}
+		err := client.Mutate(context.Background(), &m, nil)
+		if got := fmt.Sprint(err); got != tc.wantErr {
+			t.Errorf("test %d: got error: %v, want: %v", i, got, tc.wantErr)
+		}
+		if got := m.AddStar.Starred; got != tc.wantStarred {
+			t.Errorf("test %d: got m.AddStar.Starred: %v, want: %v", i, got, tc.wantStarred)
+		}
+
+		var q struct {
+			Starred graphql.Boolean
+		}
+		data, err := client.Query(context.Background(), "addStar", &q, nil)
+		if got := fmt.Sprint(err); got != tc.wantErr {
+			t.Errorf("test %d: got Query error: %v, want: %v", i, got, tc.wantErr)
+		}
+		if got, want := data != nil, bool(tc.wantStarred); got != want {
+			t.Errorf("test %d: got Query data: %v, want data: %v", i, data, want)
+		}
+	}
+}
+
+func TestClient_Mutate_retry(t *testing.T) {
+	var attempts int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		if got, want := mustRead(req.Body), `{"query":"mutation{add_star{starred}}"}`+"\n"; got != want {
+			t.Errorf("attempt %d: got body: %v, want %v", attempts, got, want)
+		}
+		if attempts < 3 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"addStar": {"starred": true}}}`)
+	})
+	client := graphql.NewClient("/graphql",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithRetry(graphql.RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, Jitter: 0.5, RetryMutations: true}),
+	)
+
+	var m struct {
+		AddStar struct {
+			Starred graphql.Boolean
+		}
+	}
+	err := client.Mutate(context.Background(), &m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := attempts, 3; got != want {
+		t.Errorf("got %d attempts, want %d", got, want)
+	}
+
+	// Once attempts are exhausted, the last failure is returned.
+	attempts = -10
+	err = client.Mutate(context.Background(), &m, nil)
+	if err == nil {
+		t.Fatal("got nil error, want non-nil")
+	}
+	if got, want := attempts, -7; got != want {
+		t.Errorf("got attempts counter %d, want %d", got, want)
+	}
+}
+
+func TestClient_retry_queriesOnly(t *testing.T) {
+	var attempts int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		http.Error(w, "try again", http.StatusBadGateway)
+	})
+	client := graphql.NewClient("/graphql",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithRetry(graphql.RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond}),
+	)
+
+	var m struct {
+		AddStar struct {
+			Starred graphql.Boolean
+		}
+	}
+	if err := client.Mutate(context.Background(), &m, nil); err == nil {
+		t.Fatal("got nil error, want non-nil")
+	}
+	if got, want := attempts, 1; got != want {
+		t.Errorf("mutation: got %d attempts, want %d", got, want)
+	}
+
+	attempts = 0
+	var q struct {
+		Login graphql.String
+	}
+	if _, err := client.Query(context.Background(), "viewer", &q, nil); err == nil {
+		t.Fatal("got nil error, want non-nil")
+	}
+	if got, want := attempts, 3; got != want {
+		t.Errorf("query: got %d attempts, want %d", got, want)
+	}
+}
+
+func TestClient_Mutate_retryTooManyRequests(t *testing.T) {
+	var attempts int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	})
+	client := graphql.NewClient("/graphql",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithRetry(graphql.RetryPolicy{
+			MinBackoff:           time.Millisecond,
+			RetryableStatusCodes: []int{http.StatusTooManyRequests},
+		}),
+	)
+
+	var m struct {
+		AddStar struct {
+			Starred graphql.Boolean
+		}
+	}
+	// Without Retry-After, the server may not have turned the mutation away.
+	if err := client.Mutate(context.Background(), &m, nil); err == nil {
+		t.Fatal("got nil error, want non-nil")
+	}
+	if got, want := attempts, 1; got != want {
+		t.Errorf("got %d attempts, want %d", got, want)
+	}
+}
+
+func TestClient_retry_idempotent(t *testing.T) {
+	var attempts int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		http.Error(w, "try again", http.StatusBadGateway)
+	})
+	client := graphql.NewClient("/graphql",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithRetry(graphql.RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond}),
+	)
+
+	var m struct {
+		AddStar struct {
+			Starred graphql.Boolean
+		}
+	}
+	if err := client.Mutate(context.Background(), &m, nil, graphql.WithIdempotent(true)); err == nil {
+		t.Fatal("got nil error, want non-nil")
+	}
+	if got, want := attempts, 3; got != want {
+		t.Errorf("idempotent mutation: got %d attempts, want %d", got, want)
+	}
+
+	attempts = 0
+	var q struct {
+		Login graphql.String
+	}
+	if _, err := client.Query(context.Background(), "viewer", &q, nil, graphql.WithIdempotent(false)); err == nil {
+		t.Fatal("got nil error, want non-nil")
+	}
+	if got, want := attempts, 1; got != want {
+		t.Errorf("non-idempotent query: got %d attempts, want %d", got, want)
+	}
+}
+
+func TestClient_idempotencyKeys(t *testing.T) {
+	var keys []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		keys = append(keys, req.Header.Get("Idempotency-Key"))
+		if len(keys)%2 == 1 {
+			http.Error(w, "try again", http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"addStar": {"starred": true}}}`)
+	})
+	httpClient := &http.Client{Transport: localRoundTripper{handler: mux}}
+	retry := graphql.RetryPolicy{MaxAttempts: 2, MinBackoff: time.Millisecond}
+
+	var m struct {
+		AddStar struct {
+			Starred graphql.Boolean
+		}
+	}
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(httpClient), graphql.WithRetry(retry), graphql.WithIdempotencyKeys(""))
+	if err := client.Mutate(context.Background(), &m, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || len(keys[0]) != 32 || keys[1] != keys[0] {
+		t.Fatalf("got idempotency keys: %q, want the same random key twice", keys)
+	}
+
+	keys = nil
+	client = graphql.NewClient("/graphql", graphql.WithHTTPClient(httpClient), graphql.WithRetry(retry))
+	if err := client.Mutate(context.Background(), &m, nil, graphql.WithIdempotencyKey("order-1")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(keys), "[order-1 order-1]"; got != want {
+		t.Errorf("got idempotency keys: %s, want: %s", got, want)
+	}
+}
+
+func TestClient_retry_budget(t *testing.T) {
+	var attempts int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		http.Error(w, "try again", http.StatusServiceUnavailable)
+	})
+	client := graphql.NewClient("/graphql",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithRetry(graphql.RetryPolicy{
+			MaxAttempts: 3,
+			MinBackoff:  time.Millisecond,
+			Budget:      &graphql.RetryBudget{Ratio: 0.5, MinRetriesPerSecond: 1, Window: time.Second},
+		}),
+	)
+
+	var q struct {
+		Login graphql.String
+	}
+	// The budget allows a retry per second, plus one per two requests.
+	var got []int
+	for i := 0; i < 3; i++ {
+		attempts = 0
+		client.Query(context.Background(), "viewer", &q, nil)
+		got = append(got, attempts)
+	}
+	if got, want := fmt.Sprint(got), "[3 1 2]"; got != want {
+		t.Errorf("got attempts: %s, want: %s", got, want)
+	}
+}
+
+func TestClient_retry_budgetShortWindow(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "try again", http.StatusServiceUnavailable)
+	})
+	client := graphql.NewClient("/graphql",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithRetry(graphql.RetryPolicy{
+			MinBackoff: time.Millisecond,
+			Budget:     &graphql.RetryBudget{Ratio: 0.5, Window: time.Nanosecond},
+		}),
+	)
+
+	var q struct {
+		Login graphql.String
+	}
+	// Windows shorter than their buckets don't divide by zero.
+	if _, err := client.Query(context.Background(), "viewer", &q, nil); err == nil {
+		t.Fatal("got nil error, want non-nil")
+	}
+}
+
+func TestClient_callInfo(t *testing.T) {
+	var attempts []int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		info, ok := graphql.CallInfoFromContext(req.Context())
+		if !ok {
+			t.Error("got no call info in transport")
+		}
+		if got, want := info.OperationName, "GetViewer"; got != want {
+			t.Errorf("got operation name: %q, want: %q", got, want)
+		}
+		attempts = append(attempts, info.Attempt)
+		if len(attempts) == 1 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"viewer": {"login": "gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithRetry(graphql.RetryPolicy{MaxAttempts: 2, MinBackoff: time.Millisecond}),
+	)
+	start := time.Now()
+	client.Use(func(next graphql.Doer) graphql.Doer {
+		return graphql.DoerFunc(func(ctx context.Context, req *graphql.Request) (*graphql.Response, error) {
+			info, ok := graphql.CallInfoFromContext(ctx)
+			if !ok {
+				t.Error("got no call info in middleware")
+			}
+			if got, want := info, (graphql.CallInfo{OperationName: "GetViewer", OperationType: "query", Start: info.Start}); got != want {
+				t.Errorf("got call info: %+v, want: %+v", got, want)
+			}
+			if info.Start.Before(start) {
+				t.Errorf("got start: %v, want after: %v", info.Start, start)
+			}
+			return next.Do(ctx, req)
+		})
+	})
+
+	var q struct {
+		Viewer struct {
+			Login string `graphql:"login"`
+		} `graphql:"viewer"`
+	}
+	if err := client.NamedQuery(context.Background(), "GetViewer", &q, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(attempts), "[1 2]"; got != want {
+		t.Errorf("got attempts: %s, want: %s", got, want)
+	}
+	if _, ok := graphql.CallInfoFromContext(context.Background()); ok {
+		t.Error("got call info in background context")
+	}
+}
+
+func TestClient_requestModifier(t *testing.T) {
+	var requests int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		if got, want := req.Header.Get("X-Trace"), "GetViewer"; got != want {
+			t.Errorf("got X-Trace header: %q, want: %q", got, want)
+		}
+		if c, err := req.Cookie("session"); err != nil || c.Value != "s3cr3t" {
+			t.Errorf("got session cookie: %v, %v, want: s3cr3t", c, err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"viewer": {"login": "gopher"}}}`)
+	})
+	fail := false
+	client := graphql.NewClient("/graphql",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithRequestModifier(func(req *http.Request) error {
+			info, _ := graphql.CallInfoFromContext(req.Context())
+			req.Header.Set("X-Trace", info.OperationName)
+			return nil
+		}),
+		graphql.WithRequestModifier(func(req *http.Request) error {
+			if fail {
+				return errors.New("no session")
+			}
+			req.AddCookie(&http.Cookie{Name: "session", Value: "s3cr3t"})
+			return nil
+		}),
+	)
+
+	var q struct {
+		Viewer struct {
+			Login string `graphql:"login"`
+		} `graphql:"viewer"`
+	}
+	if err := client.NamedQuery(context.Background(), "GetViewer", &q, nil); err != nil {
+		t.Fatal(err)
+	}
+	fail = true
+	if err := client.NamedQuery(context.Background(), "GetViewer", &q, nil); err == nil || err.Error() != "no session" {
+		t.Errorf("got error: %v, want: no session", err)
+	}
+	if got, want := requests, 1; got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+}
+
+func TestClient_cookieSession(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(mustRead(req.Body), "mutation") {
+			if got := req.Header.Get("X-CSRFToken"); got != "" {
+				t.Errorf("got X-CSRFToken header before login: %q", got)
+			}
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", HttpOnly: true})
+			http.SetCookie(w, &http.Cookie{Name: "csrftoken", Value: "t1"})
+			mustWrite(w, `{"data": {"login": {"ok": true}}}`)
+			return
+		}
+		if c, err := req.Cookie("session"); err != nil || c.Value != "s1" {
+			t.Errorf("got session cookie: %v, %v, want: s1", c, err)
+		}
+		if got, want := req.Header.Get("X-CSRFToken"), "t1"; got != want {
+			t.Errorf("got X-CSRFToken header: %q, want: %q", got, want)
+		}
+		mustWrite(w, `{"data": {"viewer": {"login": "gopher"}}}`)
+	}))
+	defer srv.Close()
+	client := graphql.NewClient(srv.URL, graphql.WithCookieJar(nil), graphql.WithCSRFToken("csrftoken", "X-CSRFToken"))
+
+	var m struct {
+		Login struct {
+			OK bool `graphql:"ok"`
+		} `graphql:"login"`
+	}
+	if err := client.Mutate(context.Background(), &m, nil); err != nil {
+		t.Fatal(err)
+	}
+	var q struct {
+		Viewer struct {
+			Login string `graphql:"login"`
+		} `graphql:"viewer"`
+	}
+	if err := client.NamedQuery(context.Background(), "GetViewer", &q, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.Viewer.Login, "gopher"; got != want {
+		t.Errorf("got login: %q, want: %q", got, want)
+	}
+}
+
+func TestClient_responseHeaders(t *testing.T) {
+	limited := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Request-Id", "r1")
+		if limited {
+			w.Header().Set("Retry-After", "30")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		mustWrite(w, `{"data": {"viewer": {"login": "gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	var q struct {
+		Viewer struct {
+			Login string `graphql:"login"`
+		} `graphql:"viewer"`
+	}
+	var header http.Header
+	if err := client.NamedQuery(context.Background(), "GetViewer", &q, nil, graphql.WithResponseHeaders(&header)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := header.Get("X-RateLimit-Remaining"), "0"; got != want {
+		t.Errorf("got X-RateLimit-Remaining header: %q, want: %q", got, want)
+	}
+	limited, header = true, nil
+	if err := client.NamedQuery(context.Background(), "GetViewer", &q, nil, graphql.WithResponseHeaders(&header)); err == nil {
+		t.Fatal("got nil error, want non-nil")
+	}
+	if got, want := header.Get("Retry-After"), "30"; got != want {
+		t.Errorf("got Retry-After header: %q, want: %q", got, want)
+	}
+}
+
+func TestClient_requestID(t *testing.T) {
+	var ids []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		ids = append(ids, req.Header.Get("X-Request-ID"))
+		if len(ids) == 1 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"viewer": null}, "errors": [{"message": "forbidden"}]}`)
+	})
+	client := graphql.NewClient("/graphql",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithRetry(graphql.RetryPolicy{MaxAttempts: 2, MinBackoff: time.Millisecond}),
+		graphql.WithRequestID(""),
+	)
+
+	var q struct {
+		Viewer struct {
+			Login string `graphql:"login"`
+		} `graphql:"viewer"`
+	}
+	err := client.NamedQuery(context.Background(), "GetViewer", &q, nil)
+	if len(ids) != 2 || len(ids[0]) != 32 || ids[1] != ids[0] {
+		t.Fatalf("got request IDs: %q, want the same random ID twice", ids)
+	}
+	var idErr *graphql.RequestIDError
+	if !errors.As(err, &idErr) || idErr.RequestID != ids[0] {
+		t.Fatalf("got error: %v, want one with request ID %s", err, ids[0])
+	}
+	var errs graphql.Errors
+	if !errors.As(err, &errs) || errs[0].Message != "forbidden" {
+		t.Errorf("got error: %v, want GraphQL errors", err)
+	}
+
+	ids = ids[:1]
+	ctx := graphql.ContextWithRequestID(context.Background(), "upstream-1")
+	err = client.NamedQuery(ctx, "GetViewer", &q, nil)
+	if got, want := ids[1], "upstream-1"; got != want {
+		t.Errorf("got request ID: %q, want: %q", got, want)
+	}
+	if got, want := err.Error(), "forbidden (request ID upstream-1)"; got != want {
+		t.Errorf("got error: %q, want: %q", got, want)
+	}
+}
+
+func TestClient_requestID_batching(t *testing.T) {
+	var header string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		header = req.Header.Get("X-Request-ID")
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `[{"data": {"viewer": {"login": "a"}}}, {"data": {"viewer": {"login": "b"}}}]`)
+	})
+	client := graphql.NewClient("/graphql",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithBatching(graphql.Batcher{Window: time.Hour, MaxSize: 2}),
+		graphql.WithRequestID(""),
+	)
+	var wg sync.WaitGroup
+	for _, id := range []string{"a", "b"} {
+		id := id
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var q struct {
+				Viewer struct {
+					Login string `graphql:"login"`
+				} `graphql:"viewer"`
+			}
+			if err := client.NamedQuery(graphql.ContextWithRequestID(context.Background(), id), "GetViewer", &q, nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if header != "a,b" && header != "b,a" {
+		t.Errorf("got request IDs: %q, want: a,b", header)
+	}
+}
+
+func TestClient_hedging(t *testing.T) {
+	var queries, mutations int32
+	canceled := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(mustRead(req.Body), "mutation") {
+			atomic.AddInt32(&mutations, 1)
+			time.Sleep(20 * time.Millisecond)
+			mustWrite(w, `{"data": {"addStar": {"starred": true}}}`)
+			return
+		}
+		if atomic.AddInt32(&queries, 1) == 1 {
+			// Stall the first query until it's canceled.
+			<-req.Context().Done()
+			close(canceled)
+			return
+		}
+		mustWrite(w, `{"data": {"viewer": {"login": "gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithHedging(graphql.HedgePolicy{Delay: time.Millisecond}),
+	)
+
+	var q struct {
+		Viewer struct {
+			Login graphql.String
+		}
+	}
+	if err := client.NamedQuery(context.Background(), "GetViewer", &q, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.Viewer.Login, graphql.String("gopher"); got != want {
+		t.Errorf("got login: %q, want: %q", got, want)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("got slow query not canceled")
+	}
+	if got, want := atomic.LoadInt32(&queries), int32(2); got != want {
+		t.Errorf("got %d queries sent, want %d", got, want)
+	}
+
+	var m struct {
+		AddStar struct {
+			Starred graphql.Boolean
+		}
+	}
+	if err := client.Mutate(context.Background(), &m, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := atomic.LoadInt32(&mutations), int32(1); got != want {
+		t.Errorf("got %d mutations sent, want %d", got, want)
+	}
+}
+
+func TestClient_loadBalancing(t *testing.T) {
+	var mu sync.Mutex
+	var hosts []string
+	down := map[string]bool{"b": true}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		hosts = append(hosts, req.URL.Host)
+		isDown := down[req.URL.Host]
+		mu.Unlock()
+		if isDown {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"viewer": {"login": "gopher"}}}`)
+	})
+	checked := make(chan string, 1)
+	client := graphql.NewClient("/graphql",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithRetry(graphql.RetryPolicy{MinBackoff: time.Millisecond}),
+		graphql.WithLoadBalancing(graphql.LoadBalancer{
+			Endpoints:    []graphql.Endpoint{{URL: "http://a/graphql"}, {URL: "http://b/graphql"}, {URL: "http://c/graphql"}},
+			EjectionTime: 50 * time.Millisecond,
+			HealthCheck: func(ctx context.Context, url string) error {
+				defer func() {
+					select {
+					case checked <- url:
+					default:
+					}
+				}()
+				mu.Lock()
+				defer mu.Unlock()
+				if down[strings.TrimSuffix(strings.TrimPrefix(url, "http://"), "/graphql")] {
+					return errors.New("down")
+				}
+				return nil
+			},
+		}),
+	)
+	query := func() {
+		t.Helper()
+		var q struct {
+			Viewer struct {
+				Login graphql.String
+			}
+		}
+		if err := client.NamedQuery(context.Background(), "GetViewer", &q, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hostsSent := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		s := strings.Join(hosts, ",")
+		hosts = nil
+		return s
+	}
+
+	// b fails and is retried on another endpoint, then left out.
+	for i := 0; i < 4; i++ {
+		query()
+	}
+	if got := hostsSent(); strings.Count(got, "b") != 1 || strings.Count(got, ",") != 4 {
+		t.Errorf("got hosts: %q, want b once among 5", got)
+	}
+
+	// Once b is back up, a health check brings it back.
+	mu.Lock()
+	down["b"] = false
+	mu.Unlock()
+	time.Sleep(60 * time.Millisecond)
+	query()
+	if got, want := <-checked, "http://b/graphql"; got != want {
+		t.Errorf("got health check of: %q, want: %q", got, want)
+	}
+	hostsSent()
+	for i := 0; i < 3; i++ {
+		query()
+	}
+	if got := hostsSent(); !strings.Contains(got, "b") {
+		t.Errorf("got hosts: %q, want b among them", got)
+	}
+}
+
+func TestClient_circuitBreaker(t *testing.T) {
+	var requests int32
+	var down atomic.Bool
+	down.Store(true)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if down.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"viewer": {"login": "gopher"}}}`)
+	})
+	var mu sync.Mutex
+	var changes []string
+	client := graphql.NewClient("/graphql",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithCircuitBreaker(graphql.CircuitBreaker{
+			ConsecutiveFailures: 2,
+			OpenTimeout:         20 * time.Millisecond,
+			OnStateChange: func(endpoint string, from, to graphql.CircuitState) {
+				mu.Lock()
+				defer mu.Unlock()
+				changes = append(changes, fmt.Sprintf("%s: %v -> %v", endpoint, from, to))
+			},
+		}),
+	)
+	query := func() error {
+		var q struct {
+			Viewer struct {
+				Login graphql.String
+			}
+		}
+		return client.NamedQuery(context.Background(), "GetViewer", &q, nil)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := query(); err == nil || errors.Is(err, graphql.ErrCircuitOpen) {
+			t.Errorf("got error: %v, want a server error", err)
+		}
+	}
+	if err := query(); !errors.Is(err, graphql.ErrCircuitOpen) {
+		t.Errorf("got error: %v, want: %v", err, graphql.ErrCircuitOpen)
+	}
+	if got, want := atomic.LoadInt32(&requests), int32(2); got != want {
+		t.Errorf("got %d requests sent, want %d", got, want)
+	}
+
+	down.Store(false)
+	time.Sleep(30 * time.Millisecond)
+	if err := query(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"/graphql: closed -> open",
+		"/graphql: open -> half-open",
+		"/graphql: half-open -> closed",
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("got state changes: %q, want: %q", changes, want)
+	}
+}
+
+func TestClient_rateLimit(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"viewer": {"login": "gopher"}}}`)
+	})
+	newClient := func(l graphql.RateLimiter) *graphql.Client {
+		return graphql.NewClient("/graphql",
+			graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+			graphql.WithRateLimit(l),
+		)
+	}
+	query := func(ctx context.Context, client *graphql.Client) error {
+		var q struct {
+			Viewer struct {
+				Login graphql.String
+			}
+		}
+		return client.NamedQuery(ctx, "GetViewer", &q, nil)
+	}
+
+	// 6 queries at 100 per second, in bursts of 2, take 40ms at least.
+	client := newClient(graphql.NewRateLimiter(100, 2))
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := query(context.Background(), client); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got, want := time.Since(start), 40*time.Millisecond; got < want {
+		t.Errorf("got queries done in %v, want %v at least", got, want)
+	}
+
+	client = newClient(graphql.NewRateLimiter(1, 1))
+	if err := query(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := query(ctx, client); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error: %v, want: %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestClient_Mutate_retryAfter(t *testing.T) {
+	var attempts int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		if attempts == 2 {
+			w.Header().Set("Retry-After", "3600")
+			http.Error(w, "slow down a lot", http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"addStar": {"starred": true}}}`)
+	})
+	client := graphql.NewClient("/graphql",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithRetry(graphql.RetryPolicy{RespectRetryAfter: true}),
+	)
+
+	var m struct {
+		AddStar struct {
+			Starred graphql.Boolean
+		}
+	}
+	// The second Retry-After exceeds the deadline, so the 429 is returned.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	err := client.Mutate(ctx, &m, nil)
+	var httpErr *graphql.HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("got error %v, want *graphql.HTTPError", err)
+	}
+	if got, want := httpErr.StatusCode, http.StatusTooManyRequests; got != want {
+		t.Errorf("got StatusCode %v, want %v", got, want)
+	}
+	if got, want := httpErr.Header.Get("Retry-After"), "3600"; got != want {
+		t.Errorf("got Retry-After %q, want %q", got, want)
+	}
+	if got, want := string(httpErr.Body), "slow down a lot\n"; got != want {
+		t.Errorf("got Body %q, want %q", got, want)
+	}
+	if got, want := attempts, 2; got != want {
+		t.Errorf("got %d attempts, want %d", got, want)
+	}
+}
+
+func TestClient_Use(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		if got, want := req.Header.Get("Authorization"), "Bearer refreshed"; got != want {
+			t.Errorf("got Authorization: %q, want: %q", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"name": "Gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	var calls []string
+	client.Use(
+		func(next graphql.Doer) graphql.Doer {
+			return graphql.DoerFunc(func(ctx context.Context, req *graphql.Request) (*graphql.Response, error) {
+				calls = append(calls, "outer:"+req.OperationName)
+				if got, want := req.Query, `query GetUser($id:ID!){user(id:$id){name}}`; got != want {
+					t.Errorf("got query %q, want %q", got, want)
+				}
+				if got, want := req.Variables["id"], graphql.ID("1"); got != want {
+					t.Errorf("got variable id %v, want %v", got, want)
+				}
+				return next.Do(ctx, req)
+			})
+		},
+		func(next graphql.Doer) graphql.Doer {
+			return graphql.DoerFunc(func(ctx context.Context, req *graphql.Request) (*graphql.Response, error) {
+				calls = append(calls, "inner")
+				req.Header.Set("Authorization", "Bearer refreshed")
+				resp, err := next.Do(ctx, req)
+				if err == nil {
+					calls = append(calls, "response:"+string(resp.Data))
+				}
+				return resp, err
+			})
+		},
+	)
+
+	var q struct {
+		User struct {
+			Name graphql.String
+		} `graphql:"user(id:$id)"`
+	}
+	err := client.NamedQuery(context.Background(), "GetUser", &q, map[string]interface{}{"id": graphql.ID("1")},
+		graphql.WithHeader("Authorization", "Bearer stale"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := calls, []string{"outer:GetUser", "inner", `response:{"user": {"name": "Gopher"}}`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got calls %q, want %q", got, want)
+	}
+	if got, want := q.User.Name, graphql.String("Gopher"); got != want {
+		t.Errorf("got q.User.Name: %q, want: %q", got, want)
+	}
+}
+
+func TestClient_Use_query(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		if got, want := mustRead(req.Body), `{"query":"query($id:ID!){user(id:$id){name}}","variables":{"id":"1"}}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"name": "Gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	var seen []string
+	client.Use(func(next graphql.Doer) graphql.Doer {
+		return graphql.DoerFunc(func(ctx context.Context, req *graphql.Request) (*graphql.Response, error) {
+			seen = append(seen, req.Query)
+			return next.Do(ctx, req)
+		})
+	})
+
+	var q struct {
+		Name graphql.String
+	}
+	data, err := client.Query(context.Background(), "user(id:$id)", &q, map[string]interface{}{"id": graphql.ID("1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := seen, []string{`query($id:ID!){user(id:$id){name}}`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got queries seen by middleware %q, want %q", got, want)
+	}
+	if got, want := data["user"], map[string]interface{}{"name": "Gopher"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got data: %v, want: %v", got, want)
+	}
+}
+
+func TestClient_WithTransport(t *testing.T) {
+	transport := graphql.TransportFunc(func(ctx context.Context, req *graphql.Request) (*graphql.Response, error) {
+		if got, want := req.Header.Get("User-Agent"), "test-agent"; got != want {
+			t.Errorf("got User-Agent: %q, want: %q", got, want)
+		}
+		switch req.Query {
+		case `mutation{add_star{starred}}`:
+			return &graphql.Response{Data: json.RawMessage(`{"addStar": {"starred": true}}`)}, nil
+		case `{viewer{login}}`:
+			return &graphql.Response{Data: json.RawMessage(`{"viewer": {"login": "gopher"}}`)}, nil
+		}
+		t.Errorf("unexpected query %q", req.Query)
+		return &graphql.Response{}, nil
+	})
+	client := graphql.NewClient("", graphql.WithTransport(transport), graphql.WithUserAgent("test-agent"))
+
+	var m struct {
+		AddStar struct {
+			Starred graphql.Boolean
+		}
+	}
+	err := client.Mutate(context.Background(), &m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.AddStar.Starred, graphql.Boolean(true); got != want {
+		t.Errorf("got m.AddStar.Starred: %v, want: %v", got, want)
+	}
+
+	var q struct {
+		Login graphql.String
+	}
+	data, err := client.Query(context.Background(), "viewer", &q, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := data["viewer"], map[string]interface{}{"login": "gopher"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got data: %v, want: %v", got, want)
+	}
+}
+
+func TestClient_automaticPersistedQueries(t *testing.T) {
+	const query = `query GetUser{user{name}}`
+	sum := sha256.Sum256([]byte(query))
+	hash := hex.EncodeToString(sum[:])
+	var bodies []string
+	registered := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		var body struct {
+			Query      string
+			Extensions struct {
+				PersistedQuery struct {
+					Version    int
+					SHA256Hash string
+				}
+			}
+		}
+		b := mustRead(req.Body)
+		bodies = append(bodies, b)
+		if err := json.Unmarshal([]byte(b), &body); err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case body.Extensions.PersistedQuery.SHA256Hash != hash:
+			t.Errorf("got hash %q, want %q", body.Extensions.PersistedQuery.SHA256Hash, hash)
+		case body.Query != "":
+			registered = true
+		case !registered:
+			mustWrite(w, `{"errors": [{"message": "PersistedQueryNotFound", "extensions": {"code": "PERSISTED_QUERY_NOT_FOUND"}}]}`)
+			return
+		}
+		mustWrite(w, `{"data": {"user": {"name": "Gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+	client.Use(graphql.AutomaticPersistedQueries())
+
+	for i := 0; i < 2; i++ {
+		var q struct {
+			User struct {
+				Name graphql.String
+			}
+		}
+		if err := client.NamedQuery(context.Background(), "GetUser", &q, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := q.User.Name, graphql.String("Gopher"); got != want {
+			t.Errorf("got q.User.Name: %q, want: %q", got, want)
+		}
+	}
+	persisted := `"extensions":{"persistedQuery":{"sha256Hash":"` + hash + `","version":1}}}` + "\n"
+	want := []string{
+		`{"operationName":"GetUser",` + persisted,
+		`{"query":"query GetUser{user{name}}","operationName":"GetUser",` + persisted,
+		`{"operationName":"GetUser",` + persisted,
+	}
+	if !reflect.DeepEqual(bodies, want) {
+		t.Errorf("got bodies:\n%s\nwant:\n%s", strings.Join(bodies, ""), strings.Join(want, ""))
+	}
+}
+
+func TestClient_responseCache(t *testing.T) {
+	var calls int32
+	refreshed := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		var body struct {
+			Variables struct{ ID string }
+		}
+		if err := json.Unmarshal([]byte(mustRead(req.Body)), &body); err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, fmt.Sprintf(`{"data": {"user": {"name": "%s-%d"}}}`, body.Variables.ID, n))
+		if n == 4 {
+			close(refreshed)
+		}
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+	client.Use(graphql.ResponseCache(graphql.ResponseCachePolicy{
+		Store:                graphql.NewMemoryStore(2),
+		TTL:                  50 * time.Millisecond,
+		StaleWhileRevalidate: time.Minute,
+	}))
+
+	get := func(id string, want string) {
+		t.Helper()
+		var q struct {
+			User struct {
+				Name graphql.String
+			} `graphql:"user(id: $id)"`
+		}
+		if err := client.NamedQuery(context.Background(), "GetUser", &q, map[string]interface{}{"id": graphql.ID(id)}); err != nil {
+			t.Fatal(err)
+		}
+		if got := string(q.User.Name); got != want {
+			t.Errorf("got name: %q, want: %q", got, want)
+		}
+	}
+	get("a", "a-1")
+	get("a", "a-1") // Fresh.
+	get("b", "b-2")
+	get("c", "c-3") // Evicts a, the least recently used.
+	if got, want := atomic.LoadInt32(&calls), int32(3); got != want {
+		t.Errorf("got calls: %v, want: %v", got, want)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	get("c", "c-3") // Stale, refreshed in the background.
+	<-refreshed
+	for i := 0; ; i++ {
+		var q struct {
+			User struct {
+				Name graphql.String
+			} `graphql:"user(id: $id)"`
+		}
+		if err := client.NamedQuery(context.Background(), "GetUser", &q, map[string]interface{}{"id": graphql.ID("c")}); err != nil {
+			t.Fatal(err)
+		}
+		if q.User.Name == "c-4" {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("got name: %q, want: %q", q.User.Name, "c-4")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	get("a", "a-5")
+	if got, want := atomic.LoadInt32(&calls), int32(5); got != want {
+		t.Errorf("got calls: %v, want: %v", got, want)
+	}
+}
+
+func TestClient_bearerAuth(t *testing.T) {
+	var authorizations []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		authorizations = append(authorizations, req.Header.Get("Authorization"))
+		if req.Header.Get("Authorization") == "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"viewer": {"login": "gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+	var fetches int
+	client.Use(graphql.BearerAuth(graphql.NewTokenProvider(func(ctx context.Context) (string, time.Time, error) {
+		fetches++
+		return fmt.Sprintf("token-%d", fetches), time.Now().Add(time.Hour), nil
+	})))
+
+	var q struct {
+		Viewer struct {
+			Login graphql.String `graphql:"login"`
+		} `graphql:"viewer"`
+	}
+	for i := 0; i < 2; i++ {
+		if err := client.NamedQuery(context.Background(), "GetViewer", &q, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := fmt.Sprint(authorizations), "[Bearer token-1 Bearer token-2 Bearer token-2]"; got != want {
+		t.Errorf("got authorizations: %v, want: %v", got, want)
+	}
+}
+
+func TestClient_singleflight(t *testing.T) {
+	var calls int32
+	arrived, release := make(chan struct{}), make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(arrived)
+			<-release
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"viewer": {"login": "gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}), graphql.WithSingleflight())
+
+	const n = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var q struct {
+				Viewer struct {
+					Login graphql.String
+				}
+			}
+			err := client.NamedQuery(context.Background(), "GetViewer", &q, nil)
+			if err == nil && q.Viewer.Login != "gopher" {
+				err = fmt.Errorf("got login: %q, want: %q", q.Viewer.Login, "gopher")
+			}
+			errs <- err
+		}()
+	}
+	<-arrived
+	time.Sleep(50 * time.Millisecond) // Let the other queries join the first.
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if got, want := atomic.LoadInt32(&calls), int32(1); got != want {
+		t.Errorf("got calls: %v, want: %v", got, want)
+	}
+
+	// Queries made after the first completes, and mutations, aren't coalesced.
+	var m struct {
+		Viewer struct {
+			Login graphql.String
+		}
+	}
+	if err := client.Mutate(context.Background(), &m, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.NamedQuery(context.Background(), "GetViewer", &m, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := atomic.LoadInt32(&calls), int32(3); got != want {
+		t.Errorf("got calls: %v, want: %v", got, want)
+	}
+}
+
+func TestClient_batching(t *testing.T) {
+	var batches []int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		var reqs []struct {
+			Query     string
+			Variables map[string]string
+		}
+		if err := json.NewDecoder(req.Body).Decode(&reqs); err != nil {
+			t.Fatal(err)
+		}
+		batches = append(batches, len(reqs))
+		var resps []string
+		for _, r := range reqs {
+			switch r.Query {
+			case `mutation($id:ID!){add_star(id:$id){id}}`:
+				resps = append(resps, `{"data": {"add_star": {"id": "`+r.Variables["id"]+`"}}}`)
+			case `{viewer{login}}`:
+				resps = append(resps, `{"data": {"viewer": {"login": "gopher"}}}`)
+			default:
+				t.Errorf("unexpected query %q", r.Query)
+				resps = append(resps, `{}`)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, "["+strings.Join(resps, ",")+"]")
+	})
+	client := graphql.NewClient("/graphql",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithBatching(graphql.Batcher{Window: time.Second, MaxSize: 4}),
+	)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			var m struct {
+				AddStar struct {
+					ID graphql.ID
+				} `graphql:"add_star(id:$id)"`
+			}
+			if err := client.Mutate(context.Background(), &m, map[string]interface{}{"id": graphql.ID(id)}); err != nil {
+				t.Error(err)
+				return
+			}
+			if got, want := m.AddStar.ID, graphql.ID(id); got != want {
+				t.Errorf("got id %v, want %v", got, want)
+			}
+		}(fmt.Sprint(i))
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var q struct {
+			Login graphql.String
+		}
+		data, err := client.Query(context.Background(), "viewer", &q, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if got, want := data["viewer"], map[string]interface{}{"login": "gopher"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got data: %v, want: %v", got, want)
+		}
+	}()
+	wg.Wait()
+	if got, want := batches, []int{4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got batch sizes %v, want %v", got, want)
+	}
+}
+
+func TestClient_withGET(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch req.Method {
+		case http.MethodGet:
+			q := req.URL.Query()
+			if q.Get("query") == `{viewer{login}}` {
+				mustWrite(w, `{"data": {"viewer": {"login": "gopher"}}}`)
+				return
+			}
+			if got, want := q.Get("query"), `query GetUser($id:ID!){user(id:$id){name}}`; got != want {
+				t.Errorf("got query %q, want %q", got, want)
+			}
+			if got, want := q.Get("variables"), `{"id":"1"}`; got != want {
+				t.Errorf("got variables %q, want %q", got, want)
+			}
+			if got, want := q.Get("operationName"), "GetUser"; got != want {
+				t.Errorf("got operationName %q, want %q", got, want)
+			}
+			if got, want := q.Get("key"), "k"; got != want {
+				t.Errorf("got key %q, want %q", got, want)
+			}
+			mustWrite(w, `{"data": {"user": {"name": "Gopher"}}}`)
+		case http.MethodPost:
+			if got, want := mustRead(req.Body), `{"query":"mutation{add_star{id}}"}`+"\n"; got != want {
+				t.Errorf("got body: %v, want %v", got, want)
+			}
+			mustWrite(w, `{"data": {"add_star": {"id": "s1"}}}`)
+		}
+	})
+	client := graphql.NewClient("/graphql?key=k",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithGET(true),
+	)
+
+	var q struct {
+		User struct {
+			Name graphql.String
+		} `graphql:"user(id:$id)"`
+	}
+	if err := client.NamedQuery(context.Background(), "GetUser", &q, map[string]interface{}{"id": graphql.ID("1")}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.User.Name, graphql.String("Gopher"); got != want {
+		t.Errorf("got q.User.Name: %q, want: %q", got, want)
+	}
+	var viewer struct {
+		Login graphql.String
+	}
+	data, err := client.Query(context.Background(), "viewer", &viewer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := data["viewer"], map[string]interface{}{"login": "gopher"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got data: %v, want: %v", got, want)
+	}
+	var m struct {
+		AddStar struct {
+			ID graphql.ID
+		} `graphql:"add_star"`
+	}
+	if err := client.Mutate(context.Background(), &m, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.AddStar.ID, graphql.ID("s1"); got != want {
+		t.Errorf("got m.AddStar.ID: %q, want: %q", got, want)
+	}
+}
+
+func TestClient_Mutate_compression(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		if got, want := req.Header.Get("Content-Encoding"), "gzip"; got != want {
+			t.Errorf("got Content-Encoding: %q, want: %q", got, want)
+		}
+		if got, want := req.Header.Get("Accept-Encoding"), "gzip"; got != want {
+			t.Errorf("got Accept-Encoding: %q, want: %q", got, want)
+		}
+		zr, err := gzip.NewReader(req.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := mustRead(zr), `{"query":"mutation{add_star{id}}"}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		mustWrite(zw, `{"data": {"add_star": {"id": "s1"}}}`)
+		zw.Close()
+	})
+	client := graphql.NewClient("/graphql",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithRequestCompression(0),
+	)
+
+	var m struct {
+		AddStar struct {
+			ID graphql.ID
+		} `graphql:"add_star"`
+	}
+	if err := client.Mutate(context.Background(), &m, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.AddStar.ID, graphql.ID("s1"); got != want {
+		t.Errorf("got m.AddStar.ID: %q, want: %q", got, want)
+	}
+}
+
+func TestClient_Mutate_requestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+	client := graphql.NewClient(srv.URL, graphql.WithTimeout(time.Hour), graphql.WithConnectTimeout(time.Second))
+
+	var m struct {
+		AddStar struct {
+			ID graphql.ID
+		} `graphql:"add_star"`
+	}
+	err := client.Mutate(context.Background(), &m, nil, graphql.WithRequestTimeout(10*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestClient_Mutate_connectTimeout(t *testing.T) {
+	// A server that accepts connections but never completes the TLS handshake.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	client := graphql.NewClient("https://"+l.Addr().String(), graphql.WithConnectTimeout(10*time.Millisecond))
+
+	var m struct {
+		AddStar struct {
+			ID graphql.ID
+		} `graphql:"add_star"`
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = client.Mutate(ctx, &m, nil)
+	if err == nil || !strings.Contains(err.Error(), "handshake timeout") {
+		t.Errorf("got error %v, want TLS handshake timeout", err)
+	}
+	if ctx.Err() != nil {
+		t.Error("connect timeout didn't fire before the operation deadline")
+	}
+}
+
+func TestClient_Mutate_connectionPool(t *testing.T) {
+	var proto string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		proto = req.Proto
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"addStar": {"id": "S1"}}}`)
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	var m struct {
+		AddStar struct {
+			ID graphql.ID `graphql:"id"`
+		} `graphql:"addStar"`
+	}
+	for _, tc := range []struct {
+		pool graphql.ConnectionPool
+		want string
+	}{
+		{graphql.ConnectionPool{MaxIdleConnsPerHost: 100, IdleConnTimeout: time.Minute}, "HTTP/2.0"},
+		{graphql.ConnectionPool{DisableHTTP2: true}, "HTTP/1.1"},
+	} {
+		client := graphql.NewClient(srv.URL, graphql.WithHTTPClient(srv.Client()), graphql.WithConnectionPool(tc.pool))
+		if err := client.Mutate(context.Background(), &m, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := proto, tc.want; got != want {
+			t.Errorf("got protocol: %v, want: %v", got, want)
+		}
+	}
+}
+
+func TestClient_Mutate_proxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if got, want := req.URL.String(), "http://graphql.internal/graphql"; got != want {
+			t.Errorf("got proxied URL: %v, want: %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"addStar": {"id": "S1"}}}`)
+	}))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := graphql.NewClient("http://graphql.internal/graphql", graphql.WithProxy(proxyURL))
+
+	var m struct {
+		AddStar struct {
+			ID graphql.ID `graphql:"id"`
+		} `graphql:"addStar"`
+	}
+	if err := client.Mutate(context.Background(), &m, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.AddStar.ID, graphql.ID("S1"); got != want {
+		t.Errorf("got id: %v, want: %v", got, want)
+	}
+}
+
+func TestClient_Mutate_clientCertificate(t *testing.T) {
+	// A self-signed client certificate, trusted by the server.
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if got, want := req.TLS.PeerCertificates[0].Subject.CommonName, "client"; got != want {
+			t.Errorf("got client certificate: %v, want: %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"addStar": {"id": "S1"}}}`)
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cert)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(srv.Certificate())
+
+	var m struct {
+		AddStar struct {
+			ID graphql.ID `graphql:"id"`
+		} `graphql:"addStar"`
+	}
+	client := graphql.NewClient(srv.URL,
+		graphql.WithTLSConfig(&tls.Config{RootCAs: rootCAs}),
+		graphql.WithClientCertificate(certFile, keyFile),
+	)
+	if err := client.Mutate(context.Background(), &m, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a certificate, the server rejects the connection.
+	client = graphql.NewClient(srv.URL, graphql.WithTLSConfig(&tls.Config{RootCAs: rootCAs}))
+	if err := client.Mutate(context.Background(), &m, nil); err == nil {
+		t.Error("got no error without a client certificate")
+	}
+
+	// A missing certificate fails the handshake.
+	client = graphql.NewClient(srv.URL,
+		graphql.WithTLSConfig(&tls.Config{RootCAs: rootCAs}),
+		graphql.WithClientCertificate(filepath.Join(dir, "missing.crt"), keyFile),
+	)
+	if err := client.Mutate(context.Background(), &m, nil); err == nil || !strings.Contains(err.Error(), "loading client certificate") {
+		t.Errorf("got error: %v, want: loading client certificate", err)
+	}
+}
+
+func TestClient_Mutate_unixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"addStar": {"id": "S1"}}}`)
+	})
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(l)
+	defer srv.Close()
+	client := graphql.NewClient("unix://"+socket+":/graphql", graphql.WithConnectTimeout(time.Second))
+
+	var m struct {
+		AddStar struct {
+			ID graphql.ID `graphql:"id"`
+		} `graphql:"addStar"`
+	}
+	if err := client.Mutate(context.Background(), &m, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.AddStar.ID, graphql.ID("S1"); got != want {
+		t.Errorf("got id: %v, want: %v", got, want)
+	}
+}
+
+func TestClient_Mutate_inputObject(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"mutation($input:CreateIssueInput!){createIssue(input:$input){title}}","variables":{"input":{"Body":null,"assignees":[{"login":"gopher"}],"labels":null,"repositoryId":"R1","title":"Crash"}}}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"createIssue": {"title": "Crash"}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	type userInput struct {
+		Login graphql.String `graphql:"login"`
+	}
+	type repositoryInput struct {
+		RepositoryID graphql.ID `json:"repositoryId"`
+	}
+	type createIssueInput struct {
+		_ struct{} `graphql:"CreateIssueInput"`
+		repositoryInput
+		Title     graphql.String    `json:"title"`
+		Body      *graphql.String   // Explicit null.
+		Milestone *graphql.ID       `json:"milestone,omitempty"` // Left out.
+		Labels    []graphql.ID      `json:"labels"`
+		Assignees []userInput       `json:"assignees,omitempty"`
+		internal  string            // Unexported, left out.
+		Ignored   map[string]string `json:"-"`
+	}
+	var m struct {
+		CreateIssue struct {
+			Title graphql.String
+		} `graphql:"createIssue(input:$input)"`
+	}
+	variables := map[string]interface{}{
+		"input": createIssueInput{
+			repositoryInput: repositoryInput{RepositoryID: "R1"},
+			Title:           "Crash",
+			Assignees:       []userInput{{Login: "gopher"}},
+			Ignored:         map[string]string{"a": "b"},
+		},
+	}
+	err := client.Mutate(context.Background(), &m, variables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.CreateIssue.Title, graphql.String("Crash"); got != want {
+		t.Errorf("got title: %q, want: %q", got, want)
+	}
+}
+
+func TestClient_Mutate_optional(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"mutation($assignee:ID$input:UpdateIssueInput!$milestone:ID$notify:Boolean){updateIssue(input:$input,milestone:$milestone,assignee:$assignee,notify:$notify){title}}","variables":{"assignee":"U1","input":{"body":null,"title":"Crash"},"milestone":null}}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"updateIssue": {"title": "Crash"}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	type updateIssueInput struct {
+		_     struct{}                         `graphql:"UpdateIssueInput"`
+		Title graphql.Optional[graphql.String] `json:"title"`
+		Body  graphql.Optional[graphql.String] `json:"body"`
+		State graphql.Optional[graphql.String] `json:"state"`
+	}
+	var m struct {
+		UpdateIssue struct {
+			Title graphql.String
+		} `graphql:"updateIssue(input:$input,milestone:$milestone,assignee:$assignee,notify:$notify)"`
+	}
+	variables := map[string]interface{}{
+		"input": updateIssueInput{
+			Title: graphql.NewOptional[graphql.String]("Crash"),
+			Body:  graphql.Null[graphql.String](), // Cleared.
+			// State is omitted, so left as is.
+		},
+		"milestone": graphql.Null[graphql.ID](),
+		"assignee":  graphql.NewOptional[graphql.ID]("U1"),
+		"notify":    graphql.Optional[graphql.Boolean]{}, // Omitted, so the default applies.
+	}
+	err := client.Mutate(context.Background(), &m, variables)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_Mutate_skipUnknownFields(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"addStar": {"starrable": {"id": "R1", "stargazerCount": 2}}}}`)
+	})
+	var m struct {
+		AddStar struct {
+			Starrable struct {
+				ID graphql.ID
+			}
+		}
+	}
+
+	strict := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+	err := strict.Mutate(context.Background(), &m, nil)
+	if got, want := fmt.Sprint(err), `decoding data.addStar.starrable.stargazerCount: struct field for "stargazerCount" doesn't exist in any of 1 places to unmarshal`; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+
+	lenient := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithSkipUnknownFields(true))
+	err = lenient.Mutate(context.Background(), &m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.AddStar.Starrable.ID, graphql.ID("R1"); got != want {
+		t.Errorf("got id: %v, want: %v", got, want)
+	}
+}
+
+func TestClient_Mutate_caseSensitiveFields(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"viewer": {"Login": "gopher"}}}`)
+	})
+	var m struct {
+		Viewer struct {
+			Login graphql.String
+		}
+	}
+
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+	err := client.Mutate(context.Background(), &m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Viewer.Login, graphql.String("gopher"); got != want {
+		t.Errorf("got login: %v, want: %v", got, want)
+	}
+
+	sensitive := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithCaseSensitiveFields(true))
+	err = sensitive.Mutate(context.Background(), &m, nil)
+	if got, want := fmt.Sprint(err), `decoding data.viewer.Login: struct field for "Login" doesn't exist in any of 1 places to unmarshal`; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+}
+
+// SearchResult is a Go interface type for the SearchResultItem union.
+type SearchResult interface{ isSearchResult() }
+
+type searchIssue struct {
+	Title graphql.String `graphql:"title"`
+}
+
+type searchRepository struct {
+	Name graphql.String `graphql:"name"`
+}
+
+func (searchIssue) isSearchResult()       {}
+func (*searchRepository) isSearchResult() {}
+
+func TestClient_Query_union(t *testing.T) {
+	graphql.RegisterUnion[SearchResult]("SearchResultItem", map[string]reflect.Type{
+		"Issue":      reflect.TypeOf(searchIssue{}),
+		"Repository": reflect.TypeOf(&searchRepository{}),
+	})
+	defer graphql.RegisterUnion[SearchResult]("SearchResultItem", nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"{search(query: \"crash\"){nodes{__typename,... on Issue{title},... on Repository{name}}}}"}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"search": {"nodes": [
+			{"__typename": "Issue", "title": "Crash on start"},
+			{"name": "graphql", "__typename": "Repository"}
+		]}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	var q struct {
+		Search struct {
+			Nodes []SearchResult
+		} `graphql:"search(query: \"crash\")"`
+	}
+	if _, err := client.Query(context.Background(), "", &q, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := []SearchResult{
+		searchIssue{Title: "Crash on start"},
+		&searchRepository{Name: "graphql"},
+	}
+	if !reflect.DeepEqual(q.Search.Nodes, want) {
+		t.Errorf("got nodes: %#v, want: %#v", q.Search.Nodes, want)
+	}
+}
+
+func TestClient_Query_bigNumbers(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"query($amount:Float$id:ID!){order(id: $id, amount: $amount){id,total}}","variables":{"amount":1.234567890125e+10,"id":"O1"}}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"order": {"id": "O1", "total": 9223372036854775809}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithUseNumber(true))
+
+	var order struct {
+		ID    graphql.ID `graphql:"id"`
+		Total *big.Int   `graphql:"total"`
+	}
+	variables := map[string]interface{}{
+		"id":     graphql.ID("O1"),
+		"amount": big.NewFloat(12345678901.25),
+	}
+	data, err := client.Query(context.Background(), "order(id: $id, amount: $amount)", &order, variables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := order.Total.String(), "9223372036854775809"; got != want {
+		t.Errorf("got total: %v, want: %v", got, want)
+	}
+	if got, want := data["order"].(map[string]interface{})["total"], json.Number("9223372036854775809"); got != want {
+		t.Errorf("got total in data: %#v, want: %#v", got, want)
+	}
+}
+
+// countingCodec is a graphql.Codec counting the values it encodes and decodes.
+type countingCodec struct {
+	marshaled, unmarshaled int
+}
+
+func (c *countingCodec) Marshal(v interface{}) ([]byte, error) {
+	c.marshaled++
+	return graphql.StdCodec.Marshal(v)
+}
+
+func (c *countingCodec) Unmarshal(data []byte, v interface{}) error {
+	c.unmarshaled++
+	return graphql.StdCodec.Unmarshal(data, v)
+}
+
+func TestClient_Mutate_codec(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"mutation{addStar{starrable{id}}}"}`; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"addStar": {"starrable": {"id": "R1"}}}}`)
+	})
+	codec := &countingCodec{}
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithCodec(codec))
+
+	var m struct {
+		AddStar struct {
+			Starrable struct {
+				ID graphql.ID `graphql:"id"`
+			} `graphql:"starrable"`
+		} `graphql:"addStar"`
+	}
+	if err := client.Mutate(context.Background(), &m, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.AddStar.Starrable.ID, graphql.ID("R1"); got != want {
+		t.Errorf("got id: %v, want: %v", got, want)
+	}
+	if codec.marshaled != 1 || codec.unmarshaled != 1 {
+		t.Errorf("got %d values encoded and %d decoded by the codec, want 1 and 1", codec.marshaled, codec.unmarshaled)
+	}
+}
+
+func TestNewClient_nilOption(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"viewer": {"login": "gopher"}}}`)
+	}))
+	defer srv.Close()
+	// The former signature took an *http.Client, which was often nil.
+	client := graphql.NewClient(srv.URL, nil)
+
+	var q struct {
+		Viewer struct {
+			Login graphql.String
+		}
+	}
+	if _, err := client.Query(context.Background(), "", &q, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.Viewer.Login, graphql.String("gopher"); got != want {
+		t.Errorf("got login: %q, want: %q", got, want)
+	}
+}
+
+func TestClient_Query_streamedRequests(t *testing.T) {
+	type issueInput struct {
+		Title  graphql.String   `json:"title"`
+		Labels []graphql.String `json:"labels"`
+	}
+	var (
+		bodies   []string
+		lengths  []int64
+		attempts int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		body := mustRead(req.Body)
+		if req.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = mustRead(zr)
+		}
+		bodies = append(bodies, body)
+		lengths = append(lengths, req.ContentLength)
+		if attempts%2 == 1 {
+			// Have each request retried, for its body to be streamed again.
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"issues": [{"title": "a"}]}}`)
+	}))
+	defer srv.Close()
+
+	var q struct {
+		Issues []struct {
+			Title graphql.String
+		} `graphql:"issues(input: $input, first: $first)"`
+	}
+	variables := map[string]interface{}{
+		"input": []issueInput{
+			{Title: "<a & b>", Labels: []graphql.String{"bug", "help wanted"}},
+			{Title: "\u00e9t\u00e9", Labels: nil},
+		},
+		"first": graphql.Int(2),
+	}
+	retry := graphql.WithRetry(graphql.RetryPolicy{MinBackoff: time.Millisecond})
+	for _, opts := range [][]graphql.ClientOption{
+		{retry},
+		{retry, graphql.WithStreamedRequests(true)},
+		{retry, graphql.WithStreamedRequests(true), graphql.WithRequestCompression(0)},
+	} {
+		client := graphql.NewClient(srv.URL, opts...)
+		if _, err := client.Query(context.Background(), "", &q, variables); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := q.Issues[0].Title, graphql.String("a"); got != want {
+			t.Errorf("got title: %q, want: %q", got, want)
+		}
+	}
+	if len(bodies) != 6 {
+		t.Fatalf("got %d requests, want 6", len(bodies))
+	}
+	for i, body := range bodies {
+		if body != bodies[0] {
+			t.Errorf("got body %d: %s, want: %s", i, body, bodies[0])
+		}
+	}
+	// Uncompressed streamed bodies are sent with their length.
+	if got, want := lengths[2:4], []int64{int64(len(bodies[0])), int64(len(bodies[0]))}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got Content-Length: %v, want: %v", got, want)
+	}
+	if got, want := lengths[4:], []int64{-1, -1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got compressed Content-Length: %v, want: %v", got, want)
+	}
+}
+
+func TestClient_Paginate(t *testing.T) {
+	var queries []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		var body struct {
+			Query     string
+			Variables struct {
+				After *string
+			}
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		queries = append(queries, body.Query)
+		w.Header().Set("Content-Type", "application/json")
+		switch after := body.Variables.After; {
+		case after == nil:
+			mustWrite(w, `{"data": {"repo": {"issues": {"nodes": [{"title": "1"}, {"title": "2"}], "pageInfo": {"hasNextPage": true, "endCursor": "c2"}}}}}`)
+		case *after == "c2":
+			mustWrite(w, `{"data": {"repo": {"issues": {"nodes": [{"title": "3"}], "pageInfo": {"hasNextPage": true, "endCursor": "c3"}}}}}`)
+		default:
+			mustWrite(w, `{"data": {"repo": {"issues": {"nodes": [], "pageInfo": {"hasNextPage": false, "endCursor": null}}}}}`)
+		}
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	var q struct {
+		Repository struct {
+			Issues struct {
+				Nodes []struct {
+					Title graphql.String `graphql:"title"`
+				} `graphql:"nodes"`
+				PageInfo struct {
+					HasNextPage graphql.Boolean `graphql:"hasNextPage"`
+					EndCursor   *graphql.String `graphql:"endCursor"`
+				} `graphql:"pageInfo"`
+			} `graphql:"issues(first:2,after:$after)"`
+		} `graphql:"repo: repository(owner:\"o\")"`
+	}
+	var titles []string
+	err := client.Paginate(context.Background(), &q, nil, "repo.issues", func() error {
+		for _, n := range q.Repository.Issues.Nodes {
+			titles = append(titles, string(n.Title))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(titles, ","), "1,2,3"; got != want {
+		t.Errorf("got titles: %q, want: %q", got, want)
+	}
+	if got, want := len(queries), 3; got != want {
+		t.Fatalf("got queries: %v, want: %v", got, want)
+	}
+	if got, want := queries[0], `query($after:String){repo: repository(owner:"o"){issues(first:2,after:$after){nodes{title},pageInfo{hasNextPage,endCursor}}}}`; got != want {
+		t.Errorf("got query: %q, want: %q", got, want)
+	}
+
+	// An error from fn stops paginating.
+	stop := errors.New("stop")
+	queries = nil
+	err = client.Paginate(context.Background(), &q, nil, "repo.issues", func() error { return stop })
+	if err != stop || len(queries) != 1 {
+		t.Errorf("got error: %v after %v queries, want: stop after 1", err, len(queries))
+	}
+
+	err = client.Paginate(context.Background(), &q, nil, "repo.pulls", func() error { return nil })
+	if got, want := fmt.Sprint(err), "graphql: paginating: no pageInfo{hasNextPage} at repo.pulls"; got != want {
+		t.Errorf("got error: %q, want: %q", got, want)
+	}
+}
+
+func TestClient_Paginate_connection(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.Contains(body, `"after":"c1"`) {
+			mustWrite(w, `{"data": {"issues": {"edges": [{"cursor": "c1", "node": {"title": "1"}}], "pageInfo": {"hasNextPage": true, "hasPreviousPage": false, "startCursor": "c1", "endCursor": "c1"}}}}`)
+			return
+		}
+		mustWrite(w, `{"data": {"issues": {"edges": [{"cursor": "c2", "node": {"title": "2"}}], "pageInfo": {"hasNextPage": false, "hasPreviousPage": true, "startCursor": "c2", "endCursor": "c2"}}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	type Issue struct {
+		Title graphql.String `graphql:"title"`
+	}
+	var q struct {
+		Issues graphql.Connection[Issue] `graphql:"issues(first:1,after:$after)"`
+	}
+	var titles, cursors []string
+	err := client.Paginate(context.Background(), &q, nil, "issues", func() error {
+		for _, issue := range q.Issues.Nodes() {
+			titles = append(titles, string(issue.Title))
+		}
+		for _, e := range q.Issues.Edges {
+			cursors = append(cursors, string(e.Cursor))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(titles, ",")+" "+strings.Join(cursors, ","), "1,2 c1,c2"; got != want {
+		t.Errorf("got titles and cursors: %q, want: %q", got, want)
+	}
+	if !q.Issues.PageInfo.HasPreviousPage || *q.Issues.PageInfo.StartCursor != "c2" {
+		t.Errorf("got page info: %+v, want the last page's", q.Issues.PageInfo)
+	}
+}
+
+func TestClient_PaginateOffset(t *testing.T) {
+	items := []string{"a", "b", "c", "d", "e"}
+	var queries []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		var body struct {
+			Query     string
+			Variables struct{ Skip, Take int }
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		queries = append(queries, body.Query)
+		end := body.Variables.Skip + body.Variables.Take
+		if end > len(items) {
+			end = len(items)
+		}
+		var names []string
+		for _, item := range items[body.Variables.Skip:end] {
+			names = append(names, `{"name": "`+item+`"}`)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, fmt.Sprintf(`{"data": {"users": {"items": [%s], "total": %d}}}`, strings.Join(names, ","), len(items)))
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	var q struct {
+		Users struct {
+			Items []struct {
+				Name graphql.String `graphql:"name"`
+			} `graphql:"items"`
+			Total graphql.Int `graphql:"total"`
+		} `graphql:"users(skip:$skip,take:$take)"`
+	}
+	for _, tc := range []struct {
+		p           graphql.OffsetPagination
+		wantQueries int
+	}{
+		{graphql.OffsetPagination{Path: "users.items", Limit: 2, OffsetVariable: "skip", LimitVariable: "take", TotalCountPath: "users.total"}, 3},
+		{graphql.OffsetPagination{Path: "users.items", Limit: 5, OffsetVariable: "skip", LimitVariable: "take"}, 2}, // The last page is empty.
+	} {
+		queries = nil
+		var names []string
+		err := client.PaginateOffset(context.Background(), &q, nil, tc.p, func() error {
+			for _, item := range q.Users.Items {
+				names = append(names, string(item.Name))
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := strings.Join(names, ","), "a,b,c,d,e"; got != want {
+			t.Errorf("got names: %q, want: %q", got, want)
+		}
+		if got := len(queries); got != tc.wantQueries {
+			t.Errorf("got queries: %v, want: %v", got, tc.wantQueries)
+		}
+		if got, want := queries[0], `query($skip:Int!$take:Int!){users(skip:$skip,take:$take){items{name},total}}`; got != want {
+			t.Errorf("got query: %q, want: %q", got, want)
+		}
+	}
+}
+
+func TestQuery_generic(t *testing.T) {
+	var queries []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		queries = append(queries, body)
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(body, "mutation") {
+			mustWrite(w, `{"data": {"add_star": {"starred": true}}}`)
+			return
+		}
+		mustWrite(w, `{"data": {"viewer": {"login": "gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	type viewerQuery struct {
+		Viewer struct {
+			Login graphql.String
+		}
+	}
+	q, err := graphql.Query[viewerQuery](context.Background(), client, nil, graphql.WithOperationName("GetViewer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.Viewer.Login, graphql.String("gopher"); got != want {
+		t.Errorf("got login: %q, want: %q", got, want)
+	}
+
+	m, err := graphql.Mutate[struct {
+		AddStar struct {
+			Starred graphql.Boolean
+		} `graphql:"add_star(id:$id)"`
+	}](context.Background(), client, map[string]interface{}{"id": graphql.ID("1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.AddStar.Starred {
+		t.Errorf("got starred: false, want: true")
+	}
+	want := []string{
+		`{"query":"query GetViewer{viewer{login}}","operationName":"GetViewer"}` + "\n",
+		`{"query":"mutation($id:ID!){add_star(id:$id){starred}}","variables":{"id":"1"}}` + "\n",
+	}
+	if !reflect.DeepEqual(queries, want) {
+		t.Errorf("got bodies:\n%s\nwant:\n%s", strings.Join(queries, ""), strings.Join(want, ""))
+	}
+}
+
+func TestClient_Query_streamedList(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		result := `{"data": {"repository": {"name": "a", "issues": {"nodes": [{"number": 1}, {"number": 2}, {"number": 3}], "total": 3}}}}`
+		if strings.HasPrefix(mustRead(req.Body), "[") {
+			result = "[" + result + "]"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, result)
+	})
+	type issue struct {
+		Number graphql.Int
+	}
+	var q struct {
+		Repository struct {
+			Name   graphql.String
+			Issues struct {
+				Nodes []issue
+				Total graphql.Int
+			}
+		}
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts []graphql.ClientOption
+	}{
+		{name: "http"},
+		{name: "batched", opts: []graphql.ClientOption{graphql.WithBatching(graphql.Batcher{MaxSize: 1})}},
+	} {
+		client := graphql.NewClient("/graphql", append([]graphql.ClientOption{
+			graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		}, tc.opts...)...)
+
+		var numbers []graphql.Int
+		var resp graphql.Response
+		err := client.NamedQuery(context.Background(), "Issues", &q, nil, graphql.WithResponse(&resp),
+			graphql.WithStreamedList("repository.issues.nodes", func(decode func(interface{}) error) error {
+				var i issue
+				if err := decode(&i); err != nil {
+					return err
+				}
+				numbers = append(numbers, i.Number)
+				return nil
+			}))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got, want := numbers, []graphql.Int{1, 2, 3}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got numbers: %v, want: %v", tc.name, got, want)
+		}
+		if got, want := len(q.Repository.Issues.Nodes), 0; got != want {
+			t.Errorf("%s: got %d nodes, want: %d", tc.name, got, want)
+		}
+		if got, want := q.Repository.Issues.Total, graphql.Int(3); got != want {
+			t.Errorf("%s: got total: %v, want: %v", tc.name, got, want)
+		}
+		if got, want := string(resp.Data), `{"repository":{"name":"a","issues":{"nodes":[],"total":3}}}`; got != want {
+			t.Errorf("%s: got resp.Data: %s, want: %s", tc.name, got, want)
+		}
+	}
+
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+	errStop := errors.New("stop")
+	err := client.NamedQuery(context.Background(), "Issues", &q, nil,
+		graphql.WithStreamedList("repository.issues.nodes", func(decode func(interface{}) error) error {
+			return errStop
+		}))
+	if err != errStop {
+		t.Errorf("got error: %v, want: %v", err, errStop)
+	}
+}
+
+func TestClient_Mutate_maxResponseBytes(t *testing.T) {
+	const body = `{"data": {"viewer": {"login": "gopher"}}}`
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, body)
+	})
+	var m struct {
+		Viewer struct {
+			Login graphql.String
+		}
+	}
+
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithMaxResponseBytes(int64(len(body))))
+	err := client.Mutate(context.Background(), &m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Viewer.Login, graphql.String("gopher"); got != want {
+		t.Errorf("got login: %q, want: %q", got, want)
+	}
+
+	client = graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithMaxResponseBytes(int64(len(body)-1)))
+	err = client.Mutate(context.Background(), &m, nil)
+	var tooLarge *graphql.ResponseTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("got error: %v, want: *graphql.ResponseTooLargeError", err)
+	}
+	if got, want := tooLarge.Limit, int64(len(body)-1); got != want {
+		t.Errorf("got limit: %v, want: %v", got, want)
+	}
+}
+
+func TestClient_Mutate_logger(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"login": {"token": "t"}}}`)
+	})
+	var buf bytes.Buffer
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+		graphql.WithLogRedactor(graphql.RedactVariables("password")))
+
+	var m struct {
+		Login struct {
+			Token graphql.String
+		} `graphql:"login(input: $input)"`
+	}
+	type LoginInput struct {
+		User     string `json:"user"`
+		Password string `json:"password"`
+	}
+	err := client.Mutate(context.Background(), &m, map[string]interface{}{
+		"input": LoginInput{User: "gopher", Password: "secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{`msg="graphql: sending request"`, `type=mutation`, `user:gopher`, `password:[REDACTED]`, `msg="graphql: received response"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got log:\n%s\nwant it to contain: %s", got, want)
+		}
+	}
+	if strings.Contains(got, "secret") {
+		t.Errorf("got log:\n%s\nwant the password redacted", got)
+	}
+}
+
+func TestClient_Mutate_dump(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"viewer": {"login": "gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	var m struct {
+		Viewer struct {
+			Login graphql.String
+		}
+	}
+	var request, response string
+	err := client.Mutate(context.Background(), &m, nil, graphql.WithHeader("X-Request-ID", "a"), graphql.WithDump(func(req, resp []byte) {
+		request, response = string(req), string(resp)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"POST /graphql HTTP/1.1\r\n", "X-Request-Id: a\r\n", `{"query":"mutation{viewer{login}}"}`} {
+		if !strings.Contains(request, want) {
+			t.Errorf("got request:\n%s\nwant it to contain: %q", request, want)
+		}
+	}
+	for _, want := range []string{"HTTP/1.1 200 OK\r\n", `{"data": {"viewer": {"login": "gopher"}}}`} {
+		if !strings.Contains(response, want) {
+			t.Errorf("got response:\n%s\nwant it to contain: %q", response, want)
+		}
+	}
+	if got, want := m.Viewer.Login, graphql.String("gopher"); got != want {
+		t.Errorf("got login: %q, want: %q", got, want)
+	}
+}
+
+func TestClient_NamedQuery_withSchema(t *testing.T) {
+	schema, err := graphql.ParseSchema(`
+		type Query { viewer: User! }
+		type User { login: String! }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"viewer": {"login": "gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}), graphql.WithSchema(schema))
+
+	var q struct {
+		Viewer struct {
+			Login graphql.String
+			Name  graphql.String
+		}
+	}
+	err = client.NamedQuery(context.Background(), "GetViewer", &q, nil)
+	var errs graphql.ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("got error: %v, want: graphql.ValidationErrors", err)
+	}
+	if got, want := err.Error(), "graphql: invalid document: viewer.name: unknown field name on type User"; got != want {
+		t.Errorf("got error: %q, want: %q", got, want)
+	}
+	if got, want := calls, 0; got != want {
+		t.Errorf("got calls: %v, want: %v", got, want)
+	}
+
+	var valid struct {
+		Viewer struct {
+			Login graphql.String
+		}
+	}
+	if err := client.NamedQuery(context.Background(), "GetViewer", &valid, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := valid.Viewer.Login, graphql.String("gopher"); got != want {
+		t.Errorf("got login: %q, want: %q", got, want)
+	}
+}
+
+func TestClient_NamedQuery_deprecationWarning(t *testing.T) {
+	schema, err := graphql.ParseSchema(`
+		type Query { viewer: User! }
+		type User { login: String!, name: String! @deprecated(reason: "Use login.") }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"viewer": {"name": "Gopher"}}}`)
+	})
+	var buf bytes.Buffer
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithSchema(schema), graphql.WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+
+	var q struct {
+		Viewer struct {
+			Name graphql.String
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := client.NamedQuery(context.Background(), "GetViewer", &q, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := buf.String()
+	for _, want := range []string{`level=WARN`, `msg="graphql: deprecated field selected"`, `path=viewer.name`, `field=User.name`, `reason="Use login."`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got log:\n%s\nwant it to contain: %s", got, want)
+		}
+	}
+	if n := strings.Count(got, "deprecated field selected"); n != 1 {
+		t.Errorf("got %v warnings, want: 1", n)
+	}
+}
+
+func TestClient_NamedQuery_cache(t *testing.T) {
+	var calls int32
+	refreshed := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		body := mustRead(req.Body)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasPrefix(body, `{"query":"mutation`):
+			mustWrite(w, `{"data": {"renameViewer": {"__typename": "User", "id": "1", "login": "gopher2"}}}`)
+		default:
+			mustWrite(w, fmt.Sprintf(`{"data": {"viewer": {"__typename": "User", "id": "1", "login": "gopher", "calls": %d}}}`, n))
+		}
+		if n == 4 {
+			close(refreshed)
+		}
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithCache(graphql.NewCache(0)))
+
+	type query struct {
+		Viewer struct {
+			Typename graphql.String `graphql:"__typename"`
+			ID       graphql.String `graphql:"id"`
+			Login    graphql.String `graphql:"login"`
+			Calls    graphql.Int    `graphql:"calls"`
+		} `graphql:"viewer"`
+	}
+	get := func(wantLogin string, wantCalls, wantServerCalls int32, opts ...graphql.RequestOption) {
+		t.Helper()
+		var q query
+		if err := client.NamedQuery(context.Background(), "GetViewer", &q, nil, opts...); err != nil {
+			t.Fatal(err)
+		}
+		if got := string(q.Viewer.Login); got != wantLogin {
+			t.Errorf("got login: %q, want: %q", got, wantLogin)
+		}
+		if got := int32(q.Viewer.Calls); got != wantCalls {
+			t.Errorf("got calls in data: %v, want: %v", got, wantCalls)
+		}
+		if got := atomic.LoadInt32(&calls); wantServerCalls > 0 && got != wantServerCalls {
+			t.Errorf("got server calls: %v, want: %v", got, wantServerCalls)
+		}
+	}
+
+	get("gopher", 1, 1)
+	get("gopher", 1, 1) // From the cache.
+
+	// The mutation result updates the cached entity.
+	var m struct {
+		RenameViewer struct {
+			Typename graphql.String `graphql:"__typename"`
+			ID       graphql.String `graphql:"id"`
+			Login    graphql.String `graphql:"login"`
+		} `graphql:"renameViewer"`
+	}
+	if err := client.Mutate(context.Background(), &m, nil); err != nil {
+		t.Fatal(err)
+	}
+	get("gopher2", 1, 2)
+
+	get("gopher", 3, 3, graphql.WithCachePolicy(graphql.NetworkOnly))
+	get("gopher", 3, 3, graphql.WithCachePolicy(graphql.CacheFirst))
+
+	// Served from the cache, and refreshed in the background.
+	get("gopher", 3, 0, graphql.WithCachePolicy(graphql.CacheAndNetwork))
+	<-refreshed
+	for i := 0; ; i++ {
+		var q query
+		if err := client.NamedQuery(context.Background(), "GetViewer", &q, nil); err != nil {
+			t.Fatal(err)
+		}
+		if q.Viewer.Calls == 4 {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("got calls in data: %v, want: 4", q.Viewer.Calls)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got, want := atomic.LoadInt32(&calls), int32(4); got != want {
+		t.Errorf("got server calls: %v, want: %v", got, want)
+	}
+}
+
+func TestClient_NamedQuery_cacheExpiry(t *testing.T) {
+	var calls int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"repo": {"name": "graphql"}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithCache(graphql.NewCache(50*time.Millisecond)))
+
+	var q struct {
+		Repo struct {
+			Name graphql.String `graphql:"name"`
+		} `graphql:"repo: repository(owner: $owner)"`
+	}
+	query := func(owner string) {
+		t.Helper()
+		if err := client.NamedQuery(context.Background(), "GetRepo", &q, map[string]interface{}{"owner": graphql.String(owner)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	query("a")
+	query("a")
+	if got, want := calls, 1; got != want {
+		t.Errorf("got calls: %v, want: %v", got, want)
+	}
+	query("b") // Different arguments aren't cached.
+	if got, want := calls, 2; got != want {
+		t.Errorf("got calls: %v, want: %v", got, want)
+	}
+	time.Sleep(100 * time.Millisecond)
+	query("a")
+	if got, want := calls, 3; got != want {
+		t.Errorf("got calls: %v, want: %v", got, want)
+	}
+	if got, want := q.Repo.Name, graphql.String("graphql"); got != want {
+		t.Errorf("got name: %q, want: %q", got, want)
+	}
+}
+
+func TestClient_Introspect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		var body struct {
+			Query         string `json:"query"`
+			OperationName string `json:"operationName"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(body.Query, "query IntrospectionQuery{__schema{") || body.OperationName != "IntrospectionQuery" {
+			t.Errorf("got query: %q, operation name: %q, want the introspection query", body.Query, body.OperationName)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"__schema": {
+			"queryType": {"name": "Query"},
+			"mutationType": null,
+			"subscriptionType": null,
+			"types": [
+				{"kind": "OBJECT", "name": "Query", "fields": [
+					{"name": "tags", "args": [], "type": {"kind": "NON_NULL", "name": null, "ofType": {"kind": "LIST", "name": null, "ofType": {"kind": "NON_NULL", "name": null, "ofType": {"kind": "SCALAR", "name": "String", "ofType": null}}}}, "isDeprecated": false, "deprecationReason": null},
+					{"name": "oldTags", "args": [], "type": {"kind": "SCALAR", "name": "String", "ofType": null}, "isDeprecated": true, "deprecationReason": "Use tags."}
+				]},
+				{"kind": "SCALAR", "name": "String"}
+			],
+			"directives": []
+		}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	schema, err := client.Introspect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := schema.QueryType.Name, "Query"; got != want {
+		t.Errorf("got query type: %q, want: %q", got, want)
+	}
+	query := schema.Type("Query")
+	if query == nil {
+		t.Fatal("got type Query: nil, want: non-nil")
+	}
+	tags := query.Field("tags")
+	if got, want := tags.Type.String(), "[String!]!"; got != want {
+		t.Errorf("got type of tags: %q, want: %q", got, want)
+	}
+	if got, want := tags.Type.NamedType(), "String"; got != want {
+		t.Errorf("got named type of tags: %q, want: %q", got, want)
+	}
+	if old := query.Field("oldTags"); !old.IsDeprecated || old.DeprecationReason != "Use tags." {
+		t.Errorf("got oldTags deprecated: %v, reason: %q, want: true, %q", old.IsDeprecated, old.DeprecationReason, "Use tags.")
+	}
+	if got := schema.Type("Missing"); got != nil {
+		t.Errorf("got type Missing: %v, want: nil", got)
+	}
+}
+
+func TestClient_Query_incrementalDelivery(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		if got, want := req.Header.Get("Accept"), "multipart/mixed;deferSpec=20220824, application/json"; got != want {
+			t.Errorf("got Accept: %q, want: %q", got, want)
+		}
+		w.Header().Set("Content-Type", `multipart/mixed; boundary="-"`)
+		mustWrite(w, "\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n"+
+			`{"data": {"hero": {"name": "R2-D2", "friends": [{"name": "Luke"}]}}, "hasNext": true}`+
+			"\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n"+
+			`{"incremental": [{"data": {"primaryFunction": "Astromech"}, "path": ["hero"]}], "hasNext": true}`+
+			"\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n"+
+			`{"incremental": [{"items": [{"name": "Han"}, {"name": "Leia"}], "path": ["hero", "friends", 1]}], "hasNext": false}`+
+			"\r\n-----\r\n")
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	var q struct {
+		Hero struct {
+			Name    graphql.String
+			Details struct {
+				PrimaryFunction graphql.String
+			} `graphql:"... @defer"`
+			Friends []struct {
+				Name graphql.String
+			} `graphql:"friends @stream(initialCount: 1)"`
+		}
+	}
+	var got []string
+	err := client.NamedQuery(context.Background(), "Hero", &q, nil, graphql.WithIncrementalDelivery(func(path []interface{}) error {
+		got = append(got, fmt.Sprintf("%v: %q, %d friends", path, q.Hero.Details.PrimaryFunction, len(q.Hero.Friends)))
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`[]: "", 1 friends`,
+		`[hero]: "Astromech", 1 friends`,
+		`[hero friends 1]: "Astromech", 3 friends`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got deliveries:\n%v\nwant:\n%v", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if got, want := q.Hero.Friends[2].Name, graphql.String("Leia"); got != want {
+		t.Errorf("got friends[2].Name: %q, want: %q", got, want)
+	}
+}
+
+func TestClient_Mutate_upload(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		if err := req.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := req.FormValue("operations"), `{"query":"mutation($avatar:Upload!$docs:[Upload!]!$user:ID!){upload_files(user:$user,avatar:$avatar,docs:$docs){ok}}","variables":{"avatar":null,"docs":[null,null],"user":"u1"}}`+"\n"; got != want {
+			t.Errorf("got operations %s, want %s", got, want)
+		}
+		if got, want := req.FormValue("map"), `{"0":["variables.avatar"],"1":["variables.docs.0"],"2":["variables.docs.1"]}`+"\n"; got != want {
+			t.Errorf("got map %s, want %s", got, want)
+		}
+		for name, want := range map[string]string{"0": "avatar.png:image/png:PNG", "1": "a.txt:application/octet-stream:A", "2": "blob:application/octet-stream:B"} {
+			f, h, err := req.FormFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := h.Filename + ":" + h.Header.Get("Content-Type") + ":" + mustRead(f); got != want {
+				t.Errorf("got file %s: %q, want %q", name, got, want)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"upload_files": {"ok": true}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	var m struct {
+		UploadFiles struct {
+			OK graphql.Boolean
+		} `graphql:"upload_files(user:$user,avatar:$avatar,docs:$docs)"`
+	}
+	err := client.Mutate(context.Background(), &m, map[string]interface{}{
+		"user":   graphql.ID("u1"),
+		"avatar": graphql.Upload{File: strings.NewReader("PNG"), Filename: "avatar.png", ContentType: "image/png"},
+		"docs":   []graphql.Upload{{File: strings.NewReader("A"), Filename: "a.txt"}, {File: strings.NewReader("B")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_Do(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		if got, want := req.Header.Get("Authorization"), "Bearer t"; got != want {
+			t.Errorf("got Authorization: %q, want: %q", got, want)
+		}
+		if err := req.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := req.FormValue("operations"), `{"query":"mutation A($user: ID!, $avatar: Upload!) { a(user: $user, avatar: $avatar) } mutation B { b }","variables":{"avatar":null,"user":"u1"},"operationName":"A"}`+"\n"; got != want {
+			t.Errorf("got operations: %s, want: %s", got, want)
+		}
+		f, _, err := req.FormFile("0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := mustRead(f), "PNG"; got != want {
+			t.Errorf("got file: %q, want: %q", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"a": true}, "errors": [{"message": "partial"}]}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	req := graphql.NewRequest(`mutation A($user: ID!, $avatar: Upload!) { a(user: $user, avatar: $avatar) } mutation B { b }`).
+		Operation("A").
+		Var("user", graphql.ID("u1")).
+		File("avatar", graphql.Upload{File: strings.NewReader("PNG"), Filename: "avatar.png"}).
+		SetHeader("Authorization", "Bearer t")
+	var resp graphql.Response
+	err := client.Do(context.Background(), req, &resp)
+	if got, want := fmt.Sprint(err), "partial"; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+	if got, want := string(resp.Data), `{"a": true}`; got != want {
+		t.Errorf("got data: %s, want: %s", got, want)
+	}
+}
+
+func TestClient_variables(t *testing.T) {
+	var requests int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"viewer": {"login": "gopher"}}}`)
+	})
+	httpClient := &http.Client{Transport: localRoundTripper{handler: mux}}
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(httpClient))
+	strict := graphql.NewClient("/graphql", graphql.WithHTTPClient(httpClient), graphql.WithStrictVariables())
+
+	var q struct {
+		Repository struct {
+			Name string `graphql:"name"`
+		} `graphql:"repository(owner:$owner,name:$name)"`
+	}
+	err := client.NamedQuery(context.Background(), "Repo", &q, map[string]interface{}{"owner": graphql.String("o")})
+	if got, want := fmt.Sprint(err), "graphql: invalid variables: variable $name is used, but not defined"; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+
+	req := graphql.NewRequest(`query($login: String!, $first: Int = 10, $after: String) { user(login: $login) { repositories(first: $first, after: $after) { totalCount } } }`)
+	err = client.Do(context.Background(), req.Var("after", nil), nil)
+	if got, want := fmt.Sprint(err), "graphql: invalid variables: variable $login of type String! is required, but not given"; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+	err = client.Do(context.Background(), req.Var("login", nil), nil)
+	if got, want := fmt.Sprint(err), "graphql: invalid variables: variable $login of type String! is required, but null"; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+
+	req = graphql.NewRequest(`query($login: String, $unused: Int) { viewer { login } }`).Var("extra", 1)
+	if err := client.Do(context.Background(), req, nil); err != nil {
+		t.Errorf("got error: %v, want: nil", err)
+	}
+	err = strict.Do(context.Background(), req, nil)
+	if got, want := fmt.Sprint(err), "graphql: invalid variables: variable $login is defined, but not used; variable $unused is defined, but not used; variable $extra is given, but not defined"; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+	if got, want := requests, 1; got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+}
+
+func TestClient_nestedArguments(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"query($n:Int!$owner:String!){repository(owner:$owner){issues(first: 2, filterBy: {states: [OPEN, CLOSED], labels: [\"bug\"]}){nodes{recent: comments(first: $n, orderBy: {field: CREATED_AT, direction: DESC}){totalCount}}}}}","variables":{"n":1,"owner":"o"}}`+"\n"; got != want {
+			t.Errorf("got body: %s, want: %s", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"repository": {"issues": {"nodes": [{"recent": {"totalCount": 3}}, {"recent": {"totalCount": 5}}]}}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	var q struct {
+		Repository struct {
+			Issues struct {
+				Nodes []struct {
+					Recent struct {
+						TotalCount int `graphql:"totalCount"`
+					} `graphql:"recent: comments(first: $n, orderBy: {field: CREATED_AT, direction: DESC})"`
+				} `graphql:"nodes"`
+			} `graphql:"issues(first: 2, filterBy: {states: [OPEN, CLOSED], labels: [\"bug\"]})"`
+		} `graphql:"repository(owner:$owner)"`
+	}
+	if _, err := client.Query(context.Background(), "", &q, map[string]interface{}{"n": graphql.Int(1), "owner": graphql.String("o")}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(q.Repository.Issues.Nodes), "[{{3}} {{5}}]"; got != want {
+		t.Errorf("got nodes: %s, want: %s", got, want)
+	}
+
+	var bad struct {
+		Repository struct {
+			Issues struct {
+				TotalCount int `graphql:"totalCount"`
+			} `graphql:"issues(filterBy: {states: [OPEN})"`
+		} `graphql:"repository(owner:$owner)"`
+	}
+	_, err := client.Query(context.Background(), "", &bad, map[string]interface{}{"owner": graphql.String("o")})
+	if err == nil || !strings.HasPrefix(err.Error(), `graphql: invalid graphql tag "issues(filterBy: {states: [OPEN})" of field Repository.Issues: `) {
+		t.Errorf("got error: %v, want invalid tag of Repository.Issues", err)
+	}
+}
+
+// localRoundTripper is an http.RoundTripper that executes HTTP transactions
+// by using handler directly, instead of going over an HTTP connection.
+func BenchmarkClient_Mutate(b *testing.B) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.Copy(io.Discard, req.Body)
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"addReaction": {"reaction": {"content": "HOORAY"}, "subject": {"id": "MDU6SXNzdWUyMTc5NTQ0OTc=", "viewerHasReacted": true}}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: handler}}))
+	type reactionContent string
+	type addReactionInput struct {
+		SubjectID graphql.ID      `json:"subjectId"`
+		Content   reactionContent `json:"content"`
+	}
+	variables := map[string]interface{}{
+		"input": addReactionInput{SubjectID: "MDU6SXNzdWUyMTc5NTQ0OTc=", Content: "HOORAY"},
+	}
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			var m struct {
+				AddReaction struct {
+					Reaction struct {
+						Content graphql.String `graphql:"content"`
+					} `graphql:"reaction"`
+					Subject struct {
+						ID               graphql.ID      `graphql:"id"`
+						ViewerHasReacted graphql.Boolean `graphql:"viewerHasReacted"`
+					} `graphql:"subject"`
+				} `graphql:"addReaction(input: $input)"`
+			}
+			if err := client.Mutate(context.Background(), &m, variables); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+type localRoundTripper struct {
+	handler http.Handler
+}
+
+func (l localRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := httptest.NewRecorder()
+	l.handler.ServeHTTP(w, req)
+	return w.Result(), nil
+}
+
+func mustRead(r io.Reader) string {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+func mustWrite(w io.Writer, s string) {
+	_, err := io.WriteString(w, s)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,146 @@
+package graphqltest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	graphql "github.com/nobody05/graphql_go_client"
+)
+
+// TestCodec checks that codec meets the requirements of graphql.Codec,
+// encoding requests and decoding responses as encoding/json does,
+// reporting any mismatch to t. Implementations plugged into clients
+// with graphql.WithCodec are meant to be tested with it:
+//
+//	func TestCodec(t *testing.T) {
+//		graphqltest.TestCodec(t, sonicCodec{})
+//	}
+func TestCodec(t *testing.T, codec graphql.Codec) {
+	t.Run("Request", func(t *testing.T) {
+		req := &graphql.Request{
+			Query: `query($id:ID!){node(id:$id){... on User{login}}}`,
+			Variables: map[string]interface{}{
+				"id":     "VXNlcjox",
+				"count":  json.Number("12345678901234567890"),
+				"text":   "<é \"\\>",
+				"input":  map[string]interface{}{"tags": []string{"a", "b"}, "note": nil},
+				"status": codecStatus(1),
+			},
+			OperationName: "GetNode",
+			Header:        map[string][]string{"Authorization": {"Bearer token"}},
+		}
+		b, err := codec.Marshal(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := json.Marshal(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := decodeTree(t, b), decodeTree(t, want); !reflect.DeepEqual(got, want) {
+			t.Errorf("got request: %s, want: %s", b, want)
+		}
+	})
+
+	t.Run("Response", func(t *testing.T) {
+		data := []byte(`{
+			"data": {"node": {"login": "gopher", "id": 12345678901234567890}},
+			"errors": [{
+				"message": "partial",
+				"locations": [{"line": 1, "column": 2}],
+				"path": ["node", 0, "login"],
+				"extensions": {"code": "PARTIAL"}
+			}],
+			"extensions": {"cost": 3}
+		}`)
+		var got graphql.Response
+		if err := codec.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		// Data must be a copy.
+		for i := range data {
+			data[i] = ' '
+		}
+		var want graphql.Response
+		if err := json.Unmarshal([]byte(`{
+			"data": {"node": {"login": "gopher", "id": 12345678901234567890}},
+			"errors": [{
+				"message": "partial",
+				"locations": [{"line": 1, "column": 2}],
+				"path": ["node", 0, "login"],
+				"extensions": {"code": "PARTIAL"}
+			}],
+			"extensions": {"cost": 3}
+		}`), &want); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := decodeTree(t, got.Data), decodeTree(t, want.Data); !reflect.DeepEqual(got, want) {
+			t.Errorf("got data: %v, want: %v", got, want)
+		}
+		got.Data, want.Data = nil, nil
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got response: %+v, want: %+v", got, want)
+		}
+	})
+
+	t.Run("Unmarshaler", func(t *testing.T) {
+		var got struct {
+			Status codecStatus `json:"status"`
+			Number json.Number `json:"number"`
+		}
+		if err := codec.Unmarshal([]byte(`{"status": "CLOSED", "number": 1.50}`), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != 2 || got.Number != "1.50" {
+			t.Errorf("got status: %v, number: %v, want: 2, 1.50", got.Status, got.Number)
+		}
+	})
+
+	t.Run("InvalidJSON", func(t *testing.T) {
+		for _, data := range []string{``, `{"data":`, `{"data": {}} trailing`, `{"errors": "none"}`} {
+			var resp graphql.Response
+			if err := codec.Unmarshal([]byte(data), &resp); err == nil {
+				t.Errorf("got nil error decoding %q, want non-nil", data)
+			}
+		}
+	})
+}
+
+// codecStatus is a value encoding itself as a string.
+type codecStatus int
+
+func (s codecStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]string{"", "OPEN", "CLOSED"}[s])
+}
+
+func (s *codecStatus) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err != nil {
+		return err
+	}
+	switch name {
+	case "OPEN":
+		*s = 1
+	case "CLOSED":
+		*s = 2
+	default:
+		return fmt.Errorf("unknown status %q", name)
+	}
+	return nil
+}
+
+// decodeTree decodes the JSON encoding b with encoding/json,
+// keeping numbers as they're written.
+func decodeTree(t *testing.T, b []byte) interface{} {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("decoding %s: %v", b, err)
+	}
+	return v
+}
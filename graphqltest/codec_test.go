@@ -0,0 +1,12 @@
+package graphqltest_test
+
+import (
+	"testing"
+
+	graphql "github.com/nobody05/graphql_go_client"
+	"github.com/nobody05/graphql_go_client/graphqltest"
+)
+
+func TestCodec(t *testing.T) {
+	graphqltest.TestCodec(t, graphql.StdCodec)
+}
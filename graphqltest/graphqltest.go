@@ -0,0 +1,238 @@
+// Package graphqltest provides a mock transport for testing code using
+// clients of package graphql, without running GraphQL servers.
+//
+// Tests register the operations they expect, matched by operation name or
+// document, along with canned responses, and optionally the variables they
+// expect them to be sent with:
+//
+//	mock := graphqltest.NewMock(t)
+//	mock.ExpectOperation("GetUser").
+//		WithVariables(map[string]interface{}{"id": "1"}).
+//		Respond(`{"user": {"login": "gopher"}}`)
+//	client := mock.Client()
+//	// Exercise code using client...
+//
+// Unexpected operations, and expected ones that weren't executed by the
+// end of the test, fail the test.
+//
+// Alternatively, a Recorder records the operations of tests against a real
+// server to a file, and replays them from the file in later runs.
+package graphqltest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	graphql "github.com/nobody05/graphql_go_client"
+	"github.com/nobody05/graphql_go_client/internal/parser"
+)
+
+// Mock is a graphql.Transport answering the operations expected by a test
+// with their canned responses. It's safe for concurrent use.
+type Mock struct {
+	t testing.TB
+
+	mu           sync.Mutex
+	expectations []*Expectation
+}
+
+var _ graphql.Transport = (*Mock)(nil)
+
+// NewMock returns a Mock failing t on unexpected operations,
+// and on expected ones that weren't executed when t ends.
+func NewMock(t testing.TB) *Mock {
+	m := &Mock{t: t}
+	t.Cleanup(m.checkExecuted)
+	return m
+}
+
+// Client returns a client executing operations via m,
+// configured by opts.
+func (m *Mock) Client(opts ...graphql.ClientOption) *graphql.Client {
+	return graphql.NewClient("graphqltest", append(opts, graphql.WithTransport(m))...)
+}
+
+// ExpectOperation registers an expected operation with the given name,
+// as given to NamedQuery or WithOperationName, for instance.
+func (m *Mock) ExpectOperation(name string) *Expectation {
+	return m.expect(&Expectation{name: name})
+}
+
+// ExpectDocument registers an expected operation with the given document,
+// exactly as sent by the client, such as "query{viewer{login}}".
+func (m *Mock) ExpectDocument(document string) *Expectation {
+	return m.expect(&Expectation{document: document})
+}
+
+func (m *Mock) expect(e *Expectation) *Expectation {
+	e.times = 1
+	m.mu.Lock()
+	m.expectations = append(m.expectations, e)
+	m.mu.Unlock()
+	return e
+}
+
+// Execute implements graphql.Transport, answering req with the response
+// of the first expectation it matches that isn't used up, in the order
+// the expectations were registered.
+func (m *Mock) Execute(ctx context.Context, req *graphql.Request) (*graphql.Response, error) {
+	name := operationName(req)
+	m.mu.Lock()
+	var e *Expectation
+	for _, x := range m.expectations {
+		if x.matches(name, req.Query) && (x.times == 0 || x.calls < x.times) {
+			e = x
+			e.calls++
+			break
+		}
+	}
+	m.mu.Unlock()
+	if e == nil {
+		m.t.Errorf("graphqltest: unexpected operation %q: %s", name, req.Query)
+		return nil, fmt.Errorf("graphqltest: unexpected operation %q", name)
+	}
+	if e.variables != nil {
+		if got, want, ok := equalJSON(req.Variables, e.variables); !ok {
+			m.t.Errorf("graphqltest: operation %s: got variables: %s, want: %s", e, got, want)
+			return nil, fmt.Errorf("graphqltest: operation %s: unexpected variables", e)
+		}
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	resp := e.response
+	return &resp, nil
+}
+
+// checkExecuted fails the test if expected operations weren't executed.
+func (m *Mock) checkExecuted() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.expectations {
+		if e.times > 0 && e.calls < e.times {
+			m.t.Errorf("graphqltest: operation %s executed %d times, want %d", e, e.calls, e.times)
+		}
+	}
+}
+
+// Expectation is an operation expected by a Mock, along with its response.
+// Its methods configure it, returning it for chaining, and must be called
+// before the operation is executed.
+type Expectation struct {
+	name      string // Operation name, if matched by name.
+	document  string // Document, if matched by document.
+	variables map[string]interface{}
+	response  graphql.Response
+	err       error
+	times     int // Number of executions expected, or 0 for any.
+	calls     int // Number of executions so far.
+}
+
+// String returns the operation name or document that e is matched by.
+func (e *Expectation) String() string {
+	if e.document != "" {
+		return fmt.Sprintf("%q", e.document)
+	}
+	return e.name
+}
+
+func (e *Expectation) matches(name, document string) bool {
+	if e.document != "" {
+		return document == e.document
+	}
+	return name == e.name
+}
+
+// WithVariables makes the operation expect to be sent with variables,
+// which are compared by their JSON encoding, failing the test if they
+// differ.
+func (e *Expectation) WithVariables(variables map[string]interface{}) *Expectation {
+	if variables == nil {
+		variables = map[string]interface{}{}
+	}
+	e.variables = variables
+	return e
+}
+
+// Respond sets the data of the response: a JSON document, given as
+// a string, []byte or json.RawMessage, or a value encoded as JSON.
+func (e *Expectation) Respond(data interface{}) *Expectation {
+	var raw json.RawMessage
+	switch data := data.(type) {
+	case string:
+		raw = json.RawMessage(data)
+	case []byte:
+		raw = json.RawMessage(data)
+	case json.RawMessage:
+		raw = data
+	default:
+		var err error
+		raw, err = json.Marshal(data)
+		if err != nil {
+			panic(fmt.Sprintf("graphqltest: encoding data: %v", err))
+		}
+	}
+	e.response.Data = raw
+	return e
+}
+
+// RespondErrors sets the GraphQL errors of the response,
+// which may accompany data set by Respond.
+func (e *Expectation) RespondErrors(errs ...graphql.Error) *Expectation {
+	e.response.Errors = errs
+	return e
+}
+
+// RespondExtensions sets the extensions of the response.
+func (e *Expectation) RespondExtensions(extensions map[string]interface{}) *Expectation {
+	e.response.Extensions = extensions
+	return e
+}
+
+// Fail makes the operation fail with err, as a transport error,
+// such as a network error or a *graphql.HTTPError.
+func (e *Expectation) Fail(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// Times sets the number of times the operation is expected, which
+// defaults to 1. If n is 0, it may be executed any number of times.
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+// operationName returns the name of the operation requested by req.
+func operationName(req *graphql.Request) string {
+	if req.OperationName != "" {
+		return req.OperationName
+	}
+	doc, err := parser.Parse(req.Query)
+	if err != nil || len(doc.Operations) != 1 {
+		return ""
+	}
+	return doc.Operations[0].Name
+}
+
+// equalJSON reports whether a and b have equal JSON encodings,
+// returning them.
+func equalJSON(a, b interface{}) (aJSON, bJSON []byte, ok bool) {
+	aJSON, errA := json.Marshal(a)
+	bJSON, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return aJSON, bJSON, false
+	}
+	var x, y interface{}
+	if json.Unmarshal(aJSON, &x) != nil || json.Unmarshal(bJSON, &y) != nil {
+		return aJSON, bJSON, false
+	}
+	if x == nil {
+		x = map[string]interface{}{}
+	}
+	return aJSON, bJSON, reflect.DeepEqual(x, y)
+}
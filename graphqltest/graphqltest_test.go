@@ -0,0 +1,93 @@
+package graphqltest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	graphql "github.com/nobody05/graphql_go_client"
+	"github.com/nobody05/graphql_go_client/graphqltest"
+)
+
+type user struct {
+	User struct {
+		Login string `graphql:"login"`
+	} `graphql:"user(id:$id)"`
+}
+
+func TestMock(t *testing.T) {
+	mock := graphqltest.NewMock(t)
+	mock.ExpectOperation("GetUser").
+		WithVariables(map[string]interface{}{"id": "1"}).
+		Respond(`{"user": {"login": "gopher"}}`).
+		Times(2)
+	mock.ExpectDocument(`mutation{addStar{id}}`).
+		RespondErrors(graphql.Error{Message: "not allowed"})
+	client := mock.Client()
+
+	var q user
+	for i := 0; i < 2; i++ {
+		if err := client.NamedQuery(context.Background(), "GetUser", &q, map[string]interface{}{"id": graphql.ID("1")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := q.User.Login, "gopher"; got != want {
+		t.Errorf("got login: %q, want: %q", got, want)
+	}
+	var m struct {
+		AddStar struct {
+			ID string `graphql:"id"`
+		} `graphql:"addStar"`
+	}
+	if err := client.Mutate(context.Background(), &m, nil); err == nil || err.Error() != "not allowed" {
+		t.Errorf("got error: %v, want: not allowed", err)
+	}
+}
+
+// recorder is a testing.TB recording errors rather than failing.
+type recorder struct {
+	testing.TB
+	errors   []string
+	cleanups []func()
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Cleanup(f func()) {
+	r.cleanups = append(r.cleanups, f)
+}
+
+func TestMock_failures(t *testing.T) {
+	r := &recorder{}
+	mock := graphqltest.NewMock(r)
+	mock.ExpectOperation("GetUser").WithVariables(map[string]interface{}{"id": "1"})
+	mock.ExpectOperation("GetViewer")
+	mock.ExpectOperation("Flaky").Fail(errors.New("connection reset"))
+	client := mock.Client()
+
+	var q user
+	if err := client.NamedQuery(context.Background(), "GetUser", &q, map[string]interface{}{"id": graphql.ID("2")}); err == nil {
+		t.Error("got no error for unexpected variables")
+	}
+	if err := client.NamedQuery(context.Background(), "GetRepository", &q, map[string]interface{}{"id": graphql.ID("1")}); err == nil {
+		t.Error("got no error for unexpected operation")
+	}
+	if err := client.NamedQuery(context.Background(), "Flaky", &q, map[string]interface{}{"id": graphql.ID("1")}); err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Errorf("got error: %v, want: connection reset", err)
+	}
+	for _, f := range r.cleanups {
+		f()
+	}
+	want := []string{
+		`graphqltest: operation GetUser: got variables: {"id":"2"}, want: {"id":"1"}`,
+		`graphqltest: unexpected operation "GetRepository": query GetRepository($id:ID!){user(id:$id){login}}`,
+		`graphqltest: operation GetViewer executed 0 times, want 1`,
+	}
+	if got := r.errors; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
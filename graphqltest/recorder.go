@@ -0,0 +1,316 @@
+package graphqltest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	graphql "github.com/nobody05/graphql_go_client"
+)
+
+// Mode is how a Recorder handles operations.
+type Mode int
+
+const (
+	// ModeAuto replays the recording file if it exists,
+	// and records it otherwise. It's the default.
+	ModeAuto Mode = iota
+
+	// ModeRecord sends operations to the server, and records them,
+	// replacing the recording file.
+	ModeRecord
+
+	// ModeReplay answers operations from the recording file,
+	// without contacting the server.
+	ModeReplay
+)
+
+// Recorder records the operations of a client and the responses of the
+// server to a file, and replays them from the file in later runs, so that
+// tests written against a real server run deterministically and offline,
+// such as in CI. Its middleware is added to the client under test:
+//
+//	r := graphqltest.NewRecorder(t, "testdata/get_user.json",
+//		graphqltest.WithRedactor(graphql.RedactVariables("token")))
+//	client := graphql.NewClient(url)
+//	client.Use(r.Middleware())
+//
+// Operations are replayed by matching their document, variables and
+// operation name, after redaction, to those recorded, each recorded
+// operation being used once. Operations with no match fail the test.
+//
+// Recordings don't hold the values of the Authorization and Cookie headers,
+// or those redacted via WithRedactedHeaders, nor the values of variables
+// redacted via WithRedactor, so that they can be committed.
+type Recorder struct {
+	t        testing.TB
+	file     string
+	mode     Mode
+	redactor graphql.Redactor
+	headers  []string // Names of headers to redact.
+
+	mu           sync.Mutex
+	interactions []*interaction // Recorded, or to replay.
+	used         []bool         // Whether each interaction was replayed.
+}
+
+// RecorderOption configures a Recorder.
+type RecorderOption func(*Recorder)
+
+// WithMode sets how the Recorder handles operations.
+// The default is ModeAuto.
+func WithMode(m Mode) RecorderOption {
+	return func(r *Recorder) {
+		r.mode = m
+	}
+}
+
+// WithRedactor makes the Recorder replace the values of variables and
+// input object fields by those returned by redactor in its recordings,
+// such as graphql.RedactVariables("password").
+func WithRedactor(redactor graphql.Redactor) RecorderOption {
+	return func(r *Recorder) {
+		r.redactor = redactor
+	}
+}
+
+// WithRedactedHeaders makes the Recorder leave out the values of
+// the request and response headers with the given names from its
+// recordings, besides Authorization and Cookie.
+func WithRedactedHeaders(names ...string) RecorderOption {
+	return func(r *Recorder) {
+		r.headers = append(r.headers, names...)
+	}
+}
+
+// NewRecorder returns a Recorder recording to, or replaying from, file,
+// as configured by opts. In ModeReplay, or ModeAuto if file exists,
+// the file is read right away, failing t if it can't be. Recordings are
+// written to file, creating its directory as needed, when t ends.
+func NewRecorder(t testing.TB, file string, opts ...RecorderOption) *Recorder {
+	r := &Recorder{t: t, file: file, headers: []string{"Authorization", "Cookie", "Set-Cookie"}}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.mode == ModeAuto {
+		r.mode = ModeRecord
+		if _, err := os.Stat(file); err == nil {
+			r.mode = ModeReplay
+		}
+	}
+	if r.mode == ModeReplay {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("graphqltest: reading recording: %v", err)
+		}
+		if err := json.Unmarshal(b, &r.interactions); err != nil {
+			t.Fatalf("graphqltest: decoding recording %s: %v", file, err)
+		}
+		r.used = make([]bool, len(r.interactions))
+		return r
+	}
+	t.Cleanup(r.write)
+	return r
+}
+
+// interaction is a recorded operation, and its outcome.
+type interaction struct {
+	Request  recordedRequest   `json:"request"`
+	Response *recordedResponse `json:"response,omitempty"`
+	Error    *recordedError    `json:"error,omitempty"`
+}
+
+type recordedRequest struct {
+	Query         string                 `json:"query,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+	DocumentID    string                 `json:"id,omitempty"`
+	Header        http.Header            `json:"header,omitempty"`
+}
+
+type recordedResponse struct {
+	Data       json.RawMessage        `json:"data,omitempty"`
+	Errors     graphql.Errors         `json:"errors,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+	StatusCode int                    `json:"statusCode,omitempty"`
+	Header     http.Header            `json:"header,omitempty"`
+}
+
+// recordedError is an error getting a response: an *graphql.HTTPError
+// if StatusCode is set, or any other error otherwise.
+type recordedError struct {
+	Message    string      `json:"message,omitempty"`
+	StatusCode int         `json:"statusCode,omitempty"`
+	Status     string      `json:"status,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	Header     http.Header `json:"header,omitempty"`
+}
+
+// Middleware returns middleware recording or replaying the operations
+// of the client it's added to.
+func (r *Recorder) Middleware() graphql.Middleware {
+	return func(next graphql.Doer) graphql.Doer {
+		return graphql.DoerFunc(func(ctx context.Context, req *graphql.Request) (*graphql.Response, error) {
+			recorded, err := r.request(req)
+			if err != nil {
+				return nil, err
+			}
+			if r.mode == ModeReplay {
+				return r.replay(recorded)
+			}
+			resp, err := next.Do(ctx, req)
+			r.record(recorded, resp, err)
+			return resp, err
+		})
+	}
+}
+
+// request returns req as recorded, redacted.
+func (r *Recorder) request(req *graphql.Request) (recordedRequest, error) {
+	// Encode the variables as they're sent, to redact them.
+	var variables map[string]interface{}
+	if len(req.Variables) > 0 {
+		b, err := json.Marshal(req.Variables)
+		if err != nil {
+			return recordedRequest{}, err
+		}
+		if err := json.Unmarshal(b, &variables); err != nil {
+			return recordedRequest{}, err
+		}
+		if r.redactor != nil {
+			variables = redactValue(variables, r.redactor).(map[string]interface{})
+		}
+	}
+	return recordedRequest{
+		Query:         req.Query,
+		Variables:     variables,
+		OperationName: req.OperationName,
+		DocumentID:    req.DocumentID,
+		Header:        r.redactHeader(req.Header),
+	}, nil
+}
+
+// replay returns the outcome recorded for the first unused interaction
+// matching req.
+func (r *Recorder) replay(req recordedRequest) (*graphql.Response, error) {
+	r.mu.Lock()
+	var in *interaction
+	for i, x := range r.interactions {
+		if !r.used[i] && x.Request.Query == req.Query && x.Request.OperationName == req.OperationName &&
+			x.Request.DocumentID == req.DocumentID && reflect.DeepEqual(x.Request.Variables, req.Variables) {
+			r.used[i] = true
+			in = x
+			break
+		}
+	}
+	r.mu.Unlock()
+	if in == nil {
+		variables, _ := json.Marshal(req.Variables)
+		r.t.Errorf("graphqltest: no recorded operation in %s matches %s with variables %s", r.file, req.Query, variables)
+		return nil, fmt.Errorf("graphqltest: no recorded operation matches")
+	}
+	if e := in.Error; e != nil {
+		if e.StatusCode != 0 {
+			return nil, &graphql.HTTPError{StatusCode: e.StatusCode, Status: e.Status, Body: []byte(e.Body), Header: e.Header}
+		}
+		return nil, errors.New(e.Message)
+	}
+	if in.Response == nil {
+		return nil, fmt.Errorf("graphqltest: recorded operation has neither response nor error")
+	}
+	return &graphql.Response{
+		Data:       in.Response.Data,
+		Errors:     in.Response.Errors,
+		Extensions: in.Response.Extensions,
+		StatusCode: in.Response.StatusCode,
+		Header:     in.Response.Header,
+	}, nil
+}
+
+// record records req, along with resp or err.
+func (r *Recorder) record(req recordedRequest, resp *graphql.Response, err error) {
+	in := &interaction{Request: req}
+	var httpErr *graphql.HTTPError
+	switch {
+	case errors.As(err, &httpErr):
+		in.Error = &recordedError{
+			StatusCode: httpErr.StatusCode,
+			Status:     httpErr.Status,
+			Body:       string(httpErr.Body),
+			Header:     r.redactHeader(httpErr.Header),
+		}
+	case err != nil:
+		in.Error = &recordedError{Message: err.Error()}
+	default:
+		in.Response = &recordedResponse{
+			Data:       resp.Data,
+			Errors:     resp.Errors,
+			Extensions: resp.Extensions,
+			StatusCode: resp.StatusCode,
+			Header:     r.redactHeader(resp.Header),
+		}
+	}
+	r.mu.Lock()
+	r.interactions = append(r.interactions, in)
+	r.mu.Unlock()
+}
+
+// write writes the recorded interactions to the recording file.
+func (r *Recorder) write() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, err := json.MarshalIndent(r.interactions, "", "\t")
+	if err != nil {
+		r.t.Errorf("graphqltest: encoding recording: %v", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(r.file), 0o755); err != nil {
+		r.t.Errorf("graphqltest: writing recording: %v", err)
+		return
+	}
+	if err := os.WriteFile(r.file, append(b, '\n'), 0o644); err != nil {
+		r.t.Errorf("graphqltest: writing recording: %v", err)
+	}
+}
+
+// redactHeader returns a copy of header without the values of the
+// headers to redact.
+func (r *Recorder) redactHeader(header http.Header) http.Header {
+	if len(header) == 0 {
+		return nil
+	}
+	h := header.Clone()
+	for _, name := range r.headers {
+		if _, ok := h[http.CanonicalHeaderKey(name)]; ok {
+			h.Set(name, "[REDACTED]")
+		}
+	}
+	return h
+}
+
+// redactValue returns v, decoded from JSON, with the values of the
+// object members replaced as by redactor.
+func redactValue(v interface{}, redactor graphql.Redactor) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for name, value := range v {
+			m[name] = redactValue(redactor(name, value), redactor)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, value := range v {
+			s[i] = redactValue(value, redactor)
+		}
+		return s
+	}
+	return v
+}
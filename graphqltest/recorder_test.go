@@ -0,0 +1,78 @@
+package graphqltest_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	graphql "github.com/nobody05/graphql_go_client"
+	"github.com/nobody05/graphql_go_client/graphqltest"
+)
+
+func TestRecorder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Api-Key", "server-secret")
+		w.Write([]byte(`{"data": {"user": {"login": "gopher"}}}`))
+	}))
+	file := filepath.Join(t.TempDir(), "testdata", "get_user.json")
+	opts := []graphqltest.RecorderOption{
+		graphqltest.WithRedactor(graphql.RedactVariables("token")),
+		graphqltest.WithRedactedHeaders("X-Api-Key"),
+	}
+	getUser := func(t *testing.T, client *graphql.Client, token string) {
+		var q user
+		if err := client.NamedQuery(context.Background(), "GetUser", &q, map[string]interface{}{"id": graphql.ID("1")},
+			graphql.WithHeader("Authorization", "Bearer "+token)); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := q.User.Login, "gopher"; got != want {
+			t.Errorf("got login: %q, want: %q", got, want)
+		}
+	}
+
+	t.Run("record", func(t *testing.T) {
+		r := graphqltest.NewRecorder(t, file, opts...)
+		client := graphql.NewClient(srv.URL)
+		client.Use(r.Middleware())
+		getUser(t, client, "secret")
+	})
+	srv.Close()
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "secret") {
+		t.Errorf("got recording with secrets:\n%s", b)
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		r := graphqltest.NewRecorder(t, file, opts...)
+		client := graphql.NewClient(srv.URL)
+		client.Use(r.Middleware())
+		getUser(t, client, "another-secret")
+	})
+}
+
+func TestRecorder_unmatched(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "empty.json")
+	if err := os.WriteFile(file, []byte("[]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r := &recorder{}
+	rec := graphqltest.NewRecorder(r, file, graphqltest.WithMode(graphqltest.ModeReplay))
+	client := graphql.NewClient("graphqltest")
+	client.Use(rec.Middleware())
+
+	var q user
+	if err := client.NamedQuery(context.Background(), "GetUser", &q, map[string]interface{}{"id": graphql.ID("1")}); err == nil {
+		t.Error("got no error for unrecorded operation")
+	}
+	if got, want := len(r.errors), 1; got != want {
+		t.Errorf("got errors: %q, want %d", r.errors, want)
+	}
+}
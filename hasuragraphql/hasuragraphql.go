@@ -0,0 +1,121 @@
+// Package hasuragraphql provides conveniences for GraphQL clients of package
+// graphql talking to Hasura: options setting its admin secret and role
+// headers, and typed access to the codes of the errors it reports.
+//
+// For instance, to insert a user as an admin acting with the "user" role,
+// and tell uniqueness violations from other errors:
+//
+//	client := graphql.NewClient(url, hasuragraphql.WithAdminSecret(secret), hasuragraphql.WithRole("user"))
+//	err := client.Mutate(ctx, &m, variables)
+//	if hasuragraphql.HasCode(err, hasuragraphql.CodeConstraintViolation) {
+//		// The user exists already.
+//	}
+package hasuragraphql
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	graphql "github.com/nobody05/graphql_go_client"
+)
+
+// Headers that Hasura authorizes requests with.
+const (
+	adminSecretHeader = "X-Hasura-Admin-Secret"
+	roleHeader        = "X-Hasura-Role"
+)
+
+// WithAdminSecret makes the client authenticate its requests
+// with the admin secret of the Hasura instance.
+func WithAdminSecret(secret string) graphql.ClientOption {
+	return graphql.WithDefaultHeaders(http.Header{adminSecretHeader: {secret}})
+}
+
+// WithRole makes the client's requests run with the permissions of role,
+// rather than those of the admin, or the default role of the JWT or
+// webhook authenticating them.
+func WithRole(role string) graphql.ClientOption {
+	return graphql.WithDefaultHeaders(http.Header{roleHeader: {role}})
+}
+
+// WithRequestRole makes the request run with the permissions of role,
+// overriding that set by WithRole.
+func WithRequestRole(role string) graphql.RequestOption {
+	return graphql.WithHeader(roleHeader, role)
+}
+
+// Code is the code of an error reported by Hasura, in the "code"
+// extension of the error.
+type Code string
+
+// Codes of errors reported by Hasura.
+const (
+	CodeValidationFailed    Code = "validation-failed"    // The request doesn't match the schema.
+	CodeParseFailed         Code = "parse-failed"         // The request isn't valid GraphQL or JSON.
+	CodeConstraintViolation Code = "constraint-violation" // A database constraint, such as uniqueness, was violated.
+	CodeDataException       Code = "data-exception"       // The database rejected a value, such as an invalid UUID.
+	CodePermissionError     Code = "permission-error"     // The role isn't allowed to perform the operation.
+	CodeAccessDenied        Code = "access-denied"        // The admin secret or role is missing or wrong.
+	CodeInvalidJWT          Code = "invalid-jwt"          // The JWT authenticating the request is invalid or expired.
+	CodeInvalidHeaders      Code = "invalid-headers"      // Headers required for authorization are missing.
+	CodeNotExists           Code = "not-exists"           // A referenced object doesn't exist.
+	CodeAlreadyExists       Code = "already-exists"       // An object being created exists already.
+	CodeUnexpected          Code = "unexpected"           // An internal error occurred.
+)
+
+// Error is an error reported by Hasura.
+type Error struct {
+	// Code is the code of the error.
+	Code Code
+
+	// Path is the JSON path of the part of the request that caused the
+	// error, such as "$.selectionSet.insert_users.args.objects".
+	Path string
+
+	// Message describes the error.
+	Message string
+
+	// Internal holds the details of errors of the database, which Hasura
+	// includes for admin requests if its dev mode is enabled.
+	Internal interface{}
+}
+
+// Error implements error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("hasura: %s: %s", e.Code, e.Message)
+}
+
+// Errors returns the errors reported by Hasura among the GraphQL errors of
+// err, those with a code, or nil if err doesn't hold graphql.Errors.
+func Errors(err error) []*Error {
+	var gqlErrs graphql.Errors
+	if !errors.As(err, &gqlErrs) {
+		return nil
+	}
+	var errs []*Error
+	for _, e := range gqlErrs {
+		code, _ := e.Extensions["code"].(string)
+		if code == "" {
+			continue
+		}
+		path, _ := e.Extensions["path"].(string)
+		errs = append(errs, &Error{
+			Code:     Code(code),
+			Path:     path,
+			Message:  e.Message,
+			Internal: e.Extensions["internal"],
+		})
+	}
+	return errs
+}
+
+// HasCode reports whether err holds an error reported by Hasura with code.
+func HasCode(err error, code Code) bool {
+	for _, e := range Errors(err) {
+		if e.Code == code {
+			return true
+		}
+	}
+	return false
+}
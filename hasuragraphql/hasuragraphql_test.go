@@ -0,0 +1,64 @@
+package hasuragraphql_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	graphql "github.com/nobody05/graphql_go_client"
+	"github.com/nobody05/graphql_go_client/hasuragraphql"
+)
+
+func TestClient(t *testing.T) {
+	var role string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/graphql", func(w http.ResponseWriter, req *http.Request) {
+		if got, want := req.Header.Get("X-Hasura-Admin-Secret"), "secret"; got != want {
+			t.Errorf("got admin secret: %q, want: %q", got, want)
+		}
+		role = req.Header.Get("X-Hasura-Role")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"errors": [{"message": "Uniqueness violation. duplicate key value violates unique constraint \"users_email_key\"", "extensions": {"path": "$.selectionSet.insert_users.args.objects", "code": "constraint-violation"}}]}`))
+	})
+	client := graphql.NewClient("/v1/graphql",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		hasuragraphql.WithAdminSecret("secret"),
+		hasuragraphql.WithRole("user"),
+	)
+
+	var m struct {
+		InsertUsers struct {
+			AffectedRows int `graphql:"affected_rows"`
+		} `graphql:"insert_users(objects:[{email:\"gopher@example.com\"}])"`
+	}
+	err := client.Mutate(context.Background(), &m, nil)
+	if got, want := role, "user"; got != want {
+		t.Errorf("got role: %q, want: %q", got, want)
+	}
+	if !hasuragraphql.HasCode(err, hasuragraphql.CodeConstraintViolation) {
+		t.Fatalf("got error: %v, want a constraint violation", err)
+	}
+	errs := hasuragraphql.Errors(err)
+	if got, want := errs[0].Path, "$.selectionSet.insert_users.args.objects"; got != want {
+		t.Errorf("got path: %q, want: %q", got, want)
+	}
+	if hasuragraphql.HasCode(err, hasuragraphql.CodePermissionError) {
+		t.Error("got a permission error, want none")
+	}
+
+	client.Mutate(context.Background(), &m, nil, hasuragraphql.WithRequestRole("editor"))
+	if got, want := role, "editor"; got != want {
+		t.Errorf("got role: %q, want: %q", got, want)
+	}
+}
+
+type localRoundTripper struct {
+	handler http.Handler
+}
+
+func (l localRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := httptest.NewRecorder()
+	l.handler.ServeHTTP(w, req)
+	return w.Result(), nil
+}
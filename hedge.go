@@ -0,0 +1,150 @@
+package graphql
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// HedgePolicy configures the hedging of requests, as set by WithHedging:
+// if a request hasn't been answered within Delay, the same request is sent
+// again, and whichever succeeds first is used, the others being canceled.
+// This trades extra load on the server for lower tail latency, when slow
+// responses are caused by a slow replica or connection rather than by the
+// request itself. The zero value of each field selects a reasonable default.
+//
+// Only queries are hedged, as sending a mutation twice could apply it twice.
+type HedgePolicy struct {
+	// Delay is how long to wait for a response before sending
+	// the next hedged request. It's typically set to a high percentile,
+	// such as the 95th, of the latency of requests.
+	// Defaults to 100 milliseconds.
+	Delay time.Duration
+
+	// MaxHedges is the maximum number of requests sent in addition
+	// to the first one. Defaults to 1.
+	MaxHedges int
+}
+
+// delay returns how long to wait before sending the next request.
+func (p *HedgePolicy) delay() time.Duration {
+	if p.Delay <= 0 {
+		return 100 * time.Millisecond
+	}
+	return p.Delay
+}
+
+// maxHedges returns the maximum number of requests sent in addition
+// to the first one.
+func (p *HedgePolicy) maxHedges() int {
+	if p.MaxHedges <= 0 {
+		return 1
+	}
+	return p.MaxHedges
+}
+
+// hedgeResult is the outcome of one of the requests sent by sendHedged.
+type hedgeResult struct {
+	i    int // Index of the request.
+	resp *http.Response
+	err  error
+}
+
+// ok reports whether r is a successful response, which ends hedging.
+// Network errors and server errors aren't, so that another request
+// may still succeed.
+func (r hedgeResult) ok() bool {
+	return r.err == nil && r.resp.StatusCode < http.StatusInternalServerError
+}
+
+// sendHedged makes an attempt at sending a request to the GraphQL server,
+// hedged according to the client's hedge policy. A request that fails
+// before the delay elapses is hedged right away. If all requests fail,
+// the outcome of the last one is returned.
+func (c *Client) sendHedged(ctx context.Context, header http.Header, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	results := make(chan hedgeResult, c.hedge.maxHedges()+1)
+	var cancels []context.CancelFunc
+	launch := func() {
+		i := len(cancels)
+		ctx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := c.sendOnce(ctx, header, newRequest)
+			results <- hedgeResult{i: i, resp: resp, err: err}
+		}()
+	}
+	canLaunch := func() bool {
+		return len(cancels) < cap(results) && ctx.Err() == nil
+	}
+
+	launch()
+	timer := time.NewTimer(c.hedge.delay())
+	defer timer.Stop()
+	var last hedgeResult
+	for done := 0; done < len(cancels); {
+		select {
+		case <-timer.C:
+			if canLaunch() {
+				launch()
+				timer.Reset(c.hedge.delay())
+			}
+			continue
+		case r := <-results:
+			done++
+			if done > 1 {
+				discard(last, cancels[last.i])
+			}
+			last = r
+		}
+		if last.ok() {
+			// Cancel the others, and release their responses.
+			for i, cancel := range cancels {
+				if i != last.i {
+					cancel()
+				}
+			}
+			go func(n int) {
+				for ; n > 0; n-- {
+					r := <-results
+					discard(r, cancels[r.i])
+				}
+			}(len(cancels) - done)
+			break
+		}
+		if canLaunch() {
+			launch()
+			timer.Reset(c.hedge.delay())
+		}
+	}
+	if last.err != nil {
+		cancels[last.i]()
+		return nil, last.err
+	}
+	// The request's context must outlive reading the response.
+	last.resp.Body = cancelOnClose{ReadCloser: last.resp.Body, cancel: cancels[last.i]}
+	return last.resp, nil
+}
+
+// discard releases the response of r, if any, and cancels its request.
+func discard(r hedgeResult, cancel context.CancelFunc) {
+	if r.resp != nil {
+		io.Copy(ioutil.Discard, r.resp.Body)
+		r.resp.Body.Close()
+	}
+	cancel()
+}
+
+// cancelOnClose is a response body canceling the context
+// of its request when closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
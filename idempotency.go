@@ -0,0 +1,61 @@
+package graphql
+
+import "net/http"
+
+// DefaultIdempotencyKeyHeader is the header that idempotency keys are sent
+// in, unless another one is given to WithIdempotencyKeys.
+//
+// Specification: https://datatracker.ietf.org/doc/draft-ietf-httpapi-idempotency-key-header/.
+const DefaultIdempotencyKeyHeader = "Idempotency-Key"
+
+// WithIdempotencyKeys makes the client send a random idempotency key with
+// each mutation, in the HTTP header with the given name, or
+// DefaultIdempotencyKeyHeader if it's empty, for servers deduplicating
+// mutations by key. The key is the same for all the HTTP requests retried
+// for a mutation, so mutations are retried like queries, as the server
+// applies them at most once. Keys can be given per mutation with
+// WithIdempotencyKey instead.
+//
+// As mutations are then sent with different headers,
+// they're not batched together by WithBatching.
+func WithIdempotencyKeys(header string) ClientOption {
+	return func(c *Client) {
+		if header == "" {
+			header = DefaultIdempotencyKeyHeader
+		}
+		c.idempotencyHeader = http.CanonicalHeaderKey(header)
+	}
+}
+
+// WithIdempotencyKey sends key as the idempotency key of the operation,
+// in the header set by WithIdempotencyKeys, or DefaultIdempotencyKeyHeader,
+// and marks the operation as idempotent, so that it's retried according to
+// the client's retry policy. Keys derived from the data of the mutation,
+// such as the ID of an order, let the server deduplicate it even if it's
+// sent again by another process.
+//
+// Servers taking the key as an argument of the mutation rather than
+// a header get it as a variable, with the mutation marked as idempotent
+// by WithIdempotent.
+func WithIdempotencyKey(key string) RequestOption {
+	return func(o *requestOptions) {
+		o.idempotencyKey = key
+	}
+}
+
+// idempotencyKey returns the header and the key identifying the operation
+// of type op, sent with the given request options, or empty strings if it
+// has none.
+func (c *Client) idempotencyKey(op operationType, o *requestOptions) (header, key string) {
+	header = c.idempotencyHeader
+	if header == "" {
+		header = DefaultIdempotencyKeyHeader
+	}
+	switch {
+	case o.idempotencyKey != "":
+		return header, o.idempotencyKey
+	case op == mutationOperation && c.idempotencyHeader != "":
+		return header, randomID()
+	}
+	return "", ""
+}
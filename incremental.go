@@ -0,0 +1,207 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+)
+
+// incrementalHandler receives the results of an operation using @defer
+// or @stream as they're delivered, as set by WithIncrementalDelivery.
+type incrementalHandler struct {
+	// fn is called with the data merged so far, and the path of the
+	// latest patch, or nil for the initial result.
+	fn func(data json.RawMessage, path []interface{}) error
+}
+
+// incrementalAccept is the Accept header for operations that may use
+// incremental delivery, which prefers multipart responses.
+//
+// Specification: https://github.com/graphql/graphql-over-http/blob/main/rfcs/IncrementalDelivery.md.
+const incrementalAccept = "multipart/mixed;deferSpec=20220824, application/json"
+
+// incrementalPayload is a part of a multipart response: either the initial
+// result, or subsequent results with patches in either the current format
+// (Incremental) or the original one (Data or Items, with Path).
+type incrementalPayload struct {
+	Data        json.RawMessage        `json:"data"`
+	Items       []json.RawMessage      `json:"items"`
+	Path        []interface{}          `json:"path"`
+	Errors      Errors                 `json:"errors"`
+	Extensions  map[string]interface{} `json:"extensions"`
+	Incremental []incrementalPayload   `json:"incremental"`
+	HasNext     bool                   `json:"hasNext"`
+}
+
+// readIncremental reads the multipart response resp, merging the patches
+// of subsequent results into the data of the initial one, and calling h
+// after each. It returns the complete response.
+func readIncremental(resp *http.Response, h *incrementalHandler) (*Response, error) {
+	_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	mr := multipart.NewReader(resp.Body, params["boundary"])
+	var out Response
+	var data interface{} // Data merged so far.
+	initial := true
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		b, err := ioutil.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(b)) == 0 {
+			continue
+		}
+		var p incrementalPayload
+		if err := json.Unmarshal(b, &p); err != nil {
+			return nil, err
+		}
+
+		var path []interface{}
+		if initial {
+			if err := unmarshalTree(p.Data, &data); err != nil {
+				return nil, err
+			}
+			out.Errors, out.Extensions = p.Errors, p.Extensions
+			initial = false
+		} else {
+			patches := p.Incremental
+			if patches == nil {
+				patches = []incrementalPayload{p}
+			}
+			for _, patch := range patches {
+				if err := mergePatch(&data, patch); err != nil {
+					return nil, err
+				}
+				out.Errors = append(out.Errors, patch.Errors...)
+				path = patch.Path
+			}
+			if p.Incremental != nil {
+				out.Errors = append(out.Errors, p.Errors...)
+			}
+			if path == nil {
+				// Nothing was delivered, such as in a final {"hasNext": false}.
+				if !p.HasNext {
+					break
+				}
+				continue
+			}
+		}
+		if out.Data, err = json.Marshal(data); err != nil {
+			return nil, err
+		}
+		if h.fn != nil && data != nil {
+			if err := h.fn(out.Data, path); err != nil {
+				return nil, err
+			}
+		}
+		if !p.HasNext {
+			break
+		}
+	}
+	if initial {
+		return nil, fmt.Errorf("graphql: multipart response has no parts")
+	}
+	return &out, nil
+}
+
+// mergePatch merges the data or list items of patch into data, at its path.
+func mergePatch(data *interface{}, patch incrementalPayload) error {
+	if patch.Items != nil {
+		// Stream: the last element of the path is the index of the first item.
+		if len(patch.Path) == 0 {
+			return fmt.Errorf("graphql: streamed items without a path")
+		}
+		target, set, err := lookupPath(data, patch.Path[:len(patch.Path)-1])
+		if err != nil {
+			return err
+		}
+		list, ok := target.([]interface{})
+		if !ok && target != nil {
+			return fmt.Errorf("graphql: streamed items at %v, which isn't a list", patch.Path)
+		}
+		for _, item := range patch.Items {
+			var v interface{}
+			if err := unmarshalTree(item, &v); err != nil {
+				return err
+			}
+			list = append(list, v)
+		}
+		set(list)
+		return nil
+	}
+	if len(patch.Data) == 0 || string(patch.Data) == "null" {
+		return nil
+	}
+	target, set, err := lookupPath(data, patch.Path)
+	if err != nil {
+		return err
+	}
+	var v interface{}
+	if err := unmarshalTree(patch.Data, &v); err != nil {
+		return err
+	}
+	set(mergeTree(target, v))
+	return nil
+}
+
+// lookupPath returns the value at path in data, whose elements are object
+// keys and list indices, and a function that replaces it.
+func lookupPath(data *interface{}, path []interface{}) (interface{}, func(interface{}), error) {
+	v, set := *data, func(x interface{}) { *data = x }
+	for i, key := range path {
+		switch parent := v.(type) {
+		case map[string]interface{}:
+			k, ok := key.(string)
+			if !ok {
+				return nil, nil, fmt.Errorf("graphql: invalid path %v: %v is not a key", path, key)
+			}
+			v, set = parent[k], func(x interface{}) { parent[k] = x }
+		case []interface{}:
+			n, ok := key.(float64)
+			j := int(n)
+			if !ok || float64(j) != n || j < 0 || j >= len(parent) {
+				return nil, nil, fmt.Errorf("graphql: invalid path %v: %v is not an index", path, key)
+			}
+			v, set = parent[j], func(x interface{}) { parent[j] = x }
+		default:
+			return nil, nil, fmt.Errorf("graphql: invalid path %v: nothing at %v", path, path[:i+1])
+		}
+	}
+	return v, set, nil
+}
+
+// mergeTree merges src into dst, combining the keys of objects recursively.
+func mergeTree(dst, src interface{}) interface{} {
+	d, ok1 := dst.(map[string]interface{})
+	s, ok2 := src.(map[string]interface{})
+	if !ok1 || !ok2 {
+		return src
+	}
+	for k, v := range s {
+		d[k] = mergeTree(d[k], v)
+	}
+	return d
+}
+
+// unmarshalTree decodes JSON data into v, keeping numbers as json.Number.
+func unmarshalTree(data json.RawMessage, v *interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
@@ -0,0 +1,281 @@
+package graphql
+
+import (
+	"encoding"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// GraphQLTyper is implemented by Go types of variables whose GraphQL type
+// name differs from their Go type name, such as input objects.
+// GraphQLType is called on the zero value of the type.
+type GraphQLTyper interface {
+	GraphQLType() string
+}
+
+var (
+	graphQLTyperType  = reflect.TypeOf((*GraphQLTyper)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	uploadType        = reflect.TypeOf(Upload{})
+	bigFloatType      = reflect.TypeOf(big.Float{})
+)
+
+// inputTypeName returns the GraphQL type name given to struct type t,
+// by its GraphQLType method or by the graphql tag of a blank field,
+// such as _ struct{} `graphql:"AddReactionInput"`, or "" if none.
+func inputTypeName(t reflect.Type) string {
+	switch {
+	case t.Implements(graphQLTyperType):
+		return reflect.Zero(t).Interface().(GraphQLTyper).GraphQLType()
+	case t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(graphQLTyperType):
+		return reflect.New(t).Interface().(GraphQLTyper).GraphQLType()
+	case t.Kind() != reflect.Struct:
+		return ""
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.Name == "_" {
+			if name, ok := f.Tag.Lookup("graphql"); ok {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// marshalVariables returns variables with input objects given as Go structs
+// converted to maps, the values of custom scalars replaced by what their
+// marshal functions return, and omitted Optional values left out.
+// Variables without such values are returned as is.
+func marshalVariables(variables map[string]interface{}) (map[string]interface{}, error) {
+	plain := true
+	for _, value := range variables {
+		if !isPlainInput(reflect.TypeOf(value)) {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		return variables, nil
+	}
+	v, ok, err := marshalInput(reflect.ValueOf(variables))
+	if err != nil || !ok {
+		return variables, err
+	}
+	return v.(map[string]interface{}), nil
+}
+
+// plainInputs caches the results of isPlainInput, by type.
+var plainInputs sync.Map // map[reflect.Type]bool
+
+// isPlainInput reports whether values of type t are sent as they are,
+// being neither, nor holding, input objects, custom scalars, enums or
+// optional values, which marshalInput converts or checks, so that they
+// needn't be walked with reflection.
+func isPlainInput(t reflect.Type) bool {
+	if plain, ok := plainInputs.Load(t); ok {
+		return plain.(bool)
+	}
+	plain := plainInput(t)
+	plainInputs.Store(t, plain)
+	return plain
+}
+
+func plainInput(t reflect.Type) bool {
+	if t == nil {
+		return true
+	}
+	if _, ok := lookupScalar(t); ok || t.Implements(graphQLEnumType) || t.Implements(optionalType) || t == bigFloatType {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return plainInput(t.Elem())
+	}
+	return false
+}
+
+// marshalInput returns the value to encode as JSON for input value v,
+// and whether it differs from v.
+func marshalInput(v reflect.Value) (interface{}, bool, error) {
+	if !v.IsValid() {
+		return nil, false, nil
+	}
+	if err := checkEnum(v); err != nil {
+		return nil, false, err
+	}
+	if s, ok := lookupScalar(v.Type()); ok && s.marshal != nil {
+		e, err := s.marshal(v.Interface())
+		return e, true, err
+	}
+	if o, ok := v.Interface().(optional); ok && v.Kind() != reflect.Interface {
+		value, state := o.optionalValue()
+		if state != optionalSet {
+			return nil, true, nil
+		}
+		e, ok, err := marshalInput(reflect.ValueOf(value))
+		if !ok {
+			e = value
+		}
+		return e, true, err
+	}
+	if v.Type() == bigFloatType {
+		// big.Float marshals itself as a string, but Float values are numbers.
+		f := v.Interface().(big.Float)
+		return json.Number(f.Text('g', -1)), true, nil
+	}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if !v.IsNil() {
+			return marshalInput(v.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		list := make([]interface{}, v.Len())
+		any := false
+		for i := range list {
+			e, ok, err := marshalInput(v.Index(i))
+			if err != nil {
+				return nil, false, err
+			}
+			if !ok {
+				e = v.Index(i).Interface()
+			}
+			list[i], any = e, any || ok
+		}
+		if any {
+			return list, true, nil
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			break
+		}
+		m := make(map[string]interface{}, v.Len())
+		any := false
+		for _, k := range v.MapKeys() {
+			if isOmitted(v.MapIndex(k)) {
+				any = true
+				continue
+			}
+			e, ok, err := marshalInput(v.MapIndex(k))
+			if err != nil {
+				return nil, false, err
+			}
+			if !ok {
+				e = v.MapIndex(k).Interface()
+			}
+			m[k.String()], any = e, any || ok
+		}
+		if any {
+			return m, true, nil
+		}
+	case reflect.Struct:
+		if !isInputObject(v.Type()) {
+			break
+		}
+		m := make(map[string]interface{})
+		if err := marshalInputObject(v, m); err != nil {
+			return nil, false, err
+		}
+		return m, true, nil
+	}
+	return v.Interface(), false, nil
+}
+
+// isInputObject reports whether values of struct type t are input objects,
+// rather than values that encode themselves, like time.Time, or uploads.
+func isInputObject(t reflect.Type) bool {
+	if t == uploadType || t.Implements(readerType) {
+		return false
+	}
+	for _, m := range []reflect.Type{jsonMarshalerType, textMarshalerType} {
+		if t.Implements(m) || reflect.PtrTo(t).Implements(m) {
+			return false
+		}
+	}
+	return true
+}
+
+// marshalInputObject stores the fields of struct v into m, by name.
+//
+// A field is named by its graphql tag, or else by its json tag, or else
+// by its Go name. A json tag of "-" leaves it out, and with the
+// "omitempty" option, so does an empty value. Otherwise, nil pointers
+// and such are sent as explicit nulls. Embedded structs without tags
+// have their fields promoted, as with encoding/json.
+func marshalInputObject(v reflect.Value, m map[string]interface{}) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		graphqlTag, hasGraphQLTag := f.Tag.Lookup("graphql")
+		jsonTag, hasJSONTag := f.Tag.Lookup("json")
+		fv := v.Field(i)
+		if f.Anonymous && !hasGraphQLTag && !hasJSONTag {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				if err := marshalInputObject(fv, m); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if f.PkgPath != "" || jsonTag == "-" {
+			// Unexported or ignored.
+			continue
+		}
+		jsonName, jsonOpts := jsonTag, ""
+		if i := strings.Index(jsonTag, ","); i != -1 {
+			jsonName, jsonOpts = jsonTag[:i], jsonTag[i:]
+		}
+		name := f.Name
+		switch {
+		case hasGraphQLTag && graphqlTag != "":
+			name = graphqlTag
+		case jsonName != "":
+			name = jsonName
+		}
+		if isOmitted(fv) || strings.Contains(jsonOpts, ",omitempty") && isEmptyValue(fv) {
+			continue
+		}
+		e, ok, err := marshalInput(fv)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			e = fv.Interface()
+		}
+		m[name] = e
+	}
+	return nil
+}
+
+// isEmptyValue reports whether v is empty, as by encoding/json's omitempty.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
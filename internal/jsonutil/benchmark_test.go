@@ -52,6 +52,7 @@ func BenchmarkUnmarshalGraphQL(b *testing.B) {
 			CreatedAt time.Time
 		}
 	}
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		now := time.Now().UTC()
 		var got query
@@ -73,6 +74,43 @@ func BenchmarkUnmarshalGraphQL(b *testing.B) {
 	}
 }
 
+func BenchmarkUnmarshalGraphQL_parallel(b *testing.B) {
+	type query struct {
+		Viewer struct {
+			Login     graphql.String
+			CreatedAt time.Time
+			Issues    struct {
+				Nodes []struct {
+					Title graphql.String
+					State graphql.String
+				}
+			}
+		}
+	}
+	data := []byte(`{
+		"viewer": {
+			"login": "shurcooL-test",
+			"createdAt": "2017-06-29T04:12:01Z",
+			"issues": {
+				"nodes": [
+					{"title": "Crash on start", "state": "OPEN"},
+					{"title": "Typo in docs", "state": "CLOSED"},
+					{"title": "Slow queries", "state": "OPEN"}
+				]
+			}
+		}
+	}`)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			var got query
+			if err := jsonutil.UnmarshalGraphQL(data, &got); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
 func BenchmarkJSONUnmarshal(b *testing.B) {
 	type query struct {
 		Viewer struct {
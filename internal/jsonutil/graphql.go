@@ -8,8 +8,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/nobody05/graphql_go_client/ident"
 )
 
 // UnmarshalGraphQL parses the JSON-encoded GraphQL response data and stores
@@ -17,10 +22,56 @@ import (
 //
 // The implementation is created on top of the JSON tokenizer available
 // in "encoding/json".Decoder.
+//
+// Fields of the response data are matched to struct fields by name, in order
+// of precedence:
+//
+//  1. exactly, by the name in the graphql tag of the struct field, such as
+//     "login" for `graphql:"login"` or "owner" for `graphql:"owner: viewer"`,
+//     or, lacking one, the name in its json tag;
+//  2. exactly, by the name queries are built with for struct fields lacking
+//     a name in their graphql tag, their Go name in snake case;
+//  3. ignoring case, by the json tag or Go name of struct fields lacking
+//     a name in their graphql tag, unless Options.CaseSensitive is set.
 func UnmarshalGraphQL(data []byte, v interface{}) error {
+	return UnmarshalGraphQLOptions(data, v, Options{})
+}
+
+// UnmarshalGraphQLLenient is like UnmarshalGraphQL, but it skips fields
+// of the response data that don't exist in v, rather than reporting
+// an error, for tolerating additions to evolving schemas.
+func UnmarshalGraphQLLenient(data []byte, v interface{}) error {
+	return UnmarshalGraphQLOptions(data, v, Options{SkipUnknownFields: true})
+}
+
+// Options configures how UnmarshalGraphQLOptions decodes response data.
+type Options struct {
+	// SkipUnknownFields skips fields of the response data that don't exist
+	// in the destination, rather than reporting an error.
+	SkipUnknownFields bool
+
+	// CaseSensitive only matches fields of the response data to struct
+	// fields named exactly like them, without falling back to matching
+	// them ignoring case, for schemas with fields differing only by case.
+	CaseSensitive bool
+
+	// UseNumber decodes numbers into interface{} values, such as those
+	// of maps and JSON scalars, as json.Number rather than float64,
+	// so that 64-bit IDs and amounts of money keep their precision.
+	UseNumber bool
+
+	// Path is the JSON path of the decoded value, such as "data",
+	// which the paths of values reported by errors are relative to.
+	Path string
+}
+
+// UnmarshalGraphQLOptions is like UnmarshalGraphQL, but configured by opts.
+func UnmarshalGraphQLOptions(data []byte, v interface{}, opts Options) error {
 	dec := json.NewDecoder(bytes.NewReader(data))
 	dec.UseNumber()
-	err := (&decoder{tokenizer: dec}).Decode(v)
+	d := getDecoder(dec, opts)
+	err := d.Decode(v)
+	putDecoder(d)
 	if err != nil {
 		return err
 	}
@@ -44,9 +95,25 @@ type decoder struct {
 		Token() (json.Token, error)
 	}
 
+	opts Options
+
 	// Stack of what part of input JSON we're in the middle of - objects, arrays.
 	parseState []json.Delim
 
+	// Stack of the objects we're in the middle of, for resolving
+	// which of their inline fragments apply.
+	objects []object
+
+	// Stack of the number of elements seen of the arrays
+	// we're in the middle of.
+	arrays []int
+
+	// Path to the JSON value we're decoding, for reporting errors,
+	// and that of the struct fields it's decoded into, which is
+	// prefixed by goPath.
+	path   []pathElem
+	goPath string
+
 	// Stacks of values where to unmarshal.
 	// The top of each stack is the reflect.Value where to unmarshal next JSON value.
 	//
@@ -62,8 +129,11 @@ func (d *decoder) Decode(v interface{}) error {
 	if rv.Kind() != reflect.Ptr {
 		return fmt.Errorf("cannot decode into non-pointer %T", v)
 	}
-	d.vs = [][]reflect.Value{{rv.Elem()}}
-	return d.decode()
+	d.vs = append(d.vs[:0], []reflect.Value{rv.Elem()})
+	if err := d.decode(); err != nil {
+		return d.pathError(err)
+	}
+	return nil
 }
 
 // decode decodes a single JSON value from d.tokenizer into d.vs.
@@ -86,23 +156,32 @@ func (d *decoder) decode() error {
 			if !ok {
 				return errors.New("unexpected non-key in JSON input")
 			}
+			elem := pathElem{key: key, index: -1}
 			someFieldExist := false
 			for i := range d.vs {
-				v := d.vs[i][len(d.vs[i])-1]
-				if v.Kind() == reflect.Ptr {
-					v = v.Elem()
-				}
+				v := indirect(d.vs[i][len(d.vs[i])-1])
 				var f reflect.Value
 				if v.Kind() == reflect.Struct {
-					f = fieldByGraphQLName(v, key)
-					if f.IsValid() {
+					if j := fieldByGraphQLName(v, key, d.opts.CaseSensitive); j != -1 {
+						f = v.Field(j)
+						if !someFieldExist {
+							elem.field = v.Type().Field(j).Name
+						}
 						someFieldExist = true
 					}
 				}
 				d.vs[i] = append(d.vs[i], f)
 			}
-			if !someFieldExist {
-				return fmt.Errorf("struct field for %q doesn't exist in any of %v places to unmarshal", key, len(d.vs))
+			d.path = append(d.path, elem)
+			if !someFieldExist && key != "__typename" {
+				if !d.opts.SkipUnknownFields {
+					return fmt.Errorf("struct field for %q doesn't exist in any of %v places to unmarshal", key, len(d.vs))
+				}
+				if err := d.skipValue(); err != nil {
+					return err
+				}
+				d.popAllVs()
+				continue
 			}
 
 			// We've just consumed the current token, which was the key.
@@ -113,15 +192,17 @@ func (d *decoder) decode() error {
 			} else if err != nil {
 				return err
 			}
+			if typename, ok := tok.(string); ok && key == "__typename" {
+				d.objects[len(d.objects)-1].typename = typename
+			}
 
 		// Are we inside an array and seeing next value (rather than end of array)?
 		case d.state() == '[' && tok != json.Delim(']'):
+			d.path = append(d.path, pathElem{index: d.arrays[len(d.arrays)-1]})
+			d.arrays[len(d.arrays)-1]++
 			someSliceExist := false
 			for i := range d.vs {
-				v := d.vs[i][len(d.vs[i])-1]
-				if v.Kind() == reflect.Ptr {
-					v = v.Elem()
-				}
+				v := indirect(d.vs[i][len(d.vs[i])-1])
 				var f reflect.Value
 				if v.Kind() == reflect.Slice {
 					v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem()))) // v = append(v, T).
@@ -135,6 +216,31 @@ func (d *decoder) decode() error {
 			}
 		}
 
+		if delim, ok := tok.(json.Delim); ok && (delim == '{' || delim == '[') && d.opaque() {
+			// Object or array that destinations unmarshal by themselves.
+			raw, err := d.rawValue(delim)
+			if err != nil {
+				return err
+			}
+			for i := range d.vs {
+				v := d.vs[i][len(d.vs[i])-1]
+				if !v.IsValid() {
+					continue
+				}
+				var err error
+				if name, types, ok := LookupUnion(v.Type()); ok && delim == '{' {
+					err = d.unmarshalUnion(raw, v, name, types)
+				} else {
+					err = d.unmarshalRaw(raw, v)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			d.popAllVs()
+			continue
+		}
+
 		switch tok := tok.(type) {
 		case string, json.Number, bool, nil:
 			// Value.
@@ -144,7 +250,7 @@ func (d *decoder) decode() error {
 				if !v.IsValid() {
 					continue
 				}
-				err := unmarshalValue(tok, v)
+				err := d.unmarshalValue(tok, v)
 				if err != nil {
 					return err
 				}
@@ -157,51 +263,49 @@ func (d *decoder) decode() error {
 				// Start of object.
 
 				d.pushState(tok)
+				var obj object
 
 				frontier := make([]reflect.Value, len(d.vs)) // Places to look for GraphQL fragments/embedded structs.
 				for i := range d.vs {
 					v := d.vs[i][len(d.vs[i])-1]
 					frontier[i] = v
-					// TODO: Do this recursively or not? Add a test case if needed.
-					if v.Kind() == reflect.Ptr && v.IsNil() {
-						v.Set(reflect.New(v.Type().Elem())) // v = new(T).
-					}
 				}
 				// Find GraphQL fragments/embedded structs recursively, adding to frontier
 				// as new ones are discovered and exploring them further.
+				// Since the object isn't null, pointers to them are allocated.
 				for len(frontier) > 0 {
-					v := frontier[0]
+					v := indirect(frontier[0])
 					frontier = frontier[1:]
-					if v.Kind() == reflect.Ptr {
-						v = v.Elem()
-					}
 					if v.Kind() != reflect.Struct {
 						continue
 					}
 					for i := 0; i < v.NumField(); i++ {
+						if f := v.Type().Field(i); f.Anonymous && f.PkgPath != "" && f.Type.Kind() == reflect.Ptr && v.Field(i).IsNil() {
+							// As with encoding/json, promoted fields can't be decoded into them.
+							return fmt.Errorf("cannot set embedded pointer to unexported struct type %v", f.Type.Elem())
+						}
 						if isGraphQLFragment(v.Type().Field(i)) || v.Type().Field(i).Anonymous {
 							// Add GraphQL fragment or embedded struct.
 							d.vs = append(d.vs, []reflect.Value{v.Field(i)})
 							frontier = append(frontier, v.Field(i))
 						}
+						if on := typeCondition(v.Type().Field(i)); on != "" {
+							obj.fragments = append(obj.fragments, fragment{v: v.Field(i), on: on})
+						}
 					}
 				}
+				d.objects = append(d.objects, obj)
 			case '[':
 				// Start of array.
 
 				d.pushState(tok)
+				d.arrays = append(d.arrays, 0)
 
 				for i := range d.vs {
-					v := d.vs[i][len(d.vs[i])-1]
-					// TODO: Confirm this is needed, write a test case.
-					//if v.Kind() == reflect.Ptr && v.IsNil() {
-					//	v.Set(reflect.New(v.Type().Elem())) // v = new(T).
-					//}
+					// Since the array isn't null, pointers to it are allocated.
+					v := indirect(d.vs[i][len(d.vs[i])-1])
 
 					// Reset slice to empty (in case it had non-zero initial value).
-					if v.Kind() == reflect.Ptr {
-						v = v.Elem()
-					}
 					if v.Kind() != reflect.Slice {
 						continue
 					}
@@ -209,6 +313,12 @@ func (d *decoder) decode() error {
 				}
 			case '}', ']':
 				// End of object or array.
+				if tok == '}' {
+					d.objects[len(d.objects)-1].resolveFragments()
+					d.objects = d.objects[:len(d.objects)-1]
+				} else {
+					d.arrays = d.arrays[:len(d.arrays)-1]
+				}
 				d.popAllVs()
 				d.popState()
 			default:
@@ -221,6 +331,111 @@ func (d *decoder) decode() error {
 	return nil
 }
 
+// opaque reports whether any of the destinations at the top of d.vs
+// unmarshals JSON by itself, via a json.Unmarshaler implementation
+// or as a custom scalar, or is dynamic, like a map or interface{}.
+func (d *decoder) opaque() bool {
+	for i := range d.vs {
+		v := d.vs[i][len(d.vs[i])-1]
+		if v.IsValid() && isOpaque(v.Type()) {
+			return true
+		}
+	}
+	return false
+}
+
+// isOpaque reports whether values of type t unmarshal JSON by themselves.
+func isOpaque(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		switch e := t.Elem(); {
+		case lookupScalar(e) != nil, e.Kind() == reflect.Map, e.Kind() == reflect.Interface:
+			return true
+		}
+	}
+	switch t.Kind() {
+	case reflect.Map, reflect.Interface:
+		// Dynamic, such as a JSON scalar field, decoded by encoding/json.
+		return true
+	}
+	return lookupScalar(t) != nil || t.Implements(jsonUnmarshaler) || reflect.PtrTo(t).Implements(jsonUnmarshaler)
+}
+
+var jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// skipValue reads the next JSON value from d.tokenizer, and discards it.
+func (d *decoder) skipValue() error {
+	tok, err := d.tokenizer.Token()
+	if err == io.EOF {
+		return errors.New("unexpected end of JSON input")
+	} else if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); ok {
+		_, err = d.rawValue(delim)
+	}
+	return err
+}
+
+// rawValue reads the rest of the JSON object or array that starts with
+// delim from d.tokenizer, and returns its compact encoding.
+func (d *decoder) rawValue(delim json.Delim) ([]byte, error) {
+	type level struct {
+		delim json.Delim
+		n     int // Number of keys, values, and elements seen.
+	}
+	buf := getBuffer()
+	defer putBuffer(buf)
+	buf.WriteByte(byte(delim))
+	levels := []level{{delim: delim}}
+	for len(levels) > 0 {
+		tok, err := d.tokenizer.Token()
+		if err == io.EOF {
+			return nil, errors.New("unexpected end of JSON input")
+		} else if err != nil {
+			return nil, err
+		}
+		if delim, ok := tok.(json.Delim); ok && (delim == '}' || delim == ']') {
+			buf.WriteByte(byte(delim))
+			levels = levels[:len(levels)-1]
+			continue
+		}
+		top := &levels[len(levels)-1]
+		switch {
+		case top.delim == '{' && top.n%2 == 1:
+			buf.WriteByte(':')
+		case top.n > 0:
+			buf.WriteByte(',')
+		}
+		top.n++
+		if delim, ok := tok.(json.Delim); ok {
+			buf.WriteByte(byte(delim))
+			levels = append(levels, level{delim: delim})
+			continue
+		}
+		if err := buf.encode(tok); err != nil {
+			return nil, err
+		}
+	}
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+// indirect returns the value that v ultimately points to, through any
+// number of pointers, allocating those that are nil. If v isn't a pointer,
+// it's returned as is. If a nil pointer can't be set, such as one in an
+// unexported embedded field, the zero Value is returned.
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			if !v.CanSet() {
+				return reflect.Value{}
+			}
+			v.Set(reflect.New(v.Type().Elem())) // v = new(T).
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
 // pushState pushes a new parse state s onto the stack.
 func (d *decoder) pushState(s json.Delim) {
 	d.parseState = append(d.parseState, s)
@@ -242,7 +457,10 @@ func (d *decoder) state() json.Delim {
 
 // popAllVs pops from all d.vs stacks, keeping only non-empty ones.
 func (d *decoder) popAllVs() {
-	var nonEmpty [][]reflect.Value
+	if len(d.path) > 0 {
+		d.path = d.path[:len(d.path)-1]
+	}
+	nonEmpty := d.vs[:0] // Filtered in place.
 	for i := range d.vs {
 		d.vs[i] = d.vs[i][:len(d.vs[i])-1]
 		if len(d.vs[i]) > 0 {
@@ -252,40 +470,261 @@ func (d *decoder) popAllVs() {
 	d.vs = nonEmpty
 }
 
-// fieldByGraphQLName returns an exported struct field of struct v
-// that matches GraphQL name, or invalid reflect.Value if none found.
-func fieldByGraphQLName(v reflect.Value, name string) reflect.Value {
-	for i := 0; i < v.NumField(); i++ {
-		if v.Type().Field(i).PkgPath != "" {
-			// Skip unexported field.
+// pathElem is an element of the path to a JSON value: the key of an object
+// member, or the index of an array element.
+type pathElem struct {
+	key   string
+	index int    // Index of the array element, or -1 for a member.
+	field string // Name of the struct field of a member, if any.
+}
+
+// jsonPath returns the JSON path to the value being decoded,
+// such as "data.repository.issues.nodes[3].author.login".
+func (d *decoder) jsonPath() string {
+	var b strings.Builder
+	b.WriteString(d.opts.Path)
+	for _, e := range d.path {
+		if e.index != -1 {
+			fmt.Fprintf(&b, "[%d]", e.index)
 			continue
 		}
-		if hasGraphQLName(v.Type().Field(i), name) {
-			return v.Field(i)
+		if b.Len() > 0 {
+			b.WriteByte('.')
 		}
+		b.WriteString(e.key)
 	}
-	return reflect.Value{}
+	return b.String()
 }
 
-// hasGraphQLName reports whether struct field f has GraphQL name.
-func hasGraphQLName(f reflect.StructField, name string) bool {
-	value, ok := f.Tag.Lookup("graphql")
-	if !ok {
-		// TODO: caseconv package is relatively slow. Optimize it, then consider using it here.
-		//return caseconv.MixedCapsToLowerCamelCase(f.Name) == name
-		return strings.EqualFold(f.Name, name)
+// fieldPath returns the path of the struct fields the value being decoded
+// is decoded into, such as "Repository.Issues.Nodes[3].Author.Login", or
+// "" if a member on the path has no struct field.
+func (d *decoder) fieldPath() string {
+	var b strings.Builder
+	b.WriteString(d.goPath)
+	for _, e := range d.path {
+		switch {
+		case e.index != -1:
+			fmt.Fprintf(&b, "[%d]", e.index)
+		case e.field == "":
+			return ""
+		default:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(e.field)
+		}
 	}
-	value = strings.TrimSpace(value) // TODO: Parse better.
-	if strings.HasPrefix(value, "...") {
-		// GraphQL fragment. It doesn't have a name.
-		return false
+	return b.String()
+}
+
+// pathError returns err, which occurred decoding the current value,
+// annotated with the paths of the value and of its destination.
+func (d *decoder) pathError(err error) error {
+	var pathErr *PathError
+	if errors.As(err, &pathErr) {
+		// Already annotated, by the decoder of a union member.
+		return err
 	}
-	// Cut off anything that follows the field name,
-	// such as field arguments, aliases, directives.
-	if i := strings.IndexAny(value, "(:@"); i != -1 {
-		value = value[:i]
+	e := &PathError{Path: d.jsonPath(), Err: err}
+	if e.Path == "" {
+		return err
+	}
+	if e.Field = d.fieldPath(); e.Field != "" {
+		for i := range d.vs {
+			if v := d.vs[i][len(d.vs[i])-1]; v.IsValid() {
+				e.Type = v.Type()
+				break
+			}
+		}
+	}
+	return e
+}
+
+// PathError is an error decoding the JSON value at Path into the struct
+// field at Field, of type Type.
+type PathError struct {
+	Path  string       // JSON path, such as "data.repository.issues.nodes[3].author.login".
+	Field string       // Path of struct fields, or "" if there's no such field.
+	Type  reflect.Type // Type of the field, or nil if there's no such field.
+	Err   error
+}
+
+func (e *PathError) Error() string {
+	if e.Type == nil {
+		return fmt.Sprintf("decoding %s: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("decoding %s into %s of type %v: %v", e.Path, e.Field, e.Type, e.Err)
+}
+
+func (e *PathError) Unwrap() error { return e.Err }
+
+// object is a JSON object being decoded.
+type object struct {
+	fragments []fragment // Inline fragments with a type condition.
+	typename  string     // Value of the "__typename" key, if seen.
+}
+
+// fragment is a destination for an inline fragment, such as "... on Issue".
+type fragment struct {
+	v  reflect.Value
+	on string // Type condition.
+}
+
+// resolveFragments resets the inline fragments of o that don't apply
+// to the type of o, if the response included its __typename.
+//
+// Fragments are decoded into as the object's fields come by,
+// which may be before __typename, so any fields they received
+// that belong to other types are discarded.
+func (o *object) resolveFragments() {
+	if o.typename == "" || len(o.fragments) == 0 {
+		return
+	}
+	possibleTypes.RLock()
+	defer possibleTypes.RUnlock()
+	for _, f := range o.fragments {
+		if !fragmentApplies(f.on, o.typename) {
+			f.v.Set(reflect.Zero(f.v.Type()))
+		}
 	}
-	return strings.TrimSpace(value) == name
+}
+
+// fragmentApplies reports whether an inline fragment with type condition on
+// applies to an object of type typename. Type conditions that are neither
+// typename, nor registered with RegisterPossibleTypes, nor registered as
+// one of the possible types of another, may be interfaces or unions
+// that typename belongs to, so fragments on them apply.
+//
+// possibleTypes must be locked for reading.
+func fragmentApplies(on, typename string) bool {
+	if on == typename {
+		return true
+	}
+	if types, ok := possibleTypes.m[on]; ok {
+		return types[typename]
+	}
+	return possibleTypes.concrete[on] == 0
+}
+
+// possibleTypes holds the possible types of the interfaces and unions
+// registered with RegisterPossibleTypes, by name, along with the number
+// of registrations listing each possible type.
+var possibleTypes = struct {
+	sync.RWMutex
+	m        map[string]map[string]bool
+	concrete map[string]int
+}{m: make(map[string]map[string]bool), concrete: make(map[string]int)}
+
+// RegisterPossibleTypes registers typenames as the concrete types
+// implementing the GraphQL interface, or making up the union, name.
+// Inline fragments with one of those types as their type condition are
+// then reset when decoding objects of another type, as told by __typename,
+// and fragments on name are reset when decoding objects of types other than
+// typenames. If typenames is nil, a previous registration is removed.
+func RegisterPossibleTypes(name string, typenames []string) {
+	possibleTypes.Lock()
+	defer possibleTypes.Unlock()
+	for typename := range possibleTypes.m[name] {
+		if possibleTypes.concrete[typename]--; possibleTypes.concrete[typename] == 0 {
+			delete(possibleTypes.concrete, typename)
+		}
+	}
+	delete(possibleTypes.m, name)
+	if typenames == nil {
+		return
+	}
+	types := make(map[string]bool, len(typenames))
+	for _, typename := range typenames {
+		if !types[typename] {
+			types[typename] = true
+			possibleTypes.concrete[typename]++
+		}
+	}
+	possibleTypes.m[name] = types
+}
+
+// fieldByGraphQLName returns the index of an exported struct field of
+// struct v that matches GraphQL name, or -1 if none found. Names are
+// matched exactly first, then ignoring case unless caseSensitive,
+// as documented by UnmarshalGraphQL.
+func fieldByGraphQLName(v reflect.Value, name string, caseSensitive bool) int {
+	names := graphQLNames(v.Type())
+	for i, n := range names {
+		if name != "" && n.tag == name {
+			return i
+		}
+	}
+	for i, n := range names {
+		if name != "" && n.query == name {
+			return i
+		}
+	}
+	if caseSensitive {
+		return -1
+	}
+	for i, n := range names {
+		for _, folded := range n.folded {
+			if folded != "" && strings.EqualFold(folded, name) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// fieldName holds the names a struct field is matched by,
+// which are empty if it has fewer.
+type fieldName struct {
+	tag    string    // Name in the graphql or json tag, matched exactly.
+	query  string    // Name queries are built with, matched exactly.
+	folded [2]string // Names matched ignoring case.
+}
+
+// fieldNames caches the names of the fields of struct types,
+// as returned by graphQLNames.
+var fieldNames sync.Map // map[reflect.Type][]fieldName
+
+// graphQLNames returns the names of the fields of struct type t,
+// which are empty for unexported fields and GraphQL fragments.
+func graphQLNames(t reflect.Type) []fieldName {
+	if names, ok := fieldNames.Load(t); ok {
+		return names.([]fieldName)
+	}
+	names := make([]fieldName, t.NumField())
+	for i := range names {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			// Skip unexported field.
+			continue
+		}
+		value, ok := f.Tag.Lookup("graphql")
+		value = strings.TrimSpace(value) // TODO: Parse better.
+		switch {
+		case strings.HasPrefix(value, "..."):
+			// GraphQL fragment. It doesn't have a name.
+		case ok && !strings.HasPrefix(value, "@"):
+			// Cut off anything that follows the field name,
+			// such as field arguments, aliases, directives.
+			if i := strings.IndexAny(value, "(:@"); i != -1 {
+				value = value[:i]
+			}
+			if name := strings.TrimSpace(value); name != "" {
+				names[i].tag = name
+			}
+		default:
+			// No name in the graphql tag, or directives only,
+			// so the field has its default name.
+			names[i].query = ident.ParseMixedCaps(f.Name).ToUnderline()
+			names[i].folded[0] = f.Name
+			if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" && name != "-" {
+				names[i].tag = name
+				names[i].folded[1] = name
+			}
+		}
+	}
+	actual, _ := fieldNames.LoadOrStore(t, names)
+	return actual.([]fieldName)
 }
 
 // isGraphQLFragment reports whether struct field f is a GraphQL fragment.
@@ -298,13 +737,226 @@ func isGraphQLFragment(f reflect.StructField) bool {
 	return strings.HasPrefix(value, "...")
 }
 
+// typeCondition returns the type condition of struct field f if it's
+// an inline fragment, such as "Issue" for "... on Issue", or else "".
+func typeCondition(f reflect.StructField) string {
+	value, ok := f.Tag.Lookup("graphql")
+	if !ok {
+		return ""
+	}
+	value = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(value), "..."))
+	if !strings.HasPrefix(value, "on ") {
+		return ""
+	}
+	value = strings.TrimSpace(strings.TrimPrefix(value, "on "))
+	if i := strings.IndexAny(value, " @"); i != -1 {
+		value = value[:i]
+	}
+	return value
+}
+
 // unmarshalValue unmarshals JSON value into v.
 // v must be addressable and not obtained by the use of unexported
 // struct fields, otherwise unmarshalValue will panic.
-func unmarshalValue(value json.Token, v reflect.Value) error {
-	b, err := json.Marshal(value) // TODO: Short-circuit (if profiling says it's worth it).
-	if err != nil {
+func (d *decoder) unmarshalValue(value json.Token, v reflect.Value) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := buf.encode(value); err != nil { // TODO: Short-circuit (if profiling says it's worth it).
 		return err
 	}
+	b := buf.Bytes()
+	if t := v.Type(); lookupScalar(t) != nil || t.Kind() == reflect.Ptr && lookupScalar(t.Elem()) != nil {
+		// Unlike UnmarshalJSON methods, unmarshal functions of custom
+		// scalars may keep b.
+		b = bytes.Clone(b)
+	}
+	return d.unmarshalRaw(b, v)
+}
+
+// unmarshalRaw unmarshals the JSON encoding b of a value into v,
+// using the unmarshal function of a custom scalar if one is registered.
+func (d *decoder) unmarshalRaw(b []byte, v reflect.Value) error {
+	null := string(b) == "null"
+	if isBig(v.Type()) && !null {
+		return unmarshalBig(b, v)
+	}
+	if v.Kind() == reflect.Ptr && lookupScalar(v.Type().Elem()) != nil {
+		if null {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	if unmarshal := lookupScalar(v.Type()); unmarshal != nil && !null {
+		x, err := unmarshal(b)
+		if err != nil {
+			return err
+		}
+		xv := reflect.ValueOf(x)
+		if !xv.IsValid() || !xv.Type().AssignableTo(v.Type()) {
+			return fmt.Errorf("custom scalar %v unmarshaled as %T", v.Type(), x)
+		}
+		v.Set(xv)
+		return nil
+	}
+	if d.opts.UseNumber {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		return dec.Decode(v.Addr().Interface())
+	}
 	return json.Unmarshal(b, v.Addr().Interface())
 }
+
+var (
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+)
+
+// isBig reports whether t is big.Int or big.Float, or a pointer to either.
+func isBig(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t == bigIntType || t == bigFloatType
+}
+
+// unmarshalBig unmarshals the JSON number b, or string holding one, into v,
+// of a type for which isBig reports true, without losing precision.
+// Numbers are given to big.Float with enough precision for the digits
+// of integers, and at least that of float64.
+func unmarshalBig(b []byte, v reflect.Value) error {
+	s := string(b)
+	if unquoted, err := strconv.Unquote(s); err == nil && b[0] == '"' {
+		s = unquoted
+	}
+	if v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	var ok bool
+	switch x := v.Addr().Interface().(type) {
+	case *big.Int:
+		_, ok = x.SetString(s, 10)
+	case *big.Float:
+		prec := uint(len(s)) * 4
+		if prec < 53 {
+			prec = 53
+		}
+		_, ok = x.SetPrec(prec).SetString(s)
+	}
+	if !ok {
+		return fmt.Errorf("cannot unmarshal %s into Go value of type %v", b, v.Type())
+	}
+	return nil
+}
+
+// scalars holds the unmarshal functions of custom scalars, by Go type.
+var scalars = struct {
+	sync.RWMutex
+	m map[reflect.Type]func(data []byte) (interface{}, error)
+}{m: make(map[reflect.Type]func(data []byte) (interface{}, error))}
+
+// RegisterScalar registers unmarshal as the function that returns
+// the value of type t for the JSON encoding of a non-null value.
+// If unmarshal is nil, a previous registration is removed.
+func RegisterScalar(t reflect.Type, unmarshal func(data []byte) (interface{}, error)) {
+	scalars.Lock()
+	defer scalars.Unlock()
+	if unmarshal == nil {
+		delete(scalars.m, t)
+		return
+	}
+	scalars.m[t] = unmarshal
+}
+
+// lookupScalar returns the unmarshal function registered for t, or nil.
+func lookupScalar(t reflect.Type) func(data []byte) (interface{}, error) {
+	scalars.RLock()
+	defer scalars.RUnlock()
+	return scalars.m[t]
+}
+
+// unmarshalUnion unmarshals the JSON object b into v, of an interface type
+// registered as the union name, as the member type named by its __typename,
+// from types.
+func (d *decoder) unmarshalUnion(b []byte, v reflect.Value, name string, types map[string]reflect.Type) error {
+	var object struct {
+		Typename *string `json:"__typename"`
+	}
+	if err := json.Unmarshal(b, &object); err != nil {
+		return err
+	}
+	if object.Typename == nil {
+		return fmt.Errorf("__typename of union %s is missing", name)
+	}
+	t, ok := types[*object.Typename]
+	if !ok {
+		if d.opts.SkipUnknownFields {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		return fmt.Errorf("no type registered for %q of union %s", *object.Typename, name)
+	}
+	if !t.Implements(v.Type()) {
+		return fmt.Errorf("type %v of union %s doesn't implement %v", t, name, v.Type())
+	}
+	var p reflect.Value // Pointer to decode into.
+	if t.Kind() == reflect.Ptr {
+		p = reflect.New(t.Elem())
+	} else {
+		p = reflect.New(t)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	opts := d.opts
+	opts.Path = d.jsonPath()
+	if err := (&decoder{tokenizer: dec, opts: opts, goPath: d.fieldPath()}).Decode(p.Interface()); err != nil {
+		return err
+	}
+	if t.Kind() == reflect.Ptr {
+		v.Set(p)
+	} else {
+		v.Set(p.Elem())
+	}
+	return nil
+}
+
+// unions holds the unions registered with RegisterUnion,
+// by Go interface type.
+var unions = struct {
+	sync.RWMutex
+	m map[reflect.Type]union
+}{m: make(map[reflect.Type]union)}
+
+// union is a GraphQL union or interface registered with RegisterUnion.
+type union struct {
+	name  string                  // GraphQL name.
+	types map[string]reflect.Type // Go types of the members, by typename.
+}
+
+// RegisterUnion registers types as the Go types of the members of the GraphQL
+// union or interface name, by typename, for decoding values of the Go
+// interface type t. If types is nil, a previous registration of t
+// is removed.
+func RegisterUnion(t reflect.Type, name string, types map[string]reflect.Type) {
+	unions.Lock()
+	defer unions.Unlock()
+	if types == nil {
+		delete(unions.m, t)
+		return
+	}
+	unions.m[t] = union{name: name, types: types}
+}
+
+// LookupUnion returns the GraphQL name and member types of the union
+// registered for the Go interface type t, if any.
+func LookupUnion(t reflect.Type) (name string, types map[string]reflect.Type, ok bool) {
+	if t.Kind() != reflect.Interface {
+		return "", nil, false
+	}
+	unions.RLock()
+	defer unions.RUnlock()
+	u, ok := unions.m[t]
+	return u.name, u.types, ok
+}
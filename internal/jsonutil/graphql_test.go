@@ -1,11 +1,16 @@
 package jsonutil_test
 
 import (
-	"github.com/nobody05/graphql_go_client"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
 	"reflect"
+	"sort"
 	"testing"
 	"time"
 
+	"github.com/nobody05/graphql_go_client"
 	"github.com/nobody05/graphql_go_client/internal/jsonutil"
 )
 
@@ -61,6 +66,31 @@ func TestUnmarshalGraphQL_graphqlTag(t *testing.T) {
 	}
 }
 
+func TestUnmarshalGraphQL_alias(t *testing.T) {
+	type repository struct {
+		Name graphql.String
+	}
+	type query struct {
+		Primary   repository `graphql:"primary: repository(name: \"a\")"`
+		Secondary repository `graphql:"secondary:repository(name: \"b\") @include(if: $both)"`
+	}
+	var got query
+	err := jsonutil.UnmarshalGraphQL([]byte(`{
+		"primary": {"name": "a"},
+		"secondary": {"name": "b"}
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := query{
+		Primary:   repository{Name: "a"},
+		Secondary: repository{Name: "b"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Error("not equal")
+	}
+}
+
 func TestUnmarshalGraphQL_jsonTag(t *testing.T) {
 	type query struct {
 		Foo graphql.String `json:"baz"`
@@ -80,6 +110,330 @@ func TestUnmarshalGraphQL_jsonTag(t *testing.T) {
 	}
 }
 
+func TestUnmarshalGraphQL_fieldMatching(t *testing.T) {
+	type query struct {
+		Login     string // Matched by "LOGIN" ignoring case, as "login" matches UserLogin exactly.
+		UserLogin string `graphql:"login"`
+		CreatedAt string // Matched by "created_at" exactly, as queried.
+		Link      string `json:"href"`
+	}
+	data := []byte(`{
+		"LOGIN": "a",
+		"login": "b",
+		"created_at": "c",
+		"href": "d"
+	}`)
+	var got query
+	err := jsonutil.UnmarshalGraphQL(data, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := query{
+		Login:     "a",
+		UserLogin: "b",
+		CreatedAt: "c",
+		Link:      "d",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	err = jsonutil.UnmarshalGraphQLOptions(data, new(query), jsonutil.Options{CaseSensitive: true})
+	if got, want := fmt.Sprint(err), `decoding LOGIN: struct field for "LOGIN" doesn't exist in any of 1 places to unmarshal`; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+	got = query{}
+	err = jsonutil.UnmarshalGraphQLOptions([]byte(`{"login": "b", "created_at": "c", "href": "d"}`), &got, jsonutil.Options{CaseSensitive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.Login = ""
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+// Actor is decoded as the Actor union.
+type Actor interface{ isActor() }
+
+type user struct{ Login string }
+type bot struct{ ID string }
+
+func (user) isActor() {}
+func (bot) isActor()  {}
+
+func TestUnmarshalGraphQL_registeredUnion(t *testing.T) {
+	actorType := reflect.TypeOf((*Actor)(nil)).Elem()
+	jsonutil.RegisterUnion(actorType, "Actor", map[string]reflect.Type{
+		"User": reflect.TypeOf(user{}),
+		"Bot":  reflect.TypeOf(bot{}),
+	})
+	defer jsonutil.RegisterUnion(actorType, "Actor", nil)
+
+	// Registrations are by Go type, not by name.
+	{
+		type Actor interface{ isActor() }
+		if _, _, ok := jsonutil.LookupUnion(reflect.TypeOf((*Actor)(nil)).Elem()); ok {
+			t.Error("got a union registered for another type named Actor")
+		}
+	}
+
+	type query struct {
+		Author  Actor
+		Editors []Actor
+	}
+	data := []byte(`{
+		"author": {"__typename": "User", "login": "gopher"},
+		"editors": [
+			{"id": "B1", "__typename": "Bot"},
+			null,
+			{"__typename": "Mannequin", "email": "m@example.com"}
+		]
+	}`)
+	err := jsonutil.UnmarshalGraphQL(data, new(query))
+	if got, want := fmt.Sprint(err), `decoding editors[2] into Editors[2] of type jsonutil_test.Actor: no type registered for "Mannequin" of union Actor`; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+
+	// Members not registered are left nil by lenient decoding.
+	var got query
+	err = jsonutil.UnmarshalGraphQLLenient(data, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := query{
+		Author:  user{Login: "gopher"},
+		Editors: []Actor{bot{ID: "B1"}, nil, nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	err = jsonutil.UnmarshalGraphQL([]byte(`{"author": {"login": "gopher"}}`), new(query))
+	if got, want := fmt.Sprint(err), "decoding author into Author of type jsonutil_test.Actor: __typename of union Actor is missing"; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+}
+
+func TestUnmarshalGraphQL_bigNumbers(t *testing.T) {
+	type query struct {
+		ID     big.Int
+		Amount *big.Float
+		Total  *big.Int
+		Refund *big.Float
+		Extra  interface{}
+	}
+	data := []byte(`{
+		"id": 12345678901234567890123,
+		"amount": 1234567890123456.78,
+		"total": "98765432109876543210",
+		"refund": null,
+		"extra": {"id": 9007199254740993}
+	}`)
+	var got query
+	err := jsonutil.UnmarshalGraphQLOptions(data, &got, jsonutil.Options{UseNumber: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := got.ID.String(), "12345678901234567890123"; got != want {
+		t.Errorf("got id: %v, want: %v", got, want)
+	}
+	if got, want := got.Amount.Text('f', 2), "1234567890123456.78"; got != want {
+		t.Errorf("got amount: %v, want: %v", got, want)
+	}
+	if got, want := got.Total.String(), "98765432109876543210"; got != want {
+		t.Errorf("got total: %v, want: %v", got, want)
+	}
+	if got.Refund != nil {
+		t.Errorf("got refund: %v, want: nil", got.Refund)
+	}
+	if got, want := got.Extra, map[string]interface{}{"id": json.Number("9007199254740993")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got extra: %#v, want: %#v", got, want)
+	}
+
+	err = jsonutil.UnmarshalGraphQL([]byte(`{"total": "many"}`), new(query))
+	if got, want := fmt.Sprint(err), `decoding total into Total of type *big.Int: cannot unmarshal "many" into Go value of type big.Int`; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+}
+
+func TestUnmarshalGraphQL_errorPath(t *testing.T) {
+	type query struct {
+		Repository struct {
+			Issues struct {
+				Nodes []struct {
+					Author struct {
+						Login int `graphql:"login"`
+					} `graphql:"author"`
+				}
+			}
+		}
+	}
+	err := jsonutil.UnmarshalGraphQLOptions([]byte(`{
+		"repository": {
+			"issues": {
+				"nodes": [
+					{"author": {"login": 1}},
+					{"author": {"login": 2}},
+					{"author": {"login": 3}},
+					{"author": {"login": "gopher"}}
+				]
+			}
+		}
+	}`), new(query), jsonutil.Options{Path: "data"})
+	if got, want := fmt.Sprint(err), "decoding data.repository.issues.nodes[3].author.login into Repository.Issues.Nodes[3].Author.Login of type int: json: cannot unmarshal string into Go value of type int"; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		t.Errorf("got error: %T, want one wrapping *json.UnmarshalTypeError", err)
+	}
+}
+
+// point unmarshals itself from a JSON array of coordinates.
+type point struct{ X, Y float64 }
+
+func (p *point) UnmarshalJSON(data []byte) error {
+	var xy [2]float64
+	err := json.Unmarshal(data, &xy)
+	p.X, p.Y = xy[0], xy[1]
+	return err
+}
+
+// metadata unmarshals itself from a JSON object.
+type metadata struct{ Keys []string }
+
+func (m *metadata) UnmarshalJSON(data []byte) error {
+	var v map[string]json.RawMessage
+	err := json.Unmarshal(data, &v)
+	for k := range v {
+		m.Keys = append(m.Keys, k)
+	}
+	sort.Strings(m.Keys)
+	return err
+}
+
+// state is an enum that unmarshals itself from text.
+type state int
+
+func (s *state) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "OPEN":
+		*s = 1
+	case "CLOSED":
+		*s = 2
+	default:
+		return fmt.Errorf("unknown state %q", text)
+	}
+	return nil
+}
+
+func TestUnmarshalGraphQL_unmarshaler(t *testing.T) {
+	type query struct {
+		Location point
+		Path     []*point
+		Metadata *metadata
+		State    state
+	}
+	var got query
+	err := jsonutil.UnmarshalGraphQL([]byte(`{
+		"location": [1.5, -2],
+		"path": [[0, 0], null],
+		"metadata": {"a": {"b": [1, "<c>", true, null]}, "d": {}},
+		"state": "CLOSED"
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := query{
+		Location: point{1.5, -2},
+		Path:     []*point{{0, 0}, nil},
+		Metadata: &metadata{Keys: []string{"a", "d"}},
+		State:    2,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %+v, want: %+v", got, want)
+	}
+
+	err = jsonutil.UnmarshalGraphQL([]byte(`{"state": "MERGED"}`), &got)
+	if got, want := fmt.Sprint(err), `decoding state into State of type jsonutil_test.state: unknown state "MERGED"`; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+}
+
+func TestUnmarshalGraphQL_dynamic(t *testing.T) {
+	type query struct {
+		Settings map[string]interface{}
+		Labels   *map[string]string
+		Payload  interface{}
+		Items    []interface{}
+		Raw      json.RawMessage
+	}
+	var got query
+	err := jsonutil.UnmarshalGraphQL([]byte(`{
+		"settings": {"theme": "dark", "size": 12, "tags": ["a"]},
+		"labels": {"bug": "red"},
+		"payload": [{"x": null}],
+		"items": [1, {"y": false}, "z"],
+		"raw": {"a": [1, 2]}
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := query{
+		Settings: map[string]interface{}{"theme": "dark", "size": float64(12), "tags": []interface{}{"a"}},
+		Labels:   &map[string]string{"bug": "red"},
+		Payload:  []interface{}{map[string]interface{}{"x": nil}},
+		Items:    []interface{}{float64(1), map[string]interface{}{"y": false}, "z"},
+		Raw:      json.RawMessage(`{"a":[1,2]}`),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v\nwant: %#v", got, want)
+	}
+
+	var gotMap map[string]interface{}
+	err = jsonutil.UnmarshalGraphQL([]byte(`{"viewer": {"login": "gopher"}}`), &gotMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"viewer": map[string]interface{}{"login": "gopher"}}; !reflect.DeepEqual(gotMap, want) {
+		t.Errorf("got: %v, want: %v", gotMap, want)
+	}
+}
+
+func TestUnmarshalGraphQL_unknownFields(t *testing.T) {
+	type query struct {
+		Viewer struct {
+			Login graphql.String
+		}
+	}
+	data := []byte(`{
+		"viewer": {
+			"id": "U1",
+			"login": "gopher",
+			"repositories": {"nodes": [{"name": "a", "tags": [[1], {}]}]}
+		},
+		"rateLimit": null
+	}`)
+
+	var got query
+	err := jsonutil.UnmarshalGraphQL(data, &got)
+	if got, want := fmt.Sprint(err), `decoding viewer.id: struct field for "id" doesn't exist in any of 1 places to unmarshal`; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+
+	got = query{}
+	err = jsonutil.UnmarshalGraphQLLenient(data, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want query
+	want.Viewer.Login = "gopher"
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %+v, want: %+v", got, want)
+	}
+}
+
 func TestUnmarshalGraphQL_array(t *testing.T) {
 	type query struct {
 		Foo []graphql.String
@@ -172,6 +526,55 @@ func TestUnmarshalGraphQL_pointer(t *testing.T) {
 	}
 }
 
+func TestUnmarshalGraphQL_embedded(t *testing.T) {
+	type Node struct {
+		ID string `graphql:"id"`
+	}
+	type Timestamps struct {
+		CreatedAt string `graphql:"createdAt"`
+	}
+	type actor struct {
+		Login string `graphql:"login"`
+	}
+	type query struct {
+		Issue struct {
+			Node
+			*Timestamps
+			actor
+			Title string `graphql:"title"`
+		}
+	}
+	var got query
+	err := jsonutil.UnmarshalGraphQL([]byte(`{
+		"issue": {
+			"id": "I1",
+			"createdAt": "2024-01-02",
+			"login": "gopher",
+			"title": "Crash"
+		}
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want query
+	want.Issue.Node.ID = "I1"
+	want.Issue.Timestamps = &Timestamps{CreatedAt: "2024-01-02"}
+	want.Issue.actor.Login = "gopher"
+	want.Issue.Title = "Crash"
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// Embedded pointers to unexported structs can't be allocated.
+	var unexported struct {
+		*actor
+	}
+	err = jsonutil.UnmarshalGraphQL([]byte(`{"login": "gopher"}`), &unexported)
+	if got, want := fmt.Sprint(err), "cannot set embedded pointer to unexported struct type jsonutil_test.actor"; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+}
+
 func TestUnmarshalGraphQL_objectPointerArray(t *testing.T) {
 	type query struct {
 		Foo []*struct {
@@ -241,6 +644,81 @@ func TestUnmarshalGraphQL_pointerWithInlineFragment(t *testing.T) {
 	}
 }
 
+func TestUnmarshalGraphQL_nullable(t *testing.T) {
+	type issue struct {
+		Number graphql.Int
+	}
+	type pullRequest struct {
+		Merged graphql.Boolean
+	}
+	type query struct {
+		Labels    *[]graphql.String
+		Assignees *[]graphql.String
+		Milestone **struct {
+			DueOn *graphql.String
+		}
+		Closer *struct{ Login graphql.String }
+		Nodes  *[]*struct {
+			Title       *graphql.String
+			Issue       *issue       `graphql:"... on Issue"`
+			PullRequest *pullRequest `graphql:"... on PullRequest"`
+		}
+	}
+	jsonutil.RegisterPossibleTypes("SearchResult", []string{"Issue", "PullRequest"})
+	defer jsonutil.RegisterPossibleTypes("SearchResult", nil)
+	got := query{
+		Closer: &struct{ Login graphql.String }{"stale"},
+	}
+	err := jsonutil.UnmarshalGraphQL([]byte(`{
+		"labels": ["bug"],
+		"assignees": null,
+		"milestone": {"dueOn": null},
+		"closer": null,
+		"nodes": [
+			null,
+			{"__typename": "Issue", "title": "a", "number": 1},
+			{"__typename": "PullRequest", "title": null, "merged": true}
+		]
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Labels == nil || !reflect.DeepEqual(*got.Labels, []graphql.String{"bug"}) {
+		t.Errorf("got labels: %v, want: [bug]", got.Labels)
+	}
+	if got.Assignees != nil {
+		t.Errorf("got assignees: %v, want: nil", *got.Assignees)
+	}
+	if got.Milestone == nil || *got.Milestone == nil || (*got.Milestone).DueOn != nil {
+		t.Errorf("got milestone: %v, want: non-nil with nil dueOn", got.Milestone)
+	}
+	if got.Closer != nil {
+		t.Errorf("got closer: %v, want: nil", *got.Closer)
+	}
+	if got.Nodes == nil || len(*got.Nodes) != 3 {
+		t.Fatalf("got nodes: %v, want 3 of them", got.Nodes)
+	}
+	nodes := *got.Nodes
+	if nodes[0] != nil {
+		t.Errorf("got nodes[0]: %+v, want: nil", *nodes[0])
+	}
+	if got, want := *nodes[1].Title, graphql.String("a"); got != want {
+		t.Errorf("got nodes[1].Title: %v, want: %v", got, want)
+	}
+	if got, want := nodes[1].Issue, (&issue{Number: 1}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got nodes[1].Issue: %v, want: %v", got, want)
+	}
+	if nodes[1].PullRequest != nil {
+		t.Errorf("got nodes[1].PullRequest: %v, want: nil", nodes[1].PullRequest)
+	}
+	if nodes[2].Title != nil || nodes[2].Issue != nil {
+		t.Errorf("got nodes[2]: %+v, want nil title and issue", *nodes[2])
+	}
+	if got, want := nodes[2].PullRequest, (&pullRequest{Merged: true}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got nodes[2].PullRequest: %v, want: %v", got, want)
+	}
+}
+
 func TestUnmarshalGraphQL_unexportedField(t *testing.T) {
 	type query struct {
 		foo graphql.String
@@ -249,7 +727,7 @@ func TestUnmarshalGraphQL_unexportedField(t *testing.T) {
 	if err == nil {
 		t.Fatal("got error: nil, want: non-nil")
 	}
-	if got, want := err.Error(), "struct field for \"foo\" doesn't exist in any of 1 places to unmarshal"; got != want {
+	if got, want := err.Error(), "decoding foo: struct field for \"foo\" doesn't exist in any of 1 places to unmarshal"; got != want {
 		t.Errorf("got error: %v, want: %v", got, want)
 	}
 }
@@ -280,13 +758,15 @@ func TestUnmarshalGraphQL_directives(t *testing.T) {
 		Me struct {
 			Name   graphql.String `graphql:"name @include(if: true)"`
 			Height graphql.Float  `graphql:"height @skip(if: false)"`
+			Mass   graphql.Float  `graphql:"@include(if: true)"`
 		}
 	}
 	var got query
 	err := jsonutil.UnmarshalGraphQL([]byte(`{
 		"me": {
 			"name": "Luke Skywalker",
-			"height": 1.72
+			"height": 1.72,
+			"mass": 77
 		}
 	}`), &got)
 	if err != nil {
@@ -295,6 +775,7 @@ func TestUnmarshalGraphQL_directives(t *testing.T) {
 	var want query
 	want.Me.Name = "Luke Skywalker"
 	want.Me.Height = 1.72
+	want.Me.Mass = 77
 	if !reflect.DeepEqual(got, want) {
 		t.Error("not equal")
 	}
@@ -359,6 +840,77 @@ func TestUnmarshalGraphQL_union(t *testing.T) {
 	}
 }
 
+func TestUnmarshalGraphQL_unionWithoutTypename(t *testing.T) {
+	type closedEvent struct {
+		CreatedAt time.Time
+	}
+	type reopenedEvent struct {
+		CreatedAt time.Time
+	}
+	type issueTimelineItem struct {
+		ClosedEvent   closedEvent   `graphql:"... on ClosedEvent"`
+		ReopenedEvent reopenedEvent `graphql:"... on ReopenedEvent"`
+	}
+	jsonutil.RegisterPossibleTypes("IssueTimelineItem", []string{"ClosedEvent", "ReopenedEvent"})
+	defer jsonutil.RegisterPossibleTypes("IssueTimelineItem", nil)
+	var got []issueTimelineItem
+	err := jsonutil.UnmarshalGraphQL([]byte(`[
+		{"__typename": "ReopenedEvent", "createdAt": "2017-06-29T04:12:01Z"},
+		{"createdAt": "2017-06-29T04:12:01Z"}
+	]`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []issueTimelineItem{
+		{
+			ReopenedEvent: reopenedEvent{CreatedAt: time.Unix(1498709521, 0).UTC()},
+		},
+		{
+			// Without __typename, every fragment receives the fields.
+			ClosedEvent:   closedEvent{CreatedAt: time.Unix(1498709521, 0).UTC()},
+			ReopenedEvent: reopenedEvent{CreatedAt: time.Unix(1498709521, 0).UTC()},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestUnmarshalGraphQL_interfaceFragment(t *testing.T) {
+	type node struct {
+		Node struct {
+			ID graphql.ID
+		} `graphql:"... on Node"`
+		Issue struct {
+			Title graphql.String
+		} `graphql:"... on Issue"`
+		PullRequest struct {
+			Title graphql.String
+		} `graphql:"... on PullRequest"`
+	}
+	data := []byte(`{"__typename": "Issue", "id": "abc", "title": "t"}`)
+
+	// Without possible types, type conditions may be interfaces,
+	// so every fragment applies.
+	var got node
+	if err := jsonutil.UnmarshalGraphQL(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Node.ID != "abc" || got.Issue.Title != "t" || got.PullRequest.Title != "t" {
+		t.Errorf("got %+v, want every fragment populated", got)
+	}
+
+	jsonutil.RegisterPossibleTypes("Node", []string{"Issue", "PullRequest"})
+	defer jsonutil.RegisterPossibleTypes("Node", nil)
+	got = node{}
+	if err := jsonutil.UnmarshalGraphQL(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Node.ID != "abc" || got.Issue.Title != "t" || got.PullRequest.Title != "" {
+		t.Errorf("got %+v, want the Node and Issue fragments populated", got)
+	}
+}
+
 // Issue https://github.com/shurcooL/githubv4/issues/18.
 func TestUnmarshalGraphQL_arrayInsideInlineFragment(t *testing.T) {
 	/*
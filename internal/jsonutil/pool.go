@@ -0,0 +1,79 @@
+package jsonutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// maxPooledBuffer is the capacity beyond which buffers aren't pooled,
+// so that a single large response doesn't pin its memory.
+const maxPooledBuffer = 64 << 10
+
+// buffer is a pooled buffer, along with a JSON encoder writing to it.
+type buffer struct {
+	bytes.Buffer
+	enc *json.Encoder
+}
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		b := new(buffer)
+		b.enc = json.NewEncoder(&b.Buffer)
+		return b
+	},
+}
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *buffer {
+	b := bufferPool.Get().(*buffer)
+	b.Reset()
+	return b
+}
+
+// putBuffer returns b to the pool. b must not be used afterwards.
+func putBuffer(b *buffer) {
+	if b.Cap() > maxPooledBuffer {
+		return
+	}
+	bufferPool.Put(b)
+}
+
+// encode appends the JSON encoding of v to b, as by json.Marshal.
+func (b *buffer) encode(v interface{}) error {
+	if err := b.enc.Encode(v); err != nil {
+		return err
+	}
+	b.Truncate(b.Len() - 1) // Drop the newline Encode adds.
+	return nil
+}
+
+var decoderPool = sync.Pool{
+	New: func() interface{} {
+		return new(decoder)
+	},
+}
+
+// getDecoder returns a decoder from the pool, reading tokens from tokenizer,
+// as configured by opts.
+func getDecoder(tokenizer interface{ Token() (json.Token, error) }, opts Options) *decoder {
+	d := decoderPool.Get().(*decoder)
+	d.tokenizer, d.opts = tokenizer, opts
+	return d
+}
+
+// putDecoder returns d to the pool, keeping the memory of its stacks,
+// but none of the values they refer to. d must not be used afterwards.
+func putDecoder(d *decoder) {
+	clear(d.vs[:cap(d.vs)])
+	clear(d.objects[:cap(d.objects)])
+	clear(d.path[:cap(d.path)])
+	*d = decoder{
+		parseState: d.parseState[:0],
+		objects:    d.objects[:0],
+		arrays:     d.arrays[:0],
+		path:       d.path[:0],
+		vs:         d.vs[:0],
+	}
+	decoderPool.Put(d)
+}
@@ -0,0 +1,260 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Document is a parsed GraphQL document, which may hold executable
+// definitions (operations and fragments), type system definitions, or both.
+type Document struct {
+	Operations []*Operation
+	Fragments  []*Fragment
+	Types      []*TypeDefinition
+	Directives []*DirectiveDefinition
+	Schema     *SchemaDefinition // Schema definition, if any.
+}
+
+// Fragment returns the fragment definition with the given name, or nil.
+func (d *Document) Fragment(name string) *Fragment {
+	for _, f := range d.Fragments {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Operation is an operation definition.
+type Operation struct {
+	Type         string // "query", "mutation" or "subscription".
+	Name         string // Operation name, or empty if anonymous.
+	Variables    []*VariableDefinition
+	Directives   []*Directive
+	SelectionSet []Selection
+
+	// DirectivesEnd is the byte offset in the source past the directives
+	// of the operation, where more could be inserted.
+	DirectivesEnd int
+}
+
+// VariableDefinition is the definition of a variable of an operation.
+type VariableDefinition struct {
+	Name         string // Name, without the "$".
+	Type         *Type
+	DefaultValue *Value // Default value, or nil.
+}
+
+// Fragment is a named fragment definition.
+type Fragment struct {
+	Name          string
+	TypeCondition string
+	Directives    []*Directive
+	SelectionSet  []Selection
+}
+
+// Selection is a *Field, *FragmentSpread or *InlineFragment.
+type Selection interface {
+	selection()
+}
+
+// Field is a field selection.
+type Field struct {
+	Alias        string // Alias, or empty if none.
+	Name         string
+	Arguments    []*Argument
+	Directives   []*Directive
+	SelectionSet []Selection
+
+	// ArgumentsEnd is the byte offset in the source of the closing
+	// parenthesis of the arguments of the field, or past its name if it
+	// has none, where more arguments could be inserted.
+	ArgumentsEnd int
+
+	// DirectivesEnd is the byte offset in the source past the arguments
+	// and directives of the field, where more directives could be inserted.
+	DirectivesEnd int
+}
+
+// ResponseKey returns the key of the field in the response:
+// its alias if it has one, its name otherwise.
+func (f *Field) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// FragmentSpread is a spread of a named fragment.
+type FragmentSpread struct {
+	Name       string
+	Directives []*Directive
+}
+
+// InlineFragment is an inline fragment.
+type InlineFragment struct {
+	TypeCondition string // Type condition, or empty if none.
+	Directives    []*Directive
+	SelectionSet  []Selection
+}
+
+func (*Field) selection()          {}
+func (*FragmentSpread) selection() {}
+func (*InlineFragment) selection() {}
+
+// Argument is an argument of a field or directive,
+// or a field of an input object value.
+type Argument struct {
+	Name  string
+	Value *Value
+}
+
+// Directive is a directive applied to a definition or selection.
+type Directive struct {
+	Name      string
+	Arguments []*Argument
+}
+
+// Argument returns the argument of d with the given name, or nil.
+func (d *Directive) Argument(name string) *Argument {
+	for _, a := range d.Arguments {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// ValueKind is the kind of a value.
+type ValueKind uint8
+
+// Kinds of values.
+const (
+	VariableValue ValueKind = iota
+	IntValue
+	FloatValue
+	StringValue
+	BooleanValue
+	NullValue
+	EnumValue
+	ListValue
+	ObjectValue
+)
+
+// Value is an input value, given literally or as a variable.
+type Value struct {
+	Kind ValueKind
+	// Raw is the text of scalar and enum values, the decoded value
+	// of strings, or the name of variables, without the "$".
+	Raw    string
+	List   []*Value    // Items of list values.
+	Fields []*Argument // Fields of object values.
+}
+
+// String returns v in GraphQL syntax.
+func (v *Value) String() string {
+	switch v.Kind {
+	case VariableValue:
+		return "$" + v.Raw
+	case StringValue:
+		return strconv.Quote(v.Raw)
+	case ListValue:
+		items := make([]string, len(v.List))
+		for i, item := range v.List {
+			items[i] = item.String()
+		}
+		return "[" + strings.Join(items, ",") + "]"
+	case ObjectValue:
+		fields := make([]string, len(v.Fields))
+		for i, f := range v.Fields {
+			fields[i] = f.Name + ":" + f.Value.String()
+		}
+		return "{" + strings.Join(fields, ",") + "}"
+	}
+	return v.Raw
+}
+
+// Type is a reference to a type, as in variable definitions
+// and field definitions.
+type Type struct {
+	Name    string // Name of a named type, or empty for a list type.
+	Elem    *Type  // Element type of a list type, or nil.
+	NonNull bool
+}
+
+// NamedType returns the name of the named type that t refers to,
+// unwrapping lists.
+func (t *Type) NamedType() string {
+	for t.Elem != nil {
+		t = t.Elem
+	}
+	return t.Name
+}
+
+// String returns t in GraphQL syntax, such as "[String!]!".
+func (t *Type) String() string {
+	s := t.Name
+	if t.Elem != nil {
+		s = "[" + t.Elem.String() + "]"
+	}
+	if t.NonNull {
+		s += "!"
+	}
+	return s
+}
+
+// TypeDefinition is the definition, or extension, of a named type.
+type TypeDefinition struct {
+	Kind        string // "SCALAR", "OBJECT", "INTERFACE", "UNION", "ENUM" or "INPUT_OBJECT".
+	Name        string
+	Description string
+	Extension   bool // Whether it extends a type defined elsewhere.
+	Directives  []*Directive
+	Interfaces  []string                // Interfaces implemented by objects and interfaces.
+	Fields      []*FieldDefinition      // Fields of objects and interfaces.
+	Types       []string                // Member types of unions.
+	EnumValues  []*EnumValueDefinition  // Values of enums.
+	InputFields []*InputValueDefinition // Fields of input objects.
+}
+
+// FieldDefinition is the definition of a field of an object or interface type.
+type FieldDefinition struct {
+	Name        string
+	Description string
+	Arguments   []*InputValueDefinition
+	Type        *Type
+	Directives  []*Directive
+}
+
+// InputValueDefinition is the definition of an argument,
+// or of a field of an input object type.
+type InputValueDefinition struct {
+	Name         string
+	Description  string
+	Type         *Type
+	DefaultValue *Value // Default value, or nil.
+	Directives   []*Directive
+}
+
+// EnumValueDefinition is the definition of a value of an enum type.
+type EnumValueDefinition struct {
+	Name        string
+	Description string
+	Directives  []*Directive
+}
+
+// DirectiveDefinition is the definition of a directive.
+type DirectiveDefinition struct {
+	Name        string
+	Description string
+	Arguments   []*InputValueDefinition
+	Repeatable  bool
+	Locations   []string
+}
+
+// SchemaDefinition is a schema definition, naming the root operation types.
+type SchemaDefinition struct {
+	Query        string
+	Mutation     string
+	Subscription string
+}
@@ -0,0 +1,193 @@
+package parser
+
+import "strings"
+
+// indent is the indentation of each level of selection sets and other
+// blocks in formatted documents.
+const indent = "  "
+
+// definitionKeywords are the keywords starting definitions,
+// which begin a new paragraph in formatted documents.
+var definitionKeywords = map[string]bool{
+	"query": true, "mutation": true, "subscription": true, "fragment": true,
+	"schema": true, "scalar": true, "type": true, "interface": true,
+	"union": true, "enum": true, "input": true, "directive": true, "extend": true,
+}
+
+// Format returns src printed in the conventional layout of GraphQL
+// documents: one selection, or field definition, per line, indented by two
+// spaces per level of nesting; arguments, variable definitions, lists and
+// input objects on a single line, separated by ", "; and a blank line
+// between definitions. Comments are dropped, and strings are kept as is.
+func Format(src string) (string, error) {
+	l := &lexer{src: src}
+	f := &formatter{}
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return "", err
+		}
+		if tok.kind == tokenEOF {
+			return f.b.String(), nil
+		}
+		f.write(tok, src[tok.pos:l.pos])
+	}
+}
+
+// formatter prints tokens for Format.
+type formatter struct {
+	b strings.Builder
+
+	// stack holds the brackets enclosing the current token: '{' for
+	// blocks, such as selection sets, printed over several lines, 'o' for
+	// input object values, '(' and '[', which are printed inline.
+	stack []byte
+	level int // Number of blocks on the stack.
+
+	prev, prev2 token // Two previous tokens, with prev2 before prev.
+	closed      bool  // Whether prev closed a definition.
+}
+
+func (f *formatter) write(tok token, text string) {
+	switch {
+	case f.b.Len() == 0:
+	case len(f.stack) == 0 && f.startsDefinition(tok):
+		f.b.WriteString("\n\n")
+	case len(f.stack) == 0 && isString(f.prev.kind):
+		// Descriptions are on a line of their own.
+		f.newline()
+	case is(tok, "}") && f.top() == '{':
+		f.level--
+		f.newline()
+	case f.top() == '{' && (is(f.prev, "{") || f.startsSelection(tok)):
+		f.newline()
+	case f.inline() && f.startsItem(tok):
+		f.b.WriteString(", ")
+	case f.spaced(tok):
+		f.b.WriteByte(' ')
+	}
+	f.b.WriteString(text)
+
+	f.closed = false
+	if tok.kind == tokenPunctuator {
+		switch tok.value {
+		case "{":
+			if f.inline() || is(f.prev, ":") || is(f.prev, "=") {
+				f.stack = append(f.stack, 'o')
+			} else {
+				f.stack = append(f.stack, '{')
+				f.level++
+			}
+		case "(", "[":
+			f.stack = append(f.stack, tok.value[0])
+		case "}", ")", "]":
+			if len(f.stack) > 0 {
+				f.stack = f.stack[:len(f.stack)-1]
+			}
+			f.closed = tok.value == "}" && len(f.stack) == 0
+		}
+	}
+	f.prev2, f.prev = f.prev, tok
+}
+
+// is reports whether tok is the punctuator p.
+func is(tok token, p string) bool {
+	return tok.kind == tokenPunctuator && tok.value == p
+}
+
+// isValue reports whether tokens of kind k end a value or a name.
+func isValue(k tokenKind) bool {
+	return isWord(k) || isString(k)
+}
+
+// top returns the innermost bracket enclosing the current token, or 0.
+func (f *formatter) top() byte {
+	if len(f.stack) == 0 {
+		return 0
+	}
+	return f.stack[len(f.stack)-1]
+}
+
+// inline reports whether the current token is printed
+// on the line of its enclosing bracket.
+func (f *formatter) inline() bool {
+	top := f.top()
+	return top != 0 && top != '{'
+}
+
+// newline starts a new line at the current level of indentation.
+func (f *formatter) newline() {
+	f.b.WriteByte('\n')
+	f.b.WriteString(strings.Repeat(indent, f.level))
+}
+
+// startsDefinition reports whether tok, at the top level,
+// starts a definition other than the first.
+func (f *formatter) startsDefinition(tok token) bool {
+	if f.closed || isString(tok.kind) {
+		// Definitions with a description start with it.
+		return true
+	}
+	if tok.kind != tokenName || !definitionKeywords[tok.value] {
+		return false
+	}
+	switch {
+	case f.prev.kind == tokenName:
+		return !definitionKeywords[f.prev.value] && f.prev.value != "on" && f.prev.value != "implements"
+	case is(f.prev, "!"), is(f.prev, "]"), is(f.prev, ")"):
+		return true
+	}
+	return false
+}
+
+// startsSelection reports whether tok, in a block, starts a selection,
+// or a field, enum value or other item of a type system definition.
+func (f *formatter) startsSelection(tok token) bool {
+	if !isValue(tok.kind) && !is(tok, "...") {
+		return false
+	}
+	if f.prev.kind == tokenPunctuator {
+		switch f.prev.value {
+		case ":", "@", "...", "=", "$", "|", "&":
+			return false
+		}
+	}
+	return f.prev.kind != tokenName || f.prev.value != "on" || !is(f.prev2, "...")
+}
+
+// startsItem reports whether tok, in brackets printed inline, starts an
+// argument, variable definition, list item or input object field other
+// than the first.
+func (f *formatter) startsItem(tok token) bool {
+	if !isValue(tok.kind) && !is(tok, "$") && !is(tok, "[") && !is(tok, "{") {
+		return false
+	}
+	return isValue(f.prev.kind) || is(f.prev, "]") || is(f.prev, "}") || is(f.prev, "!")
+}
+
+// spaced reports whether tok is separated from the previous token,
+// on the same line, by a space.
+func (f *formatter) spaced(tok token) bool {
+	if f.prev.kind == tokenPunctuator {
+		switch f.prev.value {
+		case "(", "[", "$", "@":
+			return false
+		case "{":
+			return f.top() != 'o'
+		case "...":
+			return tok.kind != tokenName || tok.value == "on"
+		}
+	}
+	if tok.kind == tokenPunctuator {
+		switch tok.value {
+		case "(":
+			// As in "query ($id: ID!)", for anonymous operations.
+			return len(f.stack) == 0 && f.prev.kind == tokenName && definitionKeywords[f.prev.value]
+		case ")", "]", "!", ":":
+			return false
+		case "}":
+			return f.top() != 'o'
+		}
+	}
+	return true
+}
@@ -0,0 +1,37 @@
+package parser
+
+import "strings"
+
+// Minify returns src without ignored tokens: whitespace, line terminators,
+// commas and comments. Tokens are separated by a space only where they
+// would run together otherwise, such as two names, or two strings, the
+// first of which may be empty. Strings are kept as is.
+func Minify(src string) (string, error) {
+	l := &lexer{src: src}
+	var b strings.Builder
+	var prev tokenKind
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return "", err
+		}
+		if tok.kind == tokenEOF {
+			return b.String(), nil
+		}
+		if isWord(prev) && isWord(tok.kind) || isString(prev) && isString(tok.kind) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(src[tok.pos:l.pos])
+		prev = tok.kind
+	}
+}
+
+// isWord reports whether tokens of kind k run together with one another.
+func isWord(k tokenKind) bool {
+	return k == tokenName || k == tokenInt || k == tokenFloat
+}
+
+// isString reports whether tokens of kind k are strings.
+func isString(k tokenKind) bool {
+	return k == tokenString || k == tokenBlockString
+}
@@ -0,0 +1,449 @@
+// Package parser provides a parser for GraphQL documents: both executable
+// documents, with operations and fragments, and schemas written in the
+// GraphQL schema definition language (SDL).
+package parser
+
+import (
+	"fmt"
+)
+
+// Parse parses the GraphQL document src.
+//
+// Specification: https://spec.graphql.org/October2021/#sec-Document.
+func Parse(src string) (*Document, error) {
+	p := &parser{lex: lexer{src: src}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	doc := &Document{}
+	for p.tok.kind != tokenEOF {
+		if err := p.definition(doc); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// parser parses a GraphQL document with one token of lookahead.
+// Its methods panic with a syntaxError on errors,
+// which Parse recovers from.
+type parser struct {
+	lex lexer
+	tok token // Current token.
+}
+
+// syntaxError wraps errors panicked with by parser methods.
+type syntaxError struct{ err error }
+
+func (p *parser) advance() (err error) {
+	p.tok, err = p.lex.next()
+	return err
+}
+
+// fail panics with an error at the current token.
+func (p *parser) fail(format string, args ...interface{}) {
+	panic(syntaxError{p.lex.errorf(p.tok.pos, format, args...)})
+}
+
+// next advances to the next token, panicking on errors.
+func (p *parser) next() {
+	if err := p.advance(); err != nil {
+		panic(syntaxError{err})
+	}
+}
+
+// peek reports whether the current token is the punctuator or keyword s.
+func (p *parser) peek(s string) bool {
+	return (p.tok.kind == tokenPunctuator || p.tok.kind == tokenName) && p.tok.value == s
+}
+
+// skip advances past the current token and returns true
+// if it's the punctuator or keyword s.
+func (p *parser) skip(s string) bool {
+	if !p.peek(s) {
+		return false
+	}
+	p.next()
+	return true
+}
+
+// expect advances past the punctuator or keyword s, which must be current.
+func (p *parser) expect(s string) {
+	if !p.skip(s) {
+		p.fail("expected %q, found %s", s, p.describe())
+	}
+}
+
+// name advances past a name, which must be current, and returns it.
+func (p *parser) name() string {
+	if p.tok.kind != tokenName {
+		p.fail("expected name, found %s", p.describe())
+	}
+	name := p.tok.value
+	p.next()
+	return name
+}
+
+// describe describes the current token for error messages.
+func (p *parser) describe() string {
+	switch p.tok.kind {
+	case tokenEOF:
+		return "end of input"
+	case tokenString, tokenBlockString:
+		return "string"
+	}
+	return fmt.Sprintf("%q", p.tok.value)
+}
+
+// definition parses a single definition into doc.
+func (p *parser) definition(doc *Document) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(syntaxError)
+			if !ok {
+				panic(r)
+			}
+			err = e.err
+		}
+	}()
+	description := p.description()
+	switch {
+	case description == "" && (p.peek("{") || p.peek("query") || p.peek("mutation") || p.peek("subscription")):
+		doc.Operations = append(doc.Operations, p.operation())
+	case description == "" && p.peek("fragment"):
+		doc.Fragments = append(doc.Fragments, p.fragment())
+	case p.peek("schema"):
+		doc.Schema = p.schema(doc.Schema)
+	case p.peek("directive"):
+		d := p.directiveDefinition()
+		d.Description = description
+		doc.Directives = append(doc.Directives, d)
+	case p.peek("extend"):
+		p.next()
+		if p.peek("schema") {
+			doc.Schema = p.schema(doc.Schema)
+			return nil
+		}
+		t := p.typeDefinition()
+		t.Extension = true
+		doc.Types = append(doc.Types, t)
+	default:
+		t := p.typeDefinition()
+		t.Description = description
+		doc.Types = append(doc.Types, t)
+	}
+	return nil
+}
+
+// description parses an optional description.
+func (p *parser) description() string {
+	if p.tok.kind != tokenString && p.tok.kind != tokenBlockString {
+		return ""
+	}
+	s := p.tok.value
+	p.next()
+	return s
+}
+
+func (p *parser) operation() *Operation {
+	op := &Operation{Type: "query"}
+	if !p.peek("{") {
+		op.Type = p.name()
+		if p.tok.kind == tokenName {
+			op.Name = p.name()
+		}
+		if p.skip("(") {
+			for !p.skip(")") {
+				op.Variables = append(op.Variables, p.variableDefinition())
+			}
+		}
+		op.Directives = p.directives()
+	}
+	op.DirectivesEnd = p.tok.pos
+	op.SelectionSet = p.selectionSet()
+	return op
+}
+
+func (p *parser) variableDefinition() *VariableDefinition {
+	p.expect("$")
+	v := &VariableDefinition{Name: p.name()}
+	p.expect(":")
+	v.Type = p.typeRef()
+	if p.skip("=") {
+		v.DefaultValue = p.value(true)
+	}
+	p.directives()
+	return v
+}
+
+func (p *parser) fragment() *Fragment {
+	p.expect("fragment")
+	f := &Fragment{Name: p.name()}
+	if f.Name == "on" {
+		p.fail("unexpected fragment name \"on\"")
+	}
+	p.expect("on")
+	f.TypeCondition = p.name()
+	f.Directives = p.directives()
+	f.SelectionSet = p.selectionSet()
+	return f
+}
+
+func (p *parser) selectionSet() []Selection {
+	p.expect("{")
+	var set []Selection
+	for !p.skip("}") {
+		set = append(set, p.selection())
+	}
+	if len(set) == 0 {
+		p.fail("empty selection set")
+	}
+	return set
+}
+
+func (p *parser) selection() Selection {
+	if p.skip("...") {
+		if p.tok.kind == tokenName && p.tok.value != "on" {
+			return &FragmentSpread{Name: p.name(), Directives: p.directives()}
+		}
+		f := &InlineFragment{}
+		if p.skip("on") {
+			f.TypeCondition = p.name()
+		}
+		f.Directives = p.directives()
+		f.SelectionSet = p.selectionSet()
+		return f
+	}
+	f := &Field{Name: p.name()}
+	if p.skip(":") {
+		f.Alias, f.Name = f.Name, p.name()
+	}
+	f.ArgumentsEnd = p.tok.pos
+	f.Arguments = p.arguments(false, &f.ArgumentsEnd)
+	f.Directives = p.directives()
+	f.DirectivesEnd = p.tok.pos
+	if p.peek("{") {
+		f.SelectionSet = p.selectionSet()
+	}
+	return f
+}
+
+// arguments parses arguments, if any. If end is non-nil and there are
+// arguments, it's set to the byte offset of the closing parenthesis.
+func (p *parser) arguments(constant bool, end *int) []*Argument {
+	if !p.skip("(") {
+		return nil
+	}
+	var args []*Argument
+	for !p.peek(")") {
+		a := &Argument{Name: p.name()}
+		p.expect(":")
+		a.Value = p.value(constant)
+		args = append(args, a)
+	}
+	if end != nil {
+		*end = p.tok.pos
+	}
+	p.next()
+	return args
+}
+
+func (p *parser) directives() []*Directive {
+	var ds []*Directive
+	for p.skip("@") {
+		ds = append(ds, &Directive{Name: p.name(), Arguments: p.arguments(false, nil)})
+	}
+	return ds
+}
+
+// value parses a value. If constant is true, variables aren't allowed.
+func (p *parser) value(constant bool) *Value {
+	tok := p.tok
+	switch tok.kind {
+	case tokenInt:
+		p.next()
+		return &Value{Kind: IntValue, Raw: tok.value}
+	case tokenFloat:
+		p.next()
+		return &Value{Kind: FloatValue, Raw: tok.value}
+	case tokenString, tokenBlockString:
+		p.next()
+		return &Value{Kind: StringValue, Raw: tok.value}
+	case tokenName:
+		p.next()
+		switch tok.value {
+		case "true", "false":
+			return &Value{Kind: BooleanValue, Raw: tok.value}
+		case "null":
+			return &Value{Kind: NullValue, Raw: tok.value}
+		}
+		return &Value{Kind: EnumValue, Raw: tok.value}
+	}
+	switch {
+	case p.peek("$") && !constant:
+		p.next()
+		return &Value{Kind: VariableValue, Raw: p.name()}
+	case p.skip("["):
+		v := &Value{Kind: ListValue}
+		for !p.skip("]") {
+			v.List = append(v.List, p.value(constant))
+		}
+		return v
+	case p.skip("{"):
+		v := &Value{Kind: ObjectValue}
+		for !p.skip("}") {
+			f := &Argument{Name: p.name()}
+			p.expect(":")
+			f.Value = p.value(constant)
+			v.Fields = append(v.Fields, f)
+		}
+		return v
+	}
+	p.fail("expected value, found %s", p.describe())
+	return nil
+}
+
+func (p *parser) typeRef() *Type {
+	var t *Type
+	if p.skip("[") {
+		t = &Type{Elem: p.typeRef()}
+		p.expect("]")
+	} else {
+		t = &Type{Name: p.name()}
+	}
+	t.NonNull = p.skip("!")
+	return t
+}
+
+func (p *parser) schema(s *SchemaDefinition) *SchemaDefinition {
+	p.expect("schema")
+	p.directives()
+	if s == nil {
+		s = &SchemaDefinition{}
+	}
+	if !p.skip("{") {
+		return s
+	}
+	for !p.skip("}") {
+		op := p.name()
+		p.expect(":")
+		name := p.name()
+		switch op {
+		case "query":
+			s.Query = name
+		case "mutation":
+			s.Mutation = name
+		case "subscription":
+			s.Subscription = name
+		default:
+			p.fail("unknown operation type %q", op)
+		}
+	}
+	return s
+}
+
+func (p *parser) directiveDefinition() *DirectiveDefinition {
+	p.expect("directive")
+	p.expect("@")
+	d := &DirectiveDefinition{Name: p.name()}
+	d.Arguments = p.inputValueDefinitions("(", ")")
+	d.Repeatable = p.skip("repeatable")
+	p.expect("on")
+	p.skip("|")
+	d.Locations = append(d.Locations, p.name())
+	for p.skip("|") {
+		d.Locations = append(d.Locations, p.name())
+	}
+	return d
+}
+
+// kinds maps the keywords of type definitions to type kinds.
+var kinds = map[string]string{
+	"scalar":    "SCALAR",
+	"type":      "OBJECT",
+	"interface": "INTERFACE",
+	"union":     "UNION",
+	"enum":      "ENUM",
+	"input":     "INPUT_OBJECT",
+}
+
+func (p *parser) typeDefinition() *TypeDefinition {
+	kind, ok := kinds[p.tok.value]
+	if p.tok.kind != tokenName || !ok {
+		p.fail("expected definition, found %s", p.describe())
+	}
+	p.next()
+	t := &TypeDefinition{Kind: kind, Name: p.name()}
+	switch kind {
+	case "OBJECT", "INTERFACE":
+		if p.skip("implements") {
+			p.skip("&")
+			t.Interfaces = append(t.Interfaces, p.name())
+			for p.skip("&") {
+				t.Interfaces = append(t.Interfaces, p.name())
+			}
+		}
+		t.Directives = p.directives()
+		if p.skip("{") {
+			for !p.skip("}") {
+				t.Fields = append(t.Fields, p.fieldDefinition())
+			}
+		}
+	case "UNION":
+		t.Directives = p.directives()
+		if p.skip("=") {
+			p.skip("|")
+			t.Types = append(t.Types, p.name())
+			for p.skip("|") {
+				t.Types = append(t.Types, p.name())
+			}
+		}
+	case "ENUM":
+		t.Directives = p.directives()
+		if p.skip("{") {
+			for !p.skip("}") {
+				v := &EnumValueDefinition{Description: p.description()}
+				v.Name = p.name()
+				v.Directives = p.directives()
+				t.EnumValues = append(t.EnumValues, v)
+			}
+		}
+	case "INPUT_OBJECT":
+		t.Directives = p.directives()
+		t.InputFields = p.inputValueDefinitions("{", "}")
+	default:
+		t.Directives = p.directives()
+	}
+	return t
+}
+
+func (p *parser) fieldDefinition() *FieldDefinition {
+	f := &FieldDefinition{Description: p.description()}
+	f.Name = p.name()
+	f.Arguments = p.inputValueDefinitions("(", ")")
+	p.expect(":")
+	f.Type = p.typeRef()
+	f.Directives = p.directives()
+	return f
+}
+
+// inputValueDefinitions parses input value definitions enclosed in
+// the punctuators open and close, if present.
+func (p *parser) inputValueDefinitions(open, close string) []*InputValueDefinition {
+	if !p.skip(open) {
+		return nil
+	}
+	var defs []*InputValueDefinition
+	for !p.skip(close) {
+		v := &InputValueDefinition{Description: p.description()}
+		v.Name = p.name()
+		p.expect(":")
+		v.Type = p.typeRef()
+		if p.skip("=") {
+			v.DefaultValue = p.value(true)
+		}
+		v.Directives = p.directives()
+		defs = append(defs, v)
+	}
+	return defs
+}
@@ -0,0 +1,215 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/nobody05/graphql_go_client/internal/parser"
+)
+
+func TestParse_operation(t *testing.T) {
+	doc, err := parser.Parse(`
+		# Comment.
+		query GetRepo($owner: String!, $first: Int = 10) @cached {
+			repo: repository(owner: $owner, name: "graphql") {
+				issues(first: $first, states: [OPEN], orderBy: {field: CREATED_AT}) {
+					nodes { ...IssueFields }
+				}
+				... on Node @include(if: true) { id }
+			}
+		}
+		fragment IssueFields on Issue { title }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(doc.Operations), 1; got != want {
+		t.Fatalf("got operations: %v, want: %v", got, want)
+	}
+	op := doc.Operations[0]
+	if op.Type != "query" || op.Name != "GetRepo" {
+		t.Errorf("got operation: %s %s, want: query GetRepo", op.Type, op.Name)
+	}
+	if got, want := op.Variables[0].Type.String(), "String!"; got != want {
+		t.Errorf("got type of $owner: %q, want: %q", got, want)
+	}
+	if got, want := op.Variables[1].DefaultValue.String(), "10"; got != want {
+		t.Errorf("got default of $first: %q, want: %q", got, want)
+	}
+	repo := op.SelectionSet[0].(*parser.Field)
+	if repo.Alias != "repo" || repo.Name != "repository" || repo.ResponseKey() != "repo" {
+		t.Errorf("got field: %s: %s, want: repo: repository", repo.Alias, repo.Name)
+	}
+	issues := repo.SelectionSet[0].(*parser.Field)
+	var args string
+	for _, a := range issues.Arguments {
+		args += a.Name + ":" + a.Value.String() + " "
+	}
+	if want := "first:$first states:[OPEN] orderBy:{field:CREATED_AT} "; args != want {
+		t.Errorf("got arguments: %q, want: %q", args, want)
+	}
+	spread := issues.SelectionSet[0].(*parser.Field).SelectionSet[0].(*parser.FragmentSpread)
+	if got, want := spread.Name, "IssueFields"; got != want {
+		t.Errorf("got spread: %q, want: %q", got, want)
+	}
+	inline := repo.SelectionSet[1].(*parser.InlineFragment)
+	if inline.TypeCondition != "Node" || inline.Directives[0].Name != "include" {
+		t.Errorf("got inline fragment on %q, want: on Node @include", inline.TypeCondition)
+	}
+	if f := doc.Fragment("IssueFields"); f == nil || f.TypeCondition != "Issue" {
+		t.Errorf("got fragment IssueFields: %+v, want: on Issue", f)
+	}
+}
+
+func TestParse_schema(t *testing.T) {
+	doc, err := parser.Parse(`
+		schema { query: Root }
+		"""
+		  The root.
+		"""
+		type Root implements Node & Named {
+			"Look up a user."
+			user(id: ID!, active: Boolean = true): User @deprecated(reason: "Use node.")
+			tags: [String!]!
+		}
+		extend type Root { extra: Int }
+		union SearchResult = | User | Repo
+		enum State { OPEN CLOSED @deprecated }
+		input Filter { state: State = OPEN, limit: Int }
+		scalar DateTime
+		directive @cached(ttl: Int) repeatable on QUERY | FIELD
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := doc.Schema.Query, "Root"; got != want {
+		t.Errorf("got query type: %q, want: %q", got, want)
+	}
+	root := doc.Types[0]
+	if root.Kind != "OBJECT" || root.Description != "The root." || len(root.Interfaces) != 2 {
+		t.Errorf("got type: %s %q implementing %v, want: OBJECT \"The root.\" implementing Node & Named", root.Kind, root.Description, root.Interfaces)
+	}
+	user := root.Fields[0]
+	if user.Description != "Look up a user." || user.Arguments[1].DefaultValue.String() != "true" || user.Directives[0].Argument("reason").Value.Raw != "Use node." {
+		t.Errorf("got field user: %+v", user)
+	}
+	if got, want := root.Fields[1].Type.String(), "[String!]!"; got != want {
+		t.Errorf("got type of tags: %q, want: %q", got, want)
+	}
+	if !doc.Types[1].Extension {
+		t.Error("got extension: false, want: true")
+	}
+	if got, want := len(doc.Types[2].Types), 2; got != want {
+		t.Errorf("got union members: %v, want: %v", got, want)
+	}
+	if got, want := len(doc.Types[3].EnumValues[1].Directives), 1; got != want {
+		t.Errorf("got directives of CLOSED: %v, want: %v", got, want)
+	}
+	if got, want := doc.Types[4].InputFields[0].DefaultValue.String(), "OPEN"; got != want {
+		t.Errorf("got default of state: %q, want: %q", got, want)
+	}
+	if d := doc.Directives[0]; !d.Repeatable || len(d.Locations) != 2 {
+		t.Errorf("got directive: %+v, want: repeatable on QUERY | FIELD", d)
+	}
+}
+
+func TestParse_error(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"query {", "1:8: expected name, found end of input"},
+		{"{\n  a(x: $y)\n  b(x: 1.)\n}", "3:8: invalid number"},
+		{"type T { f: Int = 1 }", "1:17: expected name, found \"=\""},
+		{"{ a }}", "1:6: expected definition, found \"}\""},
+		{`type T { f(a: Int = $x): Int }`, "1:21: expected value, found \"$\""},
+	}
+	for _, tc := range tests {
+		_, err := parser.Parse(tc.in)
+		if err == nil {
+			t.Errorf("%q: got error: nil, want: %q", tc.in, tc.want)
+			continue
+		}
+		if got := err.Error(); got != tc.want {
+			t.Errorf("%q: got error: %q, want: %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestMinify(t *testing.T) {
+	got, err := parser.Minify(`
+		# Comment.
+		query GetRepo($owner: String!, $first: Int = 10) @cached {
+			repository(owner: $owner, name: "graphql, the   repo") {
+				issues(first: $first, labels: ["", "b"]) { nodes { ...IssueFields, body } }
+			}
+		}
+		fragment IssueFields on Issue { title }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `query GetRepo($owner:String!$first:Int=10)@cached{repository(owner:$owner name:"graphql, the   repo"){issues(first:$first labels:["" "b"]){nodes{...IssueFields body}}}}fragment IssueFields on Issue{title}`; got != want {
+		t.Errorf("\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	got, err := parser.Format(`# Comment.
+query GetRepo($owner:String!$first:Int=10)@cached{repository(owner:$owner name:"graphql, the   repo"){issues(first:$first labels:["" "b"] orderBy:{field:CREATED_AT direction:DESC}){nodes{...IssueFields body ... on Issue @include(if:true){id}}}}}fragment IssueFields on Issue{title}{viewer{login}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `query GetRepo($owner: String!, $first: Int = 10) @cached {
+  repository(owner: $owner, name: "graphql, the   repo") {
+    issues(first: $first, labels: ["", "b"], orderBy: {field: CREATED_AT, direction: DESC}) {
+      nodes {
+        ...IssueFields
+        body
+        ... on Issue @include(if: true) {
+          id
+        }
+      }
+    }
+  }
+}
+
+fragment IssueFields on Issue {
+  title
+}
+
+{
+  viewer {
+    login
+  }
+}`
+	if got != want {
+		t.Errorf("\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFormat_typeSystem(t *testing.T) {
+	got, err := parser.Format(`scalar Date "A user."
+type User implements Node&Actor{id:ID! "The login." login:String! friends(first:Int=10):[User!]! @deprecated(reason:"no")} union Result=User|Issue enum State{OPEN CLOSED}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `scalar Date
+
+"A user."
+type User implements Node & Actor {
+  id: ID!
+  "The login."
+  login: String!
+  friends(first: Int = 10): [User!]! @deprecated(reason: "no")
+}
+
+union Result = User | Issue
+
+enum State {
+  OPEN
+  CLOSED
+}`
+	if got != want {
+		t.Errorf("\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
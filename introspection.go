@@ -0,0 +1,158 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Schema is a GraphQL schema, as described by introspection.
+//
+// Specification: https://spec.graphql.org/October2021/#sec-Schema-Introspection.
+type Schema struct {
+	QueryType        *TypeRef    `json:"queryType"`
+	MutationType     *TypeRef    `json:"mutationType"`
+	SubscriptionType *TypeRef    `json:"subscriptionType"`
+	Types            []Type      `json:"types"`
+	Directives       []Directive `json:"directives"`
+}
+
+// Type returns the named type with the given name, or nil if there's none.
+func (s *Schema) Type(name string) *Type {
+	for i := range s.Types {
+		if s.Types[i].Name == name {
+			return &s.Types[i]
+		}
+	}
+	return nil
+}
+
+// TypeKind is the kind of a type, such as TypeKindObject.
+type TypeKind string
+
+// Kinds of types.
+const (
+	TypeKindScalar      TypeKind = "SCALAR"
+	TypeKindObject      TypeKind = "OBJECT"
+	TypeKindInterface   TypeKind = "INTERFACE"
+	TypeKindUnion       TypeKind = "UNION"
+	TypeKindEnum        TypeKind = "ENUM"
+	TypeKindInputObject TypeKind = "INPUT_OBJECT"
+	TypeKindList        TypeKind = "LIST"
+	TypeKindNonNull     TypeKind = "NON_NULL"
+)
+
+// Type is a named type of a schema. Only the fields relevant to its kind
+// are set: Fields and Interfaces for objects and interfaces, PossibleTypes
+// for interfaces and unions, EnumValues for enums, and InputFields for
+// input objects.
+type Type struct {
+	Kind          TypeKind     `json:"kind"`
+	Name          string       `json:"name"`
+	Description   string       `json:"description"`
+	Fields        []Field      `json:"fields"`
+	InputFields   []InputValue `json:"inputFields"`
+	Interfaces    []TypeRef    `json:"interfaces"`
+	EnumValues    []EnumValue  `json:"enumValues"`
+	PossibleTypes []TypeRef    `json:"possibleTypes"`
+}
+
+// Field returns the field of t with the given name, or nil if there's none.
+func (t *Type) Field(name string) *Field {
+	for i := range t.Fields {
+		if t.Fields[i].Name == name {
+			return &t.Fields[i]
+		}
+	}
+	return nil
+}
+
+// Field is a field of an object or interface type.
+type Field struct {
+	Name              string       `json:"name"`
+	Description       string       `json:"description"`
+	Args              []InputValue `json:"args"`
+	Type              TypeRef      `json:"type"`
+	IsDeprecated      bool         `json:"isDeprecated"`
+	DeprecationReason string       `json:"deprecationReason"`
+}
+
+// InputValue is an argument of a field or directive,
+// or a field of an input object type.
+type InputValue struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Type        TypeRef `json:"type"`
+	// DefaultValue is the default value in GraphQL syntax, if any.
+	DefaultValue *string `json:"defaultValue"`
+}
+
+// EnumValue is a value of an enum type.
+type EnumValue struct {
+	Name              string `json:"name"`
+	Description       string `json:"description"`
+	IsDeprecated      bool   `json:"isDeprecated"`
+	DeprecationReason string `json:"deprecationReason"`
+}
+
+// Directive is a directive supported by a schema.
+type Directive struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Locations   []string     `json:"locations"`
+	Args        []InputValue `json:"args"`
+}
+
+// TypeRef is a reference to a type: a named type,
+// or a list or non-null type wrapping another.
+type TypeRef struct {
+	Kind   TypeKind `json:"kind"`
+	Name   string   `json:"name"`
+	OfType *TypeRef `json:"ofType"`
+}
+
+// NamedType returns the name of the named type that r refers to,
+// unwrapping lists and non-null types.
+func (r TypeRef) NamedType() string {
+	for r.OfType != nil && (r.Kind == TypeKindList || r.Kind == TypeKindNonNull) {
+		r = *r.OfType
+	}
+	return r.Name
+}
+
+// String returns r in GraphQL syntax, such as "[String!]!".
+func (r TypeRef) String() string {
+	switch {
+	case r.Kind == TypeKindNonNull && r.OfType != nil:
+		return r.OfType.String() + "!"
+	case r.Kind == TypeKindList && r.OfType != nil:
+		return "[" + r.OfType.String() + "]"
+	}
+	return r.Name
+}
+
+// introspectionQuery is the standard introspection query, which unwraps
+// type references up to seven levels deep, such as [[String!]!]!.
+const introspectionQuery = `query IntrospectionQuery{__schema{queryType{name}mutationType{name}subscriptionType{name}types{...FullType}directives{name description locations args{...InputValue}}}}` +
+	`fragment FullType on __Type{kind name description fields(includeDeprecated:true){name description args{...InputValue}type{...TypeRef}isDeprecated deprecationReason}inputFields{...InputValue}interfaces{...TypeRef}enumValues(includeDeprecated:true){name description isDeprecated deprecationReason}possibleTypes{...TypeRef}}` +
+	`fragment InputValue on __InputValue{name description type{...TypeRef}defaultValue}` +
+	`fragment TypeRef on __Type{kind name ofType{kind name ofType{kind name ofType{kind name ofType{kind name ofType{kind name ofType{kind name ofType{kind name}}}}}}}}`
+
+// Introspect runs the standard introspection query against the server
+// and returns its schema. Servers may disable introspection in production.
+func (c *Client) Introspect(ctx context.Context, opts ...RequestOption) (*Schema, error) {
+	o := newRequestOptions(append([]RequestOption{WithOperationName("IntrospectionQuery")}, opts...))
+	var data struct {
+		Schema *Schema `json:"__schema"`
+	}
+	err := c.execute(ctx, queryOperation, introspectionQuery, nil, o, func(b json.RawMessage) error {
+		return json.Unmarshal(b, &data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if data.Schema == nil {
+		return nil, fmt.Errorf("graphql: introspection response has no schema")
+	}
+	return data.Schema, nil
+}
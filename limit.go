@@ -0,0 +1,36 @@
+package graphql
+
+import (
+	"io"
+	"net/http"
+)
+
+// limitBody makes reading the body of resp fail with a *ResponseTooLargeError
+// once more than limit bytes are read from it.
+func limitBody(resp *http.Response, limit int64) {
+	resp.Body = &limitedBody{ReadCloser: resp.Body, limit: limit, remaining: limit}
+}
+
+// limitedBody is a response body that may not exceed a size limit.
+type limitedBody struct {
+	io.ReadCloser
+	limit     int64
+	remaining int64 // Bytes left until the limit, or -1 if it's exceeded.
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, &ResponseTooLargeError{Limit: b.limit}
+	}
+	if int64(len(p)) > b.remaining+1 {
+		// Read one byte past the limit, to tell if there are more.
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n, b.remaining = int(b.remaining), -1
+		return n, &ResponseTooLargeError{Limit: b.limit}
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
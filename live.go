@@ -0,0 +1,19 @@
+package graphql
+
+import "context"
+
+// LiveQuery starts a live query, with a query derived from q marked by the
+// @live directive, for servers implementing live queries, such as with the
+// useLiveQuery plugin of Envelop. The server delivers the result of the query,
+// and then the whole result again whenever it changes, until ctx is done.
+//
+// Live queries are long-lived operations, so they're sent over the
+// subscription transport, and their results are delivered on the returned
+// channel like the events of Subscribe, which describes the channel and the
+// options configuring the transport. Each result can be decoded via
+// SubscriptionMessage.Decode into q.
+func (c *Client) LiveQuery(ctx context.Context, q interface{}, variables map[string]interface{}, opts ...RequestOption) (<-chan SubscriptionMessage, error) {
+	o := newRequestOptions(opts)
+	o.directives = append([]appliedDirective{{name: "live"}}, o.directives...)
+	return c.subscribe(ctx, queryOperation, q, variables, o)
+}
@@ -0,0 +1,253 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// BatchFunc looks up the values of keys, returning them in the same order,
+// for a Loader.
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) ([]V, error)
+
+// LoaderConfig configures a Loader.
+type LoaderConfig struct {
+	// Wait is how long to wait for more keys after the first key of
+	// a batch is requested, before looking the batch up.
+	// Defaults to 1 millisecond.
+	Wait time.Duration
+
+	// MaxBatch, if positive, is the maximum number of keys in a batch.
+	// A batch that reaches it is looked up right away.
+	MaxBatch int
+
+	// NoCache disables caching values by key, so that each Load looks
+	// its key up, although still in a batch.
+	NoCache bool
+}
+
+// Loader batches the lookups of values by key made within a short window
+// of each other, such as while resolving the entities of a request in
+// a server, into a single call of a BatchFunc, typically making a single
+// GraphQL query. It caches the values looked up by key for its lifetime,
+// so it's typically created per request. Errors aren't cached.
+// It's safe for concurrent use.
+//
+// BatchAliases and BatchList return BatchFuncs for common query shapes.
+type Loader[K comparable, V any] struct {
+	batch  BatchFunc[K, V]
+	config LoaderConfig
+
+	mu      sync.Mutex
+	cache   map[K]*loaderResult[V] // Results, by key.
+	pending *loaderBatch[K, V]     // Batch being collected, or nil.
+}
+
+// loaderResult is the result of looking up a key,
+// available once done is closed.
+type loaderResult[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// loaderBatch is a set of keys to look up together.
+type loaderBatch[K comparable, V any] struct {
+	ctx     context.Context // Context of the first Load of the batch.
+	keys    []K
+	results []*loaderResult[V]
+	timer   *time.Timer
+}
+
+// NewLoader returns a Loader looking up values via batch,
+// as configured by config.
+func NewLoader[K comparable, V any](batch BatchFunc[K, V], config LoaderConfig) *Loader[K, V] {
+	if config.Wait <= 0 {
+		config.Wait = time.Millisecond
+	}
+	return &Loader[K, V]{
+		batch:  batch,
+		config: config,
+		cache:  make(map[K]*loaderResult[V]),
+	}
+}
+
+// Load returns the value of key, looking it up in a batch
+// unless it's cached.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	r := l.result(ctx, key)
+	select {
+	case <-r.done:
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// LoadMany returns the values of keys, looking those that aren't cached
+// up in a batch. It returns the first error among those of the keys.
+func (l *Loader[K, V]) LoadMany(ctx context.Context, keys []K) ([]V, error) {
+	results := make([]*loaderResult[V], len(keys))
+	for i, key := range keys {
+		results[i] = l.result(ctx, key)
+	}
+	values := make([]V, len(keys))
+	for i, r := range results {
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		values[i] = r.value
+	}
+	return values, nil
+}
+
+// Prime caches value as the value of key, unless one is cached already.
+func (l *Loader[K, V]) Prime(key K, value V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.cache[key]; !ok && !l.config.NoCache {
+		r := &loaderResult[V]{done: make(chan struct{}), value: value}
+		close(r.done)
+		l.cache[key] = r
+	}
+}
+
+// Clear removes the value of key from the cache,
+// so that it's looked up again by the next Load.
+func (l *Loader[K, V]) Clear(key K) {
+	l.mu.Lock()
+	delete(l.cache, key)
+	l.mu.Unlock()
+}
+
+// result returns the result of key, adding it to the pending batch
+// if it's neither cached nor pending.
+func (l *Loader[K, V]) result(ctx context.Context, key K) *loaderResult[V] {
+	l.mu.Lock()
+	if r, ok := l.cache[key]; ok {
+		l.mu.Unlock()
+		return r
+	}
+	r := &loaderResult[V]{done: make(chan struct{})}
+	if !l.config.NoCache {
+		l.cache[key] = r
+	}
+	b := l.pending
+	if b == nil {
+		b = &loaderBatch[K, V]{ctx: ctx}
+		l.pending = b
+		b.timer = time.AfterFunc(l.config.Wait, func() { l.flush(b) })
+	}
+	b.keys = append(b.keys, key)
+	b.results = append(b.results, r)
+	full := l.config.MaxBatch > 0 && len(b.keys) >= l.config.MaxBatch
+	l.mu.Unlock()
+	if full {
+		l.flush(b)
+	}
+	return r
+}
+
+// flush looks up the keys of b, unless it's been flushed already.
+func (l *Loader[K, V]) flush(b *loaderBatch[K, V]) {
+	l.mu.Lock()
+	if l.pending != b {
+		l.mu.Unlock()
+		return
+	}
+	l.pending = nil
+	l.mu.Unlock()
+	b.timer.Stop()
+
+	// The batch serves the Loads of many callers, so it isn't canceled
+	// along with the context of the first one.
+	ctx, cancel := withoutCancel(b.ctx)
+	defer cancel()
+	values, err := l.batch(ctx, b.keys)
+	if err == nil && len(values) != len(b.keys) {
+		err = fmt.Errorf("graphql: batch function returned %d values for %d keys", len(values), len(b.keys))
+	}
+	for i, r := range b.results {
+		if err != nil {
+			r.err = err
+		} else {
+			r.value = values[i]
+		}
+		close(r.done)
+	}
+	if err != nil {
+		// Let the keys be looked up again.
+		l.mu.Lock()
+		for i, key := range b.keys {
+			if l.cache[key] == b.results[i] {
+				delete(l.cache, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+// BatchAliases returns a BatchFunc looking keys up via c in a single query
+// selecting field once per key, under the aliases k0, k1 and so on, with the
+// key given as its argument arg, such as:
+//
+//	query($k0:ID!$k1:ID!){k0:user(id:$k0){login},k1:user(id:$k1){login}}
+//
+// The selection set is derived from V, as by Query. The GraphQL type of
+// the arguments is derived from K, like those of variables. Fields resolving
+// to null are returned as the zero value of V, so V is typically a pointer.
+func BatchAliases[K comparable, V any](c *Client, field, arg string) BatchFunc[K, V] {
+	return func(ctx context.Context, keys []K) ([]V, error) {
+		fields := make([]reflect.StructField, len(keys))
+		variables := make(map[string]interface{}, len(keys))
+		for i, key := range keys {
+			alias := fmt.Sprintf("k%d", i)
+			fields[i] = reflect.StructField{
+				Name: fmt.Sprintf("K%d", i),
+				Type: reflect.TypeOf((*V)(nil)).Elem(),
+				Tag:  reflect.StructTag(fmt.Sprintf(`graphql:"%s:%s(%s:$%s)"`, alias, field, arg, alias)),
+			}
+			variables[alias] = key
+		}
+		q := reflect.New(reflect.StructOf(fields))
+		if err := c.do(ctx, queryOperation, q.Interface(), variables, newRequestOptions(nil)); err != nil {
+			return nil, err
+		}
+		values := make([]V, len(keys))
+		for i := range values {
+			values[i] = q.Elem().Field(i).Interface().(V)
+		}
+		return values, nil
+	}
+}
+
+// BatchList returns a BatchFunc looking keys up via c in a single query
+// selecting field once, with all keys given as its list argument arg,
+// such as:
+//
+//	query($keys:[ID!]!){nodes(ids:$keys){login}}
+//
+// The field must return a list of values in the order of the keys, with
+// null for those that weren't found. The selection set is derived from V,
+// as by Query, and the GraphQL type of the argument from K.
+func BatchList[K comparable, V any](c *Client, field, arg string) BatchFunc[K, V] {
+	return func(ctx context.Context, keys []K) ([]V, error) {
+		q := reflect.New(reflect.StructOf([]reflect.StructField{{
+			Name: "Values",
+			Type: reflect.TypeOf([]V(nil)),
+			Tag:  reflect.StructTag(fmt.Sprintf(`graphql:"%s(%s:$keys)"`, field, arg)),
+		}}))
+		if err := c.do(ctx, queryOperation, q.Interface(), map[string]interface{}{"keys": keys}, newRequestOptions(nil)); err != nil {
+			return nil, err
+		}
+		return q.Elem().Field(0).Interface().([]V), nil
+	}
+}
@@ -0,0 +1,141 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nobody05/graphql_go_client"
+)
+
+type loaderUser struct {
+	Login graphql.String `graphql:"login"`
+}
+
+func TestLoader_batchAliases(t *testing.T) {
+	var queries []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		var body struct {
+			Query     string
+			Variables map[string]string
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		queries = append(queries, body.Query)
+		var fields []string
+		for alias, id := range body.Variables {
+			if id == "missing" {
+				fields = append(fields, fmt.Sprintf(`"%s": null`, alias))
+				continue
+			}
+			fields = append(fields, fmt.Sprintf(`"%s": {"login": "user-%s"}`, alias, id))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {`+strings.Join(fields, ",")+`}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+	loader := graphql.NewLoader(graphql.BatchAliases[string, *loaderUser](client, "user", "id"), graphql.LoaderConfig{Wait: 50 * time.Millisecond})
+
+	var wg sync.WaitGroup
+	for _, id := range []string{"1", "2", "1", "missing"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			u, err := loader.Load(context.Background(), id)
+			switch {
+			case err != nil:
+				t.Error(err)
+			case id == "missing" && u != nil:
+				t.Errorf("got user %q: %+v, want: nil", id, u)
+			case id != "missing" && (u == nil || string(u.Login) != "user-"+id):
+				t.Errorf("got user %q: %+v, want: user-%s", id, u, id)
+			}
+		}(id)
+	}
+	wg.Wait()
+	if got, want := len(queries), 1; got != want {
+		t.Fatalf("got queries: %q, want: %v", queries, want)
+	}
+	if got, want := strings.Count(queries[0], ":user(id:"), 3; got != want {
+		t.Errorf("got query: %q, want %v aliased fields", queries[0], want)
+	}
+
+	// Cached values aren't looked up again.
+	users, err := loader.LoadMany(context.Background(), []string{"2", "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(users[0].Login)+","+string(users[1].Login), "user-2,user-3"; got != want {
+		t.Errorf("got logins: %q, want: %q", got, want)
+	}
+	if got, want := queries[1], `query($k0:ID!){k0:user(id:$k0){login}}`; got != want {
+		t.Errorf("got query: %q, want: %q", got, want)
+	}
+}
+
+func TestLoader_batchList(t *testing.T) {
+	var queries []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		var body struct {
+			Query     string
+			Variables struct{ Keys []string }
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		queries = append(queries, body.Query)
+		var nodes []string
+		for _, id := range body.Variables.Keys {
+			nodes = append(nodes, fmt.Sprintf(`{"login": "user-%s"}`, id))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"nodes": [`+strings.Join(nodes, ",")+`]}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+	loader := graphql.NewLoader(graphql.BatchList[string, loaderUser](client, "nodes", "ids"), graphql.LoaderConfig{MaxBatch: 2})
+
+	users, err := loader.LoadMany(context.Background(), []string{"1", "2", "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"user-1", "user-2", "user-3"} {
+		if got := string(users[i].Login); got != want {
+			t.Errorf("got login %d: %q, want: %q", i, got, want)
+		}
+	}
+	want := []string{`query($keys:[ID!]!){nodes(ids:$keys){login}}`, `query($keys:[ID!]!){nodes(ids:$keys){login}}`}
+	if strings.Join(queries, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got queries: %q, want: %q", queries, want)
+	}
+}
+
+func TestLoader_error(t *testing.T) {
+	calls := 0
+	loader := graphql.NewLoader(func(ctx context.Context, keys []int) ([]string, error) {
+		calls++
+		if calls == 1 {
+			return nil, fmt.Errorf("unavailable")
+		}
+		return []string{"one"}, nil
+	}, graphql.LoaderConfig{})
+
+	if _, err := loader.Load(context.Background(), 1); err == nil || err.Error() != "unavailable" {
+		t.Errorf("got error: %v, want: unavailable", err)
+	}
+	// Errors aren't cached.
+	v, err := loader.Load(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "one" {
+		t.Errorf("got value: %q, want: %q", v, "one")
+	}
+}
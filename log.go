@@ -0,0 +1,91 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Redactor returns the value to log in place of the variable or input
+// object field with the given name, as set by WithLogRedactor.
+// It returns value itself to log it as is.
+type Redactor func(name string, value interface{}) interface{}
+
+// RedactVariables returns a Redactor that hides the values of the
+// variables and input object fields with the given names,
+// such as "password" or "token".
+func RedactVariables(names ...string) Redactor {
+	redacted := make(map[string]bool, len(names))
+	for _, name := range names {
+		redacted[name] = true
+	}
+	return func(name string, value interface{}) interface{} {
+		if redacted[name] {
+			return "[REDACTED]"
+		}
+		return value
+	}
+}
+
+// logged calls do to execute req, logging the request and the outcome
+// to the client's logger, if any, at debug level.
+func (c *Client) logged(ctx context.Context, req *Request, do func(ctx context.Context, req *Request) (*Response, error)) (*Response, error) {
+	if c.logger == nil || !c.logger.Enabled(ctx, slog.LevelDebug) {
+		return do(ctx, req)
+	}
+	logger := c.logger.With(
+		slog.String("operation", req.OperationName),
+		slog.String("type", req.OperationType()),
+	)
+	logger.DebugContext(ctx, "graphql: sending request",
+		slog.String("query", req.Query),
+		slog.Any("variables", redact(req.Variables, c.redact)),
+	)
+	start := time.Now()
+	out, err := do(ctx, req)
+	duration := slog.Duration("duration", time.Since(start))
+	if err != nil {
+		attrs := []interface{}{duration, slog.Any("error", err)}
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			attrs = append(attrs, slog.Int("status", httpErr.StatusCode), slog.String("body", string(httpErr.Body)))
+		}
+		logger.DebugContext(ctx, "graphql: request failed", attrs...)
+		return out, err
+	}
+	attrs := []interface{}{duration}
+	if len(out.Errors) > 0 {
+		attrs = append(attrs, slog.Any("errors", out.Errors))
+	}
+	logger.DebugContext(ctx, "graphql: received response", attrs...)
+	return out, nil
+}
+
+// redact returns a copy of variables whose values, and the fields of
+// input objects within them, are replaced according to r.
+func redact(variables map[string]interface{}, r Redactor) map[string]interface{} {
+	if r == nil || variables == nil {
+		return variables
+	}
+	return redactValue(variables, r).(map[string]interface{})
+}
+
+func redactValue(v interface{}, r Redactor) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for name, value := range v {
+			m[name] = redactValue(r(name, value), r)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, value := range v {
+			s[i] = redactValue(value, r)
+		}
+		return s
+	default:
+		return v
+	}
+}
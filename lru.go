@@ -0,0 +1,69 @@
+package graphql
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lru holds up to max values computed from keys, such as parsed
+// documents, evicting the least recently used ones to make room for new
+// ones. It's safe for concurrent use.
+type lru[K comparable, V any] struct {
+	max int
+
+	mu      sync.Mutex
+	entries map[K]*list.Element // Elements of order, by key.
+	order   *list.List          // Of *lruEntry[K, V], most recently used first.
+}
+
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// newLRU returns an empty lru holding up to max values.
+func newLRU[K comparable, V any](max int) *lru[K, V] {
+	return &lru[K, V]{max: max, entries: make(map[K]*list.Element), order: list.New()}
+}
+
+// get returns the value held for key, computing it with compute,
+// and holding it, if there's none. Values are computed without holding
+// the lock, so concurrent calls may compute the same value.
+func (c *lru[K, V]) get(key K, compute func(key K) V) V {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.order.MoveToFront(e)
+		c.mu.Unlock()
+		return e.Value.(*lruEntry[K, V]).value
+	}
+	c.mu.Unlock()
+	v := compute(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.order.MoveToFront(e)
+		return e.Value.(*lruEntry[K, V]).value
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: v})
+	if c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry[K, V]).key)
+	}
+	return v
+}
+
+// len returns the number of values held.
+func (c *lru[K, V]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// clear removes every value held.
+func (c *lru[K, V]) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+	c.order.Init()
+}
@@ -0,0 +1,47 @@
+package graphql
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	c := newLRU[string, string](2)
+	var computed int
+	compute := func(key string) string {
+		computed++
+		return "value of " + key
+	}
+	for _, key := range []string{"a", "b", "a", "c", "a", "b"} {
+		if got, want := c.get(key, compute), "value of "+key; got != want {
+			t.Errorf("got %q, want: %q", got, want)
+		}
+	}
+	// "b" was evicted by "c", being the least recently used.
+	if got, want := computed, 4; got != want {
+		t.Errorf("got %d values computed, want: %d", got, want)
+	}
+	if got, want := c.len(), 2; got != want {
+		t.Errorf("got %d values held, want: %d", got, want)
+	}
+	c.clear()
+	if got, want := c.len(), 0; got != want {
+		t.Errorf("got %d values held after clear, want: %d", got, want)
+	}
+}
+
+func TestCache_parse(t *testing.T) {
+	c := NewCache(time.Minute)
+	// Documents inlining argument values are each parsed once.
+	for i := 0; i < 2*maxCachedDocuments; i++ {
+		c.parse(fmt.Sprintf(`{user(id:%d){name}}`, i))
+	}
+	if got, want := c.docs.len(), maxCachedDocuments; got != want {
+		t.Errorf("got %d documents kept, want: %d", got, want)
+	}
+	c.Clear()
+	if got, want := c.docs.len(), 0; got != want {
+		t.Errorf("got %d documents kept after Clear, want: %d", got, want)
+	}
+}
@@ -0,0 +1,91 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// Manifest maps the IDs of persisted documents to the documents.
+// Servers that enforce an operation allowlist only execute the
+// documents of their manifest, and accept their IDs in place of them.
+//
+// A manifest is typically generated at build time, by a program that
+// adds each operation of the application with AddQuery or AddMutation
+// and saves the result with WriteFile, for the server to load.
+type Manifest map[string]string
+
+// ReadManifest reads a manifest from a JSON file mapping IDs to documents.
+func ReadManifest(filename string) (Manifest, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("graphql: reading manifest %s: %v", filename, err)
+	}
+	return m, nil
+}
+
+// WriteFile writes m as a JSON file mapping IDs to documents.
+func (m Manifest) WriteFile(filename string) error {
+	b, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append(b, '\n'), 0644)
+}
+
+// AddQuery adds the document of the query q, as made by NamedQuery
+// with the given name and variables, and returns its ID.
+// Only the types of variables matter, so their values may be zero.
+// The name may be empty for documents made by Query.
+func (m Manifest) AddQuery(name string, q interface{}, variables map[string]interface{}) (string, error) {
+	return m.add(queryOperation, name, q, variables)
+}
+
+// AddMutation adds the document of the mutation m, as made by NamedMutate
+// with the given name and variables, and returns its ID.
+// The name may be empty for documents made by Mutate.
+func (m Manifest) AddMutation(name string, mutation interface{}, variables map[string]interface{}) (string, error) {
+	return m.add(mutationOperation, name, mutation, variables)
+}
+
+func (m Manifest) add(op operationType, name string, v interface{}, variables map[string]interface{}) (string, error) {
+	if err := checkQuery(v, variables); err != nil {
+		return "", err
+	}
+	doc := constructOperation(op, name, v, variables)
+	id := DocumentHash(doc)
+	m[id] = doc
+	return id, nil
+}
+
+// PersistedDocuments returns middleware that sends the ID of each document
+// in manifest instead of the document itself, producing request bodies
+// such as {"id": "...", "variables": {...}}. Requests for documents missing
+// from manifest fail without being sent, since an allowlisting server
+// would reject them anyway.
+func PersistedDocuments(manifest Manifest) Middleware {
+	ids := make(map[string]string, len(manifest))
+	for id, doc := range manifest {
+		ids[doc] = id
+	}
+	return func(next Doer) Doer {
+		return DoerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+			if req.Query == "" {
+				return next.Do(ctx, req)
+			}
+			id, ok := ids[req.Query]
+			if !ok {
+				return nil, fmt.Errorf("graphql: document not in persisted document manifest: %s", req.Query)
+			}
+			persisted := *req
+			persisted.Query = ""
+			persisted.DocumentID = id
+			return next.Do(ctx, &persisted)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package graphql_test
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nobody05/graphql_go_client"
+)
+
+func TestPersistedDocuments(t *testing.T) {
+	type user struct {
+		User struct {
+			Name graphql.String
+		} `graphql:"user(id:$id)"`
+	}
+	m := graphql.Manifest{}
+	id, err := m.AddQuery("GetUser", user{}, map[string]interface{}{"id": graphql.ID("")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m[id], `query GetUser($id:ID!){user(id:$id){name}}`; got != want {
+		t.Errorf("got document %q, want %q", got, want)
+	}
+	filename := filepath.Join(t.TempDir(), "manifest.json")
+	if err := m.WriteFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	m, err = graphql.ReadManifest(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"variables":{"id":"1"},"operationName":"GetUser","id":"`+id+`"}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"name": "Gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+	client.Use(graphql.PersistedDocuments(m))
+
+	var q user
+	if err := client.NamedQuery(context.Background(), "GetUser", &q, map[string]interface{}{"id": graphql.ID("1")}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.User.Name, graphql.String("Gopher"); got != want {
+		t.Errorf("got q.User.Name: %q, want: %q", got, want)
+	}
+
+	err = client.NamedQuery(context.Background(), "GetUserByName", &q, map[string]interface{}{"id": graphql.ID("1")})
+	if err == nil || !strings.Contains(err.Error(), "not in persisted document manifest") {
+		t.Errorf("got error %v, want document not in manifest", err)
+	}
+}
+
+func TestMinifyDocument(t *testing.T) {
+	a, err := graphql.MinifyDocument("query GetViewer {\n\tviewer {\n\t\tlogin, # The username.\n\t\tname\n\t}\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `query GetViewer{viewer{login name}}`; a != want {
+		t.Errorf("got document: %q, want: %q", a, want)
+	}
+	b, err := graphql.MinifyDocument(`query GetViewer { viewer { login name } }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := graphql.DocumentHash(b), graphql.DocumentHash(a); got != want {
+		t.Errorf("got hash: %s, want: %s", got, want)
+	}
+	if got, want := graphql.DocumentHash("{viewer{login}}"), "b8a89e512adc64b05b90c6da293f5ce75404d0faf1307339c299dc4a8a3842f3"; got != want {
+		t.Errorf("got hash: %s, want: %s", got, want)
+	}
+	if _, err := graphql.MinifyDocument(`{viewer{login ~}}`); err == nil {
+		t.Error("got no error for invalid document")
+	}
+}
+
+func TestFormatDocument(t *testing.T) {
+	var q struct {
+		Repository struct {
+			Issues struct {
+				Nodes []struct {
+					Title string `graphql:"title"`
+				} `graphql:"nodes"`
+			} `graphql:"issues(first:$first,states:[OPEN])"`
+		} `graphql:"repository(owner:$owner,name:$name)"`
+	}
+	doc, err := graphql.ConstructQuery(&q, map[string]interface{}{
+		"owner": graphql.String("shurcooL"),
+		"name":  graphql.String("graphql"),
+		"first": graphql.Int(10),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := graphql.FormatDocument(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `query ($first: Int!, $name: String!, $owner: String!) {
+  repository(owner: $owner, name: $name) {
+    issues(first: $first, states: [OPEN]) {
+      nodes {
+        title
+      }
+    }
+  }
+}`
+	if got != want {
+		t.Errorf("got document:\n%s\nwant:\n%s", got, want)
+	}
+	if _, err := graphql.FormatDocument(`{viewer{login ~}}`); err == nil {
+		t.Error("got no error for invalid document")
+	}
+}
@@ -0,0 +1,88 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// MetricsCollector receives metrics about the operations executed by a
+// client, as set by WithMetrics, so they can be exported to a monitoring
+// system. See package promgraphql for a Prometheus implementation.
+//
+// Methods may be called concurrently.
+type MetricsCollector interface {
+	// ObserveOperation is called after each operation has received
+	// a response, or failed to.
+	ObserveOperation(m OperationMetrics)
+}
+
+// Outcome is the outcome of an operation, as reported in OperationMetrics.
+type Outcome string
+
+const (
+	// OutcomeSuccess is a response without GraphQL errors.
+	OutcomeSuccess Outcome = "success"
+
+	// OutcomeGraphQLError is a response with GraphQL errors,
+	// which may also contain partial data.
+	OutcomeGraphQLError Outcome = "graphql_error"
+
+	// OutcomeError is a failure to get a response, such as
+	// a network error or an *HTTPError.
+	OutcomeError Outcome = "error"
+)
+
+// OperationMetrics describes a single operation executed by a client.
+type OperationMetrics struct {
+	Name     string        // Operation name, if any.
+	Type     string        // Operation type, such as "query", as reported by Request.OperationType.
+	Outcome  Outcome       // Outcome of the operation.
+	Duration time.Duration // Time taken to get the response, including retries.
+
+	RequestBytes  int // Size of the JSON-encoded request.
+	ResponseBytes int // Size of the data of the response, or 0 if there was none.
+	Retries       int // Number of HTTP requests retried according to the client's retry policy.
+}
+
+// retriesKey is the context key for the retry counter of an operation.
+type retriesKey struct{}
+
+// countRetry increments the retry counter in ctx, if any.
+func countRetry(ctx context.Context) {
+	if n, ok := ctx.Value(retriesKey{}).(*int); ok {
+		*n++
+	}
+}
+
+// observe calls do to execute req and reports metrics about it to the
+// client's metrics collector, if any.
+func (c *Client) observe(ctx context.Context, req *Request, do func(ctx context.Context) (*Response, error)) (*Response, error) {
+	if c.metrics == nil {
+		return do(ctx)
+	}
+	m := OperationMetrics{
+		Name: req.OperationName,
+		Type: req.OperationType(),
+	}
+	if b, err := json.Marshal(req); err == nil {
+		m.RequestBytes = len(b)
+	}
+	ctx = context.WithValue(ctx, retriesKey{}, &m.Retries)
+	start := time.Now()
+	out, err := do(ctx)
+	m.Duration = time.Since(start)
+	switch {
+	case err != nil:
+		m.Outcome = OutcomeError
+	case len(out.Errors) > 0:
+		m.Outcome = OutcomeGraphQLError
+	default:
+		m.Outcome = OutcomeSuccess
+	}
+	if err == nil {
+		m.ResponseBytes = len(out.Data)
+	}
+	c.metrics.ObserveOperation(m)
+	return out, err
+}
@@ -0,0 +1,61 @@
+package graphql
+
+import (
+	"context"
+)
+
+// Doer executes GraphQL requests.
+type Doer interface {
+	// Do executes req and returns the server's response. GraphQL errors are
+	// reported via the response; the returned error is for failures to get
+	// a response at all, such as network errors or an *HTTPError.
+	Do(ctx context.Context, req *Request) (*Response, error)
+}
+
+// DoerFunc is an adapter to allow the use of ordinary functions as a Doer.
+type DoerFunc func(ctx context.Context, req *Request) (*Response, error)
+
+// Do calls f(ctx, req).
+func (f DoerFunc) Do(ctx context.Context, req *Request) (*Response, error) {
+	return f(ctx, req)
+}
+
+// Middleware wraps a Doer with additional behavior, such as logging,
+// metrics, or refreshing credentials. It may inspect and modify the
+// request, including its document, variables and headers, before
+// passing it to next, and inspect or replace the response.
+type Middleware func(next Doer) Doer
+
+// Use adds middleware to the client. Middleware added first is outermost,
+// seeing each request first and each response last.
+//
+// Use may be called concurrently with operations on the client, which use
+// the middleware added when they start, but it's best to add all middleware
+// right after calling NewClient.
+func (c *Client) Use(mw ...Middleware) {
+	c.mwMu.Lock()
+	defer c.mwMu.Unlock()
+	c.middleware = append(c.middleware, mw...)
+	c.buildDoer()
+}
+
+// buildDoer sets c.doer to the client's middleware chain
+// wrapped around its transport, coalescing identical concurrent
+// queries if WithSingleflight is set.
+func (c *Client) buildDoer() {
+	var t Transport = httpTransport{c: c}
+	switch {
+	case c.transport != nil:
+		t = c.transport
+	case c.batcher != nil:
+		t = c.batcher
+	}
+	var d Doer = DoerFunc(t.Execute)
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		d = c.middleware[i](d)
+	}
+	if c.singleflight {
+		d = &singleflight{next: d, flights: make(map[string]*flight)}
+	}
+	c.doer.Store(&d)
+}
@@ -0,0 +1,12 @@
+module github.com/nobody05/graphql_go_client/oauth2graphql
+
+go 1.21
+
+require (
+	github.com/nobody05/graphql_go_client v0.0.0-00010101000000-000000000000
+	golang.org/x/oauth2 v0.21.0
+)
+
+require golang.org/x/net v0.0.0-20220225172249-27dd8689420f // indirect
+
+replace github.com/nobody05/graphql_go_client => ../
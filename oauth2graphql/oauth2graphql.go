@@ -0,0 +1,72 @@
+// Package oauth2graphql provides token providers backed by OAuth 2.0,
+// via golang.org/x/oauth2, for authenticating the requests of GraphQL
+// clients of package graphql with graphql.BearerAuth:
+//
+//	client := graphql.NewClient(url)
+//	client.Use(graphql.BearerAuth(oauth2graphql.ClientCredentials(&clientcredentials.Config{
+//		ClientID:     clientID,
+//		ClientSecret: clientSecret,
+//		TokenURL:     "https://auth.example.com/oauth2/token",
+//	})))
+//
+// Unlike an *http.Client from oauth2.NewClient, the client then gets a new
+// token when the server rejects the current one, retrying the request.
+package oauth2graphql
+
+import (
+	"context"
+	"time"
+
+	graphql "github.com/nobody05/graphql_go_client"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// TokenSource returns a graphql.TokenProvider getting tokens from src.
+// Refreshing a rejected token calls src again, which gets a new token only
+// if src doesn't cache tokens until they expire, as the sources returned by
+// oauth2.ReuseTokenSource and oauth2.Config.TokenSource do. Config and
+// ClientCredentials refresh tokens regardless.
+func TokenSource(src oauth2.TokenSource) graphql.TokenProvider {
+	return graphql.NewTokenProvider(func(ctx context.Context) (string, time.Time, error) {
+		return token(src.Token())
+	})
+}
+
+// Config returns a graphql.TokenProvider starting with tok, and getting new
+// tokens with its refresh token from the token endpoint of config.
+func Config(config *oauth2.Config, tok *oauth2.Token) graphql.TokenProvider {
+	first, refreshToken := true, tok.RefreshToken
+	return graphql.NewTokenProvider(func(ctx context.Context) (string, time.Time, error) {
+		if first {
+			first = false
+			if tok.Valid() {
+				return token(tok, nil)
+			}
+		}
+		// A token with only a refresh token makes the source get a new one.
+		t, err := config.TokenSource(ctx, &oauth2.Token{RefreshToken: refreshToken}).Token()
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		refreshToken = t.RefreshToken
+		return token(t, nil)
+	})
+}
+
+// ClientCredentials returns a graphql.TokenProvider getting tokens from the
+// token endpoint of config, using the client credentials flow, as suited
+// for authenticating services rather than users.
+func ClientCredentials(config *clientcredentials.Config) graphql.TokenProvider {
+	return graphql.NewTokenProvider(func(ctx context.Context) (string, time.Time, error) {
+		return token(config.Token(ctx))
+	})
+}
+
+// token returns the access token and expiry of t.
+func token(t *oauth2.Token, err error) (string, time.Time, error) {
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return t.AccessToken, t.Expiry, nil
+}
@@ -0,0 +1,70 @@
+package oauth2graphql_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	graphql "github.com/nobody05/graphql_go_client"
+	"github.com/nobody05/graphql_go_client/oauth2graphql"
+	"golang.org/x/oauth2"
+)
+
+func TestConfig(t *testing.T) {
+	var refreshes int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if got, want := req.FormValue("refresh_token"), "refresh"; got != want {
+			t.Errorf("got refresh token: %q, want: %q", got, want)
+		}
+		n := atomic.AddInt32(&refreshes, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "Bearer", "expires_in": 3600}`, n)
+	}))
+	defer tokenServer.Close()
+
+	// The server rejects the initial token, revoked in the meantime.
+	var authorizations []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		authorizations = append(authorizations, req.Header.Get("Authorization"))
+		if req.Header.Get("Authorization") == "Bearer revoked" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"data": {"viewer": {"login": "gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+	config := &oauth2.Config{Endpoint: oauth2.Endpoint{TokenURL: tokenServer.URL, AuthStyle: oauth2.AuthStyleInParams}}
+	client.Use(graphql.BearerAuth(oauth2graphql.Config(config, &oauth2.Token{AccessToken: "revoked", RefreshToken: "refresh"})))
+
+	var q struct {
+		Viewer struct {
+			Login string `graphql:"login"`
+		} `graphql:"viewer"`
+	}
+	for i := 0; i < 2; i++ {
+		if err := client.NamedQuery(context.Background(), "GetViewer", &q, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := fmt.Sprint(authorizations), "[Bearer revoked Bearer token-1 Bearer token-1]"; got != want {
+		t.Errorf("got authorizations: %v, want: %v", got, want)
+	}
+	if got, want := atomic.LoadInt32(&refreshes), int32(1); got != want {
+		t.Errorf("got refreshes: %v, want: %v", got, want)
+	}
+}
+
+type localRoundTripper struct {
+	handler http.Handler
+}
+
+func (l localRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := httptest.NewRecorder()
+	l.handler.ServeHTTP(w, req)
+	return w.Result(), nil
+}
@@ -0,0 +1,81 @@
+package graphql
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Optional is a value of a variable or an input object field that can be
+// omitted or explicitly null, as well as set. The distinction matters for
+// update mutations, where null typically clears a field, while omitting
+// it leaves the field as is.
+//
+// The zero value of Optional is omitted. Variables of type Optional[T]
+// are declared with the nullable GraphQL type of T.
+type Optional[T any] struct {
+	value T
+	state optionalState
+}
+
+// optionalState is the state of an Optional.
+type optionalState uint8
+
+const (
+	optionalOmitted optionalState = iota
+	optionalNull
+	optionalSet
+)
+
+// NewOptional returns an Optional set to v.
+func NewOptional[T any](v T) Optional[T] {
+	return Optional[T]{value: v, state: optionalSet}
+}
+
+// Null returns an Optional that's explicitly null.
+func Null[T any]() Optional[T] {
+	return Optional[T]{state: optionalNull}
+}
+
+// Get returns the value of o, and whether it's set.
+func (o Optional[T]) Get() (T, bool) {
+	return o.value, o.state == optionalSet
+}
+
+// IsNull reports whether o is explicitly null.
+func (o Optional[T]) IsNull() bool { return o.state == optionalNull }
+
+// IsOmitted reports whether o is omitted.
+func (o Optional[T]) IsOmitted() bool { return o.state == optionalOmitted }
+
+// MarshalJSON encodes o as its value, or as null if it isn't set.
+// Omitting o is up to the map or input object containing it.
+func (o Optional[T]) MarshalJSON() ([]byte, error) {
+	if o.state != optionalSet {
+		return []byte("null"), nil
+	}
+	return json.Marshal(o.value)
+}
+
+func (o Optional[T]) optionalValue() (interface{}, optionalState) { return o.value, o.state }
+
+func (Optional[T]) optionalType() reflect.Type { return reflect.TypeOf((*T)(nil)).Elem() }
+
+// optional is implemented by Optional of any type.
+type optional interface {
+	optionalValue() (interface{}, optionalState)
+	optionalType() reflect.Type
+}
+
+var optionalType = reflect.TypeOf((*optional)(nil)).Elem()
+
+// isOmitted reports whether v is an omitted Optional.
+func isOmitted(v reflect.Value) bool {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if !v.IsValid() || !v.Type().Implements(optionalType) {
+		return false
+	}
+	_, state := v.Interface().(optional).optionalValue()
+	return state == optionalOmitted
+}
@@ -0,0 +1,625 @@
+package graphql
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ClientOption configures a Client created by NewClient.
+// Options take effect only when passed to NewClient: the configuration
+// of a Client is fixed once it's created, which is what makes it safe
+// for concurrent use. Options copy the maps and slices given to them,
+// so callers may reuse those afterwards.
+type ClientOption func(*Client)
+
+// WithHTTPClient sets the HTTP client used to send requests.
+// If httpClient is nil, then http.DefaultClient is used.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		if httpClient == nil {
+			httpClient = http.DefaultClient
+		}
+		c.httpClient = httpClient
+	}
+}
+
+// WithDefaultHeaders sets HTTP headers to send with every request.
+// Headers given per request via WithHeader take precedence.
+func WithDefaultHeaders(header http.Header) ClientOption {
+	return func(c *Client) {
+		if c.header == nil {
+			c.header = make(http.Header)
+		}
+		for k, v := range header {
+			c.header[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+		}
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(userAgent string) ClientOption {
+	return WithDefaultHeaders(http.Header{"User-Agent": {userAgent}})
+}
+
+// WithRequestModifier makes the client call modify on each HTTP request
+// just before sending it, after its headers are set, so that anything about
+// it can be changed, such as its cookies, trace headers or host. Requests
+// retried or hedged are modified again. If modify returns an error, the
+// request isn't sent, and the error is returned. Modifiers given by several
+// options are called in order.
+//
+// Subscriptions via WebSocket aren't affected.
+func WithRequestModifier(modify func(*http.Request) error) ClientOption {
+	return func(c *Client) {
+		c.modifiers = append(c.modifiers, modify)
+	}
+}
+
+// WithTimeout sets a time limit for each operation, covering the whole
+// exchange with the server. It is applied on top of any deadline
+// already present in the context passed to Query or Mutate.
+func WithTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithConnectTimeout sets a time limit for establishing each connection
+// to the server, so that an unreachable server is detected quickly,
+// while operations may still take as long as their timeout allows.
+//
+// It requires the HTTP client's transport to be an *http.Transport
+// (or nil, for http.DefaultTransport), whose dialer it replaces,
+// and is ignored otherwise.
+// The HTTP client given via WithHTTPClient is left unmodified.
+func WithConnectTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.connectTimeout = d
+	}
+}
+
+// WithConnectionPool tunes the pool of connections to the server,
+// and whether HTTP/2 is used, as configured by p.
+//
+// Like WithConnectTimeout, it requires the HTTP client's transport to be
+// an *http.Transport (or nil, for http.DefaultTransport), and is ignored
+// otherwise. The HTTP client given via WithHTTPClient is left unmodified.
+func WithConnectionPool(p ConnectionPool) ClientOption {
+	return func(c *Client) {
+		c.pool = &p
+	}
+}
+
+// WithProxy makes the client send HTTP requests via the proxy at proxyURL,
+// such as "http://proxy.internal:3128", rather than the one configured by
+// the environment, as by http.ProxyFromEnvironment. If proxyURL is nil,
+// requests are sent directly to the server.
+//
+// Like WithConnectTimeout, it requires the HTTP client's transport to be
+// an *http.Transport, and is ignored otherwise. It doesn't apply to
+// WebSocket subscription connections.
+func WithProxy(proxyURL *url.URL) ClientOption {
+	return func(c *Client) {
+		c.proxy = http.ProxyURL(proxyURL)
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to connect to the server,
+// such as to trust the certificate authority of an internal gateway via
+// RootCAs. config is copied, so it may be modified afterwards.
+//
+// Like WithConnectTimeout, it requires the HTTP client's transport to be
+// an *http.Transport, and is ignored otherwise. It also applies to
+// WebSocket subscription connections.
+func WithTLSConfig(config *tls.Config) ClientOption {
+	config = config.Clone()
+	return func(c *Client) {
+		c.tlsConfig = config
+	}
+}
+
+// WithClientCertificate makes the client authenticate to servers requiring
+// mutual TLS with the certificate and private key in the PEM files certFile
+// and keyFile. The files are read on each TLS handshake, so that renewed
+// certificates are picked up without recreating the client, and failing
+// to read them fails the handshake.
+//
+// Like WithConnectTimeout, it requires the HTTP client's transport to be
+// an *http.Transport, and is ignored otherwise. It also applies to
+// WebSocket subscription connections.
+func WithClientCertificate(certFile, keyFile string) ClientOption {
+	return func(c *Client) {
+		c.clientCert = loadClientCertificate(certFile, keyFile)
+	}
+}
+
+// WithErrorPolicy sets how GraphQL errors in responses are handled.
+// The default is ErrorPolicyAll.
+func WithErrorPolicy(p ErrorPolicy) ClientOption {
+	return func(c *Client) {
+		c.errorPolicy = p
+	}
+}
+
+// WithRetry makes the client retry requests that fail with a network error
+// or a retryable HTTP status code, as configured by policy.
+func WithRetry(policy RetryPolicy) ClientOption {
+	policy.RetryableStatusCodes = append([]int(nil), policy.RetryableStatusCodes...)
+	if policy.Budget != nil {
+		budget := *policy.Budget
+		policy.Budget = &budget
+	}
+	return func(c *Client) {
+		p := policy
+		if p.Budget != nil {
+			p.budget = &retryBudget{config: *p.Budget}
+		}
+		c.retry = &p
+	}
+}
+
+// WithLoadBalancing makes the client spread its requests over the
+// replicas of a GraphQL server, as configured by lb, rather than send them
+// to the URL the client was created with. That URL is still used for
+// subscriptions, unless WithSubscriptionURL is set. Combined with WithRetry,
+// requests failing on an endpoint that's down are retried on another one.
+func WithLoadBalancing(lb LoadBalancer) ClientOption {
+	lb.Endpoints = append([]Endpoint(nil), lb.Endpoints...)
+	return func(c *Client) {
+		c.balancer = newBalancer(c, lb)
+	}
+}
+
+// WithCircuitBreaker makes the client fail requests to endpoints that keep
+// failing right away, with ErrCircuitOpen, until they recover, as configured
+// by cb. Each endpoint has its own circuit breaker.
+func WithCircuitBreaker(cb CircuitBreaker) ClientOption {
+	return func(c *Client) {
+		c.circuits = &circuits{config: cb}
+	}
+}
+
+// WithRateLimit makes the client wait for l before sending each HTTP
+// request, such as to respect the limits on requests per second of an API.
+// Retried and hedged requests are limited too, while batched operations
+// count as one request. See NewRateLimiter.
+func WithRateLimit(l RateLimiter) ClientOption {
+	return func(c *Client) {
+		c.limiter = l
+	}
+}
+
+// WithHedging makes the client hedge queries, sending them again if they
+// aren't answered quickly enough, as configured by policy. Each attempt
+// allowed by WithRetry is hedged.
+func WithHedging(policy HedgePolicy) ClientOption {
+	return func(c *Client) {
+		c.hedge = &policy
+	}
+}
+
+// WithMetrics makes the client report metrics about each operation,
+// such as its outcome, latency and payload sizes, to m.
+func WithMetrics(m MetricsCollector) ClientOption {
+	return func(c *Client) {
+		c.metrics = m
+	}
+}
+
+// WithLogger makes the client log each operation at debug level to
+// logger: the document and variables sent, the time taken to respond,
+// and any errors, including the bodies of non-200 OK responses.
+// Headers aren't logged. Use WithLogRedactor to hide secrets
+// among the variables.
+func WithLogger(logger *slog.Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// WithLogRedactor sets r to replace the values of variables, and of the
+// fields of input objects among them, in the logs written via WithLogger.
+// The variables sent to the server are unaffected.
+func WithLogRedactor(r Redactor) ClientOption {
+	return func(c *Client) {
+		c.redact = r
+	}
+}
+
+// WithSchema makes the client validate the documents of operations against
+// schema before sending them, as by Schema.Validate, so that mistakes in
+// structs are reported as ValidationErrors without hitting the server.
+// The schema can be obtained via Introspect or ParseSchema. Results are
+// cached per document.
+//
+// The first time a document selecting fields marked @deprecated is
+// validated, a warning with the deprecation reason of each is written
+// to the logger set by WithLogger, if any.
+func WithSchema(schema *Schema) ClientOption {
+	return func(c *Client) {
+		c.validation = &validationCache{schema: schema}
+	}
+}
+
+// WithCache makes the client cache the results of queries in cache,
+// serving queries from it according to their cache policy, which is
+// CacheFirst unless set by WithCachePolicy. The entities in the results
+// of mutations are cached too. Operations using WithStreamedList or
+// WithIncrementalDelivery bypass the cache.
+func WithCache(cache *Cache) ClientOption {
+	return func(c *Client) {
+		c.cache = cache
+	}
+}
+
+// WithSkipUnknownFields sets whether fields of response data that have
+// no corresponding struct field are skipped when decoding, as by Mutate
+// and SubscriptionMessage.Decode. By default, they're an error, which
+// catches drift between structs and the schema early; skipping them
+// tolerates fields added to evolving schemas, such as through fragments
+// or directives the structs don't know about.
+func WithSkipUnknownFields(skip bool) ClientOption {
+	return func(c *Client) {
+		c.decoding.SkipUnknownFields = skip
+	}
+}
+
+// WithCaseSensitiveFields sets whether fields of response data are only
+// decoded into struct fields named exactly like them, by their graphql
+// or json tag, or as queries are built. By default, fields that don't
+// match any exactly are matched ignoring case, to the Go names and json
+// tags of struct fields lacking a name in their graphql tag; requiring
+// exact names suits schemas with fields that differ only by case.
+func WithCaseSensitiveFields(sensitive bool) ClientOption {
+	return func(c *Client) {
+		c.decoding.CaseSensitive = sensitive
+	}
+}
+
+// WithUseNumber sets whether numbers in response data decoded into
+// interface{} values, such as those of maps, JSON scalars, and the data
+// returned by Query, are decoded as json.Number rather than float64, which
+// can't represent 64-bit IDs or amounts of money exactly. Struct fields of
+// type big.Int or big.Float, or pointers to them, hold numbers of any size
+// regardless, decoding strings holding numbers as well.
+func WithUseNumber(use bool) ClientOption {
+	return func(c *Client) {
+		c.decoding.UseNumber = use
+	}
+}
+
+// WithMaxResponseBytes limits the size of response bodies to n bytes,
+// after decompression, protecting the client from malicious or accidental
+// giant responses. Operations with larger responses fail with
+// a *ResponseTooLargeError. Subscriptions aren't limited.
+func WithMaxResponseBytes(n int64) ClientOption {
+	return func(c *Client) {
+		c.maxResponseBytes = n
+	}
+}
+
+// WithGET makes the client send queries as HTTP GET requests, with the
+// document, variables and operation name encoded as URL query parameters,
+// so that CDNs and other HTTP caches can cache responses. Mutations are
+// always sent via POST, as are requests with file uploads.
+//
+// Combined with AutomaticPersistedQueries, this keeps URLs short.
+func WithGET(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.useGET = enabled
+	}
+}
+
+// WithRequestCompression makes the client compress request bodies
+// of at least minSize bytes (all of them, if minSize is 0) with gzip,
+// sending them with the header Content-Encoding: gzip. This helps with
+// large bulk mutations, but the server must support compressed requests.
+//
+// Responses are always requested with Accept-Encoding: gzip
+// and decompressed transparently.
+func WithRequestCompression(minSize int) ClientOption {
+	return func(c *Client) {
+		if minSize <= 0 {
+			minSize = 1
+		}
+		c.gzipMinSize = minSize
+	}
+}
+
+// WithBatching makes the client coalesce requests made within a short
+// window of each other into batches, as configured by b.
+// It applies to the default transport.
+func WithBatching(b Batcher) ClientOption {
+	return func(c *Client) {
+		if b.Window <= 0 {
+			b.Window = 10 * time.Millisecond
+		}
+		c.batcher = &batchTransport{c: c, b: b, pending: make(map[string]*batch)}
+	}
+}
+
+// WithSingleflight makes the client coalesce identical queries, with the
+// same document, variables, operation name and headers, made while one
+// of them is in flight into that one request, sharing its result among
+// the callers. This keeps bursts of identical queries, as in servers
+// fanning out requests, from hitting the GraphQL server all at once.
+// Mutations are never coalesced.
+func WithSingleflight() ClientOption {
+	return func(c *Client) {
+		c.singleflight = true
+	}
+}
+
+// WithTransport sets the transport used to send requests,
+// replacing the default HTTP transport. Options that configure
+// HTTP behavior, such as WithHTTPClient, WithRetry and WithBatching,
+// only apply to the default transport.
+func WithTransport(t Transport) ClientOption {
+	return func(c *Client) {
+		c.transport = t
+	}
+}
+
+// WithSubscriptionURL sets the URL used by Subscribe.
+// By default, WebSocket protocols use the client URL with the scheme
+// changed from http to ws, or from https to wss, and GraphQLSSE uses
+// the client URL as is.
+func WithSubscriptionURL(url string) ClientOption {
+	return func(c *Client) {
+		c.subscriptionEndpoint = url
+	}
+}
+
+// WithSubscriptionProtocol sets the WebSocket protocol used by Subscribe.
+// The default is GraphQLTransportWS.
+func WithSubscriptionProtocol(p SubscriptionProtocol) ClientOption {
+	return func(c *Client) {
+		c.subscriptionProtocol = p
+	}
+}
+
+// WithConnectionParams sets the payload of the connection_init message
+// sent when opening a subscription connection. Servers commonly expect
+// authentication tokens here, since browsers can't set WebSocket headers.
+func WithConnectionParams(params map[string]interface{}) ClientOption {
+	return func(c *Client) {
+		if params == nil {
+			c.connectionParams = nil
+			return
+		}
+		c.connectionParams = make(map[string]interface{}, len(params))
+		for k, v := range params {
+			c.connectionParams[k] = v
+		}
+	}
+}
+
+// WithSubscriptionAuthorizer makes the client authorize WebSocket
+// subscription connections and the subscriptions on them via a.
+func WithSubscriptionAuthorizer(a SubscriptionAuthorizer) ClientOption {
+	return func(c *Client) {
+		c.subscriptionAuthorizer = a
+	}
+}
+
+// WithSubscriptionKeepAlive makes subscription connections send a ping
+// to the server every interval d, so that idle connections aren't dropped
+// by proxies. Pings from the server are always answered.
+func WithSubscriptionKeepAlive(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.keepAlive = d
+	}
+}
+
+// WithSubscriptionReconnect makes WebSocket subscriptions whose connection
+// fails, such as when the server restarts, start again on a new connection,
+// after a pause as configured by policy, rather than end with an error.
+// Subscriptions sharing the failed connection share the new one too.
+// Events sent while disconnected are lost, unless the server can resume
+// the subscription given variables set by ReconnectPolicy.Resume.
+func WithSubscriptionReconnect(policy ReconnectPolicy) ClientOption {
+	return func(c *Client) {
+		c.reconnect = &policy
+	}
+}
+
+// WithSubscriptionBuffer makes WebSocket subscriptions buffer up to size
+// events their subscriber hasn't received yet, and handle events arriving
+// while the buffer is full per policy. By default, subscriptions don't buffer
+// events, and block, so that a slow subscriber stalls its connection, rather
+// than grow the memory of the process. Other policies don't stall it, and
+// need a buffer of at least one event, which is used if size is smaller.
+//
+// Subscriptions using the GraphQLSSE protocol have a connection of their own,
+// so they always block.
+func WithSubscriptionBuffer(size int, policy SlowConsumerPolicy) ClientOption {
+	return func(c *Client) {
+		switch {
+		case policy != BlockSlowConsumer && size < 1:
+			size = 1
+		case size < 0:
+			size = 0
+		}
+		c.subscriptionBuffer = size
+		c.slowConsumer = policy
+	}
+}
+
+// RequestOption configures a single GraphQL request made by Query or Mutate.
+type RequestOption func(*requestOptions)
+
+// requestOptions holds the per-request configuration
+// collected from RequestOption values.
+type requestOptions struct {
+	header        http.Header   // Additional HTTP headers to send.
+	operationName string        // GraphQL operation name, or empty for an anonymous operation.
+	response      *Response     // If non-nil, where to store the decoded response.
+	timeout       time.Duration // Time limit for the operation, or 0 for the client's.
+	fragments     []Fragment    // Named fragments that the operation may spread.
+	cachePolicy   CachePolicy   // How the query uses the client's cache, if any.
+
+	directives []appliedDirective // Directives applied to the operation and its fields.
+	arguments  []fieldArguments   // Arguments given to fields.
+
+	responseHeader *http.Header // If non-nil, where to store the HTTP headers of the response.
+
+	idempotent     *bool  // Whether the operation is safe to retry, or nil to tell by its type.
+	idempotencyKey string // Idempotency key of the operation, or empty for the client's.
+
+	documentID string                 // ID of the document, sent in place of it, if set by Client.Do.
+	extensions map[string]interface{} // Protocol extensions, if set by Client.Do.
+
+	dump func(request, response []byte) // Called with each HTTP exchange, if set.
+
+	incremental func(path []interface{}) error // Called as results of @defer and @stream are delivered.
+
+	listPath string                                          // Path to the list to stream, if listFn is set.
+	listFn   func(decode func(item interface{}) error) error // Handler for each item of the streamed list.
+}
+
+// newRequestOptions applies opts in order and returns the result.
+func newRequestOptions(opts []RequestOption) *requestOptions {
+	o := &requestOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithHeader adds an HTTP header to send with the request,
+// such as an Authorization token that differs between calls.
+// It may be given multiple times; values for the same key accumulate.
+func WithHeader(key, value string) RequestOption {
+	return func(o *requestOptions) {
+		if o.header == nil {
+			o.header = make(http.Header)
+		}
+		o.header.Add(key, value)
+	}
+}
+
+// WithOperationName sets the GraphQL operation name of the request,
+// for any of Query, Mutate, NamedQuery and NamedMutate.
+// It is emitted in the document (e.g., "mutation AddStar(...)")
+// and sent as operationName in the request body.
+func WithOperationName(name string) RequestOption {
+	return func(o *requestOptions) {
+		o.operationName = name
+	}
+}
+
+// WithResponse stores the decoded response of the request into resp,
+// giving access to its raw data, errors, and extensions.
+// resp is populated whenever the server's response could be decoded,
+// even if the operation returns an error due to GraphQL errors.
+func WithResponse(resp *Response) RequestOption {
+	return func(o *requestOptions) {
+		o.response = resp
+	}
+}
+
+// WithResponseHeaders stores the HTTP headers of the response to the request
+// into header, such as to read the rate limits, request ID or cache metadata
+// reported by the server. Unlike WithResponse, header is populated even for
+// responses with a non-200 OK status code, as with 429 Too Many Requests.
+// It's left unchanged if no response was received over HTTP.
+func WithResponseHeaders(header *http.Header) RequestOption {
+	return func(o *requestOptions) {
+		o.responseHeader = header
+	}
+}
+
+// WithIdempotent marks the operation as idempotent, or not, which
+// determines whether the client's retry policy retries it, and whether it's
+// hedged. By default, queries are idempotent and mutations aren't, as a
+// mutation that failed may still have been applied by the server. Mark
+// mutations that the server deduplicates, such as by idempotency key, as
+// idempotent, and queries with side effects as not.
+func WithIdempotent(idempotent bool) RequestOption {
+	return func(o *requestOptions) {
+		o.idempotent = &idempotent
+	}
+}
+
+// WithRequestTimeout sets a time limit for the operation, overriding the
+// client's WithTimeout. Like it, the limit covers the whole operation,
+// including retries, and is layered on top of any deadline already present
+// in the context.
+func WithRequestTimeout(d time.Duration) RequestOption {
+	return func(o *requestOptions) {
+		o.timeout = d
+	}
+}
+
+// WithCachePolicy sets how the query uses the cache set by WithCache.
+// It defaults to CacheFirst.
+func WithCachePolicy(p CachePolicy) RequestOption {
+	return func(o *requestOptions) {
+		o.cachePolicy = p
+	}
+}
+
+// WithDump calls fn with each HTTP request sent for the operation and the
+// response received, headers and bodies included, in wire format as by
+// httputil.DumpRequestOut and httputil.DumpResponse, so that issues can be
+// reproduced with server operators. response is nil if none was received.
+// With retries, fn is called once per attempt.
+//
+// Dumps include credentials, such as Authorization headers.
+// Dumping reads whole response bodies into memory, so it's meant for
+// debugging only. It doesn't apply to requests sent in batches.
+func WithDump(fn func(request, response []byte)) RequestOption {
+	return func(o *requestOptions) {
+		o.dump = fn
+	}
+}
+
+// WithFragments makes the named fragments available to the operation.
+// The definitions of those that it spreads, directly or through
+// one another, are appended to its document.
+// It may be given multiple times; fragments accumulate.
+func WithFragments(fragments ...Fragment) RequestOption {
+	return func(o *requestOptions) {
+		o.fragments = append(o.fragments, fragments...)
+	}
+}
+
+// WithStreamedList decodes the list at path of the response data
+// incrementally, calling fn for each of its items in turn instead of
+// storing them, to bound memory use with very large lists, such as bulk
+// exports. fn decodes the item into a value of its choice via decode,
+// and may return an error to abort the operation.
+//
+// path consists of response keys separated by dots, such as
+// "repository.issues.nodes". The list is left empty in the data populated
+// into the query. The HTTP transport hands items over as they're read off
+// the response body; with other transports, they're handed over once the
+// whole response is read.
+func WithStreamedList(path string, fn func(decode func(item interface{}) error) error) RequestOption {
+	return func(o *requestOptions) {
+		o.listPath = path
+		o.listFn = fn
+	}
+}
+
+// WithIncrementalDelivery requests incremental delivery of the results of
+// an operation using the @defer or @stream directives, as a multipart
+// response. The initial result is populated into the query right away,
+// and the patches of subsequent results are merged into it by path as they
+// arrive. fn is called after the initial result and after each patch, with
+// the path of the latest patch, in the format of Error.Path, or nil for the
+// initial result. It may inspect the query populated so far. fn runs on the
+// calling goroutine, before the operation returns, and may return an error
+// to abort it.
+//
+// If the server doesn't support incremental delivery, it sends the complete
+// result at once, and fn isn't called. Only the HTTP transport supports it,
+// and not for GET requests, batching, or file uploads.
+func WithIncrementalDelivery(fn func(path []interface{}) error) RequestOption {
+	return func(o *requestOptions) {
+		o.incremental = fn
+	}
+}
@@ -0,0 +1,19 @@
+module github.com/nobody05/graphql_go_client/otelgraphql
+
+go 1.21
+
+require (
+	github.com/nobody05/graphql_go_client v0.0.0-00010101000000-000000000000
+	go.opentelemetry.io/otel v1.14.0
+	go.opentelemetry.io/otel/sdk v1.14.0
+	go.opentelemetry.io/otel/trace v1.14.0
+)
+
+require (
+	github.com/go-logr/logr v1.2.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	golang.org/x/net v0.0.0-20220225172249-27dd8689420f // indirect
+	golang.org/x/sys v0.5.0 // indirect
+)
+
+replace github.com/nobody05/graphql_go_client => ../
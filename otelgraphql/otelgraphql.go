@@ -0,0 +1,145 @@
+// Package otelgraphql provides OpenTelemetry tracing for GraphQL clients
+// of package graphql.
+//
+// Add it to a client as middleware:
+//
+//	client.Use(otelgraphql.Middleware())
+//
+// Each operation is then recorded as a client span named after its type
+// and operation name, such as "query GetUser", and the trace context is
+// propagated to the server via the request headers.
+package otelgraphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	graphql "github.com/nobody05/graphql_go_client"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the tracer of this package.
+const instrumentationName = "github.com/nobody05/graphql_go_client/otelgraphql"
+
+// Attribute keys recorded on spans.
+const (
+	OperationNameKey  = attribute.Key("graphql.operation.name")
+	OperationTypeKey  = attribute.Key("graphql.operation.type")
+	DocumentHashKey   = attribute.Key("graphql.document.sha256")
+	VariablesSizeKey  = attribute.Key("graphql.variables.size")
+	ErrorsCountKey    = attribute.Key("graphql.errors.count")
+	HTTPStatusCodeKey = attribute.Key("http.status_code")
+)
+
+// Option configures the middleware.
+type Option func(*config)
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	propagators    propagation.TextMapPropagator
+}
+
+// WithTracerProvider sets the tracer provider to create spans with.
+// The default is the global one, otel.GetTracerProvider().
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithPropagators sets the propagators used to inject the trace context
+// into request headers. The default is the global one,
+// otel.GetTextMapPropagator().
+func WithPropagators(p propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagators = p
+	}
+}
+
+// Middleware returns client middleware that records a span for each
+// operation, with attributes for its name, type, document hash, size of
+// variables, number of GraphQL errors and HTTP status code.
+//
+// The span's status is set to error if no response could be obtained,
+// or if the response contains GraphQL errors.
+func Middleware(opts ...Option) graphql.Middleware {
+	c := config{
+		tracerProvider: otel.GetTracerProvider(),
+		propagators:    otel.GetTextMapPropagator(),
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	tracer := c.tracerProvider.Tracer(instrumentationName)
+	return func(next graphql.Doer) graphql.Doer {
+		return graphql.DoerFunc(func(ctx context.Context, req *graphql.Request) (*graphql.Response, error) {
+			ctx, span := tracer.Start(ctx, spanName(req),
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(requestAttributes(req)...))
+			defer span.End()
+
+			req.Header = req.Header.Clone()
+			if req.Header == nil {
+				req.Header = make(http.Header)
+			}
+			c.propagators.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+			resp, err := next.Do(ctx, req)
+			if err != nil {
+				var httpErr *graphql.HTTPError
+				if errors.As(err, &httpErr) {
+					span.SetAttributes(HTTPStatusCodeKey.Int(httpErr.StatusCode))
+				}
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				return resp, err
+			}
+			if resp.StatusCode != 0 {
+				span.SetAttributes(HTTPStatusCodeKey.Int(resp.StatusCode))
+			}
+			span.SetAttributes(ErrorsCountKey.Int(len(resp.Errors)))
+			if len(resp.Errors) > 0 {
+				span.SetStatus(codes.Error, resp.Errors.Error())
+			}
+			return resp, nil
+		})
+	}
+}
+
+// spanName returns the name of the span for req, such as "query GetUser".
+func spanName(req *graphql.Request) string {
+	name := req.OperationType()
+	if name == "" {
+		name = "operation"
+	}
+	if req.OperationName != "" {
+		name += " " + req.OperationName
+	}
+	return name
+}
+
+// requestAttributes returns the span attributes describing req.
+func requestAttributes(req *graphql.Request) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if req.OperationName != "" {
+		attrs = append(attrs, OperationNameKey.String(req.OperationName))
+	}
+	if t := req.OperationType(); t != "" {
+		attrs = append(attrs, OperationTypeKey.String(t))
+	}
+	if req.Query != "" {
+		attrs = append(attrs, DocumentHashKey.String(graphql.DocumentHash(req.Query)))
+	}
+	if len(req.Variables) > 0 {
+		if b, err := json.Marshal(req.Variables); err == nil {
+			attrs = append(attrs, VariablesSizeKey.Int(len(b)))
+		}
+	}
+	return attrs
+}
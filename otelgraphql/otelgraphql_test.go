@@ -0,0 +1,76 @@
+package otelgraphql_test
+
+import (
+	"context"
+	"testing"
+
+	graphql "github.com/nobody05/graphql_go_client"
+	"github.com/nobody05/graphql_go_client/otelgraphql"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	var traceparent string
+	client := graphql.NewClient("/graphql", graphql.WithTransport(graphql.TransportFunc(func(ctx context.Context, req *graphql.Request) (*graphql.Response, error) {
+		traceparent = req.Header.Get("Traceparent")
+		return &graphql.Response{
+			Data:       []byte(`{"viewer":{"login":"gopher"}}`),
+			Errors:     graphql.Errors{{Message: "field error"}},
+			StatusCode: 200,
+		}, nil
+	})))
+	client.Use(otelgraphql.Middleware(
+		otelgraphql.WithTracerProvider(tp),
+		otelgraphql.WithPropagators(propagation.TraceContext{}),
+	))
+
+	var q struct {
+		Viewer struct {
+			Login string
+		}
+	}
+	err := client.NamedQuery(context.Background(), "GetViewer", &q, map[string]interface{}{"first": 10})
+	if err == nil {
+		t.Fatal("got error: nil, want: non-nil")
+	}
+
+	spans := recorder.Ended()
+	if got, want := len(spans), 1; got != want {
+		t.Fatalf("got spans: %v, want: %v", got, want)
+	}
+	span := spans[0]
+	if got, want := span.Name(), "query GetViewer"; got != want {
+		t.Errorf("got span name: %q, want: %q", got, want)
+	}
+	if got, want := span.Status().Code, codes.Error; got != want {
+		t.Errorf("got span status: %v, want: %v", got, want)
+	}
+	if got, want := traceparent[3:35], span.SpanContext().TraceID().String(); got != want {
+		t.Errorf("got propagated trace ID: %q, want: %q", got, want)
+	}
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	for key, want := range map[attribute.Key]interface{}{
+		otelgraphql.OperationNameKey:  "GetViewer",
+		otelgraphql.OperationTypeKey:  "query",
+		otelgraphql.VariablesSizeKey:  int64(len(`{"first":10}`)),
+		otelgraphql.ErrorsCountKey:    int64(1),
+		otelgraphql.HTTPStatusCodeKey: int64(200),
+	} {
+		if got := attrs[key].AsInterface(); got != want {
+			t.Errorf("got %v: %v, want: %v", key, got, want)
+		}
+	}
+	if got := attrs[otelgraphql.DocumentHashKey].AsString(); len(got) != 64 {
+		t.Errorf("got %v: %q, want: hex SHA-256", otelgraphql.DocumentHashKey, got)
+	}
+}
@@ -0,0 +1,328 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OutboxStore durably stores the queue of mutations of an Outbox, in order.
+// Implementations must be safe for concurrent use. NewDirStore returns one
+// storing them as files; others can keep them in embedded databases such
+// as bbolt or SQLite.
+type OutboxStore interface {
+	// Push adds entry to the back of the queue.
+	Push(ctx context.Context, entry []byte) error
+	// Peek returns the entry at the front of the queue,
+	// and whether there is one.
+	Peek(ctx context.Context) ([]byte, bool, error)
+	// Pop removes the entry at the front of the queue, as returned by Peek.
+	Pop(ctx context.Context) error
+}
+
+// OutboxEntry is a mutation queued in an Outbox.
+type OutboxEntry struct {
+	Query          string                 `json:"query"`
+	Variables      map[string]interface{} `json:"variables,omitempty"`
+	OperationName  string                 `json:"operationName,omitempty"`
+	IdempotencyKey string                 `json:"idempotencyKey"`
+	Enqueued       time.Time              `json:"enqueued"`
+}
+
+// OutboxPolicy configures an Outbox created by NewOutbox.
+// The zero value of each field selects a reasonable default.
+type OutboxPolicy struct {
+	// MinBackoff is how long delivery is paused after the first failure
+	// to send a mutation, such as while the server is unreachable.
+	// Defaults to 1 second.
+	MinBackoff time.Duration
+
+	// MaxBackoff caps the pause, which doubles with each failure in a row.
+	// Defaults to 1 minute.
+	MaxBackoff time.Duration
+
+	// OnDelivered, if non-nil, is called with each mutation
+	// the server executed, along with its response.
+	OnDelivered func(entry *OutboxEntry, resp *Response)
+
+	// OnRejected, if non-nil, is called with each mutation the server
+	// rejected, such as with GraphQL errors or a 4xx status code, along
+	// with the error. Such mutations wouldn't succeed if sent again,
+	// so they're removed from the queue for later ones to be delivered.
+	OnRejected func(entry *OutboxEntry, err error)
+}
+
+// Outbox queues mutations in a durable store, and delivers them to the
+// server in order, for CLI tools and edge agents whose connectivity is
+// intermittent, and which may be restarted before mutations are delivered.
+//
+// Each mutation is sent with an idempotency key, as by WithIdempotencyKey,
+// so that servers deduplicating mutations by key apply it once, even if
+// it's sent again after a crash. A mutation failing with a network error,
+// a 429 or a 5xx status code is sent again after a pause, before any later
+// one. Mutations are otherwise removed from the queue once sent.
+type Outbox struct {
+	client *Client
+	store  OutboxStore
+	policy OutboxPolicy
+
+	flushMu sync.Mutex    // Held while delivering mutations, so they're sent in order.
+	pending chan struct{} // Signals Run that mutations were enqueued.
+}
+
+// NewOutbox returns an Outbox delivering mutations through c,
+// queued in store, as configured by policy.
+func NewOutbox(c *Client, store OutboxStore, policy OutboxPolicy) *Outbox {
+	return &Outbox{client: c, store: store, policy: policy, pending: make(chan struct{}, 1)}
+}
+
+// Enqueue adds a mutation derived from m and variables, as by Client.Mutate,
+// to the back of the queue. Only the operation name, field arguments,
+// directives, fragments and idempotency key given by opts are kept; headers
+// aren't, so that credentials aren't stored, and are set by the client as it
+// delivers the mutation instead. The response to the mutation is available
+// to OutboxPolicy.OnDelivered.
+func (o *Outbox) Enqueue(ctx context.Context, m interface{}, variables map[string]interface{}, opts ...RequestOption) error {
+	ro := newRequestOptions(opts)
+	query, err := operation(mutationOperation, ro.operationName, "", m, variables)
+	if err != nil {
+		return err
+	}
+	query, err = ro.document(query)
+	if err != nil {
+		return err
+	}
+	variables, err = marshalVariables(variables)
+	if err != nil {
+		return err
+	}
+	entry := OutboxEntry{
+		Query:          query,
+		Variables:      variables,
+		OperationName:  ro.operationName,
+		IdempotencyKey: ro.idempotencyKey,
+		Enqueued:       time.Now(),
+	}
+	if entry.IdempotencyKey == "" {
+		entry.IdempotencyKey = randomID()
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := o.store.Push(ctx, b); err != nil {
+		return fmt.Errorf("graphql: enqueuing mutation: %w", err)
+	}
+	select {
+	case o.pending <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Flush delivers the queued mutations in order, until the queue is empty,
+// returning nil, or until a mutation fails to be delivered, returning the
+// error, in which case the mutation remains at the front of the queue.
+func (o *Outbox) Flush(ctx context.Context) error {
+	o.flushMu.Lock()
+	defer o.flushMu.Unlock()
+	for {
+		b, ok, err := o.store.Peek(ctx)
+		if err != nil {
+			return fmt.Errorf("graphql: reading outbox: %w", err)
+		}
+		if !ok {
+			return nil
+		}
+		var entry OutboxEntry
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber() // Keep numbers as they were.
+		if err := dec.Decode(&entry); err != nil {
+			return fmt.Errorf("graphql: decoding outbox entry: %w", err)
+		}
+		resp, err := o.send(ctx, &entry)
+		switch {
+		case err == nil:
+			if o.policy.OnDelivered != nil {
+				o.policy.OnDelivered(&entry, resp)
+			}
+		case retryableDelivery(err) || ctx.Err() != nil:
+			return err
+		default:
+			if o.policy.OnRejected != nil {
+				o.policy.OnRejected(&entry, err)
+			}
+		}
+		if err := o.store.Pop(ctx); err != nil {
+			return fmt.Errorf("graphql: removing outbox entry: %w", err)
+		}
+	}
+}
+
+// send sends the mutation of entry.
+func (o *Outbox) send(ctx context.Context, entry *OutboxEntry) (*Response, error) {
+	var resp Response
+	ro := &requestOptions{
+		operationName:  entry.OperationName,
+		response:       &resp,
+		idempotencyKey: entry.IdempotencyKey,
+	}
+	err := o.client.execute(ctx, mutationOperation, entry.Query, entry.Variables, ro, func(json.RawMessage) error {
+		return nil
+	})
+	return &resp, err
+}
+
+// retryableDelivery reports whether a mutation that failed with err
+// may succeed if sent again later, as when the server was unreachable.
+func retryableDelivery(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= http.StatusInternalServerError
+	}
+	return errors.Is(err, ErrCircuitOpen) || isNetworkError(err)
+}
+
+// Run delivers mutations as they're enqueued, until ctx is done, when it
+// returns ctx.Err(). Mutations failing to be delivered are sent again after
+// a pause, per the policy of the outbox. Mutations queued before Run is
+// called, such as by a previous run of the program, are delivered first.
+func (o *Outbox) Run(ctx context.Context) error {
+	var failures int
+	for {
+		if err := o.Flush(ctx); err != nil && ctx.Err() == nil {
+			failures++
+			if err := sleep(ctx, o.backoff(failures)); err != nil {
+				return err
+			}
+			continue
+		}
+		failures = 0
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-o.pending:
+		}
+	}
+}
+
+// backoff returns how long to pause delivery after the given number of
+// failures in a row.
+func (o *Outbox) backoff(failures int) time.Duration {
+	d := defaultDuration(o.policy.MinBackoff, time.Second)
+	max := defaultDuration(o.policy.MaxBackoff, time.Minute)
+	for i := 1; i < failures && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// dirStore is the OutboxStore returned by NewDirStore.
+type dirStore struct {
+	dir string
+
+	mu   sync.Mutex
+	next uint64 // Sequence number of the next entry.
+}
+
+// dirStoreExt is the extension of the files of entries stored by dirStore.
+const dirStoreExt = ".json"
+
+// NewDirStore returns an OutboxStore keeping each entry in a file of its
+// own in dir, which is created if it doesn't exist. Entries are written to
+// a temporary file first, synced and renamed, so that they're either stored
+// whole or not at all, even if the program crashes.
+func NewDirStore(dir string) (OutboxStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	s := &dirStore{dir: dir}
+	names, err := s.entries()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) > 0 {
+		last, _ := strconv.ParseUint(strings.TrimSuffix(names[len(names)-1], dirStoreExt), 10, 64)
+		s.next = last + 1
+	}
+	return s, nil
+}
+
+// entries returns the names of the files of the entries in s, in order.
+func (s *dirStore) entries() ([]string, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range files {
+		if name := f.Name(); strings.HasSuffix(name, dirStoreExt) && !f.IsDir() {
+			names = append(names, name)
+		}
+	}
+	// Names have a fixed width, so they sort in order.
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *dirStore) Push(ctx context.Context, entry []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.CreateTemp(s.dir, "entry-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(entry)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(s.dir, fmt.Sprintf("%020d%s", s.next, dirStoreExt)))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	s.next++
+	return nil
+}
+
+func (s *dirStore) Peek(ctx context.Context) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names, err := s.entries()
+	if err != nil || len(names) == 0 {
+		return nil, false, err
+	}
+	b, err := os.ReadFile(filepath.Join(s.dir, names[0]))
+	if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+func (s *dirStore) Pop(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names, err := s.entries()
+	if err != nil || len(names) == 0 {
+		return err
+	}
+	return os.Remove(filepath.Join(s.dir, names[0]))
+}
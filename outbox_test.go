@@ -0,0 +1,146 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nobody05/graphql_go_client"
+)
+
+func TestOutbox(t *testing.T) {
+	var (
+		down      = true
+		delivered []string
+		keys      = map[string]string{}
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		var body struct {
+			Query     string
+			Variables map[string]json.RawMessage
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		name := string(body.Variables["name"])
+		if key, ok := keys[name]; ok && key != req.Header.Get("Idempotency-Key") {
+			t.Errorf("got idempotency key: %q, want: %q", req.Header.Get("Idempotency-Key"), key)
+		}
+		keys[name] = req.Header.Get("Idempotency-Key")
+		if down {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if name == `"bad"` {
+			mustWrite(w, `{"data": null, "errors": [{"message": "invalid name"}]}`)
+			return
+		}
+		delivered = append(delivered, name+":"+string(body.Variables["count"]))
+		mustWrite(w, `{"data": {"createTag": {"id": "T1"}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	var m struct {
+		CreateTag struct {
+			ID string `graphql:"id"`
+		} `graphql:"createTag(name:$name,count:$count)"`
+	}
+	dir := t.TempDir()
+	store, err := graphql.NewDirStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbox := graphql.NewOutbox(client, store, graphql.OutboxPolicy{})
+	for _, name := range []string{"a", "bad", "b"} {
+		variables := map[string]interface{}{"name": graphql.String(name), "count": json.Number("12345678901234567890")}
+		if err := outbox.Enqueue(context.Background(), &m, variables); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := outbox.Flush(context.Background()); err == nil {
+		t.Fatal("got nil error while the server is down, want non-nil")
+	}
+
+	// Deliver them after a restart.
+	down = false
+	store, err = graphql.NewDirStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rejected []string
+	outbox = graphql.NewOutbox(client, store, graphql.OutboxPolicy{
+		OnRejected: func(entry *graphql.OutboxEntry, err error) {
+			rejected = append(rejected, fmt.Sprintf("%v: %v", entry.Variables["name"], err))
+		},
+	})
+	if err := outbox.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(delivered, " "), `"a":12345678901234567890 "b":12345678901234567890`; got != want {
+		t.Errorf("got delivered: %s, want: %s", got, want)
+	}
+	if got, want := fmt.Sprint(rejected), "[bad: invalid name]"; got != want {
+		t.Errorf("got rejected: %s, want: %s", got, want)
+	}
+	if err := outbox.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(delivered), 2; got != want {
+		t.Errorf("got %d delivered after flushing again, want %d", got, want)
+	}
+}
+
+func TestOutbox_Run(t *testing.T) {
+	var requests int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		if requests++; requests == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"createTag": {"id": "T1"}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+	store, err := graphql.NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	delivered := make(chan string, 1)
+	outbox := graphql.NewOutbox(client, store, graphql.OutboxPolicy{
+		MinBackoff: time.Millisecond,
+		OnDelivered: func(entry *graphql.OutboxEntry, resp *graphql.Response) {
+			delivered <- string(resp.Data)
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- outbox.Run(ctx) }()
+
+	var m struct {
+		CreateTag struct {
+			ID string `graphql:"id"`
+		} `graphql:"createTag"`
+	}
+	if err := outbox.Enqueue(context.Background(), &m, nil); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case data := <-delivered:
+		if want := `{"createTag": {"id": "T1"}}`; data != want {
+			t.Errorf("got data: %s, want: %s", data, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("mutation wasn't delivered")
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("got error: %v, want: %v", err, context.Canceled)
+	}
+}
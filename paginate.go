@@ -0,0 +1,239 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/nobody05/graphql_go_client/ident"
+)
+
+// Paginate executes the query q once per page of the Relay-style connection
+// at path, calling fn after each page is populated into q, until the last
+// page, or until fn returns an error, which Paginate then returns.
+//
+// path consists of response keys separated by dots, such as
+// "repository.issues". The connection must select
+// pageInfo{hasNextPage,endCursor}, and take its cursor from the variable
+// $after, such as:
+//
+//	Issues struct {
+//		Nodes    []Issue
+//		PageInfo struct {
+//			HasNextPage bool   `graphql:"hasNextPage"`
+//			EndCursor   string `graphql:"endCursor"`
+//		} `graphql:"pageInfo"`
+//	} `graphql:"issues(first:100,after:$after)"`
+//
+// $after is set by Paginate, as a nullable String: to null for the first page,
+// unless variables sets it, and to the end cursor of the previous page for the
+// next ones. q is reset to its zero value before each page is populated,
+// so fn should copy the nodes of each page it needs to keep.
+//
+// Specification: https://relay.dev/graphql/connections.htm.
+func (c *Client) Paginate(ctx context.Context, q interface{}, variables map[string]interface{}, path string, fn func() error, opts ...RequestOption) error {
+	v := reflect.ValueOf(q)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("graphql: paginating: query must be a pointer to struct, not %T", q)
+	}
+	keys := strings.Split(path, ".")
+	hasNextPage := append(keys[:len(keys):len(keys)], "pageInfo", "hasNextPage")
+	endCursor := append(keys[:len(keys):len(keys)], "pageInfo", "endCursor")
+	if _, ok := lookupField(v, hasNextPage); !ok {
+		return fmt.Errorf("graphql: paginating: no pageInfo{hasNextPage} at %s", path)
+	}
+	if _, ok := lookupField(v, endCursor); !ok {
+		return fmt.Errorf("graphql: paginating: no pageInfo{endCursor} at %s", path)
+	}
+
+	vars := make(map[string]interface{}, len(variables)+1)
+	for k, v := range variables {
+		vars[k] = v
+	}
+	if _, ok := vars["after"]; !ok {
+		vars["after"] = (*String)(nil)
+	}
+	return c.paginate(ctx, q, vars, fn, func() (bool, error) {
+		next, _ := lookupField(v, hasNextPage)
+		cursor, _ := lookupField(v, endCursor)
+		if !next.IsValid() || !cursor.IsValid() {
+			// The connection or its pageInfo is null.
+			return false, nil
+		}
+		if next.Kind() != reflect.Bool || cursor.Kind() != reflect.String {
+			return false, fmt.Errorf("graphql: paginating: pageInfo at %s must have a boolean hasNextPage and a string endCursor", path)
+		}
+		if !next.Bool() {
+			return false, nil
+		}
+		vars["after"] = NewString(String(cursor.String()))
+		return true, nil
+	}, opts)
+}
+
+// OffsetPagination configures PaginateOffset.
+type OffsetPagination struct {
+	// Path is the path to the list of items of each page, consisting of
+	// response keys separated by dots, such as "users.items".
+	Path string
+
+	// Limit is the number of items per page.
+	// Defaults to 100.
+	Limit int
+
+	// OffsetVariable and LimitVariable are the names of the variables of
+	// type Int! that the query takes the offset of the page and its number
+	// of items from. They default to "offset" and "limit".
+	OffsetVariable string
+	LimitVariable  string
+
+	// TotalCountPath, if set, is the path to the field holding the total
+	// number of items, such as "users.totalCount", which tells whether
+	// there's a next page. Otherwise, a page with fewer than Limit items
+	// is taken to be the last.
+	TotalCountPath string
+}
+
+// PaginateOffset is like Paginate, but for lists paginated by offset
+// and limit rather than by cursor, as configured by p, such as:
+//
+//	Users struct {
+//		Items []User
+//		TotalCount int `graphql:"totalCount"`
+//	} `graphql:"users(offset:$offset,limit:$limit)"`
+//
+// The offset and limit variables are set by PaginateOffset, with the
+// offset starting at 0 unless variables sets it.
+func (c *Client) PaginateOffset(ctx context.Context, q interface{}, variables map[string]interface{}, p OffsetPagination, fn func() error, opts ...RequestOption) error {
+	v := reflect.ValueOf(q)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("graphql: paginating: query must be a pointer to struct, not %T", q)
+	}
+	if p.Limit <= 0 {
+		p.Limit = 100
+	}
+	if p.OffsetVariable == "" {
+		p.OffsetVariable = "offset"
+	}
+	if p.LimitVariable == "" {
+		p.LimitVariable = "limit"
+	}
+	items := strings.Split(p.Path, ".")
+	if _, ok := lookupField(v, items); !ok {
+		return fmt.Errorf("graphql: paginating: no list at %s", p.Path)
+	}
+	var totalCount []string
+	if p.TotalCountPath != "" {
+		totalCount = strings.Split(p.TotalCountPath, ".")
+		if _, ok := lookupField(v, totalCount); !ok {
+			return fmt.Errorf("graphql: paginating: no total count at %s", p.TotalCountPath)
+		}
+	}
+
+	vars := make(map[string]interface{}, len(variables)+2)
+	for k, v := range variables {
+		vars[k] = v
+	}
+	offset, _ := vars[p.OffsetVariable].(Int)
+	vars[p.OffsetVariable] = offset
+	vars[p.LimitVariable] = Int(p.Limit)
+	return c.paginate(ctx, q, vars, fn, func() (bool, error) {
+		list, _ := lookupField(v, items)
+		if !list.IsValid() {
+			return false, nil
+		}
+		if list.Kind() != reflect.Slice {
+			return false, fmt.Errorf("graphql: paginating: field at %s must be a list", p.Path)
+		}
+		offset += Int(list.Len())
+		if totalCount == nil {
+			if list.Len() < p.Limit {
+				return false, nil
+			}
+		} else {
+			total, _ := lookupField(v, totalCount)
+			if !total.IsValid() || !total.CanInt() {
+				return false, fmt.Errorf("graphql: paginating: field at %s must be an integer", p.TotalCountPath)
+			}
+			if list.Len() == 0 || int64(offset) >= total.Int() {
+				return false, nil
+			}
+		}
+		vars[p.OffsetVariable] = offset
+		return true, nil
+	}, opts)
+}
+
+// paginate executes the query q with variables vars, calling fn after
+// each page, until next, which prepares vars for the next page, reports
+// there's none.
+func (c *Client) paginate(ctx context.Context, q interface{}, vars map[string]interface{}, fn func() error, next func() (bool, error), opts []RequestOption) error {
+	v := reflect.ValueOf(q).Elem()
+	o := newRequestOptions(opts)
+	for {
+		v.Set(reflect.Zero(v.Type()))
+		if err := c.do(ctx, queryOperation, q, vars, o); err != nil {
+			return err
+		}
+		if err := fn(); err != nil {
+			return err
+		}
+		if ok, err := next(); !ok || err != nil {
+			return err
+		}
+	}
+}
+
+// lookupField returns the value in v of the field at path, which consists
+// of response keys, with pointers dereferenced, and whether the field is
+// declared. The value is invalid if the field, or one holding it, is a nil
+// pointer. Fields of inline fragments are looked up as if they belonged to
+// the enclosing struct.
+func lookupField(v reflect.Value, path []string) (reflect.Value, bool) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			// Look the field up in the zero value, to tell if it's declared.
+			_, ok := lookupField(reflect.Zero(v.Type().Elem()), path)
+			return reflect.Value{}, ok
+		}
+		v = v.Elem()
+	}
+	if len(path) == 0 {
+		return v, true
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		value, tagged := f.Tag.Lookup("graphql")
+		switch {
+		case !tagged && f.Anonymous, tagged && strings.HasPrefix(strings.TrimSpace(value), "... on "):
+			if field, ok := lookupField(v.Field(i), path); ok {
+				return field, true
+			}
+		case hasResponseKey(f, path[0]):
+			return lookupField(v.Field(i), path[1:])
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// hasResponseKey reports whether the struct field f selects the field
+// with response key key: its alias, or else its name.
+func hasResponseKey(f reflect.StructField, key string) bool {
+	value, ok := f.Tag.Lookup("graphql")
+	value = strings.TrimSpace(value)
+	if !ok || strings.HasPrefix(value, "@") {
+		// The field has its default name.
+		return strings.EqualFold(f.Name, key) || ident.ParseMixedCaps(f.Name).ToUnderline() == key
+	}
+	if strings.HasPrefix(value, "...") {
+		return false
+	}
+	if i := strings.IndexAny(value, "(:@{"); i != -1 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value) == key
+}
@@ -0,0 +1,43 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// maxPooledBuffer is the capacity beyond which buffers aren't pooled,
+// so that a single large request doesn't pin its memory.
+const maxPooledBuffer = 64 << 10
+
+// buffer is a pooled buffer, along with a JSON encoder writing to it,
+// for building documents and encoding requests without allocating
+// buffers anew for each operation.
+type buffer struct {
+	bytes.Buffer
+	enc *json.Encoder
+}
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		b := new(buffer)
+		b.enc = json.NewEncoder(&b.Buffer)
+		return b
+	},
+}
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *buffer {
+	b := bufferPool.Get().(*buffer)
+	b.Reset()
+	return b
+}
+
+// putBuffer returns b to the pool. b, and the slices returned by its
+// Bytes method, must not be used afterwards.
+func putBuffer(b *buffer) {
+	if b.Cap() > maxPooledBuffer {
+		return
+	}
+	bufferPool.Put(b)
+}
@@ -0,0 +1,158 @@
+// Package promgraphql provides Prometheus metrics for GraphQL clients
+// of package graphql.
+//
+// Create a Collector, register it, and pass it to the client:
+//
+//	metrics := promgraphql.NewCollector()
+//	prometheus.MustRegister(metrics)
+//	client := graphql.NewClient(url, graphql.WithMetrics(metrics))
+package promgraphql
+
+import (
+	graphql "github.com/nobody05/graphql_go_client"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is a graphql.MetricsCollector that exports the metrics as
+// Prometheus metrics. It's a prometheus.Collector, to be registered
+// with a prometheus.Registerer.
+//
+// The metrics are labeled by operation name and type, and operation
+// counts and latencies also by outcome:
+//
+//	graphql_client_operations_total{operation,type,outcome}
+//	graphql_client_operation_duration_seconds{operation,type,outcome}
+//	graphql_client_request_size_bytes{operation,type}
+//	graphql_client_response_size_bytes{operation,type}
+//	graphql_client_retries_total{operation,type}
+type Collector struct {
+	operations   *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	requestSize  *prometheus.HistogramVec
+	responseSize *prometheus.HistogramVec
+	retries      *prometheus.CounterVec
+}
+
+// Option configures a Collector.
+type Option func(*config)
+
+type config struct {
+	namespace       string
+	constLabels     prometheus.Labels
+	durationBuckets []float64
+	sizeBuckets     []float64
+}
+
+// WithNamespace sets a namespace prefixed to the names of the metrics.
+func WithNamespace(namespace string) Option {
+	return func(c *config) {
+		c.namespace = namespace
+	}
+}
+
+// WithConstLabels sets labels with fixed values added to all metrics,
+// such as the name of the GraphQL server.
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(c *config) {
+		c.constLabels = labels
+	}
+}
+
+// WithDurationBuckets sets the buckets of the latency histogram, in seconds.
+// The default is prometheus.DefBuckets.
+func WithDurationBuckets(buckets []float64) Option {
+	return func(c *config) {
+		c.durationBuckets = buckets
+	}
+}
+
+// WithSizeBuckets sets the buckets of the payload size histograms, in bytes.
+// The default is exponential from 64 bytes to 4 MiB.
+func WithSizeBuckets(buckets []float64) Option {
+	return func(c *config) {
+		c.sizeBuckets = buckets
+	}
+}
+
+// NewCollector returns a new Collector configured by opts.
+func NewCollector(opts ...Option) *Collector {
+	c := config{
+		durationBuckets: prometheus.DefBuckets,
+		sizeBuckets:     prometheus.ExponentialBuckets(64, 4, 9),
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return &Collector{
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   c.namespace,
+			Subsystem:   "graphql_client",
+			Name:        "operations_total",
+			Help:        "Number of GraphQL operations, by outcome.",
+			ConstLabels: c.constLabels,
+		}, []string{"operation", "type", "outcome"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   c.namespace,
+			Subsystem:   "graphql_client",
+			Name:        "operation_duration_seconds",
+			Help:        "Time taken to get responses to GraphQL operations, including retries.",
+			ConstLabels: c.constLabels,
+			Buckets:     c.durationBuckets,
+		}, []string{"operation", "type", "outcome"}),
+		requestSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   c.namespace,
+			Subsystem:   "graphql_client",
+			Name:        "request_size_bytes",
+			Help:        "Size of JSON-encoded GraphQL requests.",
+			ConstLabels: c.constLabels,
+			Buckets:     c.sizeBuckets,
+		}, []string{"operation", "type"}),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   c.namespace,
+			Subsystem:   "graphql_client",
+			Name:        "response_size_bytes",
+			Help:        "Size of the data of GraphQL responses.",
+			ConstLabels: c.constLabels,
+			Buckets:     c.sizeBuckets,
+		}, []string{"operation", "type"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   c.namespace,
+			Subsystem:   "graphql_client",
+			Name:        "retries_total",
+			Help:        "Number of retried HTTP requests for GraphQL operations.",
+			ConstLabels: c.constLabels,
+		}, []string{"operation", "type"}),
+	}
+}
+
+// ObserveOperation implements graphql.MetricsCollector.
+func (c *Collector) ObserveOperation(m graphql.OperationMetrics) {
+	outcome := string(m.Outcome)
+	c.operations.WithLabelValues(m.Name, m.Type, outcome).Inc()
+	c.duration.WithLabelValues(m.Name, m.Type, outcome).Observe(m.Duration.Seconds())
+	c.requestSize.WithLabelValues(m.Name, m.Type).Observe(float64(m.RequestBytes))
+	if m.Outcome != graphql.OutcomeError {
+		c.responseSize.WithLabelValues(m.Name, m.Type).Observe(float64(m.ResponseBytes))
+	}
+	if m.Retries > 0 {
+		c.retries.WithLabelValues(m.Name, m.Type).Add(float64(m.Retries))
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.operations.Describe(ch)
+	c.duration.Describe(ch)
+	c.requestSize.Describe(ch)
+	c.responseSize.Describe(ch)
+	c.retries.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.operations.Collect(ch)
+	c.duration.Collect(ch)
+	c.requestSize.Collect(ch)
+	c.responseSize.Collect(ch)
+	c.retries.Collect(ch)
+}
@@ -0,0 +1,72 @@
+package promgraphql_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	graphql "github.com/nobody05/graphql_go_client"
+	"github.com/nobody05/graphql_go_client/promgraphql"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	attempts := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"data": {"viewer": {"login": "gopher"}}}`)
+	})
+	metrics := promgraphql.NewCollector()
+	client := graphql.NewClient("/graphql",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithRetry(graphql.RetryPolicy{MinBackoff: time.Millisecond}),
+		graphql.WithMetrics(metrics),
+	)
+
+	var q struct {
+		Viewer struct {
+			Login string
+		}
+	}
+	if err := client.NamedQuery(context.Background(), "GetViewer", &q, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `
+# HELP graphql_client_operations_total Number of GraphQL operations, by outcome.
+# TYPE graphql_client_operations_total counter
+graphql_client_operations_total{operation="GetViewer",outcome="success",type="query"} 1
+# HELP graphql_client_retries_total Number of retried HTTP requests for GraphQL operations.
+# TYPE graphql_client_retries_total counter
+graphql_client_retries_total{operation="GetViewer",type="query"} 1
+`
+	err := testutil.CollectAndCompare(metrics, strings.NewReader(want), "graphql_client_operations_total", "graphql_client_retries_total")
+	if err != nil {
+		t.Error(err)
+	}
+	if got, want := testutil.CollectAndCount(metrics, "graphql_client_response_size_bytes"), 1; got != want {
+		t.Errorf("got response size series: %v, want: %v", got, want)
+	}
+}
+
+// localRoundTripper is an http.RoundTripper that executes HTTP transactions
+// by using handler directly, instead of going over an HTTP connection.
+type localRoundTripper struct {
+	handler http.Handler
+}
+
+func (l localRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := httptest.NewRecorder()
+	l.handler.ServeHTTP(w, req)
+	return w.Result(), nil
+}
@@ -1,37 +1,268 @@
 package graphql
 
 import (
-	"bytes"
+	"encoding"
 	"encoding/json"
+	"fmt"
 	"io"
 	"reflect"
 	"sort"
+	"strings"
+	"sync"
 
 	"github.com/nobody05/graphql_go_client/ident"
+	"github.com/nobody05/graphql_go_client/internal/parser"
 )
 
-func constructQueryNoQueryKeyword(fn string, v interface{}, variables map[string]interface{}) string {
-	query := query(v)
-	if len(variables) > 0 {
-		return queryArguments(variables) + query
+// ConstructQuery returns the GraphQL query document derived from v and variables,
+// exactly as it would be sent for a query operation. It is useful for
+// inspecting, logging, and testing the document generated from a struct.
+//
+// v should be a struct, or a pointer to struct, that corresponds to the GraphQL schema.
+func ConstructQuery(v interface{}, variables map[string]interface{}) (string, error) {
+	return operation(queryOperation, "", "", v, variables)
+}
+
+// ConstructMutation is like ConstructQuery, but for a mutation operation.
+func ConstructMutation(v interface{}, variables map[string]interface{}) (string, error) {
+	return operation(mutationOperation, "", "", v, variables)
+}
+
+// checkQuery reports an error if v is not a struct or a pointer to struct,
+// or if the GraphQL type of a variable can't be derived from its value.
+func checkQuery(v interface{}, variables map[string]interface{}) error {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return fmt.Errorf("graphql: cannot construct query from nil")
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("graphql: cannot construct query from %T, want struct or pointer to struct", v)
+	}
+	if err := checkTags(t); err != nil {
+		return err
 	}
-	return "{" + fn + query + "}"
+	for name, value := range variables {
+		if err := checkArgumentType(reflect.TypeOf(value)); err != nil {
+			return fmt.Errorf("graphql: variable $%s: %v", name, err)
+		}
+	}
+	return nil
 }
 
-func constructQuery(v interface{}, variables map[string]interface{}) string {
-	query := query(v)
-	if len(variables) > 0 {
-		return "query(" + queryArguments(variables) + ")" + query
+// tagErrors caches the results of checkTags, by type.
+var tagErrors sync.Map // map[reflect.Type]error
+
+// checkTags reports an error if the graphql tag of a field selected by
+// struct type t, directly or within nested selections, isn't valid GraphQL,
+// such as arguments with unbalanced brackets, naming the field, rather than
+// leaving it to the server to report an error in the whole document.
+func checkTags(t reflect.Type) error {
+	if err, ok := tagErrors.Load(t); ok {
+		err, _ := err.(error)
+		return err
+	}
+	err := checkTagsOf(t, "", make(map[reflect.Type]bool))
+	tagErrors.Store(t, err)
+	return err
+}
+
+// checkTagsOf checks the tags of the fields selected by t, whose names are
+// prefixed by path in errors, as by checkTags, and as selected by writeQuery.
+func checkTagsOf(t reflect.Type, path string, seen map[reflect.Type]bool) error {
+	if _, ok := lookupScalar(t); ok {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice:
+		return checkTagsOf(t.Elem(), path, seen)
+	case reflect.Struct:
+		if unmarshalsItself(t) || seen[t] {
+			return nil
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			value, ok := f.Tag.Lookup("graphql")
+			if ok {
+				// Check the selection the tag stands for on its own.
+				selection := strings.TrimSpace(value)
+				switch {
+				case strings.HasPrefix(selection, "@"):
+					selection = "field" + selection
+				case strings.HasPrefix(selection, "...") && !isFragmentSpread(selection):
+					selection += "{__typename}"
+				}
+				if _, err := parser.Parse("{" + selection + "}"); err != nil {
+					return fmt.Errorf("graphql: invalid graphql tag %q of field %s: %v", value, path+f.Name, err)
+				}
+				if isFragmentSpread(value) {
+					continue
+				}
+			}
+			if err := checkTagsOf(f.Type, path+f.Name+".", seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkArgumentType reports an error if writeArgumentType can't
+// write the GraphQL type corresponding to t.
+func checkArgumentType(t reflect.Type) error {
+	if t == nil {
+		return fmt.Errorf("cannot derive type from untyped nil, use a typed nil pointer instead")
+	}
+	if t.Implements(optionalType) {
+		return checkArgumentType(reflect.Zero(t).Interface().(optional).optionalType())
+	}
+	if _, ok := lookupScalar(t); ok || t.Implements(readerType) || inputTypeName(t) != "" {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return checkArgumentType(t.Elem())
 	}
-	return query
+	if t.Name() == "" {
+		return fmt.Errorf("cannot derive type from unnamed type %v", t)
+	}
+	return nil
+}
+
+// constructFieldQuery constructs a minified query document with the given
+// operation name selecting the root field fn, which may include arguments,
+// with the selection set derived from v. If fn is empty, v describes
+// the whole query, as with constructOperation.
+//
+// E.g., ("user(id:$id)", "", struct{Name String}{}, {"id": ID("1")}) -> "query($id:ID!){user(id:$id){name}}".
+func constructFieldQuery(fn, name string, v interface{}, variables map[string]interface{}) string {
+	if fn == "" {
+		return constructOperation(queryOperation, name, v, variables)
+	}
+	return operationDocument(queryOperation, name, "{"+fn+query(v)+"}", variables)
+}
+
+// operation checks v and variables, as checkQuery does, and returns the
+// document of an operation of type op with the given operation name, with
+// the selection set derived from v, or of a query selecting the root field
+// fn with it, if fn isn't empty, as constructFieldQuery does.
+//
+// Documents, and errors, are cached by the Go types of v and of the
+// variables, so that operations sent again and again with the same
+// struct, as in hot loops, skip reflection entirely.
+func operation(op operationType, name, fn string, v interface{}, variables map[string]interface{}) (string, error) {
+	key, ok := newDocumentKey(op, name, fn, v, variables)
+	if !ok {
+		return constructDocument(op, name, fn, v, variables)
+	}
+	d := documents.get(key, func(documentKey) cachedDocument {
+		doc, err := constructDocument(op, name, fn, v, variables)
+		return cachedDocument{doc, err}
+	})
+	return d.doc, d.err
+}
+
+// constructDocument returns the document returned by operation, without
+// caching it.
+func constructDocument(op operationType, name, fn string, v interface{}, variables map[string]interface{}) (string, error) {
+	if err := checkQuery(v, variables); err != nil {
+		return "", err
+	}
+	if fn != "" {
+		return constructFieldQuery(fn, name, v, variables), nil
+	}
+	return constructOperation(op, name, v, variables), nil
+}
+
+// documents caches the documents returned by operation.
+var documents = newLRU[documentKey, cachedDocument](maxCachedOperations)
+
+type cachedDocument struct {
+	doc string
+	err error
+}
+
+// maxKeyedVariables is the number of variables up to which documents
+// are cached by operation.
+const maxKeyedVariables = 8
+
+// documentKey identifies the document of an operation, as returned by
+// operation, by the Go types it's derived from.
+type documentKey struct {
+	op        operationType
+	name, fn  string
+	t         reflect.Type
+	variables [maxKeyedVariables]variableKey // Sorted by name.
+}
+
+type variableKey struct {
+	name string
+	t    reflect.Type
+}
+
+// newDocumentKey returns the key of the document returned by operation,
+// and whether it has one, which it doesn't for too many variables.
+func newDocumentKey(op operationType, name, fn string, v interface{}, variables map[string]interface{}) (documentKey, bool) {
+	key := documentKey{op: op, name: name, fn: fn, t: reflect.TypeOf(v)}
+	if len(variables) > maxKeyedVariables {
+		return key, false
+	}
+	n := 0
+	for name, value := range variables {
+		// Insert the variable in order, without allocating.
+		i := n
+		for ; i > 0 && key.variables[i-1].name > name; i-- {
+			key.variables[i] = key.variables[i-1]
+		}
+		key.variables[i] = variableKey{name: name, t: reflect.TypeOf(value)}
+		n++
+	}
+	return key, true
+}
+
+func constructQuery(v interface{}, variables map[string]interface{}) string {
+	return constructOperation(queryOperation, "", v, variables)
 }
 
 func constructMutation(v interface{}, variables map[string]interface{}) string {
-	query := query(v)
+	return constructOperation(mutationOperation, "", v, variables)
+}
+
+// constructOperation constructs a minified document for an operation of type op
+// with the given operation name, which may be empty for an anonymous operation.
+//
+// E.g., (queryOperation, "GetUser", struct{User struct{Name String}}{}, nil) -> "query GetUser{user{name}}".
+func constructOperation(op operationType, name string, v interface{}, variables map[string]interface{}) string {
+	return operationDocument(op, name, query(v), variables)
+}
+
+// operationDocument returns the document for an operation of type op with
+// the given operation name, selection set, and variable definitions.
+func operationDocument(op operationType, name string, query string, variables map[string]interface{}) string {
+	var keyword string
+	switch op {
+	case queryOperation:
+		keyword = "query"
+	case mutationOperation:
+		keyword = "mutation"
+	case subscriptionOperation:
+		keyword = "subscription"
+	}
+	if name != "" {
+		keyword += " " + name
+	}
 	if len(variables) > 0 {
-		return "mutation(" + queryArguments(variables) + ")" + query
+		return keyword + "(" + queryArguments(variables) + ")" + query
+	}
+	if op == queryOperation && name == "" {
+		// Use the query shorthand, since there's nothing to declare.
+		return query
 	}
-	return "mutation" + query
+	return keyword + query
 }
 
 // queryArguments constructs a minified arguments string for variables.
@@ -46,12 +277,13 @@ func queryArguments(variables map[string]interface{}) string {
 	}
 	sort.Strings(keys)
 
-	var buf bytes.Buffer
+	buf := getBuffer()
+	defer putBuffer(buf)
 	for _, k := range keys {
-		io.WriteString(&buf, "$")
-		io.WriteString(&buf, k)
-		io.WriteString(&buf, ":")
-		writeArgumentType(&buf, reflect.TypeOf(variables[k]), true)
+		io.WriteString(buf, "$")
+		io.WriteString(buf, k)
+		io.WriteString(buf, ":")
+		io.WriteString(buf, argumentType(reflect.TypeOf(variables[k])))
 		// Don't insert a comma here.
 		// Commas in GraphQL are insignificant, and we want minified output.
 		// See https://facebook.github.io/graphql/October2016/#sec-Insignificant-Commas.
@@ -63,6 +295,27 @@ func queryArguments(variables map[string]interface{}) string {
 // value indicates whether t is a value (required) type or pointer (optional) type.
 // If value is true, then "!" is written at the end of t.
 func writeArgumentType(w io.Writer, t reflect.Type, value bool) {
+	if t.Implements(optionalType) {
+		// Optional is a nullable type, like a pointer.
+		writeArgumentType(w, reflect.Zero(t).Interface().(optional).optionalType(), false)
+		return
+	}
+	if s, ok := lookupScalar(t); ok {
+		// Custom scalar, even if its Go type is a list or struct.
+		io.WriteString(w, s.name)
+		if value {
+			io.WriteString(w, "!")
+		}
+		return
+	}
+	if t.Implements(readerType) {
+		// Files given as io.Reader are uploaded.
+		io.WriteString(w, "Upload")
+		if value {
+			io.WriteString(w, "!")
+		}
+		return
+	}
 	if t.Kind() == reflect.Ptr {
 		// Pointer is an optional type, so no "!" at the end of the pointer's underlying type.
 		writeArgumentType(w, t.Elem(), false)
@@ -77,11 +330,7 @@ func writeArgumentType(w io.Writer, t reflect.Type, value bool) {
 		io.WriteString(w, "]")
 	default:
 		// Named type. E.g., "Int".
-		name := t.Name()
-		if name == "string" { // HACK: Workaround for https://github.com/shurcooL/githubv4/issues/12.
-			name = "ID"
-		}
-		io.WriteString(w, name)
+		io.WriteString(w, typeName(t))
 	}
 
 	if value {
@@ -90,29 +339,114 @@ func writeArgumentType(w io.Writer, t reflect.Type, value bool) {
 	}
 }
 
+// typeName returns the name of the GraphQL type corresponding to named type t.
+// Predeclared Go types are inferred as the built-in GraphQL scalars, so that
+// plain Go values can be used as variables. Other types keep their name,
+// unless they're given another one, as described by inputTypeName.
+//
+// E.g., int -> "Int", Int -> "Int", IssueState -> "IssueState".
+func typeName(t reflect.Type) string {
+	if name := inputTypeName(t); name != "" {
+		return name
+	}
+	if t.PkgPath() != "" {
+		return t.Name()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "Boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "Int"
+	case reflect.Float32, reflect.Float64:
+		return "Float"
+	case reflect.String:
+		// HACK: Workaround for https://github.com/shurcooL/githubv4/issues/12.
+		// An ID's dynamic type is string, so strings can't be told apart from IDs.
+		return "ID"
+	}
+	return t.Name()
+}
+
 // query uses writeQuery to recursively construct
 // a minified query string from the provided struct v.
 //
 // E.g., struct{Foo Int, BarBaz *Boolean} -> "{foo,barBaz}".
 func query(v interface{}) string {
-	var buf bytes.Buffer
-	writeQuery(&buf, reflect.TypeOf(v), false)
-	return buf.String()
+	t := reflect.TypeOf(v)
+	if s, ok := selectionSets.Load(t); ok {
+		return s.(string)
+	}
+	buf := getBuffer()
+	defer putBuffer(buf)
+	writeQuery(buf, t, false)
+	s := buf.String()
+	selectionSets.Store(t, s)
+	return s
+}
+
+// selectionSets caches the selection sets derived from query structs,
+// by type, so that operations sent again and again with the same struct,
+// as in hot loops, don't walk it with reflection every time.
+var selectionSets sync.Map // map[reflect.Type]string
+
+// argumentTypes caches the GraphQL types of variables, by Go type.
+var argumentTypes sync.Map // map[reflect.Type]string
+
+// argumentType returns the GraphQL type of variables of Go type t,
+// as written by writeArgumentType.
+func argumentType(t reflect.Type) string {
+	if s, ok := argumentTypes.Load(t); ok {
+		return s.(string)
+	}
+	buf := getBuffer()
+	defer putBuffer(buf)
+	writeArgumentType(buf, t, true)
+	s := buf.String()
+	argumentTypes.Store(t, s)
+	return s
+}
+
+// resetTypeCaches clears the caches of what's derived from Go types,
+// which custom scalars and unions change, as they're registered.
+func resetTypeCaches() {
+	for _, cache := range []*sync.Map{&selectionSets, &argumentTypes, &tagErrors, &plainInputs} {
+		cache.Range(func(key, _ interface{}) bool {
+			cache.Delete(key)
+			return true
+		})
+	}
+	documents.clear()
 }
 
 // writeQuery writes a minified query for t to w.
 // If inline is true, the struct fields of t are inlined into parent struct.
 func writeQuery(w io.Writer, t reflect.Type, inline bool) {
+	if _, ok := lookupScalar(t); ok {
+		// Custom scalar. Don't expand it.
+		return
+	}
 	switch t.Kind() {
-	case reflect.Ptr, reflect.Slice:
+	case reflect.Ptr:
+		// Embedded pointers to structs are inlined too.
+		writeQuery(w, t.Elem(), inline)
+	case reflect.Slice:
 		writeQuery(w, t.Elem(), false)
+	case reflect.Interface:
+		if types, ok := lookupUnion(t); ok {
+			writeUnion(w, types)
+		}
 	case reflect.Struct:
-		// If the type implements json.Unmarshaler, it's a scalar. Don't expand it.
-		if reflect.PtrTo(t).Implements(jsonUnmarshaler) {
+		// If the type unmarshals itself, it's a scalar. Don't expand it.
+		if unmarshalsItself(t) {
 			return
 		}
 		if !inline {
 			io.WriteString(w, "{")
+			if hasTypeCondition(t) && !selectsTypename(t) {
+				// Needed to tell which of the inline fragments applies.
+				io.WriteString(w, "__typename,")
+			}
 		}
 		for i := 0; i < t.NumField(); i++ {
 			if i != 0 {
@@ -122,10 +456,17 @@ func writeQuery(w io.Writer, t reflect.Type, inline bool) {
 			value, ok := f.Tag.Lookup("graphql")
 			inlineField := f.Anonymous && !ok
 			if !inlineField {
+				if !ok || strings.HasPrefix(strings.TrimSpace(value), "@") {
+					// Default field name, followed by directives if any,
+					// such as "@include(if:$withDetails)".
+					io.WriteString(w, ident.ParseMixedCaps(f.Name).ToUnderline())
+				}
 				if ok {
 					io.WriteString(w, value)
-				} else {
-					io.WriteString(w, ident.ParseMixedCaps(f.Name).ToUnderline())
+				}
+				if isFragmentSpread(value) {
+					// The selection set is in the fragment definition.
+					continue
 				}
 			}
 			writeQuery(w, f.Type, inlineField)
@@ -136,4 +477,71 @@ func writeQuery(w io.Writer, t reflect.Type, inline bool) {
 	}
 }
 
-var jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+// isFragmentSpread reports whether the graphql tag value spreads
+// a named fragment, such as "...UserFields".
+func isFragmentSpread(value string) bool {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "...") {
+		return false
+	}
+	value = strings.TrimSpace(strings.TrimPrefix(value, "..."))
+	return value != "" && value[0] != '@' && value != "on" && !strings.HasPrefix(value, "on ")
+}
+
+// hasTypeCondition reports whether struct type t selects an inline
+// fragment with a type condition, such as "... on Issue".
+func hasTypeCondition(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		value, ok := f.Tag.Lookup("graphql")
+		if e, ok := embeddedStruct(f); ok && hasTypeCondition(e) {
+			return true
+		}
+		value = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(value), "..."))
+		if ok && strings.HasPrefix(value, "on ") {
+			return true
+		}
+	}
+	return false
+}
+
+// embeddedStruct returns the struct type of f if it's an embedded struct,
+// or pointer to struct, without a graphql tag, whose fields are promoted
+// to the selection of the struct embedding it.
+func embeddedStruct(f reflect.StructField) (reflect.Type, bool) {
+	if _, ok := f.Tag.Lookup("graphql"); ok || !f.Anonymous {
+		return nil, false
+	}
+	t := f.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t, t.Kind() == reflect.Struct
+}
+
+// selectsTypename reports whether struct type t already selects __typename.
+func selectsTypename(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if e, ok := embeddedStruct(f); ok && selectsTypename(e) {
+			return true
+		}
+		if strings.HasPrefix(strings.TrimSpace(f.Tag.Get("graphql")), "__typename") {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// unmarshalsItself reports whether values of struct type t unmarshal
+// themselves, implementing json.Unmarshaler or encoding.TextUnmarshaler,
+// such as time.Time or big.Float, which makes them scalars.
+func unmarshalsItself(t reflect.Type) bool {
+	pt := reflect.PtrTo(t)
+	return pt.Implements(jsonUnmarshaler) || pt.Implements(textUnmarshaler)
+}
@@ -1,7 +1,10 @@
 package graphql
 
 import (
+	"fmt"
 	"net/url"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -214,7 +217,7 @@ func TestConstructQuery(t *testing.T) {
 					}
 				}{}
 			}(),
-			want: `{actor{login,avatarUrl,url},createdAt,... on IssueComment{body},currentTitle,previousTitle,label{name,color}}`,
+			want: `{__typename,actor{login,avatarUrl,url},createdAt,... on IssueComment{body},currentTitle,previousTitle,label{name,color}}`,
 		},
 		{
 			inV: struct {
@@ -271,6 +274,318 @@ func TestConstructMutation(t *testing.T) {
 	}
 }
 
+func TestConstructQuery_directives(t *testing.T) {
+	var q struct {
+		Viewer struct {
+			Login   String
+			Bio     String `graphql:"@include(if: $withDetails)"`
+			Company String `graphql:"company @skip(if: $brief)"`
+		}
+	}
+	got := constructQuery(&q, map[string]interface{}{"withDetails": Boolean(true), "brief": Boolean(false)})
+	if want := `query($brief:Boolean!$withDetails:Boolean!){viewer{login,bio@include(if: $withDetails),company @skip(if: $brief)}}`; got != want {
+		t.Errorf("\ngot:  %q\nwant: %q\n", got, want)
+	}
+}
+
+func TestConstructQuery_aliases(t *testing.T) {
+	type repository struct {
+		Name String
+	}
+	var q struct {
+		Primary   repository `graphql:"primary: repository(name: \"a\")"`
+		Secondary repository `graphql:"secondary: repository(name: $name)"`
+	}
+	got := constructQuery(&q, map[string]interface{}{"name": String("b")})
+	if want := `query($name:String!){primary: repository(name: "a"){name},secondary: repository(name: $name){name}}`; got != want {
+		t.Errorf("\ngot:  %q\nwant: %q\n", got, want)
+	}
+}
+
+func TestConstructQuery_embedded(t *testing.T) {
+	type Node struct {
+		ID ID `graphql:"id"`
+	}
+	type Timestamps struct {
+		CreatedAt String `graphql:"createdAt"`
+	}
+	type Labelable struct {
+		Label struct {
+			Name String `graphql:"name"`
+		} `graphql:"... on Label"`
+	}
+	var q struct {
+		Node struct {
+			Node
+			*Timestamps
+			*Labelable
+			Title String `graphql:"title"`
+		} `graphql:"node(id: $id)"`
+	}
+	got := constructQuery(&q, map[string]interface{}{"id": ID("someID")})
+	if want := `query($id:ID!){node(id: $id){__typename,id,createdAt,... on Label{name},title}}`; got != want {
+		t.Errorf("\ngot:  %q\nwant: %q\n", got, want)
+	}
+}
+
+func TestConstructQuery_typename(t *testing.T) {
+	type closedEvent struct{ CreatedAt String }
+	var q struct {
+		Node struct {
+			ClosedEvent closedEvent `graphql:"... on ClosedEvent"`
+		} `graphql:"node(id: $id)"`
+		Search []struct {
+			Typename    String      `graphql:"__typename"`
+			ClosedEvent closedEvent `graphql:"... on ClosedEvent"`
+		}
+	}
+	got := constructQuery(&q, map[string]interface{}{"id": ID("someID")})
+	if want := `query($id:ID!){node(id: $id){__typename,... on ClosedEvent{created_at}},search{__typename,... on ClosedEvent{created_at}}}`; got != want {
+		t.Errorf("\ngot:  %q\nwant: %q\n", got, want)
+	}
+}
+
+func TestConstructQuery_connection(t *testing.T) {
+	type Issue struct {
+		Title String `graphql:"title"`
+	}
+	var q struct {
+		Issues Connection[Issue] `graphql:"issues(first:2,after:$after)"`
+	}
+	got, err := ConstructQuery(&q, map[string]interface{}{"after": (*String)(nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `query($after:String){issues(first:2,after:$after){edges{cursor,node{title}},pageInfo{hasNextPage,hasPreviousPage,startCursor,endCursor}}}`; got != want {
+		t.Errorf("\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestAppendFragments(t *testing.T) {
+	type user struct {
+		Login String
+	}
+	type repository struct {
+		Name  String
+		Owner struct {
+			user `graphql:"...UserFields"`
+		}
+	}
+	var q struct {
+		Viewer struct {
+			Repository struct {
+				repository `graphql:"... RepositoryFields"`
+			}
+		}
+	}
+	fragments := []Fragment{
+		{Name: "UserFields", On: "User", Selection: user{}},
+		{Name: "RepositoryFields", On: "Repository", Selection: &repository{}},
+		{Name: "UserFieldsUnused", On: "User", Selection: user{}},
+	}
+	got := appendFragments(constructQuery(&q, nil), fragments)
+	if want := `{viewer{repository{... RepositoryFields}}}fragment RepositoryFields on Repository{name,owner{...UserFields}}fragment UserFields on User{login}`; got != want {
+		t.Errorf("\ngot:  %q\nwant: %q\n", got, want)
+	}
+}
+
+func TestConstructQuery_exported(t *testing.T) {
+	var q struct {
+		Viewer struct {
+			Login String
+		}
+	}
+	got, err := ConstructQuery(&q, map[string]interface{}{"id": ID("someID")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `query($id:ID!){viewer{login}}`; got != want {
+		t.Errorf("\ngot:  %q\nwant: %q\n", got, want)
+	}
+	got, err = ConstructMutation(&q, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `mutation{viewer{login}}`; got != want {
+		t.Errorf("\ngot:  %q\nwant: %q\n", got, want)
+	}
+
+	for _, v := range []interface{}{nil, 42, new(string)} {
+		if _, err := ConstructQuery(v, nil); err == nil {
+			t.Errorf("ConstructQuery(%T): got nil error, want non-nil", v)
+		}
+	}
+	for _, variables := range []map[string]interface{}{
+		{"x": nil},
+		{"x": []interface{}{nil}},
+		{"x": struct{ A Int }{}},
+	} {
+		if _, err := ConstructQuery(&q, variables); err == nil {
+			t.Errorf("ConstructQuery(%v): got nil error, want non-nil", variables)
+		}
+	}
+}
+
+// cachedResult is an interface type, registered as a union by
+// TestConstructQuery_cache.
+type cachedResult interface{}
+
+func TestConstructQuery_cache(t *testing.T) {
+	var q struct {
+		Search []cachedResult
+	}
+	check := func(want string) {
+		t.Helper()
+		if got := constructQuery(&q, nil); got != want {
+			t.Errorf("\ngot:  %q\nwant: %q\n", got, want)
+		}
+		// Documents cached by operation too.
+		if got, err := operation(queryOperation, "", "", &q, nil); err != nil || got != want {
+			t.Errorf("\ngot:  %q, %v\nwant: %q\n", got, err, want)
+		}
+	}
+	check(`{search}`)
+	// Registering a union changes the selection set of types using it.
+	RegisterUnion[cachedResult]("CachedResult", map[string]reflect.Type{
+		"Issue": reflect.TypeOf(struct{ Title String }{}),
+	})
+	check(`{search{__typename,... on Issue{title}}}`)
+	RegisterUnion[cachedResult]("CachedResult", nil)
+	check(`{search}`)
+}
+
+func TestOperation_cache(t *testing.T) {
+	var q struct {
+		User struct {
+			Login String
+		} `graphql:"user(id: $id)"`
+	}
+	tests := []struct {
+		op        operationType
+		name, fn  string
+		variables map[string]interface{}
+		want      string
+	}{
+		{queryOperation, "", "", map[string]interface{}{"id": ID("1")}, `query($id:ID!){user(id: $id){login}}`},
+		// Documents are cached by the Go types of variables, not by their values.
+		{queryOperation, "", "", map[string]interface{}{"id": ID("2")}, `query($id:ID!){user(id: $id){login}}`},
+		{queryOperation, "", "", map[string]interface{}{"id": Int(2)}, `query($id:Int!){user(id: $id){login}}`},
+		{queryOperation, "", "", map[string]interface{}{"id": NewInt(2)}, `query($id:Int){user(id: $id){login}}`},
+		{queryOperation, "GetUser", "", map[string]interface{}{"id": Int(2)}, `query GetUser($id:Int!){user(id: $id){login}}`},
+		{mutationOperation, "", "", map[string]interface{}{"id": Int(2)}, `mutation($id:Int!){user(id: $id){login}}`},
+		{queryOperation, "", "viewer", map[string]interface{}{"id": Int(2)}, `query($id:Int!){viewer{user(id: $id){login}}}`},
+		{queryOperation, "", "", map[string]interface{}{"id": Int(2), "a": String(""), "b": Boolean(false)}, `query($a:String!$b:Boolean!$id:Int!){user(id: $id){login}}`},
+	}
+	for _, tc := range tests {
+		for i := 0; i < 2; i++ {
+			got, err := operation(tc.op, tc.name, tc.fn, &q, tc.variables)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("\ngot:  %q\nwant: %q\n", got, tc.want)
+			}
+		}
+	}
+
+	// Errors are cached too.
+	for i := 0; i < 2; i++ {
+		if _, err := operation(queryOperation, "", "", &q, map[string]interface{}{"id": nil}); err == nil {
+			t.Error("got nil error, want non-nil")
+		}
+	}
+}
+
+func BenchmarkConstructQuery(b *testing.B) {
+	var q struct {
+		Repository struct {
+			Issues struct {
+				Nodes []struct {
+					Number Int
+					Title  String
+					Author struct {
+						Login String
+					}
+				}
+			} `graphql:"issues(first: $first)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+	variables := map[string]interface{}{"owner": String("o"), "name": String("n"), "first": Int(10)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		constructQuery(&q, variables)
+	}
+}
+
+// BenchmarkPrepareOperation measures what's done before sending each
+// operation: deriving its document and checking its variables.
+func BenchmarkPrepareOperation(b *testing.B) {
+	var q struct {
+		Repository struct {
+			Issues struct {
+				Nodes []struct {
+					Number Int
+					Title  String
+					Author struct {
+						Login String
+					}
+				}
+			} `graphql:"issues(first: $first)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+	variables := map[string]interface{}{"owner": String("o"), "name": String("n"), "first": Int(10)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		query, err := operation(queryOperation, "", "", &q, variables)
+		if err != nil {
+			b.Fatal(err)
+		}
+		variables, err := marshalVariables(variables)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := checkVariables(query, "", variables, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCheckVariables(b *testing.B) {
+	query := `query($owner:String!$name:String!$first:Int!){repository(owner:$owner,name:$name){issues(first:$first){nodes{number,title,author{login}}}}}`
+	variables := map[string]interface{}{"owner": "o", "name": "n", "first": 10}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := checkVariables(query, "", variables, true); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestConstructOperation_named(t *testing.T) {
+	type user struct {
+		User struct {
+			Name String
+		} `graphql:"user(id:$id)"`
+	}
+	tests := []struct {
+		op          operationType
+		name        string
+		inVariables map[string]interface{}
+		want        string
+	}{
+		{queryOperation, "GetUser", nil, `query GetUser{user(id:$id){name}}`},
+		{queryOperation, "GetUser", map[string]interface{}{"id": ID("1")}, `query GetUser($id:ID!){user(id:$id){name}}`},
+		{mutationOperation, "UpdateUser", nil, `mutation UpdateUser{user(id:$id){name}}`},
+		{mutationOperation, "UpdateUser", map[string]interface{}{"id": ID("1")}, `mutation UpdateUser($id:ID!){user(id:$id){name}}`},
+	}
+	for _, tc := range tests {
+		got := constructOperation(tc.op, tc.name, user{}, tc.inVariables)
+		if got != tc.want {
+			t.Errorf("\ngot:  %q\nwant: %q\n", got, tc.want)
+		}
+	}
+}
+
 func TestQueryArguments(t *testing.T) {
 	tests := []struct {
 		in   map[string]interface{}
@@ -313,6 +628,45 @@ func TestQueryArguments(t *testing.T) {
 			in:   map[string]interface{}{"ids": &[]ID{"someID", "anotherID"}},
 			want: `$ids:[ID!]`,
 		},
+		{
+			in: map[string]interface{}{
+				"file":     Upload{},
+				"optional": (*Upload)(nil),
+				"reader":   strings.NewReader(""),
+				"files":    []Upload{},
+			},
+			want: `$file:Upload!$files:[Upload!]!$optional:Upload$reader:Upload!`,
+		},
+		{
+			in: map[string]interface{}{
+				"first":  10,
+				"after":  (*string)(nil),
+				"ratio":  0.5,
+				"draft":  new(bool),
+				"counts": []int64{1, 2},
+				"titles": []*String{NewString("a")},
+			},
+			want: `$after:ID$counts:[Int!]!$draft:Boolean$first:Int!$ratio:Float!$titles:[String]!`,
+		},
+		{
+			in: map[string]interface{}{
+				"input":  newStarInput{},
+				"inputs": []*newStarInput{},
+				"comment": struct {
+					_    struct{} `graphql:"AddCommentInput"`
+					Body String
+				}{},
+			},
+			want: `$comment:AddCommentInput!$input:AddStarInput!$inputs:[AddStarInput]!`,
+		},
+		{
+			in: map[string]interface{}{
+				"title":  NewOptional(String("a")),
+				"labels": Null[[]ID](),
+				"state":  Optional[IssueState]{},
+			},
+			want: `$labels:[ID!]$state:IssueState$title:String`,
+		},
 	}
 	for i, tc := range tests {
 		got := queryArguments(tc.in)
@@ -333,6 +687,13 @@ type (
 
 func (u *URI) UnmarshalJSON(data []byte) error { panic("mock implementation") }
 
+// newStarInput is an input object whose Go and GraphQL type names differ.
+type newStarInput struct {
+	StarrableID ID `json:"starrableId"`
+}
+
+func (newStarInput) GraphQLType() string { return "AddStarInput" }
+
 // IssueState represents the possible states of an issue.
 type IssueState string
 
@@ -365,3 +726,72 @@ type AddReactionInput struct {
 	// A unique identifier for the client performing the mutation. (Optional.)
 	ClientMutationID *String `json:"clientMutationId,omitempty"`
 }
+
+func TestApplyDirectives(t *testing.T) {
+	tests := []struct {
+		query      string
+		directives []RequestOption
+		want       string
+	}{
+		{
+			query:      `{viewer{login}}`,
+			directives: []RequestOption{WithOperationDirective("live", nil)},
+			want:       `query@live{viewer{login}}`,
+		},
+		{
+			query: `query GetIssues($first:Int!){repo: repository(name: "graphql"){issues(first:$first){nodes{title,body@include(if: $full)}}}}`,
+			directives: []RequestOption{
+				WithOperationDirective("cached", map[string]interface{}{"ttl": 60, "scope": IssueStateOpen}),
+				WithFieldDirective("repo.issues", "stream", map[string]interface{}{"initialCount": Variable("first"), "label": "issues \"list\""}),
+				WithFieldDirective("repo.issues.nodes.body", "deprecated", nil),
+				WithFieldDirective("repo", "tags", map[string]interface{}{"names": []string{"a", "b"}, "filter": map[string]interface{}{"enabled": true, "ratio": 0.5}, "owner": nil}),
+			},
+			want: `query GetIssues($first:Int!)@cached(scope:OPEN,ttl:60){repo: repository(name: "graphql")@tags(filter:{enabled:true,ratio:0.5},names:["a","b"],owner:null){issues(first:$first)@stream(initialCount:$first,label:"issues \"list\""){nodes{title,body@include(if: $full)@deprecated}}}}`,
+		},
+	}
+	for _, tc := range tests {
+		got, err := newRequestOptions(tc.directives).document(tc.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("\ngot:  %s\nwant: %s", got, tc.want)
+		}
+	}
+
+	_, err := newRequestOptions([]RequestOption{WithFieldDirective("viewer.name", "live", nil)}).document(`{viewer{login}}`)
+	if got, want := fmt.Sprint(err), `graphql: no field at "viewer.name" to apply @live to`; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+}
+
+func TestWithFieldArguments(t *testing.T) {
+	type IssueFilters struct {
+		States   []IssueState     `json:"states"`
+		Assignee Optional[String] `json:"assignee"`
+		Since    time.Time        `json:"since"`
+	}
+	opts := []RequestOption{
+		WithFieldArguments("repository", map[string]interface{}{"owner": "o", "name": Variable("name")}),
+		WithFieldArguments("repository.issues", map[string]interface{}{
+			"orderBy":  map[string]interface{}{"field": ReactionContent("CREATED_AT"), "direction": ReactionContent("DESC")},
+			"filterBy": IssueFilters{States: []IssueState{IssueStateOpen}, Since: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+			"labels":   []String{"bug", "say \"hi\""},
+			"after":    Optional[String]{},
+			"before":   Null[String](),
+		}),
+		WithFieldDirective("repository.issues", "stream", nil),
+	}
+	got, err := newRequestOptions(opts).document(`query($name:String!){repository{issues(first: 2){totalCount}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `query($name:String!){repository(name:$name,owner:"o"){issues(first: 2,before:null,filterBy:{since:"2024-01-02T00:00:00Z",states:[OPEN]},labels:["bug","say \"hi\""],orderBy:{direction:DESC,field:CREATED_AT})@stream{totalCount}}}`; got != want {
+		t.Errorf("\ngot:  %s\nwant: %s", got, want)
+	}
+
+	_, err = newRequestOptions([]RequestOption{WithFieldArguments("viewer.name", map[string]interface{}{"x": 1})}).document(`{viewer{login}}`)
+	if got, want := fmt.Sprint(err), `graphql: no field at "viewer.name" to give arguments to`; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+}
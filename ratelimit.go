@@ -0,0 +1,53 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter paces the requests of a client, as set by WithRateLimit.
+// *rate.Limiter of package golang.org/x/time/rate implements it.
+type RateLimiter interface {
+	// Wait blocks until a request may be sent, or until ctx is done,
+	// in which case it returns an error.
+	Wait(ctx context.Context) error
+}
+
+// rateLimiter is the RateLimiter returned by NewRateLimiter.
+type rateLimiter struct {
+	interval time.Duration // Time between requests, at the sustained rate.
+	burst    int
+
+	mu   sync.Mutex
+	next time.Time // When the next request would be sent, without bursts.
+}
+
+// NewRateLimiter returns a RateLimiter letting through r requests per
+// second on average, in bursts of up to burst requests. Requests are let
+// through in the order Wait is called, so that goroutines waiting
+// concurrently get their fair share, and none starves.
+func NewRateLimiter(r float64, burst int) RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / r), burst: burst}
+}
+
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	t := l.next
+	if t.Before(now) {
+		t = now
+	}
+	// Reserve the next slot, which may be taken early by up to
+	// burst-1 intervals.
+	wait := t.Sub(now) - time.Duration(l.burst-1)*l.interval
+	l.next = t.Add(l.interval)
+	l.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	return sleep(ctx, wait)
+}
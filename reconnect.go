@@ -0,0 +1,115 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ReconnectPolicy configures how WebSocket subscriptions are resumed after
+// their connection drops, as set by WithSubscriptionReconnect.
+// The zero value of each field selects a reasonable default.
+type ReconnectPolicy struct {
+	// MaxAttempts is the number of times in a row a subscription is
+	// started again without receiving any events, before giving up
+	// and delivering the error that ended it. Defaults to 5.
+	MaxAttempts int
+
+	// MinBackoff is the delay before the first attempt.
+	// Defaults to 1 second.
+	MinBackoff time.Duration
+
+	// MaxBackoff caps the delay, which doubles with every attempt.
+	// Defaults to 30 seconds.
+	MaxBackoff time.Duration
+
+	// Resume, if non-nil, returns the variables to start a subscription
+	// again with, given those it was last started with and the last event
+	// received, or nil if none was. It lets servers resume the stream of
+	// events where it left off, such as given a resume token or the cursor
+	// of the last event as a variable, so that no events are lost.
+	// The variables are encoded as sent, with input objects as maps.
+	Resume func(variables map[string]interface{}, last *SubscriptionMessage) map[string]interface{}
+}
+
+func (p *ReconnectPolicy) backoff(attempt int) time.Duration {
+	d := defaultDuration(p.MinBackoff, time.Second)
+	max := defaultDuration(p.MaxBackoff, 30*time.Second)
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// subscribeWSResumable starts the subscription req over a WebSocket
+// connection, starting it again, per the client's reconnect policy,
+// whenever its connection fails.
+func (c *Client) subscribeWSResumable(ctx context.Context, req Request, header http.Header) (<-chan SubscriptionMessage, error) {
+	p := c.reconnect
+	subscribe := func() (<-chan SubscriptionMessage, error) {
+		payload, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		return c.subscribeWS(ctx, payload, header)
+	}
+	in, err := subscribe()
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan SubscriptionMessage)
+	go func() {
+		defer close(out)
+		var (
+			last     *SubscriptionMessage
+			attempts int
+		)
+		for {
+			var dropped error
+			for msg := range in {
+				if msg.Err != nil && !errors.Is(msg.Err, ErrSlowConsumer) {
+					// The connection failed; it's the last message.
+					dropped = msg.Err
+					continue
+				}
+				select {
+				case out <- msg:
+				case <-ctx.Done():
+					return
+				}
+				msg := msg
+				last, attempts = &msg, 0
+			}
+			if dropped == nil || ctx.Err() != nil {
+				// The subscription ended, or was stopped.
+				return
+			}
+			for {
+				if attempts == defaultInt(p.MaxAttempts, 5) {
+					select {
+					case out <- SubscriptionMessage{Err: dropped}:
+					case <-ctx.Done():
+					}
+					return
+				}
+				attempts++
+				if sleep(ctx, p.backoff(attempts)) != nil {
+					return
+				}
+				if p.Resume != nil {
+					req.Variables = p.Resume(req.Variables, last)
+				}
+				if in, err = subscribe(); err == nil {
+					break
+				}
+				dropped = err
+			}
+		}
+	}()
+	return out, nil
+}
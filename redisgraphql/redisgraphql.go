@@ -0,0 +1,69 @@
+// Package redisgraphql provides a Redis-backed store for the response
+// caches of GraphQL clients of package graphql, so that cached responses
+// are shared by all instances of a service and survive restarts.
+//
+// Create a Store and pass it to graphql.ResponseCache:
+//
+//	rdb := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+//	client := graphql.NewClient(url)
+//	client.Use(graphql.ResponseCache(graphql.ResponseCachePolicy{
+//		Store: redisgraphql.NewStore(rdb),
+//		TTL:   time.Minute,
+//	}))
+package redisgraphql
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	graphql "github.com/nobody05/graphql_go_client"
+	"github.com/redis/go-redis/v9"
+)
+
+// Store is a graphql.ResponseStore storing entries in Redis,
+// which expires them after their TTL.
+type Store struct {
+	client redis.Cmdable
+	prefix string
+}
+
+var _ graphql.ResponseStore = (*Store)(nil)
+
+// Option configures a Store.
+type Option func(*Store)
+
+// WithPrefix sets the prefix of the keys of entries in Redis,
+// which defaults to "graphql:".
+func WithPrefix(prefix string) Option {
+	return func(s *Store) {
+		s.prefix = prefix
+	}
+}
+
+// NewStore returns a Store using client, which may be
+// a *redis.Client, *redis.ClusterClient or the like.
+func NewStore(client redis.Cmdable, opts ...Option) *Store {
+	s := &Store{client: client, prefix: "graphql:"}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Get implements graphql.ResponseStore.
+func (s *Store) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	b, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+// Set implements graphql.ResponseStore.
+func (s *Store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, s.prefix+key, value, ttl).Err()
+}
@@ -0,0 +1,76 @@
+package redisgraphql_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	graphql "github.com/nobody05/graphql_go_client"
+	"github.com/nobody05/graphql_go_client/redisgraphql"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	calls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"data": {"viewer": {"login": "gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+	client.Use(graphql.ResponseCache(graphql.ResponseCachePolicy{
+		Store: redisgraphql.NewStore(rdb, redisgraphql.WithPrefix("test:")),
+		TTL:   time.Minute,
+	}))
+
+	var q struct {
+		Viewer struct {
+			Login graphql.String
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := client.NamedQuery(context.Background(), "GetViewer", &q, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := calls, 1; got != want {
+		t.Errorf("got calls: %v, want: %v", got, want)
+	}
+	if got, want := q.Viewer.Login, graphql.String("gopher"); got != want {
+		t.Errorf("got login: %q, want: %q", got, want)
+	}
+	keys := mr.Keys()
+	if len(keys) != 1 || keys[0][:5] != "test:" {
+		t.Fatalf("got keys: %q, want: one with prefix \"test:\"", keys)
+	}
+	if ttl := mr.TTL(keys[0]); ttl != time.Minute {
+		t.Errorf("got TTL: %v, want: %v", ttl, time.Minute)
+	}
+
+	mr.FastForward(2 * time.Minute) // Expire the entry.
+	if err := client.NamedQuery(context.Background(), "GetViewer", &q, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := calls, 2; got != want {
+		t.Errorf("got calls: %v, want: %v", got, want)
+	}
+}
+
+type localRoundTripper struct {
+	handler http.Handler
+}
+
+func (l localRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := httptest.NewRecorder()
+	l.handler.ServeHTTP(w, req)
+	return w.Result(), nil
+}
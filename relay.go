@@ -0,0 +1,41 @@
+package graphql
+
+// PageInfo is the pageInfo of a Relay-style connection, telling whether
+// there are more pages, and the cursors to fetch them with.
+//
+// Specification: https://relay.dev/graphql/connections.htm#sec-undefined.PageInfo.
+type PageInfo struct {
+	HasNextPage     Boolean `graphql:"hasNextPage"`
+	HasPreviousPage Boolean `graphql:"hasPreviousPage"`
+	StartCursor     *String `graphql:"startCursor"`
+	EndCursor       *String `graphql:"endCursor"`
+}
+
+// Edge is an edge of a Relay-style connection, holding a node of type T.
+// T is a struct selecting the fields of the node, like a query.
+type Edge[T any] struct {
+	Cursor String `graphql:"cursor"`
+	Node   T      `graphql:"node"`
+}
+
+// Connection is a Relay-style connection to nodes of type T, such as:
+//
+//	Issues graphql.Connection[Issue] `graphql:"issues(first:100,after:$after)"`
+//
+// which selects edges{cursor,node{...}} and pageInfo{...}. It can be
+// paginated through with Paginate.
+//
+// Specification: https://relay.dev/graphql/connections.htm.
+type Connection[T any] struct {
+	Edges    []Edge[T] `graphql:"edges"`
+	PageInfo PageInfo  `graphql:"pageInfo"`
+}
+
+// Nodes returns the nodes of the edges of c.
+func (c Connection[T]) Nodes() []T {
+	nodes := make([]T, len(c.Edges))
+	for i, e := range c.Edges {
+		nodes[i] = e.Node
+	}
+	return nodes
+}
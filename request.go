@@ -0,0 +1,84 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// NewRequest returns a request for the operation of the GraphQL document
+// query, to be executed by Client.Do. The methods of Request set its other
+// parameters, and return it, so that calls can be chained:
+//
+//	req := graphql.NewRequest(`query($id: ID!) { node(id: $id) { id } }`).
+//		Var("id", id).
+//		SetHeader("Authorization", "Bearer "+token)
+func NewRequest(query string) *Request {
+	return &Request{Query: query}
+}
+
+// Var sets the variable name to value, encoded as variables given to Query
+// are, and returns r.
+func (r *Request) Var(name string, value interface{}) *Request {
+	if r.Variables == nil {
+		r.Variables = make(map[string]interface{})
+	}
+	r.Variables[name] = value
+	return r
+}
+
+// Operation sets the name of the operation of the document to execute,
+// for documents with several, and returns r.
+func (r *Request) Operation(name string) *Request {
+	r.OperationName = name
+	return r
+}
+
+// SetHeader sets the HTTP header key to value, and returns r.
+func (r *Request) SetHeader(key, value string) *Request {
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+	r.Header.Set(key, value)
+	return r
+}
+
+// File sets the variable name to the file to upload f, of the Upload scalar
+// type, and returns r. The request is then sent as multipart/form-data.
+func (r *Request) File(name string, f Upload) *Request {
+	return r.Var(name, f)
+}
+
+// Do executes req, a request built by hand, such as by NewRequest, rather
+// than derived from a struct, through the client's middleware and transport,
+// like the other methods of Client. The response, if any, is stored in resp,
+// unless it's nil, and GraphQL errors in it are returned as per the client's
+// error policy.
+//
+// The headers of req are sent in addition to the client's. Documents whose
+// operation type can't be told, as when only DocumentID is given, are handled
+// like mutations, so that they're never cached or retried. Subscriptions are
+// started by Subscribe instead.
+func (c *Client) Do(ctx context.Context, req *Request, resp *Response) error {
+	var op operationType
+	switch req.OperationType() {
+	case "query":
+		op = queryOperation
+	case "subscription":
+		return fmt.Errorf("graphql: subscriptions can't be executed by Do")
+	default:
+		op = mutationOperation
+	}
+	o := &requestOptions{
+		header:        req.Header,
+		operationName: req.OperationName,
+		response:      resp,
+		documentID:    req.DocumentID,
+		extensions:    req.Extensions,
+		idempotent:    req.idempotent,
+	}
+	return c.execute(ctx, op, req.Query, req.Variables, o, func(json.RawMessage) error {
+		return nil
+	})
+}
@@ -0,0 +1,94 @@
+package graphql
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+)
+
+// DefaultRequestIDHeader is the header that WithRequestID sends
+// request IDs in, if given an empty name.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// WithRequestID makes the client send an ID identifying each operation
+// in the HTTP header with the given name, or DefaultRequestIDHeader if
+// it's empty, so that the logs of the client and the server can be
+// correlated. The ID is that given via WithHeader for the request, if any,
+// or else that carried by the context via ContextWithRequestID, as when
+// propagating the ID of an incoming request, or else a random one. It's the
+// same for all the HTTP requests retried or hedged for the operation, and is
+// available to middleware and transports as CallInfo.RequestID.
+//
+// Errors returned by operations are then *RequestIDError, wrapping the
+// error and giving the ID. Operations batched together are sent in a single
+// HTTP request, with the IDs of all of them, separated by commas.
+func WithRequestID(header string) ClientOption {
+	return func(c *Client) {
+		if header == "" {
+			header = DefaultRequestIDHeader
+		}
+		c.requestIDHeader = http.CanonicalHeaderKey(header)
+	}
+}
+
+// requestIDKey is the context key for the ID set by ContextWithRequestID.
+type requestIDKey struct{}
+
+// ContextWithRequestID returns ctx carrying id, which operations executed
+// with it send as their ID, if the client is configured by WithRequestID.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDError is the error returned by operations of clients configured
+// by WithRequestID, giving the ID of the operation that failed.
+type RequestIDError struct {
+	RequestID string
+	Err       error
+}
+
+// Error implements error interface.
+func (e *RequestIDError) Error() string {
+	return fmt.Sprintf("%v (request ID %s)", e.Err, e.RequestID)
+}
+
+// Unwrap returns the error wrapped by e, such as an *HTTPError or Errors.
+func (e *RequestIDError) Unwrap() error {
+	return e.Err
+}
+
+// requestID returns the ID of an operation sent with the headers given per
+// request, executed with ctx, per WithRequestID, or "" if the client isn't
+// configured by it.
+func (c *Client) requestID(ctx context.Context, header http.Header) string {
+	if c.requestIDHeader == "" {
+		return ""
+	}
+	if id := header.Get(c.requestIDHeader); id != "" {
+		return id
+	}
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok && id != "" {
+		return id
+	}
+	return randomID()
+}
+
+// randomID returns a random ID, of 128 bits in hex, such as a request ID.
+func randomID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// setRequestID sets the request ID header of req to the ID of the operation
+// that ctx carries the CallInfo of, unless it's set already.
+func (c *Client) setRequestID(ctx context.Context, req *http.Request) {
+	if c.requestIDHeader == "" || req.Header.Get(c.requestIDHeader) != "" {
+		return
+	}
+	if info, ok := CallInfoFromContext(ctx); ok && info.RequestID != "" {
+		req.Header.Set(c.requestIDHeader, info.RequestID)
+	}
+}
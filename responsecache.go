@@ -0,0 +1,200 @@
+package graphql
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// ResponseStore stores the entries of a response cache, as used by
+// ResponseCache. Implementations must be safe for concurrent use.
+// NewMemoryStore returns one storing entries in memory; package
+// redisgraphql provides one storing them in Redis.
+type ResponseStore interface {
+	// Get returns the entry stored under key, and whether there is one.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key for at least ttl, after which it may be
+	// dropped.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// ResponseCachePolicy configures a response cache created by ResponseCache.
+type ResponseCachePolicy struct {
+	// Store stores the cached responses.
+	// Defaults to NewMemoryStore(1000).
+	Store ResponseStore
+
+	// TTL is how long responses are fresh, served from the cache without
+	// contacting the server. Defaults to 1 minute.
+	TTL time.Duration
+
+	// StaleWhileRevalidate is how long responses remain in the cache after
+	// they're no longer fresh. A stale response is served from the cache
+	// while the query is sent to the server in the background,
+	// refreshing the cache for subsequent queries.
+	StaleWhileRevalidate time.Duration
+}
+
+// ResponseCache returns middleware caching whole responses to queries,
+// keyed by their document, variables and operation name, according to
+// policy. Unlike the normalized cache set by WithCache, a query is served
+// from the cache only if the very same query was sent before.
+//
+// Responses with errors aren't cached, and neither are the responses to
+// mutations and subscriptions, nor to queries identified by document ID
+// only. Errors from the store are ignored, with queries sent to the
+// server as if there were no cache.
+//
+// Responses are cached regardless of headers, so a cache shouldn't be
+// shared by requests made on behalf of different users.
+func ResponseCache(policy ResponseCachePolicy) Middleware {
+	if policy.Store == nil {
+		policy.Store = NewMemoryStore(1000)
+	}
+	if policy.TTL <= 0 {
+		policy.TTL = time.Minute
+	}
+	var refreshing sync.Map // Keys of the responses being refreshed.
+	return func(next Doer) Doer {
+		return DoerFunc(func(ctx context.Context, req *Request) (*Response, error) {
+			if req.Query == "" || req.OperationType() != "query" || req.list != nil || req.incremental != nil {
+				return next.Do(ctx, req)
+			}
+			key, err := responseCacheKey(req)
+			if err != nil {
+				return next.Do(ctx, req)
+			}
+			fetch := func(ctx context.Context) (*Response, error) {
+				resp, err := next.Do(ctx, req)
+				if err == nil && len(resp.Errors) == 0 {
+					if b, err := json.Marshal(responseCacheEntry{Response: resp, Fetched: time.Now()}); err == nil {
+						_ = policy.Store.Set(ctx, key, b, policy.TTL+policy.StaleWhileRevalidate)
+					}
+				}
+				return resp, err
+			}
+
+			b, ok, err := policy.Store.Get(ctx, key)
+			if err != nil || !ok {
+				return fetch(ctx)
+			}
+			var entry responseCacheEntry
+			if err := json.Unmarshal(b, &entry); err != nil || entry.Response == nil {
+				return fetch(ctx)
+			}
+			switch age := time.Since(entry.Fetched); {
+			case age < policy.TTL:
+				return entry.Response, nil
+			case age < policy.TTL+policy.StaleWhileRevalidate:
+				if _, ok := refreshing.LoadOrStore(key, true); !ok {
+					go func() {
+						defer refreshing.Delete(key)
+						ctx, cancel := withoutCancel(ctx)
+						defer cancel()
+						_, _ = fetch(ctx)
+					}()
+				}
+				return entry.Response, nil
+			}
+			return fetch(ctx)
+		})
+	}
+}
+
+// responseCacheEntry is a cached response, as stored in a ResponseStore.
+type responseCacheEntry struct {
+	Response *Response `json:"response"`
+	Fetched  time.Time `json:"fetched"`
+}
+
+// responseCacheKey returns the key of the response to req in a response
+// cache: a hash of its document, variables and operation name.
+func responseCacheKey(req *Request) (string, error) {
+	b, err := json.Marshal(struct {
+		Query         string                 `json:"query"`
+		Variables     map[string]interface{} `json:"variables"`
+		OperationName string                 `json:"operationName"`
+	}{req.Query, req.Variables, req.OperationName})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// withoutCancel returns a context that isn't canceled along with ctx,
+// for work outliving the operation, bounded by the time ctx had left
+// before its deadline, if any.
+func withoutCancel(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	ctx = context.WithoutCancel(ctx)
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Until(deadline))
+}
+
+// memoryStore is the ResponseStore returned by NewMemoryStore.
+type memoryStore struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // Elements of lru, by key.
+	lru     *list.List               // Of *memoryEntry, most recently used first.
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryStore returns a ResponseStore storing up to maxEntries entries
+// in memory, evicting the least recently used ones to make room for new
+// ones. If maxEntries is 0, the number of entries isn't limited.
+func NewMemoryStore(maxEntries int) ResponseStore {
+	return &memoryStore{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+func (s *memoryStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := e.Value.(*memoryEntry)
+	if time.Now().After(entry.expires) {
+		s.lru.Remove(e)
+		delete(s.entries, key)
+		return nil, false, nil
+	}
+	s.lru.MoveToFront(e)
+	return entry.value, true, nil
+}
+
+func (s *memoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := &memoryEntry{key: key, value: value, expires: time.Now().Add(ttl)}
+	if e, ok := s.entries[key]; ok {
+		e.Value = entry
+		s.lru.MoveToFront(e)
+		return nil
+	}
+	s.entries[key] = s.lru.PushFront(entry)
+	if s.maxEntries > 0 && s.lru.Len() > s.maxEntries {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryEntry).key)
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryPolicy configures automatic retries of failed requests.
+// The zero value of each field selects a reasonable default.
+//
+// Only queries are retried by default. A mutation that failed with
+// a network error or a status code such as 502 may still have been
+// executed by the server, so retrying it could apply it twice.
+// Unless RetryMutations is set, mutations are only retried when the
+// server responds with 429 Too Many Requests and a Retry-After header,
+// as requests turned away by rate limiting weren't executed.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	// Defaults to 3.
+	MaxAttempts int
+
+	// MinBackoff is the delay before the first retry.
+	// Defaults to 100 milliseconds.
+	MinBackoff time.Duration
+
+	// MaxBackoff caps the delay between attempts.
+	// Defaults to 10 seconds.
+	MaxBackoff time.Duration
+
+	// Backoff, if non-nil, computes the delay before the given retry,
+	// where retry is 1 for the first retry. By default, the delay starts at
+	// MinBackoff and doubles on every retry, up to MaxBackoff.
+	Backoff func(retry int) time.Duration
+
+	// Jitter is the fraction, between 0 and 1, by which each delay is
+	// randomly reduced, so that many clients don't retry in lockstep.
+	Jitter float64
+
+	// RetryableStatusCodes are the HTTP status codes to retry.
+	// Defaults to 502, 503 and 504.
+	RetryableStatusCodes []int
+
+	// RetryNetworkErrors enables retrying requests that fail
+	// due to network errors, such as connection resets.
+	RetryNetworkErrors bool
+
+	// RespectRetryAfter enables retrying responses with status code
+	// 429 Too Many Requests that carry a Retry-After header, after waiting
+	// as long as the header asks. The header also takes precedence over
+	// the computed backoff for other retryable responses. A retry is not
+	// attempted if the wait would exceed the context's deadline,
+	// or MaxRetryAfter if that's set.
+	RespectRetryAfter bool
+
+	// MaxRetryAfter, if positive, is the longest Retry-After wait
+	// that will be honored.
+	MaxRetryAfter time.Duration
+
+	// RetryMutations enables retrying mutations like queries,
+	// for servers whose mutations are idempotent, such as
+	// those deduplicating requests by idempotency key.
+	// Individual operations can be marked as idempotent, or not,
+	// with WithIdempotent instead.
+	RetryMutations bool
+
+	// Budget, if non-nil, limits the retries of the client as a whole,
+	// so that an outage of the server doesn't lead to a storm of retries
+	// adding to its load. Retries beyond the budget aren't attempted.
+	Budget *RetryBudget
+
+	budget *retryBudget // State of Budget, per client.
+}
+
+// RetryBudget limits the number of retries relative to the number of
+// requests, over a sliding window of time, as set in RetryPolicy.
+// The zero value of each field selects a reasonable default.
+type RetryBudget struct {
+	// Ratio is the maximum number of retries per request sent, such as
+	// 0.1 for at most one retry per 10 requests. Defaults to 0.1.
+	Ratio float64
+
+	// MinRetriesPerSecond is a number of retries allowed per second
+	// on top of Ratio, so that clients sending few requests can still
+	// retry them. Defaults to 10.
+	MinRetriesPerSecond int
+
+	// Window is the period over which requests and retries are counted.
+	// Defaults to 10 seconds.
+	Window time.Duration
+}
+
+// retryBudgetBuckets is the number of buckets a retry budget's window
+// is split into.
+const retryBudgetBuckets = 10
+
+// retryBudget enforces a RetryBudget, counting requests and retries
+// in buckets spanning its window.
+type retryBudget struct {
+	config RetryBudget
+
+	mu      sync.Mutex
+	buckets [retryBudgetBuckets]retryBudgetBucket
+}
+
+// retryBudgetBucket counts the requests and retries made in a period.
+type retryBudgetBucket struct {
+	period            int64 // Index of the period since the Unix epoch.
+	requests, retries int
+}
+
+// bucket returns the bucket for the current period, reset if it was last
+// used in an earlier one, along with the index of the current period.
+// b.mu must be held.
+func (b *retryBudget) bucket(now time.Time) (*retryBudgetBucket, int64) {
+	width := b.window() / retryBudgetBuckets
+	if width <= 0 {
+		// Windows under retryBudgetBuckets nanoseconds get 1ns buckets.
+		width = 1
+	}
+	period := now.UnixNano() / int64(width)
+	bucket := &b.buckets[period%retryBudgetBuckets]
+	if bucket.period != period {
+		*bucket = retryBudgetBucket{period: period}
+	}
+	return bucket, period
+}
+
+func (b *retryBudget) window() time.Duration {
+	return defaultDuration(b.config.Window, 10*time.Second)
+}
+
+// request records a request. b may be nil, in which case it does nothing.
+func (b *retryBudget) request() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bucket, _ := b.bucket(time.Now())
+	bucket.requests++
+}
+
+// retry reports whether a retry is within the budget,
+// and records it if so. b may be nil, in which case it always is.
+func (b *retryBudget) retry() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	current, period := b.bucket(time.Now())
+	var requests, retries int
+	for i := range b.buckets {
+		if bucket := &b.buckets[i]; period-bucket.period < retryBudgetBuckets {
+			requests += bucket.requests
+			retries += bucket.retries
+		}
+	}
+	ratio := b.config.Ratio
+	if ratio <= 0 {
+		ratio = 0.1
+	}
+	allowed := ratio*float64(requests) + float64(defaultInt(b.config.MinRetriesPerSecond, 10))*b.window().Seconds()
+	if float64(retries) >= allowed {
+		return false
+	}
+	current.retries++
+	return true
+}
+
+// defaultRetryableStatusCodes are the HTTP status codes retried by default.
+var defaultRetryableStatusCodes = []int{
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// next reports whether an attempt that produced resp and err should be
+// retried, given it was attempt number attempt, and how long to wait first.
+// idempotent reports whether the request is safe to retry, as when it
+// holds queries only.
+// p may be nil, in which case nothing is retried.
+func (p *RetryPolicy) next(ctx context.Context, attempt int, idempotent bool, resp *http.Response, err error) (time.Duration, bool) {
+	if p == nil {
+		return 0, false
+	}
+	maxAttempts := p.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = 3
+	}
+	if attempt >= maxAttempts {
+		return 0, false
+	}
+	if !idempotent && !p.RetryMutations {
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+			return 0, false
+		}
+		if _, ok := retryAfter(resp); !ok {
+			return 0, false
+		}
+	}
+	if err != nil {
+		return p.backoff(attempt), p.RetryNetworkErrors && isNetworkError(err)
+	}
+	if p.RespectRetryAfter {
+		if d, ok := retryAfter(resp); ok && (resp.StatusCode == http.StatusTooManyRequests || p.retryableStatus(resp.StatusCode)) {
+			if p.MaxRetryAfter > 0 && d > p.MaxRetryAfter {
+				return 0, false
+			}
+			if deadline, ok := ctx.Deadline(); ok && time.Now().Add(d).After(deadline) {
+				return 0, false
+			}
+			return d, true
+		}
+	}
+	return p.backoff(attempt), p.retryableStatus(resp.StatusCode)
+}
+
+// retryableStatus reports whether the HTTP status code should be retried.
+func (p *RetryPolicy) retryableStatus(code int) bool {
+	codes := p.RetryableStatusCodes
+	if codes == nil {
+		codes = defaultRetryableStatusCodes
+	}
+	for _, c := range codes {
+		if code == c {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns how long to wait before the given retry.
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	var d time.Duration
+	if p.Backoff != nil {
+		d = p.Backoff(retry)
+	} else {
+		min, max := p.MinBackoff, p.MaxBackoff
+		if min <= 0 {
+			min = 100 * time.Millisecond
+		}
+		if max <= 0 {
+			max = 10 * time.Second
+		}
+		d = min
+		for i := 1; i < retry && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+	}
+	if p.Jitter > 0 {
+		d -= time.Duration(p.Jitter * rand.Float64() * float64(d))
+	}
+	return d
+}
+
+// retryAfter parses the Retry-After header of resp, which is either
+// a number of seconds or an HTTP date, into a duration to wait.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	d := time.Until(t)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+// isNetworkError reports whether err was caused by the network,
+// as opposed to, for instance, an invalid request.
+func isNetworkError(err error) bool {
+	// *url.Error implements net.Error itself, so look inside it.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// sleep waits for d, or until ctx is done, in which case it returns ctx.Err().
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,5 +1,12 @@
 package graphql
 
+import (
+	"reflect"
+	"sync"
+
+	"github.com/nobody05/graphql_go_client/internal/jsonutil"
+)
+
 // Note: These custom types are meant to be used in queries for now.
 // But the plan is to switch to using native Go types (string, int, bool, time.Time, etc.).
 // See https://github.com/shurcooL/githubv4/issues/9 for details.
@@ -49,3 +56,43 @@ func NewInt(v Int) *Int { return &v }
 
 // NewString is a helper to make a new *String.
 func NewString(v String) *String { return &v }
+
+// scalars holds the custom scalars registered with RegisterScalar, by Go type.
+var scalars = struct {
+	sync.RWMutex
+	m map[reflect.Type]customScalar
+}{m: make(map[reflect.Type]customScalar)}
+
+// customScalar is a custom scalar registered with RegisterScalar.
+type customScalar struct {
+	name    string
+	marshal func(v interface{}) (interface{}, error)
+}
+
+// RegisterScalar registers goType as the custom GraphQL scalar name,
+// such as "UUID" or "DateTime", so that values of goType can be used
+// as variables and in queries without hand-rolled string fields.
+//
+// marshal returns the value to send as JSON for a variable of goType,
+// such as a string. unmarshal returns the value of goType for the JSON
+// encoding of a non-null response field. Either may be nil, in which case
+// the value is encoded or decoded with encoding/json as usual.
+//
+// Variables of goType are declared with the scalar's name, and fields of
+// goType are selected without a selection set. RegisterScalar is meant
+// to be called during initialization, such as from an init function.
+func RegisterScalar(goType reflect.Type, name string, marshal func(v interface{}) (interface{}, error), unmarshal func(data []byte) (interface{}, error)) {
+	scalars.Lock()
+	scalars.m[goType] = customScalar{name: name, marshal: marshal}
+	scalars.Unlock()
+	jsonutil.RegisterScalar(goType, unmarshal)
+	resetTypeCaches()
+}
+
+// lookupScalar returns the custom scalar registered for t, if any.
+func lookupScalar(t reflect.Type) (customScalar, bool) {
+	scalars.RLock()
+	s, ok := scalars.m[t]
+	scalars.RUnlock()
+	return s, ok
+}
@@ -1,7 +1,14 @@
 package graphql_test
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
 	"testing"
+
+	"github.com/nobody05/graphql_go_client"
 )
 
 func TestNewScalars(t *testing.T) {
@@ -26,3 +33,59 @@ func TestNewScalars(t *testing.T) {
 		t.Error("NewString returned nil")
 	}
 }
+
+// color is a custom scalar for testing, sent as a "#rrggbb" string.
+type color [3]byte
+
+func init() {
+	graphql.RegisterScalar(reflect.TypeOf(color{}), "HexColor",
+		func(v interface{}) (interface{}, error) {
+			c := v.(color)
+			return fmt.Sprintf("#%02x%02x%02x", c[0], c[1], c[2]), nil
+		},
+		func(data []byte) (interface{}, error) {
+			var s string
+			if err := json.Unmarshal(data, &s); err != nil {
+				return nil, err
+			}
+			var c color
+			_, err := fmt.Sscanf(s, "#%02x%02x%02x", &c[0], &c[1], &c[2])
+			return c, err
+		},
+	)
+}
+
+func TestRegisterScalar(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"mutation($color:HexColor!$fallbacks:[HexColor!]){setColor(color:$color,fallbacks:$fallbacks){color,previous}}","variables":{"color":"#00add8","fallbacks":["#ffffff"]}}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"setColor": {"color": "#00add8", "previous": null}}}`)
+	})
+	client := graphql.NewClient("/graphql", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+
+	var m struct {
+		SetColor struct {
+			Color    color
+			Previous *color
+		} `graphql:"setColor(color:$color,fallbacks:$fallbacks)"`
+	}
+	m.SetColor.Previous = &color{1, 2, 3}
+	variables := map[string]interface{}{
+		"color":     color{0x00, 0xad, 0xd8},
+		"fallbacks": &[]color{{0xff, 0xff, 0xff}},
+	}
+	err := client.Mutate(context.Background(), &m, variables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.SetColor.Color, (color{0x00, 0xad, 0xd8}); got != want {
+		t.Errorf("got color: %v, want: %v", got, want)
+	}
+	if got := m.SetColor.Previous; got != nil {
+		t.Errorf("got previous: %v, want: nil", *got)
+	}
+}
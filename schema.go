@@ -0,0 +1,194 @@
+package graphql
+
+import (
+	"fmt"
+
+	"github.com/nobody05/graphql_go_client/internal/parser"
+)
+
+// builtinScalars are the scalar types every schema has.
+var builtinScalars = []string{"Int", "Float", "String", "Boolean", "ID"}
+
+// ParseSchema parses a schema written in the GraphQL schema definition
+// language (SDL) into the model that Introspect returns. Type extensions
+// are merged into the types they extend, and the built-in scalars are
+// included even if not defined.
+func ParseSchema(sdl string) (*Schema, error) {
+	doc, err := parser.Parse(sdl)
+	if err != nil {
+		return nil, fmt.Errorf("graphql: parsing schema: %v", err)
+	}
+	if len(doc.Operations) > 0 || len(doc.Fragments) > 0 {
+		return nil, fmt.Errorf("graphql: parsing schema: operations and fragments aren't allowed in a schema")
+	}
+
+	// Merge extensions into the definitions they extend.
+	var defs []*parser.TypeDefinition
+	byName := make(map[string]*parser.TypeDefinition)
+	for _, name := range builtinScalars {
+		def := &parser.TypeDefinition{Kind: string(TypeKindScalar), Name: name}
+		defs = append(defs, def)
+		byName[name] = def
+	}
+	for _, def := range doc.Types {
+		prev, ok := byName[def.Name]
+		switch {
+		case ok && def.Extension:
+			if prev.Kind != def.Kind {
+				return nil, fmt.Errorf("graphql: parsing schema: extension of %s %s changes its kind", prev.Kind, def.Name)
+			}
+			prev.Directives = append(prev.Directives, def.Directives...)
+			prev.Interfaces = append(prev.Interfaces, def.Interfaces...)
+			prev.Fields = append(prev.Fields, def.Fields...)
+			prev.Types = append(prev.Types, def.Types...)
+			prev.EnumValues = append(prev.EnumValues, def.EnumValues...)
+			prev.InputFields = append(prev.InputFields, def.InputFields...)
+		case ok && !isBuiltinScalar(def.Name):
+			return nil, fmt.Errorf("graphql: parsing schema: type %s is defined more than once", def.Name)
+		case ok:
+			// Redefinition of a built-in scalar.
+		case def.Extension:
+			return nil, fmt.Errorf("graphql: parsing schema: extension of undefined type %s", def.Name)
+		default:
+			copy := *def
+			defs = append(defs, &copy)
+			byName[def.Name] = &copy
+		}
+	}
+
+	b := schemaBuilder{types: byName}
+	s := &Schema{}
+	for _, def := range defs {
+		t := Type{Kind: TypeKind(def.Kind), Name: def.Name, Description: def.Description}
+		for _, f := range def.Fields {
+			field := Field{Name: f.Name, Description: f.Description, Type: b.typeRef(f.Type)}
+			field.IsDeprecated, field.DeprecationReason = deprecation(f.Directives)
+			field.Args = b.inputValues(f.Arguments)
+			t.Fields = append(t.Fields, field)
+		}
+		t.InputFields = b.inputValues(def.InputFields)
+		for _, name := range def.Interfaces {
+			t.Interfaces = append(t.Interfaces, b.named(name))
+		}
+		for _, name := range def.Types {
+			t.PossibleTypes = append(t.PossibleTypes, b.named(name))
+		}
+		for _, v := range def.EnumValues {
+			value := EnumValue{Name: v.Name, Description: v.Description}
+			value.IsDeprecated, value.DeprecationReason = deprecation(v.Directives)
+			t.EnumValues = append(t.EnumValues, value)
+		}
+		s.Types = append(s.Types, t)
+	}
+	// Interfaces are possibly implemented by the objects implementing them.
+	for _, def := range defs {
+		for _, name := range def.Interfaces {
+			if i := s.Type(name); i != nil && def.Kind == string(TypeKindObject) {
+				i.PossibleTypes = append(i.PossibleTypes, b.named(def.Name))
+			}
+		}
+	}
+	for _, d := range doc.Directives {
+		s.Directives = append(s.Directives, Directive{
+			Name:        d.Name,
+			Description: d.Description,
+			Locations:   d.Locations,
+			Args:        b.inputValues(d.Arguments),
+		})
+	}
+
+	root := doc.Schema
+	if root == nil {
+		root = &parser.SchemaDefinition{Query: "Query", Mutation: "Mutation", Subscription: "Subscription"}
+	}
+	for _, r := range []struct {
+		name string
+		ref  **TypeRef
+	}{
+		{root.Query, &s.QueryType},
+		{root.Mutation, &s.MutationType},
+		{root.Subscription, &s.SubscriptionType},
+	} {
+		if _, ok := byName[r.name]; ok {
+			ref := b.named(r.name)
+			*r.ref = &ref
+		} else if doc.Schema != nil && r.name != "" {
+			b.errs = append(b.errs, fmt.Errorf("graphql: parsing schema: undefined root type %s", r.name))
+		}
+	}
+	if s.QueryType == nil && len(b.errs) == 0 {
+		b.errs = append(b.errs, fmt.Errorf("graphql: parsing schema: no query type"))
+	}
+	if len(b.errs) > 0 {
+		return nil, b.errs[0]
+	}
+	return s, nil
+}
+
+// schemaBuilder resolves the type references of a schema being parsed,
+// recording references to undefined types as errors.
+type schemaBuilder struct {
+	types map[string]*parser.TypeDefinition
+	errs  []error
+}
+
+func (b *schemaBuilder) named(name string) TypeRef {
+	def, ok := b.types[name]
+	if !ok {
+		b.errs = append(b.errs, fmt.Errorf("graphql: parsing schema: undefined type %s", name))
+		return TypeRef{Name: name}
+	}
+	return TypeRef{Kind: TypeKind(def.Kind), Name: name}
+}
+
+func (b *schemaBuilder) typeRef(t *parser.Type) TypeRef {
+	var r TypeRef
+	if t.Elem != nil {
+		elem := b.typeRef(t.Elem)
+		r = TypeRef{Kind: TypeKindList, OfType: &elem}
+	} else {
+		r = b.named(t.Name)
+	}
+	if t.NonNull {
+		elem := r
+		r = TypeRef{Kind: TypeKindNonNull, OfType: &elem}
+	}
+	return r
+}
+
+func (b *schemaBuilder) inputValues(defs []*parser.InputValueDefinition) []InputValue {
+	var values []InputValue
+	for _, d := range defs {
+		v := InputValue{Name: d.Name, Description: d.Description, Type: b.typeRef(d.Type)}
+		if d.DefaultValue != nil {
+			s := d.DefaultValue.String()
+			v.DefaultValue = &s
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// deprecation returns whether the @deprecated directive is among ds,
+// and its reason.
+func deprecation(ds []*parser.Directive) (bool, string) {
+	for _, d := range ds {
+		if d.Name != "deprecated" {
+			continue
+		}
+		if a := d.Argument("reason"); a != nil && a.Value.Kind == parser.StringValue {
+			return true, a.Value.Raw
+		}
+		return true, "No longer supported"
+	}
+	return false, ""
+}
+
+func isBuiltinScalar(name string) bool {
+	for _, s := range builtinScalars {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package graphql_test
+
+import (
+	"testing"
+
+	"github.com/nobody05/graphql_go_client"
+)
+
+func TestParseSchema(t *testing.T) {
+	schema, err := graphql.ParseSchema(`
+		schema { query: Root }
+		"The root."
+		type Root { node(id: ID!): Node, search(first: Int = 10): [Result!]! }
+		extend type Root { old: String @deprecated }
+		interface Node { id: ID! }
+		type User implements Node { id: ID! }
+		union Result = User
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := schema.QueryType.Name, "Root"; got != want {
+		t.Errorf("got query type: %q, want: %q", got, want)
+	}
+	if schema.MutationType != nil {
+		t.Errorf("got mutation type: %v, want: nil", schema.MutationType)
+	}
+	root := schema.Type("Root")
+	if got, want := root.Description, "The root."; got != want {
+		t.Errorf("got description: %q, want: %q", got, want)
+	}
+	search := root.Field("search")
+	if got, want := search.Type.String(), "[Result!]!"; got != want {
+		t.Errorf("got type of search: %q, want: %q", got, want)
+	}
+	if got, want := search.Type.OfType.OfType.OfType.Kind, graphql.TypeKindUnion; got != want {
+		t.Errorf("got kind of Result: %v, want: %v", got, want)
+	}
+	if got, want := *search.Args[0].DefaultValue, "10"; got != want {
+		t.Errorf("got default of first: %q, want: %q", got, want)
+	}
+	if old := root.Field("old"); old == nil || !old.IsDeprecated || old.DeprecationReason != "No longer supported" {
+		t.Errorf("got field old: %+v, want: deprecated", old)
+	}
+	if got := schema.Type("Node").PossibleTypes; len(got) != 1 || got[0].Name != "User" {
+		t.Errorf("got possible types of Node: %v, want: [User]", got)
+	}
+	if got, want := schema.Type("String").Kind, graphql.TypeKindScalar; got != want {
+		t.Errorf("got kind of String: %v, want: %v", got, want)
+	}
+
+	for _, tc := range []struct {
+		in   string
+		want string
+	}{
+		{`type Query { a: Missing }`, "graphql: parsing schema: undefined type Missing"},
+		{`type Query { a: Int } type Query { b: Int }`, "graphql: parsing schema: type Query is defined more than once"},
+		{`type Mutation { a: Int }`, "graphql: parsing schema: no query type"},
+		{`type Query { a: }`, "graphql: parsing schema: 1:17: expected name, found \"}\""},
+	} {
+		_, err := graphql.ParseSchema(tc.in)
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("%s: got error: %v, want: %s", tc.in, err, tc.want)
+		}
+	}
+}
+
+func TestSchema_Validate(t *testing.T) {
+	schema, err := graphql.ParseSchema(`
+		type Query {
+			repository(owner: String!, name: String!, first: Int = 10): Repository
+			search(filter: Filter, states: [State!]): [Result!]!
+		}
+		type Repository { name: String! stars: Int! }
+		type Issue { title: String! }
+		union Result = Repository | Issue
+		enum State { OPEN CLOSED }
+		input Filter { state: State!, label: String }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`query($owner: String!) { repository(owner: $owner, name: "n") { name } }`, ""},
+		{`{ search(filter: {state: OPEN}, states: OPEN) { __typename ... on Issue { title } } }`, ""},
+		{`query($name: String = "n") { repository(owner: "o", name: $name) { ...F } } fragment F on Repository { stars }`, ""},
+		{`{ repository(owner: "o", name: "n") { name, forks } }`, "graphql: invalid document: repository.forks: unknown field forks on type Repository"},
+		{`{ repository(owner: "o") { name } }`, "graphql: invalid document: repository: missing required argument name of field repository"},
+		{`{ repository(owner: "o", name: "n", last: 1) { name } }`, "graphql: invalid document: repository: unknown argument last of field repository"},
+		{`query($owner: ID!) { repository(owner: $owner, name: "n") { name } }`, "graphql: invalid document: repository: variable $owner of type ID! can't be given as argument owner of field repository of type String!"},
+		{`query($owner: String) { repository(owner: $owner, name: "n") { name } }`, "graphql: invalid document: repository: variable $owner of type String can't be given as argument owner of field repository of type String!"},
+		{`{ repository(owner: 1, name: "n") { name } }`, "graphql: invalid document: repository: 1 can't be given as argument owner of field repository of type String!"},
+		{`{ repository(owner: "o", name: $name) { name } }`, "graphql: invalid document: repository: undefined variable $name"},
+		{`{ search(filter: {label: "bug"}, states: [MERGED]) { __typename } }`, "graphql: invalid document: search: missing required field state of input object Filter; search: MERGED can't be given as argument states of field search of type State!"},
+		{`{ repository(owner: "o", name: "n") }`, "graphql: invalid document: repository: field repository of type Repository must have a selection set"},
+		{`{ repository(owner: "o", name: "n") { name { x } } }`, "graphql: invalid document: repository.name: field name of type String! can't have a selection set"},
+		{`{ search { title } }`, "graphql: invalid document: search.title: unknown field title on union Result, select it in an inline fragment"},
+		{`{ search { ... on User { name } ...G } }`, "graphql: invalid document: search: unknown type User; search: unknown fragment G"},
+		{`mutation { a }`, "graphql: invalid document: schema doesn't support mutation operations"},
+	}
+	for _, tc := range tests {
+		err := schema.Validate(tc.in)
+		var got string
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tc.want {
+			t.Errorf("%s:\ngot error: %v\nwant: %s", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestSchema_Deprecations(t *testing.T) {
+	schema, err := graphql.ParseSchema(`
+		type Query { viewer: User! }
+		type User {
+			login: String!
+			name: String! @deprecated(reason: "Use login.")
+			friends: [User!]! @deprecated
+		}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := schema.Deprecations(`{ viewer { login, name, pals: friends { name } } }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"viewer.name: field User.name is deprecated: Use login.",
+		"viewer.pals: field User.friends is deprecated: No longer supported",
+		"viewer.pals.name: field User.name is deprecated: Use login.",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got deprecations: %v, want: %v", got, want)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Errorf("got deprecation %d: %q, want: %q", i, got[i], want[i])
+		}
+	}
+	if _, err := schema.Deprecations(`{ viewer {`); err == nil {
+		t.Error("got error: nil, want: non-nil")
+	}
+}
@@ -0,0 +1,85 @@
+package graphql
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+)
+
+// WithCookieJar makes the client keep the cookies set by the server in jar,
+// and send them back with later requests, for servers authenticating
+// sessions with cookies, such as internal admin APIs behind a login
+// mutation. If jar is nil, a new in-memory jar is used. The cookies are
+// sent with the handshakes of WebSocket subscriptions too.
+//
+// The jar replaces that of the HTTP client given via WithHTTPClient, if any,
+// which is left unchanged.
+func WithCookieJar(jar http.CookieJar) ClientOption {
+	return func(c *Client) {
+		if jar == nil {
+			// cookiejar.New only fails given invalid options.
+			jar, _ = cookiejar.New(nil)
+		}
+		c.jar = jar
+	}
+}
+
+// WithCSRFToken makes the client send the value of the cookie with the given
+// name, as set by the server, in the header with the given name with each
+// request, as servers protecting sessions against cross-site request
+// forgery expect. The cookie is looked up in the cookie jar of the client,
+// set via WithCookieJar or that of the HTTP client; no header is sent until
+// the server sets it. Frameworks commonly use "csrftoken" and "X-CSRFToken",
+// as in Django, or "XSRF-TOKEN" and "X-XSRF-TOKEN".
+func WithCSRFToken(cookie, header string) ClientOption {
+	return func(c *Client) {
+		c.modifiers = append(c.modifiers, func(req *http.Request) error {
+			if token, ok := CSRFToken(c.httpClient.Jar, req.URL, cookie); ok {
+				req.Header.Set(header, token)
+			}
+			return nil
+		})
+	}
+}
+
+// CSRFToken returns the value of the cookie with the given name
+// that jar holds for u, reporting whether there was one, such as to send
+// it along with requests made to the server other than by a client.
+func CSRFToken(jar http.CookieJar, u *url.URL, cookie string) (string, bool) {
+	if jar == nil {
+		return "", false
+	}
+	for _, c := range jar.Cookies(u) {
+		if c.Name == cookie {
+			return c.Value, true
+		}
+	}
+	return "", false
+}
+
+// useCookieJar returns a copy of httpClient using jar.
+func useCookieJar(httpClient *http.Client, jar http.CookieJar) *http.Client {
+	hc := *httpClient
+	hc.Jar = jar
+	return &hc
+}
+
+// wsCookieHeader returns the value of the Cookie header to send with the
+// handshake of a WebSocket connection to u, with the cookies that jar
+// holds for the corresponding HTTP URL, or "" if there are none.
+func wsCookieHeader(jar http.CookieJar, u *url.URL) string {
+	if jar == nil {
+		return ""
+	}
+	httpURL := *u
+	httpURL.Scheme = "http"
+	if u.Scheme == "wss" {
+		httpURL.Scheme = "https"
+	}
+	var cookies []string
+	for _, c := range jar.Cookies(&httpURL) {
+		cookies = append(cookies, c.String())
+	}
+	return strings.Join(cookies, "; ")
+}
@@ -0,0 +1,186 @@
+// Package shopifygraphql provides cost-based throttling for GraphQL clients
+// of package graphql talking to the Shopify Admin GraphQL API, which limits
+// clients by the calculated cost of their queries, rather than by their
+// number, with a bucket of points restored at a steady rate.
+//
+// A Throttler paces the requests of a client so that they stay within the
+// bucket, and retries those throttled nonetheless, as suited for bulk data
+// sync jobs:
+//
+//	throttler := &shopifygraphql.Throttler{}
+//	client := graphql.NewClient("https://shop.myshopify.com/admin/api/2024-01/graphql.json",
+//		graphql.WithDefaultHeaders(http.Header{"X-Shopify-Access-Token": {token}}))
+//	client.Use(throttler.Middleware())
+package shopifygraphql
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sync"
+	"time"
+
+	graphql "github.com/nobody05/graphql_go_client"
+)
+
+// Cost is the cost of a query, as reported by Shopify in the "cost"
+// extension of responses.
+type Cost struct {
+	RequestedQueryCost float64        `json:"requestedQueryCost"`
+	ActualQueryCost    float64        `json:"actualQueryCost"`
+	ThrottleStatus     ThrottleStatus `json:"throttleStatus"`
+}
+
+// ThrottleStatus is the state of the bucket of points that the cost of
+// queries is deducted from.
+type ThrottleStatus struct {
+	MaximumAvailable   float64 `json:"maximumAvailable"`
+	CurrentlyAvailable float64 `json:"currentlyAvailable"`
+	RestoreRate        float64 `json:"restoreRate"` // Points restored per second.
+}
+
+// ParseCost returns the cost reported in the extensions of resp,
+// and whether there's one.
+func ParseCost(resp *graphql.Response) (Cost, bool) {
+	if resp == nil || resp.Extensions["cost"] == nil {
+		return Cost{}, false
+	}
+	b, err := json.Marshal(resp.Extensions["cost"])
+	if err != nil {
+		return Cost{}, false
+	}
+	var cost Cost
+	if err := json.Unmarshal(b, &cost); err != nil || cost.ThrottleStatus.RestoreRate <= 0 {
+		return Cost{}, false
+	}
+	return cost, true
+}
+
+// IsThrottled reports whether resp holds a THROTTLED error,
+// reporting that the query cost more points than were available.
+func IsThrottled(resp *graphql.Response) bool {
+	if resp == nil {
+		return false
+	}
+	for _, e := range resp.Errors {
+		if code, _ := e.Extensions["code"].(string); code == "THROTTLED" {
+			return true
+		}
+	}
+	return false
+}
+
+// Throttler paces the requests of a client by the cost of their queries,
+// waiting before each request until the bucket of points, as last reported
+// by Shopify and restored since, covers its cost. The cost of a document
+// is taken to be its requested cost when it was last sent. Its zero value
+// is ready to use. It's safe for concurrent use.
+type Throttler struct {
+	// DefaultCost is the cost assumed for documents that weren't sent yet.
+	// Defaults to 50.
+	DefaultCost float64
+
+	// MaxRetries is the maximum number of times a request is sent again
+	// after being throttled, once enough points are restored.
+	// Defaults to 3. A negative value disables retries.
+	MaxRetries int
+
+	mu      sync.Mutex
+	status  ThrottleStatus     // Last reported state of the bucket, with reservations deducted.
+	updated time.Time          // When status was last refilled, or the zero time if it's unknown.
+	costs   map[string]float64 // Requested costs of documents, by document.
+}
+
+// Middleware returns middleware pacing the requests of the client it's
+// added to, and retrying throttled ones, as configured by t.
+func (t *Throttler) Middleware() graphql.Middleware {
+	return func(next graphql.Doer) graphql.Doer {
+		return graphql.DoerFunc(func(ctx context.Context, req *graphql.Request) (*graphql.Response, error) {
+			maxRetries := t.MaxRetries
+			if maxRetries == 0 {
+				maxRetries = 3
+			}
+			for attempt := 0; ; attempt++ {
+				if err := sleep(ctx, t.reserve(req.Query)); err != nil {
+					return nil, err
+				}
+				resp, err := next.Do(ctx, req)
+				if err != nil {
+					return resp, err
+				}
+				t.update(req.Query, resp)
+				if !IsThrottled(resp) || attempt >= maxRetries {
+					return resp, nil
+				}
+			}
+		})
+	}
+}
+
+// reserve deducts the expected cost of document from the bucket,
+// and returns how long to wait for the bucket to cover it.
+func (t *Throttler) reserve(document string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.updated.IsZero() {
+		// Nothing's known about the bucket until the first response.
+		return 0
+	}
+	cost, ok := t.costs[document]
+	if !ok {
+		cost = t.DefaultCost
+		if cost <= 0 {
+			cost = 50
+		}
+	}
+	cost = math.Min(cost, t.status.MaximumAvailable)
+	t.refill(time.Now())
+	t.status.CurrentlyAvailable -= cost
+	if t.status.CurrentlyAvailable >= 0 {
+		return 0
+	}
+	// Wait until the points restored make up for the deficit,
+	// which includes the reservations of requests waiting already.
+	return time.Duration(-t.status.CurrentlyAvailable / t.status.RestoreRate * float64(time.Second))
+}
+
+// refill adds the points restored since the bucket was last refilled.
+// t.mu must be held.
+func (t *Throttler) refill(now time.Time) {
+	restored := now.Sub(t.updated).Seconds() * t.status.RestoreRate
+	t.status.CurrentlyAvailable = math.Min(t.status.CurrentlyAvailable+restored, t.status.MaximumAvailable)
+	t.updated = now
+}
+
+// update records the state of the bucket and the cost of document
+// reported in resp.
+func (t *Throttler) update(document string, resp *graphql.Response) {
+	cost, ok := ParseCost(resp)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.costs == nil {
+		t.costs = make(map[string]float64)
+	}
+	t.costs[document] = cost.RequestedQueryCost
+	// The reported state accounts for the requests completed so far,
+	// but not for those reserving points while waiting.
+	t.status, t.updated = cost.ThrottleStatus, time.Now()
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,64 @@
+package shopifygraphql_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	graphql "github.com/nobody05/graphql_go_client"
+	"github.com/nobody05/graphql_go_client/shopifygraphql"
+)
+
+func TestThrottler(t *testing.T) {
+	var requests []time.Time
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql.json", func(w http.ResponseWriter, req *http.Request) {
+		requests = append(requests, time.Now())
+		w.Header().Set("Content-Type", "application/json")
+		if len(requests) == 1 {
+			// Throttled, with 100 points missing, restored within 100 ms.
+			w.Write([]byte(`{"errors": [{"message": "Throttled", "extensions": {"code": "THROTTLED"}}], "extensions": {"cost": {"requestedQueryCost": 200, "actualQueryCost": null, "throttleStatus": {"maximumAvailable": 1000, "currentlyAvailable": 100, "restoreRate": 1000}}}}`))
+			return
+		}
+		fmt.Fprint(w, `{"data": {"shop": {"name": "Gopher Shop"}}, "extensions": {"cost": {"requestedQueryCost": 200, "actualQueryCost": 2, "throttleStatus": {"maximumAvailable": 1000, "currentlyAvailable": 998, "restoreRate": 1000}}}}`)
+	})
+	client := graphql.NewClient("/graphql.json", graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}))
+	throttler := &shopifygraphql.Throttler{}
+	client.Use(throttler.Middleware())
+
+	var q struct {
+		Shop struct {
+			Name string `graphql:"name"`
+		} `graphql:"shop"`
+	}
+	var resp graphql.Response
+	if err := client.NamedQuery(context.Background(), "GetShop", &q, nil, graphql.WithResponse(&resp)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.Shop.Name, "Gopher Shop"; got != want {
+		t.Errorf("got name: %q, want: %q", got, want)
+	}
+	if got, want := len(requests), 2; got != want {
+		t.Fatalf("got requests: %v, want: %v", got, want)
+	}
+	if got, want := requests[1].Sub(requests[0]), 100*time.Millisecond; got < want {
+		t.Errorf("got retry after: %v, want at least: %v", got, want)
+	}
+	cost, ok := shopifygraphql.ParseCost(&resp)
+	if !ok || cost.ActualQueryCost != 2 || cost.ThrottleStatus.CurrentlyAvailable != 998 {
+		t.Errorf("got cost: %+v, %v, want the cost of the last response", cost, ok)
+	}
+}
+
+type localRoundTripper struct {
+	handler http.Handler
+}
+
+func (l localRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := httptest.NewRecorder()
+	l.handler.ServeHTTP(w, req)
+	return w.Result(), nil
+}
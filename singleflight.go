@@ -0,0 +1,81 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// singleflight is a Doer that coalesces identical concurrent queries
+// into a single request to next, as enabled by WithSingleflight.
+type singleflight struct {
+	next Doer
+
+	mu      sync.Mutex
+	flights map[string]*flight // In-flight requests, by key.
+}
+
+// flight is a request in flight, whose outcome is shared by the callers
+// that requested it.
+type flight struct {
+	done chan struct{} // Closed once resp and err are set.
+	resp *Response
+	err  error
+}
+
+func (s *singleflight) Do(ctx context.Context, req *Request) (*Response, error) {
+	if req.Query == "" || req.OperationType() != "query" || req.list != nil || req.incremental != nil {
+		return s.next.Do(ctx, req)
+	}
+	key, err := flightKey(req)
+	if err != nil {
+		return s.next.Do(ctx, req)
+	}
+	s.mu.Lock()
+	if f, ok := s.flights[key]; ok {
+		s.mu.Unlock()
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if errors.Is(f.err, context.Canceled) || errors.Is(f.err, context.DeadlineExceeded) {
+			// The request was abandoned by the caller that made it,
+			// but this caller is still waiting, so make another.
+			return s.next.Do(ctx, req)
+		}
+		return f.response()
+	}
+	f := &flight{done: make(chan struct{})}
+	s.flights[key] = f
+	s.mu.Unlock()
+
+	f.resp, f.err = s.next.Do(ctx, req)
+	s.mu.Lock()
+	delete(s.flights, key)
+	s.mu.Unlock()
+	close(f.done)
+	return f.response()
+}
+
+// response returns the outcome of f, with a copy of the response,
+// so that callers don't share it.
+func (f *flight) response() (*Response, error) {
+	if f.resp == nil {
+		return nil, f.err
+	}
+	resp := *f.resp
+	return &resp, f.err
+}
+
+// flightKey returns the key identifying requests identical to req:
+// the encoding of its document, variables, operation name, extensions
+// and headers.
+func flightKey(req *Request) (string, error) {
+	b, err := json.Marshal(struct {
+		*Request
+		Header map[string][]string `json:"header"`
+	}{req, req.Header})
+	return string(b), err
+}
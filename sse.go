@@ -0,0 +1,226 @@
+package graphql
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultSSERetry is how long to wait before reconnecting
+	// a dropped event stream, unless the server says otherwise.
+	defaultSSERetry = time.Second
+
+	// maxSSERetry caps the wait before reconnecting,
+	// which doubles with every reconnection that yields no events.
+	maxSSERetry = 30 * time.Second
+
+	// maxSSEReconnects is how many times in a row a dropped event stream
+	// is reconnected without receiving any events, before giving up.
+	maxSSEReconnects = 5
+)
+
+// subscribeSSE starts a subscription using the graphql-sse protocol
+// in distinct connections mode, where each subscription is a POST request
+// whose response is a stream of server-sent events.
+//
+// If the stream drops before the subscription completes, it's reopened,
+// sending the id of the last received event via the Last-Event-ID header
+// so the server can resume where it left off. Reconnections back off
+// exponentially while no events come through, up to maxSSEReconnects
+// times in a row.
+//
+// Specification: https://github.com/enisdenjo/graphql-sse/blob/master/PROTOCOL.md.
+func (c *Client) subscribeSSE(ctx context.Context, body []byte, header http.Header) (<-chan SubscriptionMessage, error) {
+	resp, err := c.openEventStream(ctx, body, header, "")
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan SubscriptionMessage)
+	go func() {
+		defer close(ch)
+		send := func(msg SubscriptionMessage) bool {
+			msg.decoding = c.decoding
+			select {
+			case ch <- msg:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		s := &sseStream{retry: defaultSSERetry}
+		for failures := 0; ; {
+			events := s.events
+			completed := s.read(resp.Body, send)
+			resp.Body.Close()
+			if completed || ctx.Err() != nil {
+				return
+			}
+			if s.events > events {
+				failures = 0
+			}
+
+			// The stream dropped; reconnect after a delay.
+			var err error
+			for {
+				if failures == maxSSEReconnects {
+					if err == nil {
+						err = fmt.Errorf("graphql: subscription stream dropped %d times in a row without events", failures+1)
+					}
+					send(SubscriptionMessage{Err: err})
+					return
+				}
+				if sleep(ctx, sseBackoff(s.retry, failures)) != nil {
+					return
+				}
+				failures++
+				resp, err = c.openEventStream(ctx, body, header, s.lastEventID)
+				if err == nil {
+					break
+				}
+				var httpErr *HTTPError
+				if ctx.Err() != nil {
+					return
+				} else if errors.As(err, &httpErr) {
+					// The server refused the subscription.
+					send(SubscriptionMessage{Err: err})
+					return
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// sseBackoff returns how long to wait before reconnecting a dropped
+// event stream, after failures reconnections that yielded no events.
+func sseBackoff(retry time.Duration, failures int) time.Duration {
+	for i := 0; i < failures && retry < maxSSERetry; i++ {
+		retry *= 2
+	}
+	if retry > maxSSERetry {
+		return maxSSERetry
+	}
+	return retry
+}
+
+// openEventStream sends the subscription request body and returns
+// the response, whose body is an event stream.
+func (c *Client) openEventStream(ctx context.Context, body []byte, header http.Header, lastEventID string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, c.sseURL(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range c.requestHeader(header) {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		err := newHTTPError(resp)
+		resp.Body.Close()
+		return nil, err
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		resp.Body.Close()
+		return nil, fmt.Errorf("graphql: unexpected subscription response content type %q", resp.Header.Get("Content-Type"))
+	}
+	return resp, nil
+}
+
+// sseURL returns the URL to use for graphql-sse subscriptions,
+// which is the one set via WithSubscriptionURL, or the client URL.
+func (c *Client) sseURL() string {
+	if c.subscriptionEndpoint == "" {
+		return c.url
+	}
+	return c.subscriptionEndpoint
+}
+
+// sseStream holds the state of an event stream that persists across reconnections.
+type sseStream struct {
+	lastEventID string
+	retry       time.Duration
+	events      int // Number of results received.
+}
+
+// read parses server-sent events from r and delivers GraphQL results via send.
+// It reports whether the subscription completed, as opposed to the stream
+// ending prematurely.
+//
+// Specification: https://html.spec.whatwg.org/multipage/server-sent-events.html#event-stream-interpretation.
+func (s *sseStream) read(r io.Reader, send func(SubscriptionMessage) bool) (completed bool) {
+	br := bufio.NewReader(r)
+	var event, id string
+	var data bytes.Buffer
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return false
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line == "" {
+			// Dispatch the event.
+			if id != "" {
+				s.lastEventID = id
+			}
+			switch event {
+			case "next":
+				var out Response
+				if err := json.Unmarshal(data.Bytes(), &out); err != nil {
+					send(SubscriptionMessage{Err: err})
+					return true
+				}
+				s.events++
+				if !send(SubscriptionMessage{Data: out.Data, Errors: out.Errors, Extensions: out.Extensions}) {
+					return true
+				}
+			case "complete":
+				return true
+			}
+			event, id = "", ""
+			data.Reset()
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			// Comment, commonly used as a keep-alive.
+			continue
+		}
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i != -1 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+		case "id":
+			id = value
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				s.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
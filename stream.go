@@ -0,0 +1,119 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// listHandler handles the items of a list in response data one at a time,
+// as set by WithStreamedList.
+type listHandler struct {
+	path []string // Response keys leading to the list from the data.
+	fn   func(item json.RawMessage) error
+	done bool // Whether the items have been handled while reading the response.
+}
+
+// newListHandler returns a listHandler for the list at path,
+// which consists of response keys separated by dots.
+func newListHandler(path string, fn func(item json.RawMessage) error) *listHandler {
+	return &listHandler{path: strings.Split(path, "."), fn: fn}
+}
+
+// splitList reads a JSON value from r and returns its compact encoding,
+// except that the items of the list at path are passed to fn one at a time
+// as they're read, rather than kept. path consists of the keys of nested
+// objects leading to the list.
+func splitList(r io.Reader, path []string, fn func(item json.RawMessage) error) (json.RawMessage, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var buf bytes.Buffer
+	if path == nil {
+		path = []string{}
+	}
+	if err := copyValue(dec, &buf, path, fn); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// copyValue copies a single JSON value from dec to w. route is the rest of
+// the path to the list whose items are passed to fn, empty if it's the value
+// itself, or nil if the value isn't on the path.
+func copyValue(dec *json.Decoder, w *bytes.Buffer, route []string, fn func(item json.RawMessage) error) error {
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return errors.New("unexpected end of JSON input")
+	} else if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		w.WriteByte('{')
+		for n := 0; dec.More(); n++ {
+			if n > 0 {
+				w.WriteByte(',')
+			}
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key, ok := tok.(string)
+			if !ok {
+				return errors.New("unexpected non-key in JSON input")
+			}
+			b, err := json.Marshal(key)
+			if err != nil {
+				return err
+			}
+			w.Write(b)
+			w.WriteByte(':')
+			var next []string
+			if len(route) > 0 && route[0] == key {
+				next = route[1:]
+			}
+			if err := copyValue(dec, w, next, fn); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		w.WriteByte('}')
+	case json.Delim('['):
+		w.WriteByte('[')
+		target := route != nil && len(route) == 0
+		var item bytes.Buffer
+		for n := 0; dec.More(); n++ {
+			if target {
+				item.Reset()
+				if err := copyValue(dec, &item, nil, fn); err != nil {
+					return err
+				}
+				if err := fn(item.Bytes()); err != nil {
+					return err
+				}
+				continue
+			}
+			if n > 0 {
+				w.WriteByte(',')
+			}
+			if err := copyValue(dec, w, nil, fn); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		w.WriteByte(']')
+	default:
+		b, err := json.Marshal(tok)
+		if err != nil {
+			return err
+		}
+		w.Write(b)
+	}
+	return nil
+}
@@ -0,0 +1,804 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nobody05/graphql_go_client/internal/jsonutil"
+	"golang.org/x/net/websocket"
+)
+
+// SubscriptionMessage is a single event delivered on a subscription channel.
+type SubscriptionMessage struct {
+	// Data is the raw "data" entry of the event, if present.
+	Data json.RawMessage
+	// Errors holds GraphQL errors reported by the server for the subscription.
+	// Errors may accompany the data of an event, in which case the
+	// subscription goes on. If the server rejects or aborts the subscription,
+	// its errors are delivered in the last message before the channel is closed.
+	Errors Errors
+	// Extensions is the "extensions" entry of the event, if present.
+	Extensions map[string]interface{}
+	// Err is a transport or protocol error. It is always the last
+	// message delivered before the channel is closed.
+	Err error
+
+	decoding jsonutil.Options // How Decode decodes data.
+}
+
+// Decode decodes the message's data into v, which should be a pointer
+// to struct that corresponds to the GraphQL schema, such as the one
+// passed to Subscribe.
+func (m SubscriptionMessage) Decode(v interface{}) error {
+	if len(m.Data) == 0 {
+		return fmt.Errorf("graphql: subscription message has no data")
+	}
+	opts := m.decoding
+	opts.Path = "data"
+	return jsonutil.UnmarshalGraphQLOptions(m.Data, v, opts)
+}
+
+// Subscribe starts a GraphQL subscription, with a subscription derived from q,
+// using the protocol set by WithSubscriptionProtocol, which is the
+// graphql-transport-ws WebSocket protocol by default.
+// q should be a pointer to struct that corresponds to the GraphQL schema.
+//
+// Each event is delivered on the returned channel, and can be decoded
+// via SubscriptionMessage.Decode. The channel is closed when the server
+// completes the subscription, when an error occurs, or when ctx is done.
+// Canceling ctx stops the subscription.
+//
+// Concurrent WebSocket subscriptions with the same headers share
+// a single connection, which is closed after the last one ends.
+// Subscriptions whose connection fails are started again on a new one
+// if the client is configured by WithSubscriptionReconnect.
+func (c *Client) Subscribe(ctx context.Context, q interface{}, variables map[string]interface{}, opts ...RequestOption) (<-chan SubscriptionMessage, error) {
+	return c.subscribe(ctx, subscriptionOperation, q, variables, newRequestOptions(opts))
+}
+
+// subscribe starts an operation of type op, derived from q, delivering its
+// results over the subscription transport, as by Subscribe.
+func (c *Client) subscribe(ctx context.Context, op operationType, q interface{}, variables map[string]interface{}, o *requestOptions) (<-chan SubscriptionMessage, error) {
+	// Derive the document before marshaling the variables,
+	// which loses the Go types their GraphQL types derive from.
+	query, err := operation(op, o.operationName, "", q, variables)
+	if err != nil {
+		return nil, err
+	}
+	query, err = o.document(query)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.validate(ctx, query); err != nil {
+		return nil, err
+	}
+	variables, err = marshalVariables(variables)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVariables(query, o.operationName, variables, c.strictVariables); err != nil {
+		return nil, err
+	}
+	req := Request{
+		Query:         query,
+		Variables:     variables,
+		OperationName: o.operationName,
+	}
+	if c.subscriptionProtocol != GraphQLSSE && c.reconnect != nil {
+		return c.subscribeWSResumable(ctx, req, o.header)
+	}
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if c.subscriptionProtocol == GraphQLSSE {
+		return c.subscribeSSE(ctx, payload, o.header)
+	}
+	return c.subscribeWS(ctx, payload, o.header)
+}
+
+// subscribeWS starts a subscription over a WebSocket connection. Subscriptions
+// with the same headers share a connection for as long as it's open. Each
+// subscription holds a reference to it: once the last one ends, the connection
+// is closed and released, so that the next subscription opens a new one.
+func (c *Client) subscribeWS(ctx context.Context, payload json.RawMessage, header http.Header) (<-chan SubscriptionMessage, error) {
+	if c.subscriptionAuthorizer != nil {
+		var err error
+		payload, err = c.subscriptionAuthorizer.AuthorizeSubscription(ctx, payload)
+		if err != nil {
+			return nil, err
+		}
+	}
+	key := headerKey(c.requestHeader(header))
+	for {
+		c.wsMu.Lock()
+		if conn := c.wsConns[key]; conn != nil {
+			if ch, ok := conn.subscribe(ctx, payload); ok {
+				c.wsMu.Unlock()
+				return ch, nil
+			}
+			// The connection has been closed since; open a new one.
+			delete(c.wsConns, key)
+		}
+		if dial := c.wsDials[key]; dial != nil {
+			// Wait for the connection being opened, then look again.
+			// If it failed to open, this subscription tries in turn.
+			c.wsMu.Unlock()
+			select {
+			case <-dial:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		dial := make(chan struct{})
+		if c.wsDials == nil {
+			c.wsDials = make(map[string]chan struct{})
+		}
+		c.wsDials[key] = dial
+		c.wsMu.Unlock()
+
+		// Dial without holding wsMu, so that slow servers don't hold up
+		// subscriptions over other connections, or connections closing.
+		conn, err := c.dialSubscription(ctx, header)
+		var (
+			ch <-chan SubscriptionMessage
+			ok bool
+		)
+		if err == nil {
+			ch, ok = conn.subscribe(ctx, payload)
+		}
+		c.wsMu.Lock()
+		delete(c.wsDials, key)
+		if ok {
+			if c.wsConns == nil {
+				c.wsConns = make(map[string]*wsConn)
+			}
+			c.wsConns[key] = conn
+		}
+		c.wsMu.Unlock()
+		close(dial)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("graphql: subscription connection closed")
+		}
+		return ch, nil
+	}
+}
+
+// SubscriptionProtocol is a protocol used by Subscribe.
+type SubscriptionProtocol string
+
+const (
+	// GraphQLTransportWS is the graphql-transport-ws protocol, implemented by
+	// the graphql-ws library and most modern servers. It's the default.
+	//
+	// Specification: https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md.
+	GraphQLTransportWS SubscriptionProtocol = "graphql-transport-ws"
+
+	// SubscriptionsTransportWS is the legacy protocol of Apollo's
+	// subscriptions-transport-ws library, still spoken by older servers
+	// such as Apollo Server 2 and Hasura before 2.0.
+	//
+	// Specification: https://github.com/apollographql/subscriptions-transport-ws/blob/master/PROTOCOL.md.
+	SubscriptionsTransportWS SubscriptionProtocol = "graphql-ws"
+
+	// GraphQLSSE is the graphql-sse protocol, which streams events over
+	// an HTTP response using server-sent events, for servers that don't
+	// expose subscriptions over WebSockets. Dropped streams are resumed
+	// automatically using the Last-Event-ID header.
+	//
+	// Specification: https://github.com/enisdenjo/graphql-sse/blob/master/PROTOCOL.md.
+	GraphQLSSE SubscriptionProtocol = "graphql-sse"
+)
+
+// SlowConsumerPolicy determines what happens to the events of a WebSocket
+// subscription that arrive while its buffer is full, as its subscriber
+// doesn't receive them as fast as the server sends them.
+// It's set by WithSubscriptionBuffer.
+type SlowConsumerPolicy uint8
+
+const (
+	// BlockSlowConsumer waits for the subscriber to receive the event,
+	// which stalls the connection, and so the other subscriptions sharing
+	// it, meanwhile. It's the default.
+	BlockSlowConsumer SlowConsumerPolicy = iota
+
+	// DropOldestEvent discards the oldest event in the buffer to make room.
+	DropOldestEvent
+
+	// DropNewestEvent discards the event.
+	DropNewestEvent
+
+	// FailSlowConsumer ends the subscription, delivering ErrSlowConsumer
+	// after the events in the buffer.
+	FailSlowConsumer
+)
+
+// ErrSlowConsumer is the error delivered to subscriptions ended because
+// their subscriber fell behind, per FailSlowConsumer.
+var ErrSlowConsumer = errors.New("graphql: subscriber too slow, buffer full")
+
+// SubscriptionAuthorizer authorizes WebSocket subscriptions, for servers
+// authenticating them other than by headers or connection_init payload,
+// such as AWS AppSync, which expects signatures in the URL of connections
+// and in the messages starting subscriptions. It's set by
+// WithSubscriptionAuthorizer. Package appsyncgraphql provides one.
+type SubscriptionAuthorizer interface {
+	// AuthorizeConnection returns the URL to open a WebSocket connection
+	// at, in place of u.
+	AuthorizeConnection(ctx context.Context, u *url.URL) (*url.URL, error)
+
+	// AuthorizeSubscription returns the payload of the message starting
+	// a subscription, in place of payload, which holds the document,
+	// variables and operation name of the subscription.
+	AuthorizeSubscription(ctx context.Context, payload json.RawMessage) (json.RawMessage, error)
+}
+
+// wsDialect describes the message types of a WebSocket subscription protocol.
+// Empty message types aren't part of the protocol.
+type wsDialect struct {
+	connectionInit  string
+	connectionAck   string
+	connectionError string // Server rejects connection_init.
+	keepAlive       string // Server keep-alive, needs no reply.
+	ping            string
+	pong            string
+	subscribe       string // Client starts a subscription.
+	subscribeAck    string // Server acknowledges a subscription, needs no reply.
+	next            string // Server sends an event.
+	error           string // Server reports an error for a subscription.
+	complete        string // Server completes a subscription.
+	stop            string // Client stops a subscription.
+	terminate       string // Client closes the connection.
+}
+
+// wsDialects are the dialects of the supported subscription protocols.
+var wsDialects = map[SubscriptionProtocol]*wsDialect{
+	GraphQLTransportWS: {
+		connectionInit: "connection_init",
+		connectionAck:  "connection_ack",
+		ping:           "ping",
+		pong:           "pong",
+		subscribe:      "subscribe",
+		next:           "next",
+		error:          "error",
+		complete:       "complete",
+		stop:           "complete",
+	},
+	SubscriptionsTransportWS: {
+		connectionInit:  "connection_init",
+		connectionAck:   "connection_ack",
+		connectionError: "connection_error",
+		keepAlive:       "ka",
+		subscribe:       "start",
+		subscribeAck:    "start_ack", // Sent by AWS AppSync.
+		next:            "data",
+		error:           "error",
+		complete:        "complete",
+		stop:            "stop",
+		terminate:       "connection_terminate",
+	},
+}
+
+// wsMessage is a message of a WebSocket subscription protocol.
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// wsConn is a WebSocket connection to a GraphQL server that multiplexes
+// any number of subscriptions, each identified by its own id.
+type wsConn struct {
+	ws        *websocket.Conn
+	dialect   *wsDialect
+	keepAlive time.Duration // Interval between client pings, or 0 for none.
+
+	decoding jsonutil.Options // Passed on to messages.
+
+	buffer       int                // Size of the buffers of subscriptions.
+	slowConsumer SlowConsumerPolicy // Policy of subscriptions whose buffer is full.
+
+	release func() // Called once the connection is closed, to stop sharing it.
+
+	writeMu sync.Mutex // Serializes writes to ws.
+
+	mu     sync.Mutex
+	subs   map[string]*wsSubscription
+	nextID uint64
+	closed bool
+	done   chan struct{} // Closed when the connection is closed.
+}
+
+// wsSubscription is a single subscription on a wsConn.
+type wsSubscription struct {
+	ch   chan SubscriptionMessage
+	done chan struct{} // Closed when the subscription ends.
+	once sync.Once
+
+	policy     SlowConsumerPolicy
+	overflow   chan struct{} // Closed when the buffer overflows, per FailSlowConsumer.
+	overflowed atomic.Bool
+
+	mu     sync.Mutex // Guards sends to ch against closing it.
+	closed bool
+}
+
+// event delivers the event msg to the subscriber, unless the subscription
+// ends first, or, if the buffer is full, as its policy says. Only the
+// BlockSlowConsumer policy blocks while the buffer is full.
+func (s *wsSubscription) event(msg SubscriptionMessage) {
+	if s.policy == BlockSlowConsumer {
+		s.send(msg)
+		return
+	}
+	if s.overflowed.Load() {
+		// The subscription is ending.
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	for {
+		select {
+		case s.ch <- msg:
+			return
+		default:
+		}
+		switch s.policy {
+		case DropOldestEvent:
+			select {
+			case <-s.ch:
+			default:
+				// The subscriber received it meanwhile.
+			}
+		case DropNewestEvent:
+			return
+		default:
+			s.overflowed.Store(true)
+			close(s.overflow)
+			return
+		}
+	}
+}
+
+// finish delivers msg to the subscriber and ends the subscription. Unless its
+// policy is BlockSlowConsumer, it does so in the background, so that a slow
+// subscriber doesn't stall the connection.
+func (s *wsSubscription) finish(msg SubscriptionMessage) {
+	if s.policy == BlockSlowConsumer {
+		s.send(msg)
+		s.close()
+		return
+	}
+	go func() {
+		s.send(msg)
+		s.close()
+	}()
+}
+
+// send delivers msg to the subscriber, unless the subscription
+// ends first. It reports whether msg was delivered.
+func (s *wsSubscription) send(msg SubscriptionMessage) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	select {
+	case s.ch <- msg:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// fail delivers err to the subscriber and ends the subscription.
+func (s *wsSubscription) fail(err error) {
+	s.send(SubscriptionMessage{Err: err})
+	s.close()
+}
+
+// close ends the subscription and closes its channel.
+// It is safe to call more than once.
+func (s *wsSubscription) close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.mu.Lock()
+		s.closed = true
+		close(s.ch)
+		s.mu.Unlock()
+	})
+}
+
+// subscriptionURL returns the WebSocket URL to use for subscriptions.
+// Unless set via WithSubscriptionURL, it's derived from the client URL
+// by switching the scheme from http(s) to ws(s).
+func (c *Client) subscriptionURL() (*url.URL, error) {
+	if c.subscriptionEndpoint != "" {
+		return url.Parse(c.subscriptionEndpoint)
+	}
+	u, err := url.Parse(c.url)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	}
+	return u, nil
+}
+
+// dialSubscription opens a WebSocket connection to the server
+// and performs the connection_init/connection_ack handshake.
+func (c *Client) dialSubscription(ctx context.Context, header http.Header) (*wsConn, error) {
+	u, err := c.subscriptionURL()
+	if err != nil {
+		return nil, err
+	}
+	if c.subscriptionAuthorizer != nil {
+		u, err = c.subscriptionAuthorizer.AuthorizeConnection(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return nil, fmt.Errorf("graphql: unsupported subscription URL scheme %q", u.Scheme)
+	}
+	protocol := c.subscriptionProtocol
+	if protocol == "" {
+		protocol = GraphQLTransportWS
+	}
+	dialect, ok := wsDialects[protocol]
+	if !ok {
+		return nil, fmt.Errorf("graphql: unsupported subscription protocol %q", protocol)
+	}
+	origin := &url.URL{Scheme: "http", Host: u.Host}
+	if u.Scheme == "wss" {
+		origin.Scheme = "https"
+	}
+	config := &websocket.Config{
+		Location: u,
+		Origin:   origin,
+		Version:  websocket.ProtocolVersionHybi13,
+		Protocol: []string{string(protocol)},
+		Header:   make(http.Header),
+	}
+	for k, v := range c.requestHeader(header) {
+		config.Header[k] = v
+	}
+	if cookie := wsCookieHeader(c.httpClient.Jar, u); cookie != "" {
+		config.Header.Set("Cookie", cookie)
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "wss" {
+			addr = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			addr = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+	network := "tcp"
+	if c.socket != "" && c.subscriptionEndpoint == "" {
+		network, addr = "unix", c.socket
+	}
+	var d net.Dialer
+	netConn, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	// Abort the handshake below if ctx is done before it completes.
+	handshakeDone := make(chan struct{})
+	defer close(handshakeDone)
+	go func(rawConn net.Conn) {
+		select {
+		case <-ctx.Done():
+			rawConn.Close()
+		case <-handshakeDone:
+		}
+	}(netConn)
+	if u.Scheme == "wss" {
+		config := c.tlsClientConfig(nil)
+		if config.ServerName == "" {
+			config.ServerName = u.Hostname()
+		}
+		tlsConn := tls.Client(netConn, config)
+		if err := tlsConn.Handshake(); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+		netConn = tlsConn
+	}
+	ws, err := websocket.NewClient(config, netConn)
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	init := wsMessage{Type: dialect.connectionInit}
+	if c.connectionParams != nil {
+		init.Payload, err = json.Marshal(c.connectionParams)
+		if err != nil {
+			ws.Close()
+			return nil, err
+		}
+	}
+	if err := websocket.JSON.Send(ws, init); err != nil {
+		ws.Close()
+		return nil, err
+	}
+	for {
+		var msg wsMessage
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			ws.Close()
+			return nil, fmt.Errorf("graphql: waiting for connection_ack: %v", err)
+		}
+		if msg.Type == "" {
+			ws.Close()
+			return nil, fmt.Errorf("graphql: subscription message without type")
+		}
+		switch msg.Type {
+		case dialect.connectionAck:
+		case dialect.ping:
+			if err := websocket.JSON.Send(ws, wsMessage{Type: dialect.pong}); err != nil {
+				ws.Close()
+				return nil, err
+			}
+			continue
+		case dialect.keepAlive:
+			continue
+		case dialect.connectionError:
+			ws.Close()
+			return nil, fmt.Errorf("graphql: subscription connection rejected: %s", msg.Payload)
+		default:
+			ws.Close()
+			return nil, fmt.Errorf("graphql: unexpected %q message while waiting for connection_ack", msg.Type)
+		}
+		break
+	}
+	if err := ctx.Err(); err != nil {
+		ws.Close()
+		return nil, err
+	}
+
+	conn := &wsConn{
+		ws:        ws,
+		dialect:   dialect,
+		keepAlive: c.keepAlive,
+		subs:      make(map[string]*wsSubscription),
+		done:      make(chan struct{}),
+
+		decoding: c.decoding,
+
+		buffer:       c.subscriptionBuffer,
+		slowConsumer: c.slowConsumer,
+	}
+	key := headerKey(c.requestHeader(header))
+	conn.release = func() {
+		c.wsMu.Lock()
+		defer c.wsMu.Unlock()
+		if c.wsConns[key] == conn {
+			delete(c.wsConns, key)
+		}
+	}
+	go conn.readLoop()
+	if conn.keepAlive > 0 && dialect.ping != "" {
+		go conn.pingLoop()
+	}
+	return conn, nil
+}
+
+// write sends msg to the server.
+func (conn *wsConn) write(msg wsMessage) error {
+	conn.writeMu.Lock()
+	defer conn.writeMu.Unlock()
+	return websocket.JSON.Send(conn.ws, msg)
+}
+
+// subscribe starts a subscription with the given subscribe payload.
+// It reports false if the connection is closed. The connection is closed
+// once it has no subscriptions left.
+func (conn *wsConn) subscribe(ctx context.Context, payload json.RawMessage) (<-chan SubscriptionMessage, bool) {
+	sub := &wsSubscription{
+		ch:       make(chan SubscriptionMessage, conn.buffer),
+		done:     make(chan struct{}),
+		policy:   conn.slowConsumer,
+		overflow: make(chan struct{}),
+	}
+	conn.mu.Lock()
+	if conn.closed {
+		conn.mu.Unlock()
+		return nil, false
+	}
+	conn.nextID++
+	id := strconv.FormatUint(conn.nextID, 10)
+	conn.subs[id] = sub
+	conn.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			// Tell the server we're no longer interested. Errors are ignored,
+			// since the connection may already be going away.
+			conn.write(wsMessage{ID: id, Type: conn.dialect.stop})
+			sub.close()
+		case <-sub.overflow:
+			conn.write(wsMessage{ID: id, Type: conn.dialect.stop})
+			go sub.fail(ErrSlowConsumer)
+			select {
+			case <-ctx.Done():
+				sub.close()
+			case <-sub.done:
+			}
+		case <-sub.done:
+		}
+		conn.remove(id)
+	}()
+
+	if err := conn.write(wsMessage{ID: id, Type: conn.dialect.subscribe, Payload: payload}); err != nil {
+		go sub.fail(err)
+	}
+	return sub.ch, true
+}
+
+// remove ends the subscription with the given id, closing the
+// connection if it was the last one.
+func (conn *wsConn) remove(id string) {
+	conn.mu.Lock()
+	sub, ok := conn.subs[id]
+	delete(conn.subs, id)
+	conn.mu.Unlock()
+	if ok {
+		sub.close()
+	}
+	conn.shutdown(nil, true)
+}
+
+// fail delivers err to all subscriptions and closes the connection.
+func (conn *wsConn) fail(err error) {
+	conn.shutdown(err, false)
+}
+
+// shutdown closes the connection and ends all its subscriptions,
+// delivering err to each of them first if it's non-nil.
+// If onlyIfIdle is set, the connection is only closed if it has
+// no subscriptions, which is checked atomically with closing it,
+// so that no new subscription slips in.
+func (conn *wsConn) shutdown(err error, onlyIfIdle bool) {
+	conn.mu.Lock()
+	if conn.closed || onlyIfIdle && len(conn.subs) > 0 {
+		conn.mu.Unlock()
+		return
+	}
+	conn.closed = true
+	close(conn.done)
+	subs := conn.subs
+	conn.subs = make(map[string]*wsSubscription)
+	conn.mu.Unlock()
+
+	if conn.dialect.terminate != "" && err == nil {
+		conn.write(wsMessage{Type: conn.dialect.terminate})
+	}
+	conn.ws.Close()
+	conn.release()
+	for _, sub := range subs {
+		if err == nil {
+			sub.close()
+			continue
+		}
+		// Don't let a stalled subscriber block the others from learning about err.
+		go sub.fail(err)
+	}
+}
+
+// readLoop reads messages from the server and dispatches them
+// to subscriptions until the connection is closed.
+func (conn *wsConn) readLoop() {
+	for {
+		var msg wsMessage
+		if err := websocket.JSON.Receive(conn.ws, &msg); err != nil {
+			conn.fail(err)
+			return
+		}
+		if msg.Type == "" {
+			conn.fail(fmt.Errorf("graphql: subscription message without type"))
+			return
+		}
+		d := conn.dialect
+		switch msg.Type {
+		case d.ping:
+			if err := conn.write(wsMessage{Type: d.pong}); err != nil {
+				conn.fail(err)
+				return
+			}
+		case d.pong, d.keepAlive, d.connectionAck, d.subscribeAck:
+			// Nothing to do.
+		case d.next:
+			sub := conn.lookup(msg.ID)
+			if sub == nil {
+				continue
+			}
+			var out Response
+			if err := json.Unmarshal(msg.Payload, &out); err != nil {
+				sub.fail(err)
+				continue
+			}
+			sub.event(SubscriptionMessage{Data: out.Data, Errors: out.Errors, Extensions: out.Extensions, decoding: conn.decoding})
+		case d.error:
+			sub := conn.lookup(msg.ID)
+			if sub == nil {
+				continue
+			}
+			errs, err := decodeErrorPayload(msg.Payload)
+			if err != nil {
+				sub.fail(err)
+				continue
+			}
+			sub.finish(SubscriptionMessage{Errors: errs})
+		case d.complete:
+			if sub := conn.lookup(msg.ID); sub != nil {
+				sub.close()
+			}
+		default:
+			conn.fail(fmt.Errorf("graphql: unexpected %q message on subscription connection", msg.Type))
+			return
+		}
+	}
+}
+
+// decodeErrorPayload decodes the payload of an error message, which is
+// an array of errors, or a single error object in the legacy protocol.
+func decodeErrorPayload(payload json.RawMessage) (Errors, error) {
+	var errs Errors
+	if trimmed := bytes.TrimSpace(payload); len(trimmed) > 0 && trimmed[0] == '{' {
+		errs = make(Errors, 1)
+		err := json.Unmarshal(payload, &errs[0])
+		return errs, err
+	}
+	err := json.Unmarshal(payload, &errs)
+	return errs, err
+}
+
+// lookup returns the subscription with the given id, or nil.
+func (conn *wsConn) lookup(id string) *wsSubscription {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return conn.subs[id]
+}
+
+// pingLoop sends a ping to the server every keepAlive interval,
+// keeping the connection alive through idle-timeout proxies.
+func (conn *wsConn) pingLoop() {
+	t := time.NewTicker(conn.keepAlive)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := conn.write(wsMessage{Type: conn.dialect.ping}); err != nil {
+				conn.fail(err)
+				return
+			}
+		case <-conn.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,791 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nobody05/graphql_go_client"
+	"golang.org/x/net/websocket"
+)
+
+// wsMessage is a message of a WebSocket subscription protocol, for testing.
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// newWebSocketServer starts a WebSocket server speaking the given
+// subprotocol, handling each connection with handler.
+func newWebSocketServer(t *testing.T, protocol string, handler func(ws *websocket.Conn)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(websocket.Server{
+		Handshake: func(config *websocket.Config, req *http.Request) error {
+			for _, p := range config.Protocol {
+				if p == protocol {
+					config.Protocol = []string{p}
+					return nil
+				}
+			}
+			return fmt.Errorf("unsupported subprotocols %q", config.Protocol)
+		},
+		Handler: handler,
+	})
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// expectMessage receives the next message from ws and checks its type.
+func expectMessage(t *testing.T, ws *websocket.Conn, typ string) wsMessage {
+	t.Helper()
+	var msg wsMessage
+	if err := websocket.JSON.Receive(ws, &msg); err != nil {
+		t.Errorf("receiving %q message: %v", typ, err)
+		return msg
+	}
+	if msg.Type != typ {
+		t.Errorf("got message type %q, want %q", msg.Type, typ)
+	}
+	return msg
+}
+
+func TestClient_Subscribe(t *testing.T) {
+	srv := newWebSocketServer(t, "graphql-transport-ws", func(ws *websocket.Conn) {
+		init := expectMessage(t, ws, "connection_init")
+		if got, want := string(init.Payload), `{"token":"secret"}`; got != want {
+			t.Errorf("got connection_init payload %s, want %s", got, want)
+		}
+		websocket.JSON.Send(ws, wsMessage{Type: "connection_ack"})
+
+		sub := expectMessage(t, ws, "subscribe")
+		if got, want := string(sub.Payload), `{"query":"subscription($repo:ID!){star_added(repo:$repo){stars}}","variables":{"repo":"r1"}}`; got != want {
+			t.Errorf("got subscribe payload %s, want %s", got, want)
+		}
+
+		websocket.JSON.Send(ws, wsMessage{Type: "ping"})
+		expectMessage(t, ws, "pong")
+
+		for i := 1; i <= 2; i++ {
+			websocket.JSON.Send(ws, wsMessage{ID: sub.ID, Type: "next", Payload: json.RawMessage(fmt.Sprintf(`{"data":{"star_added":{"stars":%d}}}`, i))})
+		}
+		websocket.JSON.Send(ws, wsMessage{ID: sub.ID, Type: "complete"})
+	})
+	client := graphql.NewClient(srv.URL, graphql.WithConnectionParams(map[string]interface{}{"token": "secret"}))
+
+	var s struct {
+		StarAdded struct {
+			Stars graphql.Int
+		} `graphql:"star_added(repo:$repo)"`
+	}
+	ch, err := client.Subscribe(context.Background(), &s, map[string]interface{}{"repo": graphql.ID("r1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []graphql.Int
+	for msg := range ch {
+		if msg.Err != nil {
+			t.Fatal(msg.Err)
+		}
+		if err := msg.Decode(&s); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, s.StarAdded.Stars)
+	}
+	if want := []graphql.Int{1, 2}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got stars %v, want %v", got, want)
+	}
+}
+
+func TestClient_LiveQuery(t *testing.T) {
+	srv := newWebSocketServer(t, "graphql-transport-ws", func(ws *websocket.Conn) {
+		expectMessage(t, ws, "connection_init")
+		websocket.JSON.Send(ws, wsMessage{Type: "connection_ack"})
+		sub := expectMessage(t, ws, "subscribe")
+		if got, want := string(sub.Payload), `{"query":"query($repo:ID!)@live{repository(id:$repo){stars}}","variables":{"repo":"r1"}}`; got != want {
+			t.Errorf("got subscribe payload %s, want %s", got, want)
+		}
+		for _, stars := range []int{10, 11} {
+			websocket.JSON.Send(ws, wsMessage{ID: sub.ID, Type: "next", Payload: json.RawMessage(fmt.Sprintf(`{"data":{"repository":{"stars":%d}}}`, stars))})
+		}
+		websocket.JSON.Send(ws, wsMessage{ID: sub.ID, Type: "complete"})
+	})
+	client := graphql.NewClient(srv.URL)
+
+	var q struct {
+		Repository struct {
+			Stars graphql.Int
+		} `graphql:"repository(id:$repo)"`
+	}
+	ch, err := client.LiveQuery(context.Background(), &q, map[string]interface{}{"repo": graphql.ID("r1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []graphql.Int
+	for msg := range ch {
+		if msg.Err != nil {
+			t.Fatal(msg.Err)
+		}
+		if err := msg.Decode(&q); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, q.Repository.Stars)
+	}
+	if want := []graphql.Int{10, 11}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got stars %v, want %v", got, want)
+	}
+}
+
+func TestClient_Subscribe_cookies(t *testing.T) {
+	srv := httptest.NewServer(websocket.Server{
+		Handshake: func(config *websocket.Config, req *http.Request) error {
+			if c, err := req.Cookie("session"); err != nil || c.Value != "s1" {
+				t.Errorf("got session cookie: %v, %v, want: s1", c, err)
+			}
+			config.Protocol = []string{"graphql-transport-ws"}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			expectMessage(t, ws, "connection_init")
+			websocket.JSON.Send(ws, wsMessage{Type: "connection_ack"})
+			sub := expectMessage(t, ws, "subscribe")
+			websocket.JSON.Send(ws, wsMessage{ID: sub.ID, Type: "complete"})
+		},
+	})
+	defer srv.Close()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "s1"}})
+	client := graphql.NewClient(srv.URL, graphql.WithCookieJar(jar))
+
+	var s struct {
+		StarAdded struct {
+			Stars graphql.Int
+		} `graphql:"star_added"`
+	}
+	ch, err := client.Subscribe(context.Background(), &s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for msg := range ch {
+		if msg.Err != nil {
+			t.Fatal(msg.Err)
+		}
+	}
+}
+
+func TestClient_Subscribe_inputObject(t *testing.T) {
+	srv := newWebSocketServer(t, "graphql-transport-ws", func(ws *websocket.Conn) {
+		expectMessage(t, ws, "connection_init")
+		websocket.JSON.Send(ws, wsMessage{Type: "connection_ack"})
+
+		sub := expectMessage(t, ws, "subscribe")
+		if got, want := string(sub.Payload), `{"query":"subscription($filter:StarFilter!){star_added(filter:$filter){stars}}","variables":{"filter":{"repo":"r1"}}}`; got != want {
+			t.Errorf("got subscribe payload %s, want %s", got, want)
+		}
+		websocket.JSON.Send(ws, wsMessage{ID: sub.ID, Type: "complete"})
+	})
+	client := graphql.NewClient(srv.URL)
+
+	type StarFilter struct {
+		Repo graphql.ID `json:"repo"`
+	}
+	var s struct {
+		StarAdded struct {
+			Stars graphql.Int
+		} `graphql:"star_added(filter:$filter)"`
+	}
+	ch, err := client.Subscribe(context.Background(), &s, map[string]interface{}{"filter": StarFilter{Repo: "r1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for msg := range ch {
+		if msg.Err != nil {
+			t.Fatal(msg.Err)
+		}
+	}
+}
+
+func TestClient_Subscribe_errors(t *testing.T) {
+	srv := newWebSocketServer(t, "graphql-transport-ws", func(ws *websocket.Conn) {
+		expectMessage(t, ws, "connection_init")
+		websocket.JSON.Send(ws, wsMessage{Type: "connection_ack"})
+		sub := expectMessage(t, ws, "subscribe")
+		websocket.JSON.Send(ws, wsMessage{ID: sub.ID, Type: "error", Payload: json.RawMessage(`[{"message":"no such repo"}]`)})
+	})
+	client := graphql.NewClient(srv.URL)
+
+	var s struct {
+		StarAdded struct {
+			Stars graphql.Int
+		}
+	}
+	ch, err := client.Subscribe(context.Background(), &s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, ok := <-ch
+	if !ok {
+		t.Fatal("channel closed before error was delivered")
+	}
+	if got, want := fmt.Sprint(msg.Errors), "no such repo"; got != want {
+		t.Errorf("got errors %q, want %q", got, want)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("channel not closed after error")
+	}
+}
+
+func TestClient_Subscribe_cancel(t *testing.T) {
+	completed := make(chan string, 1)
+	srv := newWebSocketServer(t, "graphql-transport-ws", func(ws *websocket.Conn) {
+		expectMessage(t, ws, "connection_init")
+		websocket.JSON.Send(ws, wsMessage{Type: "connection_ack"})
+		sub := expectMessage(t, ws, "subscribe")
+		complete := expectMessage(t, ws, "complete")
+		if complete.ID != sub.ID {
+			t.Errorf("got complete for id %q, want %q", complete.ID, sub.ID)
+		}
+		completed <- complete.ID
+	})
+	client := graphql.NewClient(strings.Replace(srv.URL, "http://", "https://", 1),
+		graphql.WithSubscriptionURL(strings.Replace(srv.URL, "http://", "ws://", 1)),
+	)
+
+	var s struct {
+		StarAdded struct {
+			Stars graphql.Int
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := client.Subscribe(ctx, &s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Error("channel not closed after cancel")
+	}
+	select {
+	case <-completed:
+	case <-time.After(5 * time.Second):
+		t.Error("server didn't receive complete message")
+	}
+}
+
+func TestClient_Subscribe_subscriptionsTransportWS(t *testing.T) {
+	srv := newWebSocketServer(t, "graphql-ws", func(ws *websocket.Conn) {
+		expectMessage(t, ws, "connection_init")
+		websocket.JSON.Send(ws, wsMessage{Type: "connection_ack"})
+		websocket.JSON.Send(ws, wsMessage{Type: "ka"})
+		start := expectMessage(t, ws, "start")
+		websocket.JSON.Send(ws, wsMessage{ID: start.ID, Type: "data", Payload: json.RawMessage(`{"data":{"star_added":{"stars":7}}}`)})
+		websocket.JSON.Send(ws, wsMessage{ID: start.ID, Type: "error", Payload: json.RawMessage(`{"message":"stream ended"}`)})
+		expectMessage(t, ws, "connection_terminate")
+	})
+	client := graphql.NewClient(srv.URL, graphql.WithSubscriptionProtocol(graphql.SubscriptionsTransportWS))
+
+	var s struct {
+		StarAdded struct {
+			Stars graphql.Int
+		} `graphql:"star_added"`
+	}
+	ch, err := client.Subscribe(context.Background(), &s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := <-ch
+	if err := msg.Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.StarAdded.Stars, graphql.Int(7); got != want {
+		t.Errorf("got stars %v, want %v", got, want)
+	}
+	msg = <-ch
+	if got, want := fmt.Sprint(msg.Errors), "stream ended"; got != want {
+		t.Errorf("got errors %q, want %q", got, want)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("channel not closed after error")
+	}
+}
+
+func TestClient_Subscribe_graphQLSSE(t *testing.T) {
+	var connections int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		connections++
+		if got, want := req.Header.Get("Accept"), "text/event-stream"; got != want {
+			t.Errorf("got Accept: %q, want: %q", got, want)
+		}
+		if got, want := mustRead(req.Body), `{"query":"subscription{star_added{stars}}"}`; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		switch connections {
+		case 1:
+			mustWrite(w, "retry: 0\n\n: keep-alive\n\nid: 1\nevent: next\ndata: {\"data\":{\"star_added\":{\"stars\":1}}}\n\n")
+			// Drop the connection before completing.
+		case 2:
+			if got, want := req.Header.Get("Last-Event-ID"), "1"; got != want {
+				t.Errorf("got Last-Event-ID: %q, want: %q", got, want)
+			}
+			mustWrite(w, "id: 2\nevent: next\ndata: {\"data\":\ndata: {\"star_added\":{\"stars\":2}}}\n\nevent: complete\ndata:\n\n")
+		default:
+			t.Errorf("unexpected connection %d", connections)
+		}
+	})
+	client := graphql.NewClient("/graphql",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithSubscriptionProtocol(graphql.GraphQLSSE),
+	)
+
+	var s struct {
+		StarAdded struct {
+			Stars graphql.Int
+		} `graphql:"star_added"`
+	}
+	ch, err := client.Subscribe(context.Background(), &s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []graphql.Int
+	for msg := range ch {
+		if msg.Err != nil {
+			t.Fatal(msg.Err)
+		}
+		if err := msg.Decode(&s); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, s.StarAdded.Stars)
+	}
+	if want := []graphql.Int{1, 2}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got stars %v, want %v", got, want)
+	}
+}
+
+func TestClient_Subscribe_graphQLSSE_reconnectLimit(t *testing.T) {
+	var connections int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		connections++
+		w.Header().Set("Content-Type", "text/event-stream")
+		// Drop every connection without sending events.
+		mustWrite(w, "retry: 0\n\n")
+	})
+	client := graphql.NewClient("/graphql",
+		graphql.WithHTTPClient(&http.Client{Transport: localRoundTripper{handler: mux}}),
+		graphql.WithSubscriptionProtocol(graphql.GraphQLSSE),
+	)
+
+	var s struct {
+		StarAdded struct {
+			Stars graphql.Int
+		} `graphql:"star_added"`
+	}
+	ch, err := client.Subscribe(context.Background(), &s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, ok := <-ch
+	if !ok || msg.Err == nil {
+		t.Fatalf("got message %+v, want error", msg)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("channel not closed after error")
+	}
+	if got, want := connections, 6; got != want {
+		t.Errorf("got %d connections, want %d (1 initial and 5 reconnections)", got, want)
+	}
+}
+
+func TestClient_Subscribe_reconnect(t *testing.T) {
+	var connections int32
+	srv := newWebSocketServer(t, "graphql-transport-ws", func(ws *websocket.Conn) {
+		n := atomic.AddInt32(&connections, 1)
+		expectMessage(t, ws, "connection_init")
+		websocket.JSON.Send(ws, wsMessage{Type: "connection_ack"})
+		sub := expectMessage(t, ws, "subscribe")
+		if n == 1 {
+			websocket.JSON.Send(ws, wsMessage{ID: sub.ID, Type: "next", Payload: json.RawMessage(`{"data":{"star_added":{"stars":1}}}`)})
+			// Drop the connection.
+			return
+		}
+		if got, want := string(sub.Payload), `{"query":"subscription($after:Int!){star_added(after:$after){stars}}","variables":{"after":1}}`; got != want {
+			t.Errorf("got subscribe payload %s, want %s", got, want)
+		}
+		websocket.JSON.Send(ws, wsMessage{ID: sub.ID, Type: "next", Payload: json.RawMessage(`{"data":{"star_added":{"stars":2}}}`)})
+		websocket.JSON.Send(ws, wsMessage{ID: sub.ID, Type: "complete"})
+	})
+	var s struct {
+		StarAdded struct {
+			Stars graphql.Int
+		} `graphql:"star_added(after:$after)"`
+	}
+	client := graphql.NewClient(srv.URL, graphql.WithSubscriptionReconnect(graphql.ReconnectPolicy{
+		MinBackoff: time.Millisecond,
+		Resume: func(variables map[string]interface{}, last *graphql.SubscriptionMessage) map[string]interface{} {
+			var event struct {
+				StarAdded struct {
+					Stars graphql.Int
+				} `graphql:"star_added"`
+			}
+			if last != nil && last.Decode(&event) == nil {
+				variables["after"] = event.StarAdded.Stars
+			}
+			return variables
+		},
+	}))
+
+	ch, err := client.Subscribe(context.Background(), &s, map[string]interface{}{"after": graphql.Int(0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []graphql.Int
+	for msg := range ch {
+		if msg.Err != nil {
+			t.Fatal(msg.Err)
+		}
+		if err := msg.Decode(&s); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, s.StarAdded.Stars)
+	}
+	if want := []graphql.Int{1, 2}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got stars %v, want %v", got, want)
+	}
+	if got, want := atomic.LoadInt32(&connections), int32(2); got != want {
+		t.Errorf("got %d connections, want %d", got, want)
+	}
+}
+
+func TestClient_Subscribe_reconnectLimit(t *testing.T) {
+	var connections int32
+	srv := newWebSocketServer(t, "graphql-transport-ws", func(ws *websocket.Conn) {
+		atomic.AddInt32(&connections, 1)
+		expectMessage(t, ws, "connection_init")
+		websocket.JSON.Send(ws, wsMessage{Type: "connection_ack"})
+		// Drop every connection without sending events.
+		expectMessage(t, ws, "subscribe")
+	})
+	client := graphql.NewClient(srv.URL, graphql.WithSubscriptionReconnect(graphql.ReconnectPolicy{
+		MaxAttempts: 2,
+		MinBackoff:  time.Millisecond,
+	}))
+
+	var s struct {
+		StarAdded struct {
+			Stars graphql.Int
+		} `graphql:"star_added"`
+	}
+	ch, err := client.Subscribe(context.Background(), &s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, ok := <-ch
+	if !ok || msg.Err == nil {
+		t.Fatalf("got message %+v, want error", msg)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("channel not closed after error")
+	}
+	if got, want := atomic.LoadInt32(&connections), int32(3); got != want {
+		t.Errorf("got %d connections, want %d (1 initial and 2 reconnections)", got, want)
+	}
+}
+
+func TestClient_Subscribe_slowConsumer(t *testing.T) {
+	tests := []struct {
+		policy graphql.SlowConsumerPolicy
+		want   string
+	}{
+		{graphql.DropOldestEvent, "[4 5]"},
+		{graphql.DropNewestEvent, "[1 2]"},
+		{graphql.FailSlowConsumer, "[1 2 graphql: subscriber too slow, buffer full]"},
+	}
+	for _, tc := range tests {
+		sent := make(chan struct{})
+		srv := newWebSocketServer(t, "graphql-transport-ws", func(ws *websocket.Conn) {
+			defer close(sent)
+			expectMessage(t, ws, "connection_init")
+			websocket.JSON.Send(ws, wsMessage{Type: "connection_ack"})
+			sub := expectMessage(t, ws, "subscribe")
+			for i := 1; i <= 5; i++ {
+				websocket.JSON.Send(ws, wsMessage{ID: sub.ID, Type: "next", Payload: json.RawMessage(fmt.Sprintf(`{"data":{"star_added":{"stars":%d}}}`, i))})
+			}
+			if tc.policy == graphql.FailSlowConsumer {
+				expectMessage(t, ws, "complete")
+				return
+			}
+			websocket.JSON.Send(ws, wsMessage{ID: sub.ID, Type: "complete"})
+			// Wait for the client to close the connection, once it's read all the events.
+			var msg wsMessage
+			websocket.JSON.Receive(ws, &msg)
+		})
+		client := graphql.NewClient(srv.URL, graphql.WithSubscriptionBuffer(2, tc.policy))
+
+		var s struct {
+			StarAdded struct {
+				Stars graphql.Int
+			} `graphql:"star_added"`
+		}
+		ch, err := client.Subscribe(context.Background(), &s, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		<-sent
+		var got []interface{}
+		for msg := range ch {
+			if msg.Err != nil {
+				got = append(got, msg.Err)
+				continue
+			}
+			if err := msg.Decode(&s); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, s.StarAdded.Stars)
+		}
+		if fmt.Sprint(got) != tc.want {
+			t.Errorf("policy %d: got %v, want %s", tc.policy, got, tc.want)
+		}
+	}
+}
+
+func TestClient_Subscribe_sharedConnection(t *testing.T) {
+	var connections int
+	srv := newWebSocketServer(t, "graphql-transport-ws", func(ws *websocket.Conn) {
+		connections++
+		expectMessage(t, ws, "connection_init")
+		websocket.JSON.Send(ws, wsMessage{Type: "connection_ack"})
+		sub1 := expectMessage(t, ws, "subscribe")
+		sub2 := expectMessage(t, ws, "subscribe")
+		if sub1.ID == sub2.ID {
+			t.Errorf("got the same id %q for both subscriptions", sub1.ID)
+		}
+		for i, id := range []string{sub2.ID, sub1.ID} {
+			websocket.JSON.Send(ws, wsMessage{ID: id, Type: "next", Payload: json.RawMessage(fmt.Sprintf(`{"data":{"star_added":{"stars":%d}}}`, i+1))})
+			websocket.JSON.Send(ws, wsMessage{ID: id, Type: "complete"})
+		}
+	})
+	client := graphql.NewClient(srv.URL)
+
+	type subscription struct {
+		StarAdded struct {
+			Stars graphql.Int
+		} `graphql:"star_added"`
+	}
+	var chans []<-chan graphql.SubscriptionMessage
+	for i := 0; i < 2; i++ {
+		ch, err := client.Subscribe(context.Background(), &subscription{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chans = append(chans, ch)
+	}
+	got := make([]graphql.Int, len(chans))
+	var wg sync.WaitGroup
+	for i, ch := range chans {
+		wg.Add(1)
+		go func(i int, ch <-chan graphql.SubscriptionMessage) {
+			defer wg.Done()
+			for msg := range ch {
+				var s subscription
+				if err := msg.Decode(&s); err != nil {
+					t.Error(err)
+					continue
+				}
+				got[i] = s.StarAdded.Stars
+			}
+		}(i, ch)
+	}
+	wg.Wait()
+	if want := []graphql.Int{2, 1}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got stars %v, want %v", got, want)
+	}
+	if connections != 1 {
+		t.Errorf("got %d connections, want 1", connections)
+	}
+}
+
+func TestClient_Subscribe_slowDial(t *testing.T) {
+	slowDialing, release := make(chan struct{}), make(chan struct{})
+	srv := newWebSocketServer(t, "graphql-transport-ws", func(ws *websocket.Conn) {
+		expectMessage(t, ws, "connection_init")
+		if ws.Request().Header.Get("X-Slow") != "" {
+			close(slowDialing)
+			<-release
+		}
+		websocket.JSON.Send(ws, wsMessage{Type: "connection_ack"})
+		sub := expectMessage(t, ws, "subscribe")
+		websocket.JSON.Send(ws, wsMessage{ID: sub.ID, Type: "complete"})
+	})
+	client := graphql.NewClient(srv.URL)
+
+	var s struct {
+		StarAdded struct {
+			Stars graphql.Int
+		} `graphql:"star_added"`
+	}
+	slow := make(chan error, 1)
+	go func() {
+		_, err := client.Subscribe(context.Background(), &s, nil, graphql.WithHeader("X-Slow", "1"))
+		slow <- err
+	}()
+	<-slowDialing
+	// A server slow to acknowledge one connection doesn't hold up
+	// subscriptions over others.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ch, err := client.Subscribe(ctx, &s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range ch {
+	}
+	close(release)
+	if err := <-slow; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_Subscribe_multiplexed(t *testing.T) {
+	const n = 100
+	var connections int32
+	closed := make(chan struct{}, 2)
+	srv := newWebSocketServer(t, "graphql-transport-ws", func(ws *websocket.Conn) {
+		atomic.AddInt32(&connections, 1)
+		defer func() { closed <- struct{}{} }()
+		expectMessage(t, ws, "connection_init")
+		websocket.JSON.Send(ws, wsMessage{Type: "connection_ack"})
+		for {
+			var msg wsMessage
+			if err := websocket.JSON.Receive(ws, &msg); err != nil {
+				// The client closed the connection.
+				return
+			}
+			if msg.Type != "subscribe" {
+				continue
+			}
+			// Fail the subscriptions with even ids, and complete the others.
+			var payload struct {
+				Variables struct {
+					N int `json:"n"`
+				} `json:"variables"`
+			}
+			json.Unmarshal(msg.Payload, &payload)
+			if payload.Variables.N%2 == 0 {
+				websocket.JSON.Send(ws, wsMessage{ID: msg.ID, Type: "error", Payload: json.RawMessage(fmt.Sprintf(`[{"message":"no repo %d"}]`, payload.Variables.N))})
+				continue
+			}
+			websocket.JSON.Send(ws, wsMessage{ID: msg.ID, Type: "next", Payload: json.RawMessage(fmt.Sprintf(`{"data":{"star_added":{"stars":%d}}}`, payload.Variables.N))})
+			websocket.JSON.Send(ws, wsMessage{ID: msg.ID, Type: "complete"})
+		}
+	})
+	client := graphql.NewClient(srv.URL)
+
+	type subscription struct {
+		StarAdded struct {
+			Stars graphql.Int
+		} `graphql:"star_added(n:$n)"`
+	}
+	got := make([]string, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		ch, err := client.Subscribe(context.Background(), &subscription{}, map[string]interface{}{"n": graphql.Int(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for msg := range ch {
+				if msg.Errors != nil {
+					got[i] = msg.Errors.Error()
+					continue
+				}
+				var s subscription
+				if err := msg.Decode(&s); err != nil {
+					t.Error(err)
+				}
+				got[i] = fmt.Sprint(s.StarAdded.Stars)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i, got := range got {
+		want := fmt.Sprint(i)
+		if i%2 == 0 {
+			want = fmt.Sprintf("no repo %d", i)
+		}
+		if got != want {
+			t.Errorf("subscription %d: got %q, want %q", i, got, want)
+		}
+	}
+	if got := atomic.LoadInt32(&connections); got != 1 {
+		t.Errorf("got %d connections, want 1", got)
+	}
+
+	// The connection is closed once all subscriptions have ended,
+	// so the next subscription opens a new one.
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not closed after all subscriptions ended")
+	}
+	ch, err := client.Subscribe(context.Background(), &subscription{}, map[string]interface{}{"n": graphql.Int(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range ch {
+	}
+	if got := atomic.LoadInt32(&connections); got != 2 {
+		t.Errorf("got %d connections, want 2", got)
+	}
+}
+
+func TestClient_Subscribe_keepAlive(t *testing.T) {
+	srv := newWebSocketServer(t, "graphql-transport-ws", func(ws *websocket.Conn) {
+		expectMessage(t, ws, "connection_init")
+		websocket.JSON.Send(ws, wsMessage{Type: "connection_ack"})
+		sub := expectMessage(t, ws, "subscribe")
+		for i := 0; i < 2; i++ {
+			expectMessage(t, ws, "ping")
+			websocket.JSON.Send(ws, wsMessage{Type: "pong"})
+		}
+		websocket.JSON.Send(ws, wsMessage{ID: sub.ID, Type: "complete"})
+	})
+	client := graphql.NewClient(srv.URL, graphql.WithSubscriptionKeepAlive(10*time.Millisecond))
+
+	var s struct {
+		StarAdded struct {
+			Stars graphql.Int
+		} `graphql:"star_added"`
+	}
+	ch, err := client.Subscribe(context.Background(), &s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg, ok := <-ch:
+		if ok {
+			t.Errorf("got message %+v, want channel closed", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("subscription didn't complete after pings")
+	}
+}
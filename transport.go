@@ -0,0 +1,25 @@
+package graphql
+
+import (
+	"context"
+)
+
+// Transport sends GraphQL requests to a server and returns its responses.
+//
+// The default transport POSTs JSON-encoded requests to the client's URL
+// over HTTP. Alternative transports can be provided via WithTransport,
+// for instance to execute requests against an in-process schema in tests.
+type Transport interface {
+	// Execute sends req and returns the server's response. Like Doer.Do,
+	// it reports GraphQL errors via the response, and returns an error
+	// only if no response could be obtained.
+	Execute(ctx context.Context, req *Request) (*Response, error)
+}
+
+// TransportFunc is an adapter to allow the use of ordinary functions as a Transport.
+type TransportFunc func(ctx context.Context, req *Request) (*Response, error)
+
+// Execute calls f(ctx, req).
+func (f TransportFunc) Execute(ctx context.Context, req *Request) (*Response, error) {
+	return f(ctx, req)
+}
@@ -0,0 +1,33 @@
+package graphql
+
+import (
+	"context"
+)
+
+// Query executes a single GraphQL query request, with a query derived from
+// T, and returns a new value of T populated with the response, such as:
+//
+//	q, err := graphql.Query[struct {
+//		Viewer struct {
+//			Login graphql.String
+//		}
+//	}](ctx, client, nil)
+//
+// T should be a struct that corresponds to the GraphQL schema. Unlike with
+// the methods of Client, no value needs to be declared beforehand, so one
+// can't be reused by accident with data left over from a previous query.
+// Like them, it returns the partial data of responses with GraphQL errors,
+// as determined by the client's error policy. The operation is named via
+// WithOperationName.
+func Query[T any](ctx context.Context, c *Client, variables map[string]interface{}, opts ...RequestOption) (T, error) {
+	var v T
+	err := c.do(ctx, queryOperation, &v, variables, newRequestOptions(opts))
+	return v, err
+}
+
+// Mutate is like Query, but for a mutation derived from T.
+func Mutate[T any](ctx context.Context, c *Client, variables map[string]interface{}, opts ...RequestOption) (T, error) {
+	var v T
+	err := c.do(ctx, mutationOperation, &v, variables, newRequestOptions(opts))
+	return v, err
+}
@@ -0,0 +1,83 @@
+package graphql
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/nobody05/graphql_go_client/internal/jsonutil"
+)
+
+// RegisterUnion registers members as the Go types of the members of the
+// GraphQL union or interface name, by typename, for the Go interface type T,
+// such as:
+//
+//	graphql.RegisterUnion[SearchResult]("SearchResult", map[string]reflect.Type{
+//		"Issue":      reflect.TypeOf(Issue{}),
+//		"Repository": reflect.TypeOf(&Repository{}),
+//	})
+//
+// Fields of type T, or slices of them, are then selected with an inline
+// fragment for each member, along with __typename, and decoded into a value
+// of the member type the response names. Member types are structs, or
+// pointers to structs, implementing T. If members is nil, a previous
+// registration of T is removed. RegisterUnion panics if T isn't an interface
+// type. It's meant to be called during initialization, such as from an init
+// function.
+func RegisterUnion[T any](name string, members map[string]reflect.Type) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("graphql: RegisterUnion of non-interface type %v", t))
+	}
+	if members != nil {
+		m := make(map[string]reflect.Type, len(members))
+		for typename, t := range members {
+			m[typename] = t
+		}
+		members = m
+	}
+	jsonutil.RegisterUnion(t, name, members)
+	resetTypeCaches()
+}
+
+// RegisterPossibleTypes registers typenames as the concrete types
+// implementing the GraphQL interface, or making up the union, name,
+// such as:
+//
+//	graphql.RegisterPossibleTypes("SearchResultItem", "Issue", "PullRequest", "Repository")
+//
+// Inline fragments are populated only if they apply to the __typename of
+// the object they're part of. Without registrations, a type condition other
+// than __typename may be an interface or a union the object belongs to, so
+// fragments on it are populated. With them, fragments on a registered
+// possible type are left as zero values for objects of other types,
+// as are fragments on name for objects of types other than typenames.
+// If no typenames are given, a previous registration is removed.
+// RegisterPossibleTypes is meant to be called during initialization,
+// such as from an init function.
+func RegisterPossibleTypes(name string, typenames ...string) {
+	if len(typenames) == 0 {
+		typenames = nil
+	} else {
+		typenames = append([]string(nil), typenames...)
+	}
+	jsonutil.RegisterPossibleTypes(name, typenames)
+}
+
+// writeUnion writes the selection set of a union with member types,
+// selecting each of their fields in an inline fragment.
+func writeUnion(w io.Writer, types map[string]reflect.Type) {
+	io.WriteString(w, "{__typename")
+	for _, typename := range sortedKeys(types) {
+		io.WriteString(w, ",... on "+typename)
+		writeQuery(w, types[typename], false)
+	}
+	io.WriteString(w, "}")
+}
+
+// lookupUnion returns the member types of the union registered for
+// the Go interface type t, if any.
+func lookupUnion(t reflect.Type) (map[string]reflect.Type, bool) {
+	_, types, ok := jsonutil.LookupUnion(t)
+	return types, ok
+}
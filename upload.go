@@ -0,0 +1,162 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Upload is a file to upload as the value of a variable of the Upload scalar type.
+// Requests with uploads are sent as multipart/form-data, following the
+// GraphQL multipart request specification. A plain io.Reader may be used
+// as a variable value too, in which case it's uploaded with the default filename.
+//
+// Specification: https://github.com/jaydenseric/graphql-multipart-request-spec.
+type Upload struct {
+	File        io.Reader
+	Filename    string // Defaults to "blob", as browsers do for unnamed files.
+	ContentType string // Defaults to application/octet-stream.
+}
+
+// fileUpload is an upload found in the variables of a request,
+// along with its object path, such as "variables.files.0".
+type fileUpload struct {
+	path   string
+	upload Upload
+}
+
+var readerType = reflect.TypeOf((*io.Reader)(nil)).Elem()
+
+// extractUploads finds uploads among variables, which may be given directly
+// or as elements of lists and input object maps. It returns a copy of variables
+// with the uploads replaced by null, or variables itself if there are none.
+func extractUploads(variables map[string]interface{}) (map[string]interface{}, []fileUpload) {
+	var uploads []fileUpload
+	v := extractUploadsFrom(reflect.ValueOf(variables), "variables", &uploads)
+	if len(uploads) == 0 {
+		return variables, nil
+	}
+	sort.Slice(uploads, func(i, j int) bool { return uploads[i].path < uploads[j].path })
+	return v.(map[string]interface{}), uploads
+}
+
+// extractUploadsFrom returns v with uploads replaced by nil, appending the
+// uploads it finds to uploads. Values without uploads are returned as is.
+func extractUploadsFrom(v reflect.Value, path string, uploads *[]fileUpload) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	switch u := v.Interface().(type) {
+	case Upload:
+		*uploads = append(*uploads, fileUpload{path: path, upload: u})
+		return nil
+	case *Upload:
+		if u != nil {
+			*uploads = append(*uploads, fileUpload{path: path, upload: *u})
+		}
+		return nil
+	case io.Reader:
+		*uploads = append(*uploads, fileUpload{path: path, upload: Upload{File: u}})
+		return nil
+	}
+	n := len(*uploads)
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return v.Interface()
+		}
+		if e := extractUploadsFrom(v.Elem(), path, uploads); len(*uploads) > n {
+			return e
+		}
+	case reflect.Slice, reflect.Array:
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			list[i] = extractUploadsFrom(v.Index(i), path+"."+strconv.Itoa(i), uploads)
+		}
+		if len(*uploads) > n {
+			return list
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			break
+		}
+		m := make(map[string]interface{}, v.Len())
+		for _, k := range v.MapKeys() {
+			m[k.String()] = extractUploadsFrom(v.MapIndex(k), path+"."+k.String(), uploads)
+		}
+		if len(*uploads) > n {
+			return m
+		}
+	}
+	return v.Interface()
+}
+
+// encodeMultipart encodes req, whose variables are given with uploads
+// replaced by null, as a multipart/form-data body with the operations,
+// the map from file parts to variable paths, and the files themselves.
+// It returns the body and its content type.
+func encodeMultipart(req *Request, variables map[string]interface{}, uploads []fileUpload) ([]byte, string, error) {
+	operations := *req
+	operations.Variables = variables
+	fileMap := make(map[string][]string, len(uploads))
+	for i, u := range uploads {
+		fileMap[strconv.Itoa(i)] = []string{u.path}
+	}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, field := range []struct {
+		name  string
+		value interface{}
+	}{
+		{"operations", operations},
+		{"map", fileMap},
+	} {
+		fw, err := w.CreateFormField(field.name)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := json.NewEncoder(fw).Encode(field.value); err != nil {
+			return nil, "", err
+		}
+	}
+	for i, u := range uploads {
+		filename := u.upload.Filename
+		if filename == "" {
+			filename = "blob"
+		}
+		contentType := u.upload.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%d"; filename="%s"`, i, escapeQuotes(filename)))
+		h.Set("Content-Type", contentType)
+		fw, err := w.CreatePart(h)
+		if err != nil {
+			return nil, "", err
+		}
+		if u.upload.File != nil {
+			if _, err := io.Copy(fw, u.upload.File); err != nil {
+				return nil, "", fmt.Errorf("graphql: reading upload for %s: %v", u.path, err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
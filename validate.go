@@ -0,0 +1,400 @@
+package graphql
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/nobody05/graphql_go_client/internal/parser"
+)
+
+// ValidationError is a problem with a document found by validating it
+// against a schema, such as an unknown field.
+type ValidationError struct {
+	// Path holds the response keys of the fields leading to the problem,
+	// such as ["repository", "issues"], or is empty if it's not in a field.
+	Path    []string
+	Message string
+}
+
+// Error implements error interface.
+func (e ValidationError) Error() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+	return strings.Join(e.Path, ".") + ": " + e.Message
+}
+
+// ValidationErrors are the problems found by validating a document
+// against a schema.
+type ValidationErrors []ValidationError
+
+// Error implements error interface.
+func (e ValidationErrors) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+	return "graphql: invalid document: " + strings.Join(s, "; ")
+}
+
+// Validate checks document against s, reporting unknown types, fields,
+// arguments and fragments, missing required arguments, arguments and
+// variables of the wrong type, and selection sets missing from, or given
+// to, fields. The problems found are returned as ValidationErrors.
+//
+// It's a subset of the validation done by servers, meant to catch
+// mistakes in structs before hitting a server, such as in tests.
+//
+// Specification: https://spec.graphql.org/October2021/#sec-Validation.
+func (s *Schema) Validate(document string) error {
+	_, err := s.check(document)
+	return err
+}
+
+// check validates document against s, returning the problems found
+// as by Validate, and the deprecated fields it selects.
+func (s *Schema) check(document string) ([]Deprecation, error) {
+	doc, err := parser.Parse(document)
+	if err != nil {
+		return nil, fmt.Errorf("graphql: invalid document: %v", err)
+	}
+	v := &validator{schema: s, doc: doc}
+	for _, op := range doc.Operations {
+		v.operation(op)
+	}
+	if len(v.errs) > 0 {
+		return v.deprecations, v.errs
+	}
+	return v.deprecations, nil
+}
+
+// ValidateQuery checks the query document derived from q and variables,
+// as ConstructQuery returns it, against s. See Validate.
+func (s *Schema) ValidateQuery(q interface{}, variables map[string]interface{}) error {
+	document, err := ConstructQuery(q, variables)
+	if err != nil {
+		return err
+	}
+	return s.Validate(document)
+}
+
+// ValidateMutation is like ValidateQuery, but for a mutation.
+func (s *Schema) ValidateMutation(m interface{}, variables map[string]interface{}) error {
+	document, err := ConstructMutation(m, variables)
+	if err != nil {
+		return err
+	}
+	return s.Validate(document)
+}
+
+// validationCache caches the results of validating documents
+// against a schema, since clients send the same documents repeatedly.
+type validationCache struct {
+	schema *Schema
+	mu     sync.Mutex
+	errs   map[string]error // Results, by document.
+}
+
+// validate returns the result of s.schema.Validate(document),
+// computing it on first use. The deprecated fields document selects
+// are returned only then, so that they're reported once.
+func (c *validationCache) validate(document string) ([]Deprecation, error) {
+	c.mu.Lock()
+	err, ok := c.errs[document]
+	c.mu.Unlock()
+	if ok {
+		return nil, err
+	}
+	deprecations, err := c.schema.check(document)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.errs == nil {
+		c.errs = make(map[string]error)
+	}
+	if _, ok := c.errs[document]; ok {
+		// Another goroutine validated it concurrently, and reported it.
+		return nil, err
+	}
+	c.errs[document] = err
+	return deprecations, err
+}
+
+// validator validates the operations of a document against a schema.
+type validator struct {
+	schema       *Schema
+	doc          *parser.Document
+	errs         ValidationErrors
+	deprecations []Deprecation
+
+	vars    map[string]*parser.VariableDefinition // Variables of the current operation.
+	path    []string                              // Response keys leading to the current field.
+	spreads map[string]bool                       // Fragments being validated, to detect cycles.
+}
+
+func (v *validator) errorf(format string, args ...interface{}) {
+	v.errs = append(v.errs, ValidationError{
+		Path:    append([]string(nil), v.path...),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) operation(op *parser.Operation) {
+	var root *TypeRef
+	switch op.Type {
+	case "query":
+		root = v.schema.QueryType
+	case "mutation":
+		root = v.schema.MutationType
+	case "subscription":
+		root = v.schema.SubscriptionType
+	}
+	if root == nil {
+		v.errorf("schema doesn't support %s operations", op.Type)
+		return
+	}
+	t := v.schema.Type(root.Name)
+	if t == nil {
+		v.errorf("unknown type %s", root.Name)
+		return
+	}
+	v.vars = make(map[string]*parser.VariableDefinition)
+	v.spreads = make(map[string]bool)
+	for _, d := range op.Variables {
+		v.vars[d.Name] = d
+		named := v.schema.Type(d.Type.NamedType())
+		switch {
+		case named == nil:
+			v.errorf("variable $%s has unknown type %s", d.Name, d.Type.NamedType())
+		case named.Kind != TypeKindScalar && named.Kind != TypeKindEnum && named.Kind != TypeKindInputObject:
+			v.errorf("variable $%s has type %s, which isn't an input type", d.Name, d.Type)
+		}
+	}
+	v.selectionSet(t, op.SelectionSet)
+}
+
+func (v *validator) selectionSet(t *Type, set []parser.Selection) {
+	for _, sel := range set {
+		switch sel := sel.(type) {
+		case *parser.Field:
+			v.field(t, sel)
+		case *parser.FragmentSpread:
+			f := v.doc.Fragment(sel.Name)
+			if f == nil {
+				v.errorf("unknown fragment %s", sel.Name)
+				continue
+			}
+			if v.spreads[f.Name] {
+				v.errorf("fragment %s spreads itself", f.Name)
+				continue
+			}
+			v.spreads[f.Name] = true
+			v.fragment(t, f.TypeCondition, f.SelectionSet)
+			delete(v.spreads, f.Name)
+		case *parser.InlineFragment:
+			v.fragment(t, sel.TypeCondition, sel.SelectionSet)
+		}
+	}
+}
+
+func (v *validator) fragment(t *Type, on string, set []parser.Selection) {
+	if on == "" {
+		v.selectionSet(t, set)
+		return
+	}
+	typ := v.schema.Type(on)
+	switch {
+	case typ == nil:
+		v.errorf("unknown type %s", on)
+	case typ.Kind != TypeKindObject && typ.Kind != TypeKindInterface && typ.Kind != TypeKindUnion:
+		v.errorf("fragment on %s, which isn't an object, interface or union type", on)
+	default:
+		v.selectionSet(typ, set)
+	}
+}
+
+func (v *validator) field(t *Type, f *parser.Field) {
+	v.path = append(v.path, f.ResponseKey())
+	defer func() { v.path = v.path[:len(v.path)-1] }()
+
+	if f.Name == "__typename" {
+		if len(f.SelectionSet) > 0 {
+			v.errorf("field __typename of type String! can't have a selection set")
+		}
+		return
+	}
+	if t.Kind == TypeKindUnion {
+		v.errorf("unknown field %s on union %s, select it in an inline fragment", f.Name, t.Name)
+		return
+	}
+	def := t.Field(f.Name)
+	if def == nil {
+		if strings.HasPrefix(f.Name, "__") {
+			// Introspection fields, such as __schema, aren't described.
+			return
+		}
+		v.errorf("unknown field %s on type %s", f.Name, t.Name)
+		return
+	}
+	if def.IsDeprecated {
+		v.deprecations = append(v.deprecations, Deprecation{
+			Path:   append([]string(nil), v.path...),
+			Type:   t.Name,
+			Field:  def.Name,
+			Reason: def.DeprecationReason,
+		})
+	}
+	v.arguments(fmt.Sprintf("field %s", f.Name), "argument", def.Args, f.Arguments)
+
+	named := v.schema.Type(def.Type.NamedType())
+	if named == nil {
+		v.errorf("unknown type %s", def.Type.NamedType())
+		return
+	}
+	switch leaf := named.Kind == TypeKindScalar || named.Kind == TypeKindEnum; {
+	case leaf && len(f.SelectionSet) > 0:
+		v.errorf("field %s of type %s can't have a selection set", f.Name, def.Type)
+	case !leaf && len(f.SelectionSet) == 0:
+		v.errorf("field %s of type %s must have a selection set", f.Name, def.Type)
+	case !leaf:
+		v.selectionSet(named, f.SelectionSet)
+	}
+}
+
+// arguments checks the arguments args given to what, such as a field,
+// against their definitions defs. noun is what they're called,
+// "argument", or "field" for the fields of input objects.
+func (v *validator) arguments(what, noun string, defs []InputValue, args []*parser.Argument) {
+	for _, a := range args {
+		def := findInputValue(defs, a.Name)
+		if def == nil {
+			v.errorf("unknown %s %s of %s", noun, a.Name, what)
+			continue
+		}
+		v.value(fmt.Sprintf("%s %s of %s", noun, a.Name, what), def.Type, def.DefaultValue != nil, a.Value)
+	}
+	for _, def := range defs {
+		if def.Type.Kind == TypeKindNonNull && def.DefaultValue == nil && findArgument(args, def.Name) == nil {
+			v.errorf("missing required %s %s of %s", noun, def.Name, what)
+		}
+	}
+}
+
+// value checks that val can be given as what, of type r,
+// which has a default value if hasDefault is true.
+func (v *validator) value(what string, r TypeRef, hasDefault bool, val *parser.Value) {
+	if val.Kind == parser.VariableValue {
+		d, ok := v.vars[val.Raw]
+		switch {
+		case !ok:
+			v.errorf("undefined variable $%s", val.Raw)
+		case !compatible(d.Type, r, hasDefault || d.DefaultValue != nil):
+			v.errorf("variable $%s of type %s can't be given as %s of type %s", val.Raw, d.Type, what, r)
+		}
+		return
+	}
+	if val.Kind == parser.NullValue {
+		if r.Kind == TypeKindNonNull {
+			v.errorf("null can't be given as %s of type %s", what, r)
+		}
+		return
+	}
+	expected := r
+	if r.Kind == TypeKindNonNull {
+		r = *r.OfType
+	}
+	if r.Kind == TypeKindList {
+		if val.Kind != parser.ListValue {
+			// A single value is coerced to a list of one.
+			v.value(what, *r.OfType, false, val)
+			return
+		}
+		for _, item := range val.List {
+			v.value(what, *r.OfType, false, item)
+		}
+		return
+	}
+	t := v.schema.Type(r.Name)
+	if t == nil {
+		v.errorf("unknown type %s", r.Name)
+		return
+	}
+	ok := true
+	switch t.Kind {
+	case TypeKindEnum:
+		ok = val.Kind == parser.EnumValue && findEnumValue(t.EnumValues, val.Raw)
+	case TypeKindInputObject:
+		if ok = val.Kind == parser.ObjectValue; ok {
+			v.arguments(fmt.Sprintf("input object %s", t.Name), "field", t.InputFields, val.Fields)
+		}
+	case TypeKindScalar:
+		switch t.Name {
+		case "Int":
+			ok = val.Kind == parser.IntValue
+		case "Float":
+			ok = val.Kind == parser.IntValue || val.Kind == parser.FloatValue
+		case "String":
+			ok = val.Kind == parser.StringValue
+		case "Boolean":
+			ok = val.Kind == parser.BooleanValue
+		case "ID":
+			ok = val.Kind == parser.IntValue || val.Kind == parser.StringValue
+		}
+	}
+	if !ok {
+		v.errorf("%s can't be given as %s of type %s", val, what, expected)
+	}
+}
+
+// compatible reports whether a variable of type varType can be used where
+// a value of type r is expected. If hasDefault is true, the variable or
+// the location has a default value, so a nullable variable can be used
+// where a non-null value is expected.
+//
+// Specification: https://spec.graphql.org/October2021/#AreTypesCompatible().
+func compatible(varType *parser.Type, r TypeRef, hasDefault bool) bool {
+	if r.Kind == TypeKindNonNull {
+		if !varType.NonNull && !hasDefault {
+			return false
+		}
+		nullable := *varType
+		nullable.NonNull = false
+		return compatible(&nullable, *r.OfType, false)
+	}
+	if varType.NonNull {
+		nullable := *varType
+		nullable.NonNull = false
+		return compatible(&nullable, r, false)
+	}
+	if r.Kind == TypeKindList {
+		return varType.Elem != nil && compatible(varType.Elem, *r.OfType, false)
+	}
+	return varType.Elem == nil && varType.Name == r.Name
+}
+
+func findInputValue(defs []InputValue, name string) *InputValue {
+	for i := range defs {
+		if defs[i].Name == name {
+			return &defs[i]
+		}
+	}
+	return nil
+}
+
+func findArgument(args []*parser.Argument, name string) *parser.Argument {
+	for _, a := range args {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+func findEnumValue(values []EnumValue, name string) bool {
+	for _, v := range values {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
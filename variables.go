@@ -0,0 +1,208 @@
+package graphql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nobody05/graphql_go_client/internal/parser"
+)
+
+// WithStrictVariables makes the client reject operations given variables
+// they don't use, before sending them, for servers rejecting them, as
+// the GraphQL specification requires. By default, such variables are sent
+// as given; servers ignore those the document doesn't define.
+func WithStrictVariables() ClientOption {
+	return func(c *Client) {
+		c.strictVariables = true
+	}
+}
+
+// checkVariables checks that the variables given for the operation of query
+// named operationName are consistent with it: that the variables it uses are
+// defined, and that those it requires are given, and not null. If strict
+// is set, it also checks that the variables it defines are used, and that
+// the variables given are defined. Documents that don't parse are left for
+// the server to report.
+func checkVariables(query, operationName string, variables map[string]interface{}, strict bool) error {
+	if query == "" {
+		return nil
+	}
+	op := operationVariablesOf(query, operationName)
+	if op == nil {
+		return nil
+	}
+
+	var problems []string
+	for _, d := range op.defined {
+		value, given := variables[d.name]
+		switch {
+		case d.required && !given:
+			problems = append(problems, fmt.Sprintf("variable $%s of type %s is required, but not given", d.name, d.typ))
+		case d.nonNull && given && value == nil:
+			problems = append(problems, fmt.Sprintf("variable $%s of type %s is required, but null", d.name, d.typ))
+		case strict && !op.used[d.name]:
+			problems = append(problems, fmt.Sprintf("variable $%s is defined, but not used", d.name))
+		}
+	}
+	problems = append(problems, op.undefined...)
+	if strict {
+		for _, name := range sortedKeys(variables) {
+			if !op.isDefined(name) {
+				problems = append(problems, fmt.Sprintf("variable $%s is given, but not defined", name))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("graphql: invalid variables: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// operationVariables describes the variables an operation defines and uses,
+// as checked by checkVariables.
+type operationVariables struct {
+	defined   []variableDefinition
+	used      map[string]bool
+	undefined []string // Problems with the variables used, but not defined.
+}
+
+type variableDefinition struct {
+	name, typ string
+	nonNull   bool // Whether the variable can't be null.
+	required  bool // Whether it must be given: it can't be null, and has no default value.
+}
+
+// isDefined reports whether the operation defines the variable name.
+func (op *operationVariables) isDefined(name string) bool {
+	for _, d := range op.defined {
+		if d.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// maxCachedOperations is the number of operations whose variables are kept
+// by operationVariablesOf.
+const maxCachedOperations = 1024
+
+// operations holds the variables of the operations of documents sent,
+// so that documents are parsed once, rather than for each request.
+var operations = newLRU[operationKey, *operationVariables](maxCachedOperations)
+
+// operationKey identifies an operation of a document by name.
+type operationKey struct {
+	query, operationName string
+}
+
+// operationVariablesOf returns the variables of the operation of query named
+// operationName, or nil if query doesn't parse or has no such operation.
+func operationVariablesOf(query, operationName string) *operationVariables {
+	return operations.get(operationKey{query, operationName}, func(operationKey) *operationVariables {
+		doc, err := parser.Parse(query)
+		if err != nil {
+			return nil
+		}
+		var op *parser.Operation
+		for _, o := range doc.Operations {
+			if o.Name == operationName || operationName == "" && len(doc.Operations) == 1 {
+				op = o
+				break
+			}
+		}
+		if op == nil {
+			return nil
+		}
+
+		used := make(map[string]bool)
+		u := &variableUses{doc: doc, used: used, spread: make(map[string]bool)}
+		u.directives(op.Directives)
+		u.selectionSet(op.SelectionSet)
+
+		vars := &operationVariables{used: used}
+		for _, d := range op.Variables {
+			vars.defined = append(vars.defined, variableDefinition{
+				name:     d.Name,
+				typ:      d.Type.String(),
+				nonNull:  d.Type.NonNull,
+				required: d.Type.NonNull && d.DefaultValue == nil,
+			})
+		}
+		for _, name := range sortedKeys(used) {
+			if !vars.isDefined(name) {
+				vars.undefined = append(vars.undefined, fmt.Sprintf("variable $%s is used, but not defined", name))
+			}
+		}
+		return vars
+	})
+}
+
+// sortedKeys returns the keys of m, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// variableUses collects the variables used by an operation,
+// including those used by the fragments it spreads.
+type variableUses struct {
+	doc    *parser.Document
+	used   map[string]bool
+	spread map[string]bool // Fragments visited.
+}
+
+func (u *variableUses) selectionSet(set []parser.Selection) {
+	for _, sel := range set {
+		switch sel := sel.(type) {
+		case *parser.Field:
+			u.arguments(sel.Arguments)
+			u.directives(sel.Directives)
+			u.selectionSet(sel.SelectionSet)
+		case *parser.InlineFragment:
+			u.directives(sel.Directives)
+			u.selectionSet(sel.SelectionSet)
+		case *parser.FragmentSpread:
+			u.directives(sel.Directives)
+			if u.spread[sel.Name] {
+				continue
+			}
+			u.spread[sel.Name] = true
+			for _, f := range u.doc.Fragments {
+				if f.Name == sel.Name {
+					u.directives(f.Directives)
+					u.selectionSet(f.SelectionSet)
+				}
+			}
+		}
+	}
+}
+
+func (u *variableUses) directives(directives []*parser.Directive) {
+	for _, d := range directives {
+		u.arguments(d.Arguments)
+	}
+}
+
+func (u *variableUses) arguments(args []*parser.Argument) {
+	for _, a := range args {
+		u.value(a.Value)
+	}
+}
+
+func (u *variableUses) value(v *parser.Value) {
+	switch v.Kind {
+	case parser.VariableValue:
+		u.used[v.Raw] = true
+	case parser.ListValue:
+		for _, item := range v.List {
+			u.value(item)
+		}
+	case parser.ObjectValue:
+		u.arguments(v.Fields)
+	}
+}